@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+)
+
+type ActionConfig struct {
+	Name    string   `yaml:"name"`
+	At      Duration `yaml:"at"`
+	Compose []string `yaml:"compose"`
+	Command []string `yaml:"command"`
+}
+
+type Action struct {
+	name    string
+	at      time.Duration
+	workDir string
+	command []string
+	env     []string
+}
+
+func validateActions(config Config) error {
+	for _, action := range config.Actions {
+		if (len(action.Compose) > 0) == (len(action.Command) > 0) {
+			return fmt.Errorf("action %s: exactly one of compose or command must be set", action.Name)
+		}
+		if action.At < 0 {
+			return fmt.Errorf("action %s: negative offset", action.Name)
+		}
+	}
+	return nil
+}
+
+func newActions(config Config) []Action {
+	compose := DockerCompose{workDir: config.WorkDir, composeCommand: config.ComposeCommand, envFiles: config.Env.EnvFile}
+	actions := make([]Action, 0)
+	for _, action := range config.Actions {
+		command := action.Command
+		var env []string
+		if len(action.Compose) > 0 {
+			command = compose.compose(action.Compose...)
+			env = standEnvironment(config.Env.Environment)
+		}
+		actions = append(actions, Action{
+			name:    action.Name,
+			at:      time.Duration(action.At),
+			workDir: config.WorkDir,
+			command: command,
+			env:     env,
+		})
+	}
+	slices.SortStableFunc(actions, func(a, b Action) int {
+		return int(a.at - b.at)
+	})
+	return actions
+}
+
+func (action Action) run(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, action.command[0], action.command[1:]...)
+	cmd.Dir = action.workDir
+	if len(action.env) > 0 {
+		cmd.Env = append(os.Environ(), action.env...)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return fmt.Errorf("action %s: cancelled", action.name)
+		}
+		return fmt.Errorf("action %s: %w: %s", action.name, err, out)
+	}
+	return nil
+}
+
+func (scheduler *Scheduler) startActions(started time.Time) func() {
+	if len(scheduler.actions) == 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(scheduler.context())
+	leave := clockOf(scheduler.clock).join()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer leave()
+		scheduler.runActions(ctx, started)
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// runActions runs the actions at their offsets from the start of gathering,
+// a resumed run skips the actions that were due before the crash.
+func (scheduler *Scheduler) runActions(ctx context.Context, started time.Time) {
+	clock := clockOf(scheduler.clock)
+	resumedAt := clock.now().Sub(started)
+	for n, action := range scheduler.actions {
+		if scheduler.runState.isResumed() && action.at < resumedAt {
+			log.Println("action", action.name, "was due before the resume, skipped")
+			continue
+		}
+		if !scheduler.sleep(ctx, action.at-clock.now().Sub(started)) {
+			return
+		}
+		scheduler.exclusions.actionStarted(n, clock.now())
+		log.Println("=[ action " + action.name + " ]")
+		if err := action.run(ctx); err != nil {
+			log.Println(err)
+		}
+	}
+}
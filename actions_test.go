@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateActions(t *testing.T) {
+	variants := []struct {
+		action ActionConfig
+		ok     bool
+	}{
+		{action: ActionConfig{Name: "restart", Compose: []string{"restart", "api"}}, ok: true},
+		{action: ActionConfig{Name: "script", Command: []string{"sh", "-c", "true"}}, ok: true},
+		{action: ActionConfig{Name: "none"}},
+		{action: ActionConfig{Name: "both", Compose: []string{"kill"}, Command: []string{"true"}}},
+		{action: ActionConfig{Name: "past", At: Duration(-time.Second), Command: []string{"true"}}},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		err := validateActions(Config{Actions: []ActionConfig{variant.action}})
+		requires.Equal(variant.ok, err == nil, variant.action.Name)
+	}
+}
+
+func TestNewActions(t *testing.T) {
+	requires := require.New(t)
+	actions := newActions(Config{
+		WorkDir:        "/stand",
+		ComposeCommand: []string{"docker", "compose"},
+		Actions: []ActionConfig{
+			{Name: "kill", At: Duration(2 * time.Minute), Compose: []string{"kill", "db"}},
+			{Name: "restart", At: Duration(time.Minute), Compose: []string{"restart", "api"}},
+			{Name: "script", At: Duration(time.Minute), Command: []string{"./slow.sh"}},
+		},
+	})
+	requires.Equal([]Action{
+		{name: "restart", at: time.Minute, workDir: "/stand", command: []string{"docker", "compose", "restart", "api"}},
+		{name: "script", at: time.Minute, workDir: "/stand", command: []string{"./slow.sh"}},
+		{name: "kill", at: 2 * time.Minute, workDir: "/stand", command: []string{"docker", "compose", "kill", "db"}},
+	}, actions)
+}
+
+func TestSchedulerRunActions(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	eventer := FakeEventer{}
+	scheduler := Scheduler{
+		eventer:      &eventer,
+		testDuration: time.Second,
+		timeout:      100 * time.Millisecond,
+		actions: []Action{
+			{name: "early", at: 100 * time.Millisecond, workDir: dir, command: []string{"touch", "early"}},
+			{name: "late", at: time.Minute, workDir: dir, command: []string{"touch", "late"}},
+		},
+	}
+	started := time.Now()
+	scheduler.run()
+	requires.Less(time.Since(started), 5*time.Second)
+	requires.FileExists(filepath.Join(dir, "early"))
+	_, err := os.Stat(filepath.Join(dir, "late"))
+	requires.True(os.IsNotExist(err))
+}
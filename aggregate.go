@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Assertion struct {
+	aggregate string
+	operator  string
+	limit     float64
+}
+
+type RunAssertion struct {
+	scenario  string
+	metric    string
+	assertion Assertion
+	budget    bool
+	ranged    bool
+}
+
+type AssertionResult struct {
+	RunAssertion
+	value  float64
+	ok     bool
+	noData bool
+}
+
+func parseAssertion(text string) (Assertion, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		return Assertion{}, fmt.Errorf("assertion %q: expected \"<aggregate> <operator> <value>\"", text)
+	}
+	assertion := Assertion{aggregate: fields[0], operator: fields[1]}
+	if _, err := aggregateValues(assertion.aggregate, []float64{0}); err != nil {
+		return Assertion{}, fmt.Errorf("assertion %q: %w", text, err)
+	}
+	if !validOperator(assertion.operator) {
+		return Assertion{}, fmt.Errorf("assertion %q: unknown operator %s", text, assertion.operator)
+	}
+	limit, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("assertion %q: %w", text, err)
+	}
+	assertion.limit = limit
+	return assertion, nil
+}
+
+func validOperator(operator string) bool {
+	switch operator {
+	case "<", "<=", ">", ">=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func (assertion Assertion) String() string {
+	return assertion.aggregate + " " + assertion.operator + " " + strconv.FormatFloat(assertion.limit, 'f', -1, 64)
+}
+
+func (assertion Assertion) check(value float64) bool {
+	switch assertion.operator {
+	case "<":
+		return value < assertion.limit
+	case "<=":
+		return value <= assertion.limit
+	case ">":
+		return value > assertion.limit
+	case ">=":
+		return value >= assertion.limit
+	case "==":
+		return value == assertion.limit
+	case "!=":
+		return value != assertion.limit
+	}
+	return false
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func stddev(values []float64) float64 {
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	avg := sum / float64(len(values))
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - avg) * (value - avg)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+func aggregateValues(aggregate string, values []float64) (float64, error) {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	switch aggregate {
+	case "count":
+		return float64(len(sorted)), nil
+	case "min":
+		return sorted[0], nil
+	case "max":
+		return sorted[len(sorted)-1], nil
+	case "avg":
+		sum := 0.0
+		for _, value := range sorted {
+			sum += value
+		}
+		return sum / float64(len(sorted)), nil
+	case "median":
+		return percentile(sorted, 50), nil
+	case "stddev":
+		return stddev(sorted), nil
+	}
+	if p, ok := strings.CutPrefix(aggregate, "p"); ok {
+		if n, err := strconv.ParseFloat(p, 64); err == nil && n > 0 && n <= 100 {
+			return percentile(sorted, n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown aggregate %s", aggregate)
+}
+
+func collectMetricAssertions(scenario string, metrics []Metric) ([]RunAssertion, error) {
+	assertions := make([]RunAssertion, 0)
+	for _, metric := range metrics {
+		for _, text := range metric.Assertions {
+			assertion, err := parseAssertion(text)
+			if err != nil {
+				return nil, fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+			assertions = append(assertions, RunAssertion{scenario: scenario, metric: metric.Name, assertion: assertion})
+		}
+		for _, text := range metric.RangeAssertions {
+			assertion, err := parseAssertion(text)
+			if err != nil {
+				return nil, fmt.Errorf("metric %s: rangeAssertions: %w", metric.Name, err)
+			}
+			assertions = append(assertions, RunAssertion{scenario: scenario, metric: metric.Name, assertion: assertion, ranged: true})
+		}
+		for _, assertion := range metric.Budget {
+			assertions = append(assertions, RunAssertion{scenario: scenario, metric: metric.Name, assertion: assertion, budget: true})
+		}
+	}
+	return assertions, nil
+}
+
+func collectAssertions(config Config) ([]RunAssertion, error) {
+	if len(config.Scenarios) == 0 {
+		return collectMetricAssertions("", config.Metrics)
+	}
+	assertions := make([]RunAssertion, 0)
+	for _, scenario := range config.Scenarios {
+		scenarioAssertions, err := collectMetricAssertions(scenario.Name, mergeMetrics(config.Metrics, scenario.Metrics))
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, scenarioAssertions...)
+	}
+	return assertions, nil
+}
+
+func metricSamples(values []MetricValues, scenario string, metric string) []float64 {
+	samples := make([]float64, 0)
+	for _, tick := range values {
+		if tick.scenario != scenario {
+			continue
+		}
+		for _, value := range tick.values {
+			if value.name == metric && hasValue(value.value) && !value.excluded {
+				samples = append(samples, float64(value.value))
+			}
+		}
+	}
+	return samples
+}
+
+func checkAssertions(values []MetricValues, assertions []RunAssertion) ([]AssertionResult, bool) {
+	return checkSampleAssertions(func(scenario string, metric string) []float64 {
+		return metricSamples(values, scenario, metric)
+	}, assertions)
+}
+
+func checkSampleAssertions(metricSamples func(scenario string, metric string) []float64, assertions []RunAssertion) ([]AssertionResult, bool) {
+	results := make([]AssertionResult, 0)
+	flag := true
+	for _, assertion := range assertions {
+		result := AssertionResult{RunAssertion: assertion}
+		samples := metricSamples(assertion.scenario, assertion.metric)
+		if len(samples) == 0 {
+			result.noData = true
+		} else {
+			result.value, _ = aggregateValues(assertion.assertion.aggregate, samples)
+			result.ok = assertion.assertion.check(result.value)
+		}
+		if !result.ok {
+			flag = false
+		}
+		results = append(results, result)
+	}
+	return results, flag
+}
+
+func reportAssertions(results []AssertionResult) {
+	if len(results) == 0 {
+		return
+	}
+	log.Println("=[ assertions ]==============")
+	for _, line := range assertionLines(results) {
+		log.Print(line)
+	}
+}
+
+func assertionLines(results []AssertionResult) []string {
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		name := result.metric
+		if result.scenario != "" {
+			name = result.scenario + "/" + name
+		}
+		if result.budget {
+			name += " budget"
+		}
+		if result.ranged {
+			name += " range"
+		}
+		switch {
+		case result.noData:
+			lines = append(lines, fmt.Sprintln(" FAIL", name, result.assertion, "no data"))
+		case result.ok:
+			lines = append(lines, fmt.Sprintln(" ok  ", name, result.assertion, "actual", result.value))
+		default:
+			lines = append(lines, fmt.Sprintln(" FAIL", name, result.assertion, "actual", result.value))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAssertion(t *testing.T) {
+	variants := []struct {
+		text      string
+		assertion Assertion
+		err       bool
+	}{
+		{text: "avg < 50", assertion: Assertion{aggregate: "avg", operator: "<", limit: 50}},
+		{text: "p95 <= 200.5", assertion: Assertion{aggregate: "p95", operator: "<=", limit: 200.5}},
+		{text: "max < 500", assertion: Assertion{aggregate: "max", operator: "<", limit: 500}},
+		{text: "avg <", err: true},
+		{text: "mode < 1", err: true},
+		{text: "p0 < 1", err: true},
+		{text: "avg ~ 1", err: true},
+		{text: "avg < x", err: true},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		assertion, err := parseAssertion(variant.text)
+		if variant.err {
+			requires.Error(err, n)
+			continue
+		}
+		requires.NoError(err, n)
+		requires.Equal(variant.assertion, assertion, n)
+	}
+}
+
+func TestAggregateValues(t *testing.T) {
+	variants := []struct {
+		aggregate string
+		result    float64
+	}{
+		{aggregate: "count", result: 10},
+		{aggregate: "min", result: 1},
+		{aggregate: "max", result: 10},
+		{aggregate: "avg", result: 5.5},
+		{aggregate: "median", result: 5},
+		{aggregate: "p90", result: 9},
+		{aggregate: "p95", result: 10},
+		{aggregate: "p100", result: 10},
+	}
+	requires := require.New(t)
+	values := []float64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	for _, variant := range variants {
+		result, err := aggregateValues(variant.aggregate, values)
+		requires.NoError(err, variant.aggregate)
+		requires.Equal(variant.result, result, variant.aggregate)
+	}
+}
+
+func TestCheckAssertions(t *testing.T) {
+	requires := require.New(t)
+	values := []MetricValues{
+		{values: []MetricValue{{name: "latency", value: 100}}},
+		{values: []MetricValue{{name: "latency", value: 300}}},
+		{values: []MetricValue{{name: "latency", value: -1}}},
+		{scenario: "soak", values: []MetricValue{{name: "latency", value: 900}}},
+	}
+	avg, _ := parseAssertion("avg < 250")
+	max, _ := parseAssertion("max < 250")
+	results, ok := checkAssertions(values, []RunAssertion{
+		{metric: "latency", assertion: avg},
+		{metric: "latency", assertion: max},
+		{metric: "errors", assertion: max},
+	})
+	requires.False(ok)
+	requires.Len(results, 3)
+	requires.True(results[0].ok)
+	requires.Equal(200.0, results[0].value)
+	requires.False(results[1].ok)
+	requires.True(results[2].noData)
+
+	results, ok = checkAssertions(values, []RunAssertion{{metric: "latency", assertion: avg}})
+	requires.True(ok)
+	requires.Len(results, 1)
+}
+
+func TestCollectAssertions(t *testing.T) {
+	requires := require.New(t)
+	config := Config{Metrics: []Metric{{Name: "latency", Assertions: []string{"p95 < 200"}}}}
+	assertions, err := collectAssertions(config)
+	requires.NoError(err)
+	requires.Len(assertions, 1)
+	config.Scenarios = []Scenario{{Name: "a"}, {Name: "b"}}
+	assertions, err = collectAssertions(config)
+	requires.NoError(err)
+	requires.Len(assertions, 2)
+	requires.Equal("b", assertions[1].scenario)
+	_, err = collectAssertions(Config{Metrics: []Metric{{Name: "latency", Assertions: []string{"p95"}}}})
+	requires.Error(err)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultAnomalySigma = 3
+
+type AnomalyConfig struct {
+	Baseline Duration `yaml:"baseline"`
+	Sigma    float64  `yaml:"sigma"`
+}
+
+type Baseline struct {
+	Metric  string  `json:"metric"`
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean"`
+	Stddev  float64 `json:"stddev"`
+}
+
+type baselineStats struct {
+	samples int
+	mean    float64
+	m2      float64
+	learned bool
+}
+
+func (stats *baselineStats) add(value float64) {
+	stats.samples++
+	delta := value - stats.mean
+	stats.mean += delta / float64(stats.samples)
+	stats.m2 += delta * (value - stats.mean)
+}
+
+func (stats *baselineStats) stddev() float64 {
+	if stats.samples < 2 {
+		return 0
+	}
+	return math.Sqrt(stats.m2 / float64(stats.samples-1))
+}
+
+type AnomalyDetector struct {
+	mutex   sync.Mutex
+	configs map[string]AnomalyConfig
+	started time.Time
+	stats   map[string]*baselineStats
+}
+
+func NewAnomalyDetector(config Config) *AnomalyDetector {
+	configs := map[string]AnomalyConfig{}
+	metrics := append([]Metric{}, config.Metrics...)
+	for _, scenario := range config.Scenarios {
+		metrics = append(metrics, scenario.Metrics...)
+	}
+	for _, metric := range metrics {
+		if metric.Anomaly == nil {
+			continue
+		}
+		anomaly := *metric.Anomaly
+		if anomaly.Sigma <= 0 {
+			anomaly.Sigma = defaultAnomalySigma
+		}
+		configs[metric.Name] = anomaly
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+	return &AnomalyDetector{configs: configs, stats: map[string]*baselineStats{}}
+}
+
+func (detector *AnomalyDetector) enabled(name string) bool {
+	if detector == nil {
+		return false
+	}
+	_, ok := detector.configs[name]
+	return ok
+}
+
+func (detector *AnomalyDetector) observe(name string, at time.Time, value int) bool {
+	if !detector.enabled(name) || !hasValue(value) {
+		return false
+	}
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	if detector.started.IsZero() {
+		detector.started = at
+	}
+	config := detector.configs[name]
+	stats := detector.stats[name]
+	if stats == nil {
+		stats = &baselineStats{}
+		detector.stats[name] = stats
+	}
+	if at.Sub(detector.started) < time.Duration(config.Baseline) {
+		stats.add(float64(value))
+		return false
+	}
+	if !stats.learned {
+		stats.learned = true
+		if stats.samples < 2 {
+			log.Printf("WARNING: metric(%s): %d samples in the anomaly baseline, anomaly check is disabled\n", name, stats.samples)
+		} else {
+			log.Printf(" metric(%s): anomaly baseline %.2f±%.2f from %d samples\n", name, stats.mean, stats.stddev(), stats.samples)
+		}
+	}
+	if stats.samples < 2 {
+		return false
+	}
+	deviation := math.Abs(float64(value) - stats.mean)
+	if deviation <= config.Sigma*stats.stddev() {
+		return false
+	}
+	if stddev := stats.stddev(); stddev > 0 {
+		log.Printf(" metric(%s): %d deviates %.1f sigma from baseline %.2f±%.2f\n", name, value, deviation/stddev, stats.mean, stddev)
+	} else {
+		log.Printf(" metric(%s): %d deviates from constant baseline %.2f\n", name, value, stats.mean)
+	}
+	return true
+}
+
+func (detector *AnomalyDetector) baselines() []Baseline {
+	if detector == nil {
+		return nil
+	}
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	baselines := make([]Baseline, 0, len(detector.stats))
+	for name, stats := range detector.stats {
+		baselines = append(baselines, Baseline{Metric: name, Samples: stats.samples, Mean: stats.mean, Stddev: stats.stddev()})
+	}
+	slices.SortFunc(baselines, func(a, b Baseline) int {
+		return strings.Compare(a.Metric, b.Metric)
+	})
+	return baselines
+}
+
+func validateAnomaly(metric Metric) error {
+	if metric.Anomaly == nil {
+		return nil
+	}
+	if metric.Anomaly.Baseline <= 0 {
+		return fmt.Errorf("anomaly.baseline must be positive")
+	}
+	if metric.Anomaly.Sigma < 0 {
+		return fmt.Errorf("anomaly.sigma must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	requires := require.New(t)
+	requires.Nil(NewAnomalyDetector(Config{Metrics: []Metric{{Name: "a"}}}))
+
+	detector := NewAnomalyDetector(Config{
+		Metrics:   []Metric{{Name: "latency", Anomaly: &AnomalyConfig{Baseline: Duration(time.Minute)}}, {Name: "errors"}},
+		Scenarios: []Scenario{{Metrics: []Metric{{Name: "rps", Anomaly: &AnomalyConfig{Baseline: Duration(30 * time.Second), Sigma: 1}}}}},
+	})
+	requires.True(detector.enabled("latency"))
+	requires.True(detector.enabled("rps"))
+	requires.False(detector.enabled("errors"))
+	requires.Equal(float64(defaultAnomalySigma), detector.configs["latency"].Sigma)
+
+	started := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return started.Add(time.Duration(seconds) * time.Second) }
+	for n, value := range []int{100, 110, 90, 100, -1} {
+		requires.False(detector.observe("latency", at(n*10), value), n)
+	}
+	requires.False(detector.observe("errors", at(70), 1000))
+	requires.False(detector.observe("latency", at(70), 115))
+	requires.False(detector.observe("latency", at(80), missingValue))
+	requires.True(detector.observe("latency", at(90), 130))
+	requires.True(detector.observe("latency", at(100), 70))
+
+	baselines := detector.baselines()
+	requires.Len(baselines, 1)
+	requires.Equal("latency", baselines[0].Metric)
+	requires.Equal(4, baselines[0].Samples)
+	requires.Equal(100.0, baselines[0].Mean)
+	requires.InDelta(math.Sqrt(200.0/3), baselines[0].Stddev, 1e-9)
+
+	constant := NewAnomalyDetector(Config{Metrics: []Metric{{Name: "replicas", Anomaly: &AnomalyConfig{Baseline: Duration(time.Minute)}}}})
+	requires.False(constant.observe("replicas", at(0), 3))
+	requires.False(constant.observe("replicas", at(10), 3))
+	requires.False(constant.observe("replicas", at(60), 3))
+	requires.True(constant.observe("replicas", at(70), 2))
+
+	short := NewAnomalyDetector(Config{Metrics: []Metric{{Name: "a", Anomaly: &AnomalyConfig{Baseline: Duration(time.Second)}}}})
+	requires.False(short.observe("a", at(0), 1))
+	requires.False(short.observe("a", at(10), 1000))
+	requires.Nil((*AnomalyDetector)(nil).baselines())
+}
+
+func TestGathererAnomaly(t *testing.T) {
+	requires := require.New(t)
+	metrics := []Metric{{Name: "a", Anomaly: &AnomalyConfig{Baseline: Duration(time.Minute), Sigma: 2}}}
+	gatherer := Gatherer{
+		anomalies: NewAnomalyDetector(Config{Metrics: metrics}),
+	}
+	started := time.Now()
+	for n, value := range []int{1, 2, 1, 2} {
+		gatherer.metrics = []MetricGather{ValueMetricGather{metricName: "a", value: value}}
+		values, ok := gatherer.gatherAndCheck(context.Background(), started.Add(time.Duration(n)*10*time.Second))
+		requires.True(ok, n)
+		requires.Empty(values.violations, n)
+	}
+	gatherer.metrics = []MetricGather{ValueMetricGather{metricName: "a", value: 2}}
+	_, ok := gatherer.gatherAndCheck(context.Background(), started.Add(2*time.Minute))
+	requires.True(ok)
+	gatherer.metrics = []MetricGather{ValueMetricGather{metricName: "a", value: 9}}
+	values, ok := gatherer.gatherAndCheck(context.Background(), started.Add(3*time.Minute))
+	requires.False(ok)
+	requires.Equal([]MetricValue{{name: "a", value: 9}}, values.violations)
+
+	gatherer.metrics = []MetricGather{ValueMetricGather{metricName: "a", value: 2, max: 1}}
+	_, ok = gatherer.gatherAndCheck(context.Background(), started.Add(4*time.Minute))
+	requires.False(ok)
+}
+
+func TestValidateAnomaly(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Anomaly: &AnomalyConfig{Baseline: Duration(time.Minute)}}}}))
+	requires.ErrorContains(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Anomaly: &AnomalyConfig{}}}}), "metric a: anomaly.baseline must be positive")
+	requires.ErrorContains(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Anomaly: &AnomalyConfig{Baseline: Duration(time.Minute), Sigma: -1}}}}), "anomaly.sigma must not be negative")
+
+	report := RunReport{Baselines: []Baseline{{Metric: "latency", Samples: 4, Mean: 100, Stddev: 8.2}}}
+	requires.Contains(string(renderMarkdown(report)), "## Anomaly baselines\n\n| metric | samples | mean | stddev |\n|---|---|---|---|\n| latency | 4 | 100.00 | 8.20 |")
+}
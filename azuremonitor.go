@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	defaultAzureEndpoint = "https://management.azure.com"
+	defaultAzureLogin    = "https://login.microsoftonline.com"
+	azureMetricsVersion  = "2023-10-01"
+	defaultCloudWindow   = 5 * time.Minute
+)
+
+var azureAggregations = []string{"average", "total", "minimum", "maximum", "count"}
+
+// AzureMonitorConfig authenticates with a service principal (client
+// credentials) or a bearer token, e.g. of az account get-access-token.
+type AzureMonitorConfig struct {
+	TenantID     string `yaml:"tenantId"`
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret"`
+	Token        string `yaml:"token"`
+	Endpoint     string `yaml:"endpoint"`
+	LoginURL     string `yaml:"loginUrl"`
+}
+
+type AzureMonitorClient struct {
+	client   *http.Client
+	endpoint string
+}
+
+func NewAzureMonitorClient(config AzureMonitorConfig) *AzureMonitorClient {
+	endpoint := strings.TrimSuffix(config.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = defaultAzureEndpoint
+	}
+	var source oauth2.TokenSource
+	if config.Token != "" {
+		source = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Token})
+	} else {
+		login := strings.TrimSuffix(config.LoginURL, "/")
+		if login == "" {
+			login = defaultAzureLogin
+		}
+		credentials := clientcredentials.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			TokenURL:     login + "/" + config.TenantID + "/oauth2/v2.0/token",
+			Scopes:       []string{endpoint + "/.default"},
+		}
+		source = credentials.TokenSource(context.Background())
+	}
+	client := oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, source))
+	client.Timeout = 10 * time.Second
+	return &AzureMonitorClient{client: client, endpoint: endpoint}
+}
+
+type AzureMetricsResponse struct {
+	Value []struct {
+		Timeseries []struct {
+			Data []map[string]any `json:"data"`
+		} `json:"timeseries"`
+	} `json:"value"`
+}
+
+type AzureError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// values are the aggregations over the whole window (interval FULL) of every
+// series, one per dimension value of the filter.
+func (client *AzureMonitorClient) values(ctx context.Context, metric AzureMonitorMetric, now time.Time) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	params := url.Values{}
+	params.Set("api-version", azureMetricsVersion)
+	params.Set("metricnames", metric.MetricName)
+	params.Set("aggregation", metric.Aggregation)
+	params.Set("timespan", now.Add(-metric.Window).UTC().Format(time.RFC3339)+"/"+now.UTC().Format(time.RFC3339))
+	params.Set("interval", "FULL")
+	if metric.Namespace != "" {
+		params.Set("metricnamespace", metric.Namespace)
+	}
+	if metric.Filter != "" {
+		params.Set("$filter", metric.Filter)
+	}
+	resource := "/" + strings.TrimPrefix(metric.Resource, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		client.endpoint+resource+"/providers/Microsoft.Insights/metrics?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		azureError := AzureError{}
+		if json.NewDecoder(resp.Body).Decode(&azureError) == nil && azureError.Error.Message != "" {
+			return nil, fmt.Errorf("status %s: %s", resp.Status, azureError.Error.Message)
+		}
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	response := AzureMetricsResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	values := make([]float64, 0)
+	for _, value := range response.Value {
+		for _, series := range value.Timeseries {
+			for n := len(series.Data) - 1; n >= 0; n-- {
+				if number, ok := series.Data[n][metric.Aggregation].(float64); ok {
+					values = append(values, number)
+					break
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// combineSeries reduces the latest values of several series to one.
+func combineSeries(values []float64, how string) float64 {
+	switch how {
+	case "max":
+		return slices.Max(values)
+	case "min":
+		return slices.Min(values)
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	if how == "mean" {
+		return sum / float64(len(values))
+	}
+	return sum
+}
+
+func scaled(value float64, scale float64) int {
+	if scale == 0 {
+		scale = 1
+	}
+	return int(math.Round(value * scale))
+}
+
+type AzureMonitorMetric struct {
+	client      *AzureMonitorClient
+	Name        string
+	Resource    string
+	MetricName  string
+	Namespace   string
+	Filter      string
+	Aggregation string
+	Window      time.Duration
+	Scale       float64
+	MaxValue    int
+	OnMissing   string
+}
+
+func newAzureMonitorMetric(client *AzureMonitorClient, metric Metric) AzureMonitorMetric {
+	aggregation := strings.ToLower(metric.Aggregate)
+	if aggregation == "" {
+		aggregation = "average"
+	}
+	window := time.Duration(metric.Window)
+	if window == 0 {
+		window = defaultCloudWindow
+	}
+	return AzureMonitorMetric{
+		client: client,
+		Name:   metric.Name, Resource: metric.Target, MetricName: metric.Query, Namespace: metric.Namespace,
+		Filter: metric.Selector, Aggregation: aggregation, Window: window, Scale: metric.Scale,
+		MaxValue: metric.MaxValue, OnMissing: metric.OnMissing}
+}
+
+func (metric AzureMonitorMetric) name() string {
+	return metric.Name
+}
+
+func (metric AzureMonitorMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric AzureMonitorMetric) gather(ctx context.Context) int {
+	values, err := metric.client.values(ctx, metric, clockFrom(ctx).now())
+	if err != nil {
+		log.Printf("Error querying Azure Monitor: %v\n", err)
+		return -1
+	}
+	if len(values) == 0 {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	how := map[string]string{"average": "mean", "minimum": "min", "maximum": "max"}[metric.Aggregation]
+	return scaled(combineSeries(values, how), metric.Scale)
+}
+
+func validateAzureMonitorMetric(config Config, metric Metric) error {
+	azure := config.AzureMonitor
+	if azure.Token == "" && (azure.TenantID == "" || azure.ClientID == "" || azure.ClientSecret == "") {
+		return errors.New("azureMonitor.token or tenantId, clientId and clientSecret are not set")
+	}
+	if metric.Target == "" {
+		return errors.New("target is not set (the resource id)")
+	}
+	if metric.Query == "" {
+		return errors.New("query is not set (the metric name)")
+	}
+	if metric.Aggregate != "" && !slices.Contains(azureAggregations, strings.ToLower(metric.Aggregate)) {
+		return fmt.Errorf("unknown aggregate %s, expected one of %s", metric.Aggregate, strings.Join(azureAggregations, ", "))
+	}
+	if metric.Window < 0 {
+		return errors.New("window must not be negative")
+	}
+	if metric.Scale < 0 {
+		return errors.New("scale must not be negative")
+	}
+	return nil
+}
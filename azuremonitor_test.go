@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func azureServer(t *testing.T, tokens *int, timespan *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/tenant/oauth2/v2.0/token" {
+			*tokens++
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			_, _ = w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "/subscriptions/s/resourceGroups/perf/providers/Microsoft.DBforPostgreSQL/flexibleServers/db/providers/Microsoft.Insights/metrics", r.URL.Path)
+		query := r.URL.Query()
+		require.Equal(t, "FULL", query.Get("interval"))
+		*timespan = query.Get("timespan")
+		switch query.Get("metricnames") {
+		case "cpu_percent":
+			require.Equal(t, "maximum", query.Get("aggregation"))
+			_, _ = w.Write([]byte(`{"value":[{"name":{"value":"cpu_percent"},"timeseries":[` +
+				`{"data":[{"timeStamp":"2023-11-14T22:08:00Z","maximum":71.6}]},` +
+				`{"data":[{"timeStamp":"2023-11-14T22:08:00Z","maximum":42}]}]}]}`))
+		case "connections":
+			require.Equal(t, "DatabaseName eq 'orders'", query.Get("$filter"))
+			_, _ = w.Write([]byte(`{"value":[{"timeseries":[{"data":[{"timeStamp":"2023-11-14T22:08:00Z","total":7},{"timeStamp":"2023-11-14T22:13:00Z"}]},` +
+				`{"data":[{"timeStamp":"2023-11-14T22:08:00Z","total":5}]}]}]}`))
+		case "idle":
+			_, _ = w.Write([]byte(`{"value":[{"timeseries":[{"data":[{"timeStamp":"2023-11-14T22:08:00Z"}]}]}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":"BadRequest","message":"Failed to find metric configuration"}}`))
+		}
+	}))
+}
+
+func TestAzureMonitorMetric(t *testing.T) {
+	requires := require.New(t)
+	tokens, timespan := 0, ""
+	server := azureServer(t, &tokens, &timespan)
+	defer server.Close()
+
+	client := NewAzureMonitorClient(AzureMonitorConfig{TenantID: "tenant", ClientID: "id", ClientSecret: "secret",
+		Endpoint: server.URL, LoginURL: server.URL})
+	resource := "/subscriptions/s/resourceGroups/perf/providers/Microsoft.DBforPostgreSQL/flexibleServers/db"
+	now := time.Unix(1700000000, 0)
+	cpu := newAzureMonitorMetric(client, Metric{Name: "cpu", Target: resource, Query: "cpu_percent", Aggregate: "Maximum"})
+	values, err := client.values(context.Background(), cpu, now)
+	requires.NoError(err)
+	requires.Equal([]float64{71.6, 42}, values)
+	requires.Equal("2023-11-14T22:08:20Z/2023-11-14T22:13:20Z", timespan)
+	requires.Equal(72, cpu.gather(context.Background()))
+
+	connections := newAzureMonitorMetric(client, Metric{Name: "connections", Target: resource, Query: "connections",
+		Aggregate: "total", Selector: "DatabaseName eq 'orders'"})
+	values, err = client.values(context.Background(), connections, now)
+	requires.NoError(err)
+	requires.Equal([]float64{7, 5}, values)
+	requires.Equal(12, connections.gather(context.Background()))
+	requires.Equal(1, tokens)
+
+	requires.Equal(-1, newAzureMonitorMetric(client, Metric{Name: "idle", Target: resource, Query: "idle"}).gather(context.Background()))
+	requires.Equal(0, newAzureMonitorMetric(client, Metric{Name: "idle", Target: resource, Query: "idle", OnMissing: "treatAsZero"}).gather(context.Background()))
+	_, err = client.values(context.Background(), newAzureMonitorMetric(client, Metric{Target: resource, Query: "nope"}), now)
+	requires.ErrorContains(err, "Failed to find metric configuration")
+
+	static := NewAzureMonitorClient(AzureMonitorConfig{Token: "token", Endpoint: server.URL})
+	requires.Equal(72, newAzureMonitorMetric(static, Metric{Name: "cpu", Target: resource, Query: "cpu_percent", Aggregate: "maximum"}).gather(context.Background()))
+	requires.Equal(1, tokens)
+}
+
+func TestAzureMonitorHelpers(t *testing.T) {
+	requires := require.New(t)
+	requires.InDelta(3.0, combineSeries([]float64{1, 5}, "mean"), 0.001)
+	requires.InDelta(5.0, combineSeries([]float64{1, 5}, "max"), 0.001)
+	requires.InDelta(1.0, combineSeries([]float64{1, 5}, "min"), 0.001)
+	requires.InDelta(6.0, combineSeries([]float64{1, 5}, "sum"), 0.001)
+
+	config := Config{AzureMonitor: AzureMonitorConfig{Token: "t"}}
+	requires.NoError(validateAzureMonitorMetric(config, Metric{Target: "/r", Query: "cpu_percent", Aggregate: "Average"}))
+	requires.ErrorContains(validateAzureMonitorMetric(Config{AzureMonitor: AzureMonitorConfig{TenantID: "t"}}, Metric{Target: "/r", Query: "q"}),
+		"azureMonitor.token or tenantId, clientId and clientSecret are not set")
+	requires.ErrorContains(validateAzureMonitorMetric(config, Metric{Query: "q"}), "target is not set")
+	requires.ErrorContains(validateAzureMonitorMetric(config, Metric{Target: "/r"}), "query is not set")
+	requires.ErrorContains(validateAzureMonitorMetric(config, Metric{Target: "/r", Query: "q", Aggregate: "p99"}), "unknown aggregate p99")
+	requires.ErrorContains(validateAzureMonitorMetric(config, Metric{Target: "/r", Query: "q", Window: -1}), "window must not be negative")
+}
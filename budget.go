@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+type ViolationBudget struct {
+	mutex sync.Mutex
+	used  map[string]int
+}
+
+func NewViolationBudget() *ViolationBudget {
+	return &ViolationBudget{used: map[string]int{}}
+}
+
+func (budget *ViolationBudget) spend(name string, allowed int) bool {
+	if budget == nil || allowed <= 0 {
+		return false
+	}
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	budget.used[name]++
+	used := budget.used[name]
+	if used <= allowed {
+		log.Printf(" metric(%s): violation %d of %d allowed", name, used, allowed)
+		return true
+	}
+	if used == allowed+1 {
+		log.Printf(" metric(%s): violation budget of %d exhausted", name, allowed)
+	}
+	return false
+}
+
+func allowedViolations(metrics []Metric) map[string]int {
+	allowed := map[string]int{}
+	for _, metric := range metrics {
+		if metric.AllowedViolations > 0 {
+			allowed[metric.Name] = metric.AllowedViolations
+		}
+	}
+	return allowed
+}
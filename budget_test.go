@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestViolationBudget(t *testing.T) {
+	requires := require.New(t)
+	budget := NewViolationBudget()
+	requires.True(budget.spend("a", 2))
+	requires.True(budget.spend("a", 2))
+	requires.False(budget.spend("a", 2))
+	requires.False(budget.spend("a", 2))
+	requires.False(budget.spend("b", 0))
+	var none *ViolationBudget
+	requires.False(none.spend("a", 2))
+	requires.Equal(map[string]int{"b": 3}, allowedViolations([]Metric{{Name: "a"}, {Name: "b", AllowedViolations: 3}}))
+}
+
+func TestGathererAllowedViolations(t *testing.T) {
+	requires := require.New(t)
+	budget := NewViolationBudget()
+	gatherer := Gatherer{metrics: []MetricGather{FakeMetricGather{}}, allowed: map[string]int{"a": 2}, budget: budget}
+	scenario := Gatherer{metrics: []MetricGather{FakeMetricGather{}}, allowed: map[string]int{"a": 2}, budget: budget}
+	values, ok := gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.True(ok)
+	requires.Equal([]MetricValue{{name: "a", value: 2, tolerated: true}}, values.violations)
+	requires.False(values.failing())
+	_, ok = scenario.gatherAndCheck(context.Background(), time.Now())
+	requires.True(ok)
+	values, ok = scenario.gatherAndCheck(context.Background(), time.Now())
+	requires.False(ok)
+	requires.Equal([]MetricValue{{name: "a", value: 2}}, values.violations)
+	requires.True(values.failing())
+}
+
+func TestEventerFireAllowedViolations(t *testing.T) {
+	requires := require.New(t)
+	stopped, violated := 0, 0
+	eventer := Eventer{
+		gatherer: Gatherer{metrics: []MetricGather{FakeMetricGather{}}, allowed: map[string]int{"a": 1}, budget: NewViolationBudget()},
+		reporter: &Reporter{},
+		stoper:   func() { stopped++ },
+		violated: func() { violated++ },
+	}
+	eventer.Fire(context.Background())
+	requires.Equal(0, stopped)
+	requires.Equal(0, violated)
+	eventer.Fire(context.Background())
+	requires.Equal(1, stopped)
+	requires.Equal(1, violated)
+}
+
+func TestAppTuneAllowedViolations(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		Metrics:   []Metric{{Name: "a", AllowedViolations: 1}},
+		Scenarios: []Scenario{{Name: "s", Metrics: []Metric{{Name: "b", AllowedViolations: 2}}}},
+	}
+	scheduler := App{}.tune(&Reporter{}, config, Sources{})
+	gatherer := scheduler.eventer.(*Eventer).gatherer.(Gatherer)
+	scenario := scheduler.scenarios[0].eventer.(*Eventer).gatherer.(Gatherer)
+	requires.Equal(map[string]int{"a": 1}, gatherer.allowed)
+	requires.Equal(map[string]int{"a": 1, "b": 2}, scenario.allowed)
+	requires.Same(gatherer.budget, scenario.budget)
+	requires.EqualError(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", AllowedViolations: -1}}}),
+		"metric a: allowedViolations must not be negative")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ClickHouseConfig struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+}
+
+type ClickHouseClient struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+	database string
+}
+
+func NewClickHouseClient(config ClickHouseConfig) *ClickHouseClient {
+	return &ClickHouseClient{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		url:      strings.TrimSuffix(config.URL, "/"),
+		username: config.Username,
+		password: config.Password,
+		database: config.Database,
+	}
+}
+
+var errNoClickHouseValue = errors.New("no value")
+
+func (client *ClickHouseClient) query(ctx context.Context, query string) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	params := url.Values{"default_format": {"TabSeparated"}, "max_execution_time": {"10"}}
+	if client.database != "" {
+		params.Set("database", client.database)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.url+"/?"+params.Encode(), strings.NewReader(query))
+	if err != nil {
+		return 0, err
+	}
+	if client.username != "" {
+		req.Header.Set("X-ClickHouse-User", client.username)
+		req.Header.Set("X-ClickHouse-Key", client.password)
+	}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return 0, fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	field, _, _ := strings.Cut(strings.TrimSuffix(line, "\n"), "\t")
+	if field == "" || field == `\N` || field == "nan" {
+		return 0, errNoClickHouseValue
+	}
+	value, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value %q is not a number", field)
+	}
+	return value, nil
+}
+
+type ClickHouseMetric struct {
+	client    *ClickHouseClient
+	Name      string
+	Query     string
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric ClickHouseMetric) name() string {
+	return metric.Name
+}
+
+func (metric ClickHouseMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric ClickHouseMetric) gather(ctx context.Context) int {
+	value, err := metric.client.query(ctx, metric.Query)
+	if errors.Is(err, errNoClickHouseValue) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("Error querying ClickHouse: %v\n", err)
+		return -1
+	}
+	return int(value)
+}
+
+func validateClickHouseMetric(config Config, metric Metric) error {
+	if config.ClickHouse.URL == "" {
+		return fmt.Errorf("clickhouse.url is not set")
+	}
+	if u, err := url.Parse(config.ClickHouse.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("clickhouse.url %s is not an http(s) url of the HTTP interface", config.ClickHouse.URL)
+	}
+	if metric.Query == "" {
+		return fmt.Errorf("query is not set")
+	}
+	return nil
+}
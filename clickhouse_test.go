@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickHouseMetric(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requires.Equal(http.MethodPost, r.Method)
+		requires.Equal("TabSeparated", r.URL.Query().Get("default_format"))
+		requires.Equal("perf", r.URL.Query().Get("database"))
+		requires.Equal("loader", r.Header.Get("X-ClickHouse-User"))
+		requires.Equal("secret", r.Header.Get("X-ClickHouse-Key"))
+		b, _ := io.ReadAll(r.Body)
+		switch string(b) {
+		case "SELECT quantile(0.95)(duration_ms) FROM requests":
+			_, _ = w.Write([]byte("412.7\t1\n"))
+		case "SELECT count() FROM errors":
+			_, _ = w.Write([]byte("12\n"))
+		case "SELECT max(lag) FROM empty":
+			_, _ = w.Write([]byte(`\N` + "\n"))
+		case "SELECT 1 WHERE 0":
+		case "SELECT 'x'":
+			_, _ = w.Write([]byte("x\n"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("Code: 62. DB::Exception: Syntax error\n"))
+		}
+	}))
+	defer server.Close()
+	client := NewClickHouseClient(ClickHouseConfig{URL: server.URL + "/", Username: "loader", Password: "secret", Database: "perf"})
+	variants := []struct {
+		query     string
+		onMissing string
+		value     int
+	}{
+		{query: "SELECT quantile(0.95)(duration_ms) FROM requests", value: 412},
+		{query: "SELECT count() FROM errors", value: 12},
+		{query: "SELECT max(lag) FROM empty", value: -1},
+		{query: "SELECT max(lag) FROM empty", onMissing: "treatAsZero", value: 0},
+		{query: "SELECT 1 WHERE 0", onMissing: "treatAsMax", value: 100},
+		{query: "SELECT 'x'", value: -1},
+		{query: "SELEC", value: -1},
+	}
+	for _, variant := range variants {
+		metric := ClickHouseMetric{client: client, Name: "latency", Query: variant.query, MaxValue: 100, OnMissing: variant.onMissing}
+		requires.Equal(variant.value, metric.gather(context.Background()), variant.query)
+	}
+	_, err := client.query(context.Background(), "SELEC")
+	requires.ErrorContains(err, "Code: 62. DB::Exception: Syntax error")
+
+	gathers := newMetricGathers(Sources{click: client}, []Metric{{Name: "errors", Type: "clickhouse", Query: "SELECT count() FROM errors"}})
+	requires.Equal(12, gathers[0].gather(context.Background()))
+	requires.Equal(redacted, redactConfig(Config{ClickHouse: ClickHouseConfig{Password: "secret"}}).ClickHouse.Password)
+}
+
+func TestValidateClickHouseMetric(t *testing.T) {
+	requires := require.New(t)
+	metric := Metric{Name: "errors", Type: "clickhouse", Query: "SELECT count() FROM errors"}
+	requires.NoError(validateMetricTypes(Config{ClickHouse: ClickHouseConfig{URL: "http://localhost:8123"}, Metrics: []Metric{metric}}))
+	requires.ErrorContains(validateMetricTypes(Config{Metrics: []Metric{metric}}), "metric errors: clickhouse.url is not set")
+	requires.ErrorContains(validateMetricTypes(Config{ClickHouse: ClickHouseConfig{URL: "tcp://localhost:9000"}, Metrics: []Metric{metric}}), "is not an http(s) url of the HTTP interface")
+	requires.ErrorContains(validateMetricTypes(Config{ClickHouse: ClickHouseConfig{URL: "http://localhost:8123"}, Metrics: []Metric{{Name: "errors", Type: "clickhouse"}}}), "metric errors: query is not set")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type ClockInt interface {
+	now() time.Time
+	sleep(ctx context.Context, duration time.Duration) bool
+	withTimeout(ctx context.Context, duration time.Duration) (context.Context, context.CancelFunc)
+	join() func()
+}
+
+func clockOf(clock ClockInt) ClockInt {
+	if clock == nil {
+		return RealClock{}
+	}
+	return clock
+}
+
+type clockKey struct{}
+
+// withClock lets the metrics of a tick query at the time of the scheduler
+// clock, the simulated one under --faketime.
+func withClock(ctx context.Context, clock ClockInt) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+func clockFrom(ctx context.Context) ClockInt {
+	clock, _ := ctx.Value(clockKey{}).(ClockInt)
+	return clockOf(clock)
+}
+
+type RealClock struct{}
+
+func (RealClock) now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(duration):
+		return ctx.Err() == nil
+	}
+}
+
+func (RealClock) withTimeout(ctx context.Context, duration time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, duration)
+}
+
+func (RealClock) join() func() {
+	return func() {}
+}
+
+type SimulatedWaiter struct {
+	ctx   context.Context
+	until time.Time
+	woken chan struct{}
+}
+
+type SimulatedDeadline struct {
+	at     time.Time
+	cancel context.CancelFunc
+}
+
+// SimulatedClock only moves when every joined goroutine sleeps: it then jumps
+// to the earliest wake-up, so hours of schedule pass instantly and in order.
+type SimulatedClock struct {
+	mutex        sync.Mutex
+	current      time.Time
+	participants int
+	waiters      []*SimulatedWaiter
+	deadlines    []*SimulatedDeadline
+}
+
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{current: start, participants: 1}
+}
+
+func (clock *SimulatedClock) now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.current
+}
+
+func (clock *SimulatedClock) sleep(ctx context.Context, duration time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	clock.mutex.Lock()
+	waiter := &SimulatedWaiter{ctx: ctx, until: clock.current.Add(max(duration, 0)), woken: make(chan struct{})}
+	clock.waiters = append(clock.waiters, waiter)
+	clock.advance()
+	clock.mutex.Unlock()
+	select {
+	case <-waiter.woken:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		clock.mutex.Lock()
+		clock.waiters = slices.DeleteFunc(clock.waiters, func(other *SimulatedWaiter) bool { return other == waiter })
+		clock.advance()
+		clock.mutex.Unlock()
+		return false
+	}
+}
+
+func (clock *SimulatedClock) withTimeout(ctx context.Context, duration time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	deadline := &SimulatedDeadline{at: clock.current.Add(duration), cancel: cancel}
+	clock.deadlines = append(clock.deadlines, deadline)
+	return ctx, func() {
+		cancel()
+		clock.mutex.Lock()
+		defer clock.mutex.Unlock()
+		clock.deadlines = slices.DeleteFunc(clock.deadlines, func(other *SimulatedDeadline) bool { return other == deadline })
+	}
+}
+
+func (clock *SimulatedClock) join() func() {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.participants++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			clock.mutex.Lock()
+			defer clock.mutex.Unlock()
+			clock.participants--
+			clock.advance()
+		})
+	}
+}
+
+func (clock *SimulatedClock) advance() {
+	for len(clock.waiters) > 0 && len(clock.waiters) >= clock.participants {
+		for _, waiter := range clock.waiters {
+			if waiter.ctx.Err() != nil {
+				return
+			}
+		}
+		earliest := slices.MinFunc(clock.waiters, func(a, b *SimulatedWaiter) int { return a.until.Compare(b.until) })
+		if deadline := clock.nextDeadline(); deadline != nil && !deadline.at.After(earliest.until) {
+			clock.current = later(clock.current, deadline.at)
+			clock.deadlines = slices.DeleteFunc(clock.deadlines, func(other *SimulatedDeadline) bool { return other == deadline })
+			deadline.cancel()
+			continue
+		}
+		clock.current = later(clock.current, earliest.until)
+		clock.waiters = slices.DeleteFunc(clock.waiters, func(other *SimulatedWaiter) bool { return other == earliest })
+		close(earliest.woken)
+		return
+	}
+}
+
+func (clock *SimulatedClock) nextDeadline() *SimulatedDeadline {
+	if len(clock.deadlines) == 0 {
+		return nil
+	}
+	return slices.MinFunc(clock.deadlines, func(a, b *SimulatedDeadline) int { return a.at.Compare(b.at) })
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
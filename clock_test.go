@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulatedClock(t *testing.T) {
+	requires := require.New(t)
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	requires.True(clock.sleep(context.Background(), time.Hour))
+	requires.Equal(start.Add(time.Hour), clock.now())
+	requires.True(clock.sleep(context.Background(), -time.Minute))
+	requires.Equal(start.Add(time.Hour), clock.now())
+
+	ctx, cancel := clock.withTimeout(context.Background(), 90*time.Second)
+	requires.True(clock.sleep(ctx, time.Minute))
+	requires.False(clock.sleep(ctx, time.Minute))
+	requires.Error(ctx.Err())
+	requires.Equal(start.Add(time.Hour+90*time.Second), clock.now())
+	cancel()
+	requires.Empty(clock.deadlines)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	requires.False(clock.sleep(ctx, time.Hour))
+	requires.Equal(start.Add(time.Hour+90*time.Second), clock.now())
+
+	requires.Equal(start.Add(10*time.Second), nextTick(start, start, 10*time.Second))
+	requires.Equal(start.Add(30*time.Second), nextTick(start, start.Add(20*time.Second), 10*time.Second))
+	requires.Equal(start.Add(30*time.Second), nextTick(start, start.Add(25*time.Second), 10*time.Second))
+	requires.IsType(RealClock{}, clockOf(nil))
+}
+
+func TestSimulatedClockParticipants(t *testing.T) {
+	requires := require.New(t)
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	mutex := sync.Mutex{}
+	events := []time.Duration{}
+	record := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, clock.now().Sub(start))
+	}
+	leave := clock.join()
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer leave()
+		for range 3 {
+			clock.sleep(context.Background(), 30*time.Second)
+			record()
+		}
+	}()
+	for range 5 {
+		clock.sleep(context.Background(), 20*time.Second)
+		record()
+	}
+	wg.Wait()
+	requires.Len(events, 8)
+	requires.True(slices.IsSorted(events), events)
+	requires.Equal(100*time.Second, events[7])
+}
+
+func TestSchedulerSimulatedTime(t *testing.T) {
+	requires := require.New(t)
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	eventer := &FakeEventer{}
+	scheduler := &Scheduler{
+		clock:        clock,
+		eventer:      eventer,
+		startDelay:   time.Hour,
+		testDuration: 4 * time.Hour,
+		timeout:      10 * time.Second,
+		actions:      []Action{{name: "restart", at: 2 * time.Hour, command: []string{"true"}}},
+	}
+	started := time.Now()
+	scheduler.run()
+	requires.Less(time.Since(started), 10*time.Second)
+	requires.Equal(1440, eventer.fired)
+	requires.Equal(start.Add(5*time.Hour), clock.now())
+
+	scheduler = App{fakeTime: true}.tune(&Reporter{}, Config{Metrics: []Metric{{Name: "up", Query: "up"}}}, Sources{})
+	requires.IsType(&SimulatedClock{}, scheduler.clock)
+	requires.Equal(scheduler.clock, scheduler.eventer.(*Eventer).clock)
+	requires.IsType(RealClock{}, App{}.tune(&Reporter{}, Config{}, Sources{}).clock)
+}
+
+func TestSimulatedQueryTime(t *testing.T) {
+	requires := require.New(t)
+	var mutex sync.Mutex
+	times := make([]time.Time, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requires.NoError(r.ParseForm())
+		at, err := strconv.ParseFloat(r.Form.Get("time"), 64)
+		requires.NoError(err)
+		mutex.Lock()
+		times = append(times, time.Unix(int64(at), 0).UTC())
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1,"3"]}}`))
+	}))
+	defer server.Close()
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	config := Config{StartDelay: Duration(time.Hour), TestDuration: Duration(time.Hour), Timeout: Duration(10 * time.Minute),
+		Heartbeat: Duration(15 * time.Minute), Watchdog: WatchdogConfig{Factor: 2}, Metrics: []Metric{{Name: "up", Query: "up", MaxValue: 10}}}
+	scheduler := App{}.tune(&Reporter{}, config, Sources{host: server.URL})
+	scheduler.clock = clock
+	scheduler.eventer.(*Eventer).clock = clock
+	scheduler.run()
+	requires.Len(times, 6)
+	for n, at := range times {
+		requires.Equal(start.Add(time.Hour+time.Duration(n)*10*time.Minute), at)
+	}
+	requires.Empty(scheduler.stalls)
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	defaultCloudMonitoringEndpoint = "https://monitoring.googleapis.com"
+	defaultGoogleMetadata          = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	cloudMonitoringScope           = "https://www.googleapis.com/auth/monitoring.read"
+)
+
+var cloudAligners = map[string]string{
+	"mean": "ALIGN_MEAN", "max": "ALIGN_MAX", "min": "ALIGN_MIN", "sum": "ALIGN_SUM", "count": "ALIGN_COUNT",
+	"delta": "ALIGN_DELTA", "rate": "ALIGN_RATE",
+	"p50": "ALIGN_PERCENTILE_50", "p95": "ALIGN_PERCENTILE_95", "p99": "ALIGN_PERCENTILE_99",
+}
+
+// GCPMonitoringConfig authenticates with a bearer token, e.g. of gcloud
+// auth print-access-token, a service account key file or, without either,
+// the service account of the GCE/GKE metadata server.
+type GCPMonitoringConfig struct {
+	Project         string `yaml:"project"`
+	CredentialsFile string `yaml:"credentialsFile"`
+	Token           string `yaml:"token"`
+	Endpoint        string `yaml:"endpoint"`
+}
+
+type CloudMonitoringClient struct {
+	client   *http.Client
+	endpoint string
+	project  string
+}
+
+type ServiceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+type metadataTokenSource struct {
+	client *http.Client
+	url    string
+}
+
+func (source metadataTokenSource) Token() (*oauth2.Token, error) {
+	req, err := http.NewRequest(http.MethodGet, source.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := source.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata server: status %s", resp.Status)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: token.AccessToken, TokenType: token.TokenType,
+		Expiry: time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)}, nil
+}
+
+func readServiceAccountKey(fileName string, workDir string) (*jwt.Config, error) {
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(workDir, fileName)
+	}
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	key := ServiceAccountKey{}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("%s: not a service account key", fileName)
+	}
+	tokenURL := key.TokenURI
+	if tokenURL == "" {
+		tokenURL = "https://oauth2.googleapis.com/token"
+	}
+	return &jwt.Config{Email: key.ClientEmail, PrivateKey: []byte(key.PrivateKey), PrivateKeyID: key.PrivateKeyID,
+		Scopes: []string{cloudMonitoringScope}, TokenURL: tokenURL}, nil
+}
+
+func NewCloudMonitoringClient(config GCPMonitoringConfig, workDir string) (*CloudMonitoringClient, error) {
+	endpoint := strings.TrimSuffix(config.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = defaultCloudMonitoringEndpoint
+	}
+	var source oauth2.TokenSource
+	switch {
+	case config.Token != "":
+		source = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Token})
+	case config.CredentialsFile != "":
+		key, err := readServiceAccountKey(config.CredentialsFile, workDir)
+		if err != nil {
+			return nil, fmt.Errorf("cloudMonitoring.credentialsFile: %w", err)
+		}
+		source = key.TokenSource(context.Background())
+	default:
+		source = metadataTokenSource{client: &http.Client{Timeout: 5 * time.Second}, url: defaultGoogleMetadata}
+	}
+	client := oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, source))
+	client.Timeout = 10 * time.Second
+	return &CloudMonitoringClient{client: client, endpoint: endpoint, project: config.Project}, nil
+}
+
+type CloudTypedValue struct {
+	DoubleValue       *float64 `json:"doubleValue"`
+	Int64Value        *string  `json:"int64Value"`
+	BoolValue         *bool    `json:"boolValue"`
+	DistributionValue *struct {
+		Mean float64 `json:"mean"`
+	} `json:"distributionValue"`
+}
+
+func (value CloudTypedValue) number() (float64, bool) {
+	switch {
+	case value.DoubleValue != nil:
+		return *value.DoubleValue, true
+	case value.Int64Value != nil:
+		number, err := strconv.ParseFloat(*value.Int64Value, 64)
+		return number, err == nil
+	case value.BoolValue != nil:
+		if *value.BoolValue {
+			return 1, true
+		}
+		return 0, true
+	case value.DistributionValue != nil:
+		return value.DistributionValue.Mean, true
+	}
+	return 0, false
+}
+
+// timeSeries and timeSeriesData list points newest first.
+type CloudTimeSeriesResponse struct {
+	TimeSeries []struct {
+		Points []struct {
+			Value CloudTypedValue `json:"value"`
+		} `json:"points"`
+	} `json:"timeSeries"`
+	TimeSeriesData []struct {
+		PointData []struct {
+			Values []CloudTypedValue `json:"values"`
+		} `json:"pointData"`
+	} `json:"timeSeriesData"`
+}
+
+type CloudError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (client *CloudMonitoringClient) do(req *http.Request) (CloudTimeSeriesResponse, error) {
+	response := CloudTimeSeriesResponse{}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		cloudError := CloudError{}
+		if json.NewDecoder(resp.Body).Decode(&cloudError) == nil && cloudError.Error.Message != "" {
+			return response, fmt.Errorf("status %s: %s", resp.Status, cloudError.Error.Message)
+		}
+		return response, fmt.Errorf("status %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	return response, err
+}
+
+// values are the latest points of every series: of a metric filter aligned
+// over the window, or of an MQL query, which brings its own window.
+func (client *CloudMonitoringClient) values(ctx context.Context, metric CloudMonitoringMetric, now time.Time) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	base := client.endpoint + "/v3/projects/" + url.PathEscape(client.project) + "/timeSeries"
+	var req *http.Request
+	var err error
+	if metric.mql() {
+		body, marshalErr := json.Marshal(map[string]string{"query": metric.Query})
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, base+":query", bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		params := url.Values{}
+		params.Set("filter", metric.Query)
+		params.Set("interval.startTime", now.Add(-metric.Window).UTC().Format(time.RFC3339))
+		params.Set("interval.endTime", now.UTC().Format(time.RFC3339))
+		params.Set("aggregation.alignmentPeriod", strconv.Itoa(int(metric.Window.Seconds()))+"s")
+		params.Set("aggregation.perSeriesAligner", cloudAligners[metric.Aligner])
+		params.Set("view", "FULL")
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, base+"?"+params.Encode(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float64, 0)
+	for _, series := range response.TimeSeries {
+		if len(series.Points) > 0 {
+			if number, ok := series.Points[0].Value.number(); ok {
+				values = append(values, number)
+			}
+		}
+	}
+	for _, series := range response.TimeSeriesData {
+		if len(series.PointData) > 0 && len(series.PointData[0].Values) > 0 {
+			if number, ok := series.PointData[0].Values[0].number(); ok {
+				values = append(values, number)
+			}
+		}
+	}
+	return values, nil
+}
+
+type CloudMonitoringMetric struct {
+	client    *CloudMonitoringClient
+	Name      string
+	Query     string
+	Aligner   string
+	Window    time.Duration
+	Scale     float64
+	MaxValue  int
+	OnMissing string
+}
+
+func isMQL(query string) bool {
+	query = strings.TrimSpace(query)
+	return strings.HasPrefix(query, "fetch ") || strings.HasPrefix(query, "{")
+}
+
+func newCloudMonitoringMetric(client *CloudMonitoringClient, metric Metric) CloudMonitoringMetric {
+	aligner := strings.ToLower(metric.Aggregate)
+	if aligner == "" {
+		aligner = "mean"
+	}
+	window := time.Duration(metric.Window)
+	if window == 0 {
+		window = defaultCloudWindow
+	}
+	return CloudMonitoringMetric{
+		client: client,
+		Name:   metric.Name, Query: metric.Query, Aligner: aligner, Window: window, Scale: metric.Scale,
+		MaxValue: metric.MaxValue, OnMissing: metric.OnMissing}
+}
+
+func (metric CloudMonitoringMetric) mql() bool {
+	return isMQL(metric.Query)
+}
+
+func (metric CloudMonitoringMetric) name() string {
+	return metric.Name
+}
+
+func (metric CloudMonitoringMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric CloudMonitoringMetric) gather(ctx context.Context) int {
+	values, err := metric.client.values(ctx, metric, clockFrom(ctx).now())
+	if err != nil {
+		log.Printf("Error querying Cloud Monitoring: %v\n", err)
+		return -1
+	}
+	if len(values) == 0 {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	how := "sum"
+	if !metric.mql() {
+		switch metric.Aligner {
+		case "mean", "min", "max":
+			how = metric.Aligner
+		case "p50", "p95", "p99":
+			how = "max"
+		}
+	}
+	return scaled(combineSeries(values, how), metric.Scale)
+}
+
+func validateCloudMonitoringMetric(config Config, metric Metric) error {
+	if config.CloudMonitoring.Project == "" {
+		return errors.New("cloudMonitoring.project is not set")
+	}
+	if metric.Query == "" {
+		return errors.New("query is not set (a metric filter or an MQL query)")
+	}
+	if isMQL(metric.Query) {
+		if metric.Aggregate != "" || metric.Window != 0 {
+			return errors.New("aggregate and window are part of an MQL query (align, within)")
+		}
+	} else if _, ok := cloudAligners[strings.ToLower(metric.Aggregate)]; metric.Aggregate != "" && !ok {
+		aligners := make([]string, 0, len(cloudAligners))
+		for aligner := range cloudAligners {
+			aligners = append(aligners, aligner)
+		}
+		slices.Sort(aligners)
+		return fmt.Errorf("unknown aggregate %s, expected one of %s", metric.Aggregate, strings.Join(aligners, ", "))
+	}
+	if metric.Window < 0 {
+		return errors.New("window must not be negative")
+	}
+	if metric.Scale < 0 {
+		return errors.New("scale must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func cloudMonitoringServer(t *testing.T, tokens *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			*tokens++
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+			require.NotEmpty(t, r.PostForm.Get("assertion"))
+			_, _ = w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+			return
+		case "/metadata":
+			require.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			_, _ = w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/v3/projects/perf/timeSeries":
+			query := r.URL.Query()
+			require.Equal(t, "300s", query.Get("aggregation.alignmentPeriod"))
+			switch query.Get("filter") {
+			case `metric.type="pubsub.googleapis.com/subscription/num_undelivered_messages"`:
+				require.Equal(t, "ALIGN_MAX", query.Get("aggregation.perSeriesAligner"))
+				_, _ = w.Write([]byte(`{"timeSeries":[` +
+					`{"points":[{"value":{"int64Value":"1200"}},{"value":{"int64Value":"9000"}}]},` +
+					`{"points":[{"value":{"int64Value":"300"}}]}]}`))
+			case `metric.type="loadbalancing.googleapis.com/https/total_latencies"`:
+				require.Equal(t, "ALIGN_PERCENTILE_99", query.Get("aggregation.perSeriesAligner"))
+				_, _ = w.Write([]byte(`{"timeSeries":[{"points":[{"value":{"doubleValue":412.4}}]},{"points":[{"value":{"doubleValue":98}}]}]}`))
+			case `metric.type="none"`:
+				_, _ = w.Write([]byte(`{}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":{"code":400,"message":"Field filter had an invalid value"}}`))
+			}
+		case "/v3/projects/perf/timeSeries:query":
+			request := map[string]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			require.Contains(t, request["query"], "fetch cloudsql_database")
+			_, _ = w.Write([]byte(`{"timeSeriesData":[{"pointData":[{"values":[{"doubleValue":0.42}]},{"values":[{"doubleValue":0.9}]}]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func serviceAccountFile(t *testing.T, dir string, tokenURI string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	b, err := json.Marshal(ServiceAccountKey{ClientEmail: "perf@perf.iam.gserviceaccount.com", PrivateKeyID: "k1",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), TokenURI: tokenURI})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "key.json"), b, 0o600))
+	return "key.json"
+}
+
+func TestCloudMonitoringMetric(t *testing.T) {
+	requires := require.New(t)
+	tokens := 0
+	server := cloudMonitoringServer(t, &tokens)
+	defer server.Close()
+	dir := t.TempDir()
+
+	client, err := NewCloudMonitoringClient(GCPMonitoringConfig{Project: "perf", Endpoint: server.URL,
+		CredentialsFile: serviceAccountFile(t, dir, server.URL+"/token")}, dir)
+	requires.NoError(err)
+	undelivered := newCloudMonitoringMetric(client, Metric{Name: "undelivered", Aggregate: "max",
+		Query: `metric.type="pubsub.googleapis.com/subscription/num_undelivered_messages"`})
+	requires.Equal(1200, undelivered.gather(context.Background()))
+	latency := newCloudMonitoringMetric(client, Metric{Name: "latency", Aggregate: "p99",
+		Query: `metric.type="loadbalancing.googleapis.com/https/total_latencies"`})
+	requires.Equal(412, latency.gather(context.Background()))
+	cpu := newCloudMonitoringMetric(client, Metric{Name: "cpu", Scale: 100,
+		Query: "fetch cloudsql_database | metric 'cloudsql.googleapis.com/database/cpu/utilization' | within 5m"})
+	requires.True(cpu.mql())
+	requires.Equal(42, cpu.gather(context.Background()))
+	requires.Equal(1, tokens)
+
+	none := newCloudMonitoringMetric(client, Metric{Name: "none", Query: `metric.type="none"`, OnMissing: "treatAsMax", MaxValue: 10})
+	requires.Equal(10, none.gather(context.Background()))
+	_, err = client.values(context.Background(), newCloudMonitoringMetric(client, Metric{Query: "metric.type="}), time.Now())
+	requires.ErrorContains(err, "Field filter had an invalid value")
+
+	_, err = NewCloudMonitoringClient(GCPMonitoringConfig{Project: "perf", CredentialsFile: "missing.json"}, dir)
+	requires.ErrorContains(err, "cloudMonitoring.credentialsFile")
+
+	token, err := metadataTokenSource{client: http.DefaultClient, url: server.URL + "/metadata"}.Token()
+	requires.NoError(err)
+	requires.Equal("token", token.AccessToken)
+	requires.True(token.Valid())
+}
+
+func TestValidateCloudMonitoringMetric(t *testing.T) {
+	requires := require.New(t)
+	config := Config{CloudMonitoring: GCPMonitoringConfig{Project: "perf"}}
+	requires.NoError(validateCloudMonitoringMetric(config, Metric{Query: `metric.type="x"`, Aggregate: "P95", Window: Duration(time.Minute)}))
+	requires.NoError(validateCloudMonitoringMetric(config, Metric{Query: "fetch gce_instance | within 5m"}))
+	requires.ErrorContains(validateCloudMonitoringMetric(Config{}, Metric{Query: "q"}), "cloudMonitoring.project is not set")
+	requires.ErrorContains(validateCloudMonitoringMetric(config, Metric{}), "query is not set")
+	requires.ErrorContains(validateCloudMonitoringMetric(config, Metric{Query: "fetch gce_instance", Window: Duration(time.Minute)}),
+		"aggregate and window are part of an MQL query")
+	requires.ErrorContains(validateCloudMonitoringMetric(config, Metric{Query: `metric.type="x"`, Aggregate: "avg"}),
+		"unknown aggregate avg, expected one of count, delta, max, mean, min, p50, p95, p99, rate, sum")
+}
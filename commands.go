@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	version = "dev"
+	gitHash = ""
+	p2hHash = ""
+)
+
+type Command struct {
+	name    string
+	args    string
+	summary string
+	flags   func(app *App, flags *flag.FlagSet)
+	run     func(app App, w io.Writer) int
+}
+
+func configFlags(app *App, flags *flag.FlagSet) {
+	flags.StringVar(&app.configFile, "config", "./config.yaml", "config file")
+	varsFlags(app, flags)
+}
+
+func varsFlags(app *App, flags *flag.FlagSet) {
+	flags.Var(app.vars, "var", "template variable name=value, overrides config vars")
+	flags.StringVar(&app.profile, "profile", "", "apply a profile from the config")
+}
+
+func standFlag(app *App, flags *flag.FlagSet) {
+	flags.StringVar(&app.stand, "stand", "", "run only this stand of a config with stands")
+}
+
+func selectionFlags(app *App, flags *flag.FlagSet) {
+	flags.Var(&app.selection.only, "only", "gather only the metrics matching tag=<tag> or name=<name> (globs, repeatable)")
+	flags.Var(&app.selection.skip, "skip", "skip the metrics matching tag=<tag> or name=<name> (globs, repeatable)")
+}
+
+func budgetsFlag(app *App, flags *flag.FlagSet) {
+	flags.StringVar(&app.budgets, "budgets", "", "budgets file with run-level limits, overrides budgets from config")
+}
+
+func outputDirFlag(app *App, flags *flag.FlagSet) {
+	flags.StringVar(&app.outputDir, "output-dir", "", "base directory of run artifacts (default ./results)")
+}
+
+func ctlFlags(app *App, flags *flag.FlagSet) {
+	outputDirFlag(app, flags)
+	flags.StringVar(&app.configFile, "config", "", "config file with the outputDir of the runs")
+	flags.StringVar(&app.controlSocket, "socket", "", "control socket of the instance (default the latest running in --output-dir)")
+}
+
+var commands = []Command{
+	{
+		name:    "run",
+		summary: "start the stand, gather metrics and check thresholds (default)",
+		flags: func(app *App, flags *flag.FlagSet) {
+			configFlags(app, flags)
+			outputDirFlag(app, flags)
+			flags.BoolVar(&app.keepOnFailure, "keep-on-failure", false, "keep the stand running when thresholds are violated")
+			standFlag(app, flags)
+			selectionFlags(app, flags)
+			budgetsFlag(app, flags)
+			flags.StringVar(&app.reportFile, "report", "", "save the run report as JSON")
+			flags.BoolVar(&app.dryRun, "dry-run", false, "validate the config and print the plan without starting the stand")
+			flags.BoolVar(&app.checkQueries, "check-queries", false, "with --dry-run, run every query once")
+			flags.BoolVar(&app.once, "once", false, "start nothing, gather every metric once against the running stand, print the checks and exit")
+			flags.BoolVar(&app.teamCityOutput, "teamcity", false, "write TeamCity service messages (default when TEAMCITY_VERSION is set)")
+			flags.StringVar(&app.controlSocket, "control-socket", "", "listen for ctl commands on this Unix socket (default ctl.sock in the run directory)")
+			flags.IntVar(&app.livePort, "live-port", 0, "stream gathered values as server-sent events on 127.0.0.1:<port>/events")
+			flags.Var(&app.overrides.duration, "duration", "override testDuration and the duration of every scenario")
+			flags.Var(&app.overrides.startDelay, "start-delay", "override startDelay")
+			flags.Var(&app.overrides.interval, "interval", "override the tick interval (timeout)")
+			flags.BoolVar(&app.noColor, "no-color", false, "print the report tables without colors (also NO_COLOR)")
+			flags.BoolVar(&app.fakeTime, "faketime", false, "run the schedule in simulated time: delays, intervals and durations pass instantly")
+			flags.StringVar(&app.resume, "resume", "", "continue the run with this run ID of a crashed gatherer against the still running stand")
+		},
+		run: func(app App, w io.Writer) int {
+			app.run()
+			return 0
+		},
+	},
+	{
+		name:    "validate",
+		summary: "validate the config without starting the stand",
+		flags: func(app *App, flags *flag.FlagSet) {
+			configFlags(app, flags)
+			standFlag(app, flags)
+			selectionFlags(app, flags)
+			budgetsFlag(app, flags)
+			flags.BoolVar(&app.checkQueries, "check-queries", false, "run every query once")
+		},
+		run: func(app App, w io.Writer) int {
+			return app.validate(w)
+		},
+	},
+	{
+		name:    "serve",
+		summary: "accept runs over HTTP",
+		flags: func(app *App, flags *flag.FlagSet) {
+			varsFlags(app, flags)
+			outputDirFlag(app, flags)
+			flags.StringVar(&app.listen, "listen", "127.0.0.1:8080", "listen address")
+			flags.StringVar(&app.token, "token", "", "bearer token required from clients")
+		},
+		run: func(app App, w io.Writer) int {
+			if app.token == "" && !isLoopback(app.listen) {
+				log.Fatalln("serve mode on", app.listen, "requires --token")
+			}
+			log.Println("listen", app.listen)
+			log.Fatalln(http.ListenAndServe(app.listen, NewServer(app).handler()))
+			return 1
+		},
+	},
+	{
+		name:    "compare",
+		args:    "<base> <candidate>",
+		summary: "compare two saved run reports, run directories or run ids",
+		flags: func(app *App, flags *flag.FlagSet) {
+			outputDirFlag(app, flags)
+			flags.StringVar(&app.configFile, "config", "", "config file with the outputDir of the runs")
+			flags.Float64Var(&app.tolerance, "tolerance", 0, "allowed regression in percent")
+		},
+		run: func(app App, w io.Writer) int {
+			if !app.compare() {
+				return 1
+			}
+			return 0
+		},
+	},
+	{
+		name:    "report render",
+		args:    "[<report.json>]",
+		summary: "render a saved run report as " + strings.Join(reportFormats, ", "),
+		flags: func(app *App, flags *flag.FlagSet) {
+			flags.StringVar(&app.input, "input", "", "saved report.json or run directory")
+			flags.StringVar(&app.template, "template", "", "go template file used instead of the built-in md, csv or html layout")
+			flags.StringVar(&app.format, "format", "md", "output format: "+strings.Join(reportFormats, ", "))
+			flags.StringVar(&app.output, "output", "", "output file (default stdout)")
+		},
+		run: func(app App, w io.Writer) int {
+			return app.renderReport(w)
+		},
+	},
+	{
+		name:    "history list",
+		summary: "list runs in the output directory",
+		flags: func(app *App, flags *flag.FlagSet) {
+			outputDirFlag(app, flags)
+			flags.StringVar(&app.configFile, "config", "", "config file with the outputDir of the runs")
+		},
+		run: func(app App, w io.Writer) int {
+			return app.historyList(w)
+		},
+	},
+	{
+		name:    "history prune",
+		summary: "delete old runs from the output directory",
+		flags: func(app *App, flags *flag.FlagSet) {
+			outputDirFlag(app, flags)
+			flags.StringVar(&app.configFile, "config", "", "config file with the history retention (and outputDir)")
+			flags.IntVar(&app.history.KeepRuns, "keep-runs", 0, "keep the last N runs of every label set, overrides history.keepRuns")
+			flags.IntVar(&app.history.KeepDays, "keep-days", 0, "keep the runs of the last N days, overrides history.keepDays")
+			flags.BoolVar(&app.dryRun, "dry-run", false, "print the runs to prune without deleting them")
+		},
+		run: func(app App, w io.Writer) int {
+			return app.historyPrune(w)
+		},
+	},
+	{
+		name:    "ctl status",
+		summary: "print the state, ticks and latest values of a running instance",
+		flags:   ctlFlags,
+		run: func(app App, w io.Writer) int {
+			return app.ctlStatus(w)
+		},
+	},
+	{
+		name:    "ctl abort",
+		summary: "abort a running instance like Ctrl-C: stop gathering and tear the stand down",
+		flags:   ctlFlags,
+		run: func(app App, w io.Writer) int {
+			return app.ctlAbort(w)
+		},
+	},
+	{
+		name:    "ctl set-loglevel",
+		args:    "<level>",
+		summary: "set the log level of a running instance: " + strings.Join(logLevels, ", "),
+		flags:   ctlFlags,
+		run: func(app App, w io.Writer) int {
+			return app.ctlSetLogLevel(w)
+		},
+	},
+	{
+		name:    "version",
+		summary: "print the version",
+		run: func(app App, w io.Writer) int {
+			fmt.Fprintln(w, versionString())
+			return 0
+		},
+	},
+}
+
+func (app *App) parseArgs(args []string) (Command, error) {
+	app.vars = VarsFlag{}
+	command := commands[0]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		found := false
+		for _, candidate := range commands {
+			words := strings.Fields(candidate.name)
+			if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+				command, args, found = candidate, args[len(words):], true
+				break
+			}
+		}
+		if !found {
+			return Command{}, fmt.Errorf("unknown command %s\n%s", strings.Join(args[:1], " "), usage())
+		}
+	}
+	flags := flag.NewFlagSet("metricsgatherer "+command.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: metricsgatherer %s [flags] %s\n\n%s\n\n", command.name, command.args, command.summary)
+		flags.PrintDefaults()
+	}
+	if command.flags != nil {
+		command.flags(app, flags)
+	}
+	if err := flags.Parse(args); err != nil {
+		return Command{}, err
+	}
+	app.args = flags.Args()
+	return command, nil
+}
+
+func usage() string {
+	var b strings.Builder
+	b.WriteString("usage: metricsgatherer <command> [flags]\n\ncommands:\n")
+	for _, command := range commands {
+		fmt.Fprintf(&b, "  %-16s %s\n", command.name, command.summary)
+	}
+	b.WriteString("\nmetricsgatherer <command> --help shows the flags of a command")
+	return b.String()
+}
+
+func versionString() string {
+	text := "metricsgatherer " + version
+	if gitHash != "" {
+		text += " " + gitHash
+	}
+	if p2hHash != "" {
+		text += " " + p2hHash
+	}
+	return text + " " + runtime.Version()
+}
+
+func (app App) validate(w io.Writer) int {
+	config, err := app.loadConfig(app.configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	out := io.Discard
+	if app.checkQueries {
+		out = w
+	}
+	plan := app.plan
+	if len(config.Matrix) > 0 {
+		plan = app.planMatrix
+	}
+	if len(config.Stands) > 0 {
+		plan = app.planStands
+	}
+	if err := plan(context.Background(), config, out); err != nil {
+		fmt.Fprintln(w, app.configFile+":", err)
+		return 1
+	}
+	fmt.Fprintln(w, app.configFile+": ok")
+	return 0
+}
+
+func (app App) renderReport(w io.Writer) int {
+	input := app.input
+	if input == "" && len(app.args) == 1 {
+		input = app.args[0]
+	}
+	if input == "" || len(app.args) > 1 || (app.input != "" && len(app.args) > 0) {
+		log.Fatalln("usage: metricsgatherer report render [--format md] [--template file] [--output file] --input <report.json|run dir>")
+	}
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		input = filepath.Join(input, "report.json")
+	}
+	report, err := loadRunReport(input)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := loadSpooledValues(input, &report); err != nil {
+		log.Fatalln(err)
+	}
+	var b []byte
+	if app.template != "" {
+		b, err = renderTemplate(app.format, app.template, report)
+	} else {
+		b, err = renderReport(app.format, report)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if app.output == "" {
+		_, _ = w.Write(b)
+		return 0
+	}
+	if err := os.WriteFile(app.output, b, 0o644); err != nil {
+		log.Fatalln(err)
+	}
+	return 0
+}
+
+func loadHistory(baseDir string) ([]RunMetadata, error) {
+	files, err := filepath.Glob(filepath.Join(baseDir, "*", "metadata.json"))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]RunMetadata, 0, len(files))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		metadata := RunMetadata{}
+		if err := json.Unmarshal(b, &metadata); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		metadata.dir = filepath.Dir(file)
+		runs = append(runs, metadata)
+	}
+	slices.SortFunc(runs, func(a, b RunMetadata) int {
+		return a.Started.Compare(b.Started)
+	})
+	return runs, nil
+}
+
+// optionalConfig is the --config of a command that works without one, for
+// its outputDir.
+func (app App) optionalConfig() (Config, error) {
+	if app.configFile == "" {
+		return Config{}, nil
+	}
+	return app.loadConfig(app.configFile)
+}
+
+func (app App) historyList(w io.Writer) int {
+	config, err := app.optionalConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	runs, err := loadHistory(app.outputBaseDir(config))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, run := range runs {
+		result := "passed"
+		if !run.Passed {
+			result = "failed"
+		}
+		fmt.Fprintf(w, "%s  %s  %-6s  %-8s  %s\n", run.RunID, run.Started.Format(time.DateTime), result,
+			run.Finished.Sub(run.Started).Round(time.Second), strings.Join(run.Scenarios, ","))
+	}
+	return 0
+}
+
+func runMain(args []string, w io.Writer) int {
+	if len(args) > 0 && args[0] == "help" {
+		fmt.Fprintln(w, usage())
+		return 0
+	}
+	app := App{}
+	command, err := app.parseArgs(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		log.Println(err)
+		return 2
+	}
+	return command.run(app, w)
+}
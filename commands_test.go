@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommands(t *testing.T) {
+	variants := []struct {
+		args    []string
+		command string
+		rest    []string
+		err     bool
+	}{
+		{args: []string{}, command: "run"},
+		{args: []string{"--keep-on-failure"}, command: "run"},
+		{args: []string{"run", "--config", "perf.yaml"}, command: "run"},
+		{args: []string{"validate", "--check-queries"}, command: "validate"},
+		{args: []string{"serve", "--listen", ":9090"}, command: "serve"},
+		{args: []string{"compare", "--tolerance", "5", "a.json", "b.json"}, command: "compare", rest: []string{"a.json", "b.json"}},
+		{args: []string{"report", "render", "--format", "html", "run.json"}, command: "report render", rest: []string{"run.json"}},
+		{args: []string{"history", "list", "--output-dir", "out"}, command: "history list"},
+		{args: []string{"version"}, command: "version"},
+		{args: []string{"report"}, err: true},
+		{args: []string{"bench"}, err: true},
+		{args: []string{"validate", "--listen", ":9090"}, err: true},
+		{args: []string{"compare", "--keep-on-failure"}, err: true},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		app := App{}
+		command, err := app.parseArgs(variant.args)
+		if variant.err {
+			requires.Error(err, n)
+			continue
+		}
+		requires.NoError(err, n)
+		requires.Equal(variant.command, command.name, n)
+		if variant.rest == nil {
+			variant.rest = []string{}
+		}
+		requires.Equal(variant.rest, app.args, n)
+	}
+
+	app := App{}
+	_, err := app.parseArgs([]string{"history", "list", "--output-dir", "out"})
+	requires.NoError(err)
+	requires.Equal("out", app.outputDir)
+	_, err = app.parseArgs([]string{"report", "render", "run.json"})
+	requires.NoError(err)
+	requires.Equal("md", app.format)
+	_, err = app.parseArgs([]string{"version", "--help"})
+	requires.ErrorIs(err, flag.ErrHelp)
+}
+
+func TestCommandValidate(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yaml")
+	requires.NoError(os.WriteFile(valid, []byte("metrics:\n  - name: errors\n    query: sum(errors)\n"), 0o644))
+	invalid := filepath.Join(dir, "invalid.yaml")
+	requires.NoError(os.WriteFile(invalid, []byte("metrics:\n  - name: errors\n    type: unknown\n"), 0o644))
+
+	var b bytes.Buffer
+	requires.Equal(0, runMain([]string{"validate", "--config", valid}, &b))
+	requires.Equal(valid+": ok\n", b.String())
+	b.Reset()
+	requires.Equal(1, runMain([]string{"validate", "--config", invalid}, &b))
+	requires.Contains(b.String(), invalid+": ")
+	requires.Equal(2, runMain([]string{"validate", "--unknown"}, &b))
+}
+
+func TestCommandReportRender(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "run.json")
+	requires.NoError(saveRunReport(fileName, compareReport("r1", map[string][]int{"latency": {100, 120}})))
+
+	var b bytes.Buffer
+	requires.Equal(0, runMain([]string{"report", "render", fileName}, &b))
+	requires.Contains(b.String(), "r1")
+	requires.Contains(b.String(), "latency")
+
+	output := filepath.Join(dir, "run.csv")
+	b.Reset()
+	requires.Equal(0, runMain([]string{"report", "render", "--format", "csv", "--output", output, fileName}, &b))
+	requires.Empty(b.String())
+	csv, err := os.ReadFile(output)
+	requires.NoError(err)
+	requires.Contains(string(csv), "latency")
+
+	runDir := filepath.Join(dir, "20240102-030405")
+	requires.NoError(os.Mkdir(runDir, 0o755))
+	requires.NoError(saveRunReport(filepath.Join(runDir, "report.json"), compareReport("r2", map[string][]int{"errors": {1, 3}})))
+	md := filepath.Join(dir, "team.md.tmpl")
+	requires.NoError(os.WriteFile(md, []byte("# {{ .RunID }}: {{ result .RunReport }}\n{{ range .Values }}{{ range .Values }}- {{ .Name }} <{{ .Value }}>\n{{ end }}{{ end }}"), 0o644))
+	b.Reset()
+	requires.Equal(0, runMain([]string{"report", "render", "--input", runDir, "--template", md}, &b))
+	requires.Equal("# r2: failed\n- errors <1>\n- errors <3>\n", b.String())
+
+	html := filepath.Join(dir, "team.html.tmpl")
+	requires.NoError(os.WriteFile(html, []byte("{{ range .Groups }}{{ range .Rows }}<b>{{ .Name }}</b>{{ end }}{{ end }}"), 0o644))
+	b.Reset()
+	requires.Equal(0, runMain([]string{"report", "render", "--input", fileName, "--format", "html", "--template", html}, &b))
+	requires.Equal("<b>latency</b><b>latency</b>", b.String())
+
+	_, err = renderTemplate("json", md, RunReport{})
+	requires.ErrorContains(err, "format json does not support templates")
+	_, err = renderTemplate("md", filepath.Join(dir, "missing.tmpl"), RunReport{})
+	requires.Error(err)
+}
+
+func TestCommandHistoryList(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for n, metadata := range []RunMetadata{
+		{RunID: "r2", Started: started.Add(time.Hour), Finished: started.Add(time.Hour + time.Minute), Scenarios: []string{"soak"}},
+		{RunID: "r1", Started: started, Finished: started.Add(90 * time.Second), Passed: true},
+	} {
+		runDir := filepath.Join(dir, metadata.RunID)
+		requires.NoError(os.MkdirAll(runDir, 0o755), n)
+		b, _ := json.Marshal(metadata)
+		requires.NoError(os.WriteFile(filepath.Join(runDir, "metadata.json"), b, 0o644), n)
+	}
+
+	var b bytes.Buffer
+	requires.Equal(0, runMain([]string{"history", "list", "--output-dir", dir}, &b))
+	requires.Equal("r1  2024-01-02 03:04:05  passed  1m30s     \n"+
+		"r2  2024-01-02 04:04:05  failed  1m0s      soak\n", b.String())
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	requires.NoError(os.WriteFile(configFile, []byte("outputDir: "+dir+"\n"), 0o644))
+	b.Reset()
+	requires.Equal(0, runMain([]string{"history", "list", "--config", configFile}, &b))
+	requires.Contains(b.String(), "r2  2024-01-02 04:04:05  failed")
+}
+
+func TestCommandVersion(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	requires.Equal(0, runMain([]string{"version"}, &b))
+	requires.Contains(b.String(), "metricsgatherer dev")
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type MetricComparison struct {
+	metric    string
+	base      float64
+	candidate float64
+	delta     float64
+	percent   float64
+	regressed bool
+	missing   bool
+	added     bool
+}
+
+func reportAverages(report RunReport) map[string]float64 {
+	if report.Spool != "" && len(report.Values) == 0 {
+		averages := map[string]float64{}
+		for _, summary := range report.Summary {
+			if summary.Samples > 0 {
+				averages[teamCityKey(summary.Scenario, summary.Metric)] = summary.Avg
+			}
+		}
+		return averages
+	}
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for _, tick := range report.Values {
+		for _, value := range tick.Values {
+			if !hasValue(value.Value) {
+				continue
+			}
+			key := value.Name
+			if tick.Scenario != "" {
+				key = tick.Scenario + "/" + value.Name
+			}
+			sums[key] += float64(value.Value)
+			counts[key]++
+		}
+	}
+	averages := map[string]float64{}
+	for key, sum := range sums {
+		averages[key] = sum / float64(counts[key])
+	}
+	return averages
+}
+
+func compareReports(base RunReport, candidate RunReport, tolerance float64) ([]MetricComparison, bool) {
+	baseAverages := reportAverages(base)
+	candidateAverages := reportAverages(candidate)
+	keys := make([]string, 0)
+	for key := range baseAverages {
+		keys = append(keys, key)
+	}
+	for key := range candidateAverages {
+		if _, ok := baseAverages[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	comparisons := make([]MetricComparison, 0)
+	flag := true
+	for _, key := range keys {
+		baseValue, baseOk := baseAverages[key]
+		candidateValue, candidateOk := candidateAverages[key]
+		comparison := MetricComparison{metric: key, base: baseValue, candidate: candidateValue, missing: !candidateOk, added: !baseOk}
+		if baseOk && candidateOk {
+			comparison.delta = candidateValue - baseValue
+			comparison.percent = percentChange(baseValue, candidateValue)
+			comparison.regressed = comparison.percent > tolerance
+		}
+		if comparison.regressed || comparison.missing {
+			flag = false
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons, flag
+}
+
+func printComparisons(base RunReport, candidate RunReport, comparisons []MetricComparison) {
+	log.Println("=[ compare ]=================")
+	log.Println(" base:     ", base.RunID)
+	log.Println(" candidate:", candidate.RunID)
+	for _, comparison := range comparisons {
+		if comparison.missing {
+			log.Printf(" FAIL %-30s missing in the candidate\n", comparison.metric)
+			continue
+		}
+		if comparison.added {
+			log.Printf("      %-30s new in the candidate\n", comparison.metric)
+			continue
+		}
+		mark := "ok  "
+		if comparison.regressed {
+			mark = "FAIL"
+		}
+		log.Printf(" %s %-30s %12.2f -> %12.2f %+12.2f (%s)\n", mark, comparison.metric,
+			comparison.base, comparison.candidate, comparison.delta, formatPercent(comparison.percent))
+	}
+	log.Println("=[ end ]=====================")
+}
+
+func percentChange(base float64, value float64) float64 {
+	switch {
+	case base != 0:
+		return (value - base) / math.Abs(base) * 100
+	case value > base:
+		return math.Inf(1)
+	}
+	return 0
+}
+
+func formatPercent(percent float64) string {
+	if math.IsInf(percent, 1) {
+		return "+inf%"
+	}
+	return fmt.Sprintf("%+.1f%%", percent)
+}
+
+func (app App) compare() bool {
+	if len(app.args) != 2 {
+		log.Fatalln("usage: metricsgatherer compare [--tolerance N] <base.json|run dir|run id> <candidate.json|run dir|run id>")
+	}
+	base, err := app.findReport(app.args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	candidate, err := app.findReport(app.args[1])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	comparisons, ok := compareReports(base, candidate, app.tolerance)
+	printComparisons(base, candidate, comparisons)
+	return ok
+}
+
+// findReport loads a report file, the report of a run directory or of a run
+// id in the history of the output directory.
+func (app App) findReport(arg string) (RunReport, error) {
+	if info, err := os.Stat(arg); err == nil {
+		if info.IsDir() {
+			return loadRunReport(filepath.Join(arg, "report.json"))
+		}
+		return loadRunReport(arg)
+	}
+	config, err := app.optionalConfig()
+	if err != nil {
+		return RunReport{}, err
+	}
+	baseDir := app.outputBaseDir(config)
+	runs, err := loadHistory(baseDir)
+	if err != nil {
+		return RunReport{}, err
+	}
+	for _, run := range runs {
+		if run.RunID == arg {
+			return loadRunReport(filepath.Join(run.dir, "report.json"))
+		}
+	}
+	return RunReport{}, fmt.Errorf("%s is neither a report nor a run in %s", arg, baseDir)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func compareReport(runID string, values map[string][]int) RunReport {
+	report := RunReport{RunID: runID}
+	for name, samples := range values {
+		for _, sample := range samples {
+			report.Values = append(report.Values, MetricValuesJSON{Values: []ValueJSON{{Name: name, Value: sample}}})
+		}
+	}
+	return report
+}
+
+func TestCompareReports(t *testing.T) {
+	requires := require.New(t)
+	base := compareReport("a", map[string][]int{"latency": {100, 100}, "errors": {0}, "rps": {50, -1}, "old": {1}})
+	candidate := compareReport("b", map[string][]int{"latency": {110, 120}, "errors": {2}, "rps": {40}, "new": {1}})
+
+	comparisons, ok := compareReports(base, candidate, 10)
+	requires.False(ok)
+	requires.Len(comparisons, 5)
+	byName := map[string]MetricComparison{}
+	for _, comparison := range comparisons {
+		byName[comparison.metric] = comparison
+	}
+	requires.Equal(15.0, byName["latency"].percent)
+	requires.True(byName["latency"].regressed)
+	requires.True(math.IsInf(byName["errors"].percent, 1))
+	requires.Equal(-20.0, byName["rps"].percent)
+	requires.False(byName["rps"].regressed)
+	requires.True(byName["old"].missing)
+	requires.True(byName["new"].added)
+	requires.False(byName["new"].missing)
+
+	_, ok = compareReports(base, candidate, math.Inf(1))
+	requires.False(ok)
+	_, ok = compareReports(compareReport("a", map[string][]int{"latency": {100}}), candidate, math.Inf(1))
+	requires.True(ok)
+}
+
+func TestFindReport(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	runDir := filepath.Join(dir, "01HBASE-20240102-030405")
+	requires.NoError(os.MkdirAll(runDir, 0o755))
+	requires.NoError(os.WriteFile(filepath.Join(runDir, "metadata.json"), []byte(`{"runId":"01HBASE"}`), 0o644))
+	requires.NoError(saveRunReport(filepath.Join(runDir, "report.json"), compareReport("01HBASE", map[string][]int{"rps": {1}})))
+
+	app := App{outputDir: dir}
+	for _, arg := range []string{"01HBASE", runDir, filepath.Join(runDir, "report.json")} {
+		report, err := app.findReport(arg)
+		requires.NoError(err, arg)
+		requires.Equal("01HBASE", report.RunID, arg)
+	}
+	_, err := app.findReport("01HNONE")
+	requires.ErrorContains(err, "01HNONE is neither a report nor a run in "+dir)
+}
+
+func TestRunReportSaveLoad(t *testing.T) {
+	requires := require.New(t)
+	max, _ := parseAssertion("max < 1")
+	scheduler := &Scheduler{runID: "r1", results: []AssertionResult{{RunAssertion: RunAssertion{metric: "a", assertion: max}, value: 2}}}
+	report := newRunReport(scheduler, []MetricValues{{scenario: "s", values: []MetricValue{{name: "a", value: 2}}}})
+	fileName := filepath.Join(t.TempDir(), "run.json")
+	requires.NoError(saveRunReport(fileName, report))
+	loaded, err := loadRunReport(fileName)
+	requires.NoError(err)
+	requires.Equal("r1", loaded.RunID)
+	requires.True(loaded.Passed)
+	requires.Equal("s", loaded.Values[0].Scenario)
+	requires.Equal("max < 1", loaded.Assertions[0].Assertion)
+	_, err = loadRunReport(filepath.Join(t.TempDir(), "missing.json"))
+	requires.Error(err)
+}
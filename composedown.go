@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DownConfig is how the compose stand is torn down: down with extra flags,
+// or stop, which keeps containers, networks and volumes for the next run.
+type DownConfig struct {
+	Stop          bool   `yaml:"stop"`
+	Volumes       bool   `yaml:"volumes"`
+	Rmi           string `yaml:"rmi"`
+	RemoveOrphans bool   `yaml:"removeOrphans"`
+}
+
+func validateDown(down DownConfig) error {
+	if down.Rmi != "" && down.Rmi != "local" && down.Rmi != "all" {
+		return fmt.Errorf("unknown env.down.rmi %s, expected local or all", down.Rmi)
+	}
+	if down.Stop && (down.Volumes || down.Rmi != "" || down.RemoveOrphans) {
+		return errors.New("env.down.stop keeps the stand, volumes, rmi and removeOrphans need down")
+	}
+	return nil
+}
+
+func (down DownConfig) args() []string {
+	if down.Stop {
+		return []string{"stop"}
+	}
+	args := []string{"down"}
+	if down.Volumes {
+		args = append(args, "--volumes")
+	}
+	if down.Rmi != "" {
+		args = append(args, "--rmi", down.Rmi)
+	}
+	if down.RemoveOrphans {
+		args = append(args, "--remove-orphans")
+	}
+	return args
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownConfig(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		down  DownConfig
+		calls string
+		err   string
+	}{
+		{down: DownConfig{}, calls: "down\n"},
+		{down: DownConfig{Volumes: true, Rmi: "local", RemoveOrphans: true}, calls: "down --volumes --rmi local --remove-orphans\n"},
+		{down: DownConfig{Stop: true}, calls: "stop\n"},
+		{down: DownConfig{Rmi: "none"}, err: "unknown env.down.rmi none, expected local or all"},
+		{down: DownConfig{Stop: true, Volumes: true}, err: "env.down.stop keeps the stand"},
+	}
+	for _, variant := range variants {
+		err := validateStand(Config{Env: StandConfig{Down: variant.down}})
+		if variant.err != "" {
+			requires.ErrorContains(err, variant.err)
+			continue
+		}
+		requires.NoError(err)
+		dir := t.TempDir()
+		envManager := DockerCompose{
+			workDir:        dir,
+			composeCommand: []string{"sh", "-c", `echo "$@" >> calls`, "sh"},
+			down:           variant.down,
+		}
+		requires.NoError(envManager.stop())
+		calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+		requires.Equal(variant.calls, string(calls))
+	}
+
+	dir := t.TempDir()
+	envManager := DockerCompose{
+		workDir:         dir,
+		composeCommand:  []string{"sh", "-c", `echo "$@" >> calls; [ "$1" = kill ]`, "sh"},
+		teardownTimeout: 300 * time.Millisecond,
+		down:            DownConfig{Stop: true},
+	}
+	requires.NoError(envManager.stop())
+	calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+	requires.Equal("stop\nstop --timeout 0\nkill\n", string(calls))
+
+	down := DownConfig{Volumes: true}
+	scheduler := App{}.tune(&Reporter{}, Config{Env: StandConfig{Down: down}}, Sources{})
+	requires.Equal(down, scheduler.envManager.(DockerCompose).down)
+	requires.Equal([][]string{{"docker", "compose", "down", "--volumes"}}, newSSHEnv(Config{Env: StandConfig{Down: down}}, 0).stopCommands)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+type StandVars struct {
+	RunID     string
+	OutputDir string
+	Vars      map[string]string
+}
+
+func standTemplate(name string, value string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(value)
+}
+
+func validateStandEnvironment(config Config) error {
+	for _, envFile := range config.Env.EnvFile {
+		path := envFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(config.WorkDir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("env.envFile: %w", err)
+		}
+	}
+	for name, value := range config.Env.Environment {
+		if name == "" || strings.ContainsAny(name, "= \t") {
+			return fmt.Errorf("env.environment: invalid name %q", name)
+		}
+		if _, err := standTemplate(name, value); err != nil {
+			return fmt.Errorf("env.environment: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func renderStandEnvironment(environment map[string]string, vars StandVars) (map[string]string, error) {
+	if len(environment) == 0 {
+		return environment, nil
+	}
+	rendered := map[string]string{}
+	for name, value := range environment {
+		tmpl, err := standTemplate(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("env.environment: %s: %w", name, err)
+		}
+		b := bytes.Buffer{}
+		if err := tmpl.Execute(&b, vars); err != nil {
+			return nil, fmt.Errorf("env.environment: %s: %w", name, err)
+		}
+		rendered[name] = b.String()
+	}
+	return rendered, nil
+}
+
+func standEnvironment(environment map[string]string) []string {
+	if len(environment) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(environment))
+	for name, value := range environment {
+		values = append(values, name+"="+value)
+	}
+	slices.Sort(values)
+	return values
+}
+
+func newStandVars(config Config, runID string, outputDir string) (StandVars, error) {
+	dir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return StandVars{}, fmt.Errorf("env.environment: %w", err)
+	}
+	return StandVars{RunID: runID, OutputDir: dir, Vars: config.Vars}, nil
+}
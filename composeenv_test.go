@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateStandEnvironment(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "stand.env"), []byte("API_IMAGE=api:1\n"), 0o644))
+	requires.NoError(validateStand(Config{WorkDir: dir, Env: StandConfig{EnvFile: []string{"stand.env"}, Environment: map[string]string{"RUN_ID": "{{ .RunID }}"}}}))
+	requires.NoError(validateStand(Config{Env: StandConfig{EnvFile: []string{filepath.Join(dir, "stand.env")}}}))
+	requires.ErrorContains(validateStand(Config{WorkDir: dir, Env: StandConfig{EnvFile: []string{"missing.env"}}}), "env.envFile:")
+	requires.ErrorContains(validateStand(Config{Env: StandConfig{Environment: map[string]string{"A=B": "x"}}}), `env.environment: invalid name "A=B"`)
+	requires.ErrorContains(validateStand(Config{Env: StandConfig{Environment: map[string]string{"RUN_ID": "{{ .RunID"}}}), "env.environment: RUN_ID:")
+}
+
+func TestRenderStandEnvironment(t *testing.T) {
+	requires := require.New(t)
+	vars, err := newStandVars(Config{Vars: map[string]string{"team": "billing"}}, "r1", "results/r1")
+	requires.NoError(err)
+	requires.True(filepath.IsAbs(vars.OutputDir))
+	requires.Equal("r1", vars.RunID)
+
+	rendered, err := renderStandEnvironment(map[string]string{
+		"RUN_ID":     "{{ .RunID }}",
+		"RESULT_DIR": "{{ .OutputDir }}/dumps",
+		"TEAM":       "{{ .Vars.team }}",
+		"LEVEL":      "debug",
+	}, vars)
+	requires.NoError(err)
+	requires.Equal(map[string]string{"RUN_ID": "r1", "RESULT_DIR": vars.OutputDir + "/dumps", "TEAM": "billing", "LEVEL": "debug"}, rendered)
+	requires.Equal([]string{"LEVEL=debug", "RESULT_DIR=" + vars.OutputDir + "/dumps", "RUN_ID=r1", "TEAM=billing"}, standEnvironment(rendered))
+	requires.Nil(standEnvironment(nil))
+
+	_, err = renderStandEnvironment(map[string]string{"TEAM": "{{ .Vars.env }}"}, vars)
+	requires.ErrorContains(err, "env.environment: TEAM:")
+	rendered, err = renderStandEnvironment(nil, vars)
+	requires.NoError(err)
+	requires.Empty(rendered)
+}
+
+func TestDockerComposeEnvironment(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	envManager := DockerCompose{
+		workDir:        dir,
+		composeCommand: []string{"sh", "-c", `echo "$@ $RUN_ID" >> calls`, "sh"},
+		envFiles:       []string{"stand.env", "local.env"},
+		environment:    []string{"RUN_ID=r1"},
+	}
+	requires.NoError(envManager.start())
+	requires.NoError(envManager.stop())
+	calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+	requires.Equal("--env-file stand.env --env-file local.env up -d --remove-orphans r1\n--env-file stand.env --env-file local.env down r1\n", string(calls))
+
+	scheduler := App{}.tune(&Reporter{}, Config{Env: StandConfig{EnvFile: []string{"stand.env"}, Environment: map[string]string{"RUN_ID": "r2"}}}, Sources{})
+	requires.Equal([]string{"stand.env"}, scheduler.envManager.(DockerCompose).envFiles)
+	requires.Equal([]string{"RUN_ID=r2"}, scheduler.envManager.(DockerCompose).environment)
+
+	actions := newActions(Config{Env: StandConfig{EnvFile: []string{"stand.env"}, Environment: map[string]string{"RUN_ID": "r2"}}, ComposeCommand: []string{"docker", "compose"}, Actions: []ActionConfig{{Name: "restart", Compose: []string{"restart", "api"}}, {Name: "script", Command: []string{"./slow.sh"}}}})
+	requires.Equal([]string{"docker", "compose", "--env-file", "stand.env", "restart", "api"}, actions[0].command)
+	requires.Equal([]string{"RUN_ID=r2"}, actions[0].env)
+	requires.Nil(actions[1].env)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const controlSocket = "ctl.sock"
+
+var (
+	logLevels    = []string{"info", "debug"}
+	debugLogging atomic.Bool
+)
+
+func logLevel() string {
+	if debugLogging.Load() {
+		return "debug"
+	}
+	return "info"
+}
+
+func setLogLevel(level string) error {
+	if !slices.Contains(logLevels, level) {
+		return fmt.Errorf("unknown log level %s, expected one of %s", level, strings.Join(logLevels, ", "))
+	}
+	debugLogging.Store(level == "debug")
+	return nil
+}
+
+func debugf(format string, args ...any) {
+	if debugLogging.Load() {
+		log.Printf("DEBUG: "+format+"\n", args...)
+	}
+}
+
+type ControlStatusJSON struct {
+	RunID    string            `json:"runId"`
+	State    string            `json:"state"`
+	Started  time.Time         `json:"started"`
+	Scenario string            `json:"scenario,omitempty"`
+	Ticks    int               `json:"ticks"`
+	LogLevel string            `json:"logLevel"`
+	Latest   *MetricValuesJSON `json:"latest,omitempty"`
+}
+
+// ControlServer answers ctl commands of a running instance on a Unix
+// socket in its run directory.
+type ControlServer struct {
+	server   *http.Server
+	mutex    sync.Mutex
+	runID    string
+	started  time.Time
+	ticks    int
+	latest   *MetricValues
+	aborting bool
+	abort    context.CancelFunc
+}
+
+func startControl(path string, runID string, started time.Time, reporter *Reporter, abort context.CancelFunc) (*ControlServer, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	control := &ControlServer{runID: runID, started: started, abort: abort}
+	reporter.subscribe(control.observe)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", control.status)
+	mux.HandleFunc("POST /abort", control.abortRun)
+	mux.HandleFunc("POST /loglevel", control.setLogLevel)
+	control.server = &http.Server{Handler: mux}
+	log.Println("control socket:", path)
+	go func() {
+		if err := control.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Println("control error:", err)
+		}
+	}()
+	return control, nil
+}
+
+func (control *ControlServer) observe(values MetricValues) {
+	control.mutex.Lock()
+	defer control.mutex.Unlock()
+	control.ticks++
+	control.latest = &values
+}
+
+func (control *ControlServer) status(w http.ResponseWriter, r *http.Request) {
+	control.mutex.Lock()
+	defer control.mutex.Unlock()
+	status := ControlStatusJSON{RunID: control.runID, State: "running", Started: control.started, Ticks: control.ticks, LogLevel: logLevel()}
+	if control.aborting {
+		status.State = "aborting"
+	}
+	if control.latest != nil {
+		latest := metricValuesJSON(*control.latest)
+		status.Scenario = latest.Scenario
+		status.Latest = &latest
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (control *ControlServer) abortRun(w http.ResponseWriter, r *http.Request) {
+	control.mutex.Lock()
+	defer control.mutex.Unlock()
+	if !control.aborting {
+		log.Println("WARNING: abort requested over the control socket")
+		control.aborting = true
+		control.abort()
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"state": "aborting"})
+}
+
+func (control *ControlServer) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	level := r.URL.Query().Get("level")
+	if err := setLogLevel(level); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Println("log level:", level)
+	writeJSON(w, http.StatusOK, map[string]string{"logLevel": level})
+}
+
+func (control *ControlServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := control.server.Shutdown(ctx); err != nil {
+		log.Println("control error:", err)
+	}
+}
+
+// startControl listens on ctl.sock of the run directory; a socket that
+// cannot be created only costs the ctl commands, not the run.
+func (app App) startControl(dir string, runID string, started time.Time, reporter *Reporter, abort context.CancelFunc) func() {
+	path := app.controlSocket
+	if path == "" {
+		path = filepath.Join(dir, controlSocket)
+	}
+	control, err := startControl(path, runID, started, reporter, abort)
+	if err != nil {
+		log.Println("WARNING: control socket:", err)
+		return func() {}
+	}
+	return control.stop
+}
+
+// findControlSocket returns the socket of the latest started run in the
+// output directory that is still running.
+func findControlSocket(baseDir string) (string, error) {
+	sockets, err := filepath.Glob(filepath.Join(baseDir, "*", controlSocket))
+	if err != nil {
+		return "", err
+	}
+	latest, modified := "", time.Time{}
+	for _, socket := range sockets {
+		info, err := os.Stat(socket)
+		if err != nil || info.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+		if info.ModTime().After(modified) {
+			latest, modified = socket, info.ModTime()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no running instance in %s, use --socket", baseDir)
+	}
+	return latest, nil
+}
+
+func (app App) control(method string, path string, result any) error {
+	socket := app.controlSocket
+	if socket == "" {
+		config, err := app.optionalConfig()
+		if err != nil {
+			return err
+		}
+		found, err := findControlSocket(app.outputBaseDir(config))
+		if err != nil {
+			return err
+		}
+		socket = found
+	}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	req, err := http.NewRequest(method, "http://ctl"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", socket, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusBadRequest {
+		failure := map[string]string{}
+		_ = json.NewDecoder(resp.Body).Decode(&failure)
+		if failure["error"] != "" {
+			return errors.New(failure["error"])
+		}
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (app App) ctlStatus(w io.Writer) int {
+	status := ControlStatusJSON{}
+	if err := app.control(http.MethodGet, "/status", &status); err != nil {
+		log.Println(err)
+		return 1
+	}
+	fmt.Fprintln(w, "   runId:", status.RunID)
+	fmt.Fprintln(w, "   state:", status.State)
+	fmt.Fprintln(w, " started:", status.Started.Format(time.DateTime), "elapsed", time.Since(status.Started).Round(time.Second))
+	if status.Scenario != "" {
+		fmt.Fprintln(w, "scenario:", status.Scenario)
+	}
+	fmt.Fprintln(w, "   ticks:", status.Ticks)
+	fmt.Fprintln(w, "logLevel:", status.LogLevel)
+	if status.Latest != nil {
+		fmt.Fprintln(w, "  latest:", status.Latest.Timestamp.Format(time.DateTime))
+		for _, value := range status.Latest.Values {
+			fmt.Fprintf(w, "    %s: %d\n", value.Name, value.Value)
+		}
+		for _, violation := range status.Latest.Violations {
+			fmt.Fprintf(w, "    violation %s: %d\n", violation.Name, violation.Value)
+		}
+	}
+	return 0
+}
+
+func (app App) ctlAbort(w io.Writer) int {
+	result := map[string]string{}
+	if err := app.control(http.MethodPost, "/abort", &result); err != nil {
+		log.Println(err)
+		return 1
+	}
+	fmt.Fprintln(w, result["state"])
+	return 0
+}
+
+func (app App) ctlSetLogLevel(w io.Writer) int {
+	if len(app.args) != 1 {
+		log.Println("expected a log level:", strings.Join(logLevels, ", "))
+		return 2
+	}
+	result := map[string]string{}
+	if err := app.control(http.MethodPost, "/loglevel?level="+url.QueryEscape(app.args[0]), &result); err != nil {
+		log.Println(err)
+		return 1
+	}
+	fmt.Fprintln(w, "log level:", result["logLevel"])
+	return 0
+}
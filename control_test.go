@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestControl(t *testing.T) {
+	requires := require.New(t)
+	defer func() { _ = setLogLevel("info") }()
+	// a short directory, the path of a Unix socket is limited to about 100 bytes
+	base, err := os.MkdirTemp("", "ctl")
+	requires.NoError(err)
+	defer func() { _ = os.RemoveAll(base) }()
+	dir := filepath.Join(base, "r1")
+	requires.NoError(os.MkdirAll(dir, 0o755))
+
+	var b bytes.Buffer
+	requires.Equal(1, runMain([]string{"ctl", "status", "--output-dir", base}, &b))
+
+	reporter := &Reporter{}
+	started := time.Now().Add(-time.Hour).Truncate(time.Second)
+	ctx, abort := context.WithCancel(context.Background())
+	stop := App{}.startControl(dir, "r1", started, reporter, abort)
+	defer stop()
+	reporter.sendResult(MetricValues{scenario: "soak", timestamp: time.Now(),
+		values: []MetricValue{{name: "errors", value: 3}}, violations: []MetricValue{{name: "errors", value: 3}}})
+
+	requires.Equal(0, runMain([]string{"ctl", "status", "--output-dir", base}, &b))
+	requires.Contains(b.String(), "   runId: r1\n   state: running\n started: "+started.Format(time.DateTime)+" elapsed 1h")
+	requires.Contains(b.String(), "scenario: soak\n   ticks: 1\nlogLevel: info\n")
+	requires.Contains(b.String(), "    errors: 3\n    violation errors: 3\n")
+
+	configFile := filepath.Join(base, "config.yaml")
+	requires.NoError(os.WriteFile(configFile, []byte("outputDir: "+base+"\n"), 0o644))
+	b.Reset()
+	requires.Equal(0, runMain([]string{"ctl", "status", "--config", configFile}, &b))
+	requires.Contains(b.String(), "   runId: r1\n")
+
+	b.Reset()
+	socket := filepath.Join(dir, controlSocket)
+	requires.Equal(0, runMain([]string{"ctl", "set-loglevel", "--socket", socket, "debug"}, &b))
+	requires.Equal("log level: debug\n", b.String())
+	requires.Equal("debug", logLevel())
+	requires.Equal(1, runMain([]string{"ctl", "set-loglevel", "--socket", socket, "trace"}, &b))
+	requires.Equal(2, runMain([]string{"ctl", "set-loglevel", "--socket", socket}, &b))
+	requires.Equal("debug", logLevel())
+
+	b.Reset()
+	requires.NoError(ctx.Err())
+	requires.Equal(0, runMain([]string{"ctl", "abort", "--socket", socket}, &b))
+	requires.Equal("aborting\n", b.String())
+	requires.ErrorIs(ctx.Err(), context.Canceled)
+	b.Reset()
+	requires.Equal(0, runMain([]string{"ctl", "status", "--socket", socket}, &b))
+	requires.Contains(b.String(), "   state: aborting\n")
+
+	stop()
+	requires.NoFileExists(socket)
+	requires.Equal(1, runMain([]string{"ctl", "status", "--output-dir", base}, &b))
+}
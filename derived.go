@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// DerivedExpr is an arithmetic expression over the values of other metrics
+// of the same tick: numbers, metric names, + - * / and parentheses.
+type DerivedExpr struct {
+	op    byte
+	value float64
+	ref   string
+	left  *DerivedExpr
+	right *DerivedExpr
+}
+
+type derivedParser struct {
+	text string
+	pos  int
+}
+
+func parseDerivedExpr(text string) (*DerivedExpr, error) {
+	parser := &derivedParser{text: text}
+	expr, err := parser.sum()
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", text, err)
+	}
+	if parser.skipSpaces(); parser.pos < len(parser.text) {
+		return nil, fmt.Errorf("expression %q: unexpected %q at %d", text, parser.text[parser.pos:], parser.pos+1)
+	}
+	return expr, nil
+}
+
+func (parser *derivedParser) skipSpaces() {
+	for parser.pos < len(parser.text) && (parser.text[parser.pos] == ' ' || parser.text[parser.pos] == '\t') {
+		parser.pos++
+	}
+}
+
+func (parser *derivedParser) peek() byte {
+	parser.skipSpaces()
+	if parser.pos < len(parser.text) {
+		return parser.text[parser.pos]
+	}
+	return 0
+}
+
+func (parser *derivedParser) sum() (*DerivedExpr, error) {
+	left, err := parser.product()
+	for err == nil && (parser.peek() == '+' || parser.peek() == '-') {
+		op := parser.text[parser.pos]
+		parser.pos++
+		var right *DerivedExpr
+		if right, err = parser.product(); err == nil {
+			left = &DerivedExpr{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (parser *derivedParser) product() (*DerivedExpr, error) {
+	left, err := parser.operand()
+	for err == nil && (parser.peek() == '*' || parser.peek() == '/') {
+		op := parser.text[parser.pos]
+		parser.pos++
+		var right *DerivedExpr
+		if right, err = parser.operand(); err == nil {
+			left = &DerivedExpr{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func isDerivedName(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && (c == '.' || c == ':' || c >= '0' && c <= '9')
+}
+
+func (parser *derivedParser) operand() (*DerivedExpr, error) {
+	c := parser.peek()
+	start := parser.pos
+	switch {
+	case c == '(':
+		parser.pos++
+		expr, err := parser.sum()
+		if err != nil {
+			return nil, err
+		}
+		if parser.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at %d", parser.pos+1)
+		}
+		parser.pos++
+		return expr, nil
+	case c == '-':
+		parser.pos++
+		operand, err := parser.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &DerivedExpr{op: '-', left: &DerivedExpr{}, right: operand}, nil
+	case c >= '0' && c <= '9' || c == '.':
+		for parser.pos < len(parser.text) && (parser.text[parser.pos] >= '0' && parser.text[parser.pos] <= '9' || parser.text[parser.pos] == '.') {
+			parser.pos++
+		}
+		value, err := strconv.ParseFloat(parser.text[start:parser.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", parser.text[start:parser.pos])
+		}
+		return &DerivedExpr{value: value}, nil
+	case isDerivedName(c, true):
+		for parser.pos < len(parser.text) && isDerivedName(parser.text[parser.pos], false) {
+			parser.pos++
+		}
+		return &DerivedExpr{ref: parser.text[start:parser.pos]}, nil
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", string(c), parser.pos+1)
+}
+
+func (expr *DerivedExpr) refs() []string {
+	switch {
+	case expr.ref != "":
+		return []string{expr.ref}
+	case expr.op != 0:
+		return append(expr.left.refs(), expr.right.refs()...)
+	}
+	return nil
+}
+
+// eval has no value when a referenced metric has none or on division by zero.
+func (expr *DerivedExpr) eval(value func(name string) (float64, bool)) (float64, bool) {
+	if expr.ref != "" {
+		return value(expr.ref)
+	}
+	if expr.op == 0 {
+		return expr.value, true
+	}
+	left, ok := expr.left.eval(value)
+	if !ok {
+		return 0, false
+	}
+	right, ok := expr.right.eval(value)
+	if !ok {
+		return 0, false
+	}
+	switch expr.op {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	}
+	if right == 0 {
+		return 0, false
+	}
+	return left / right, true
+}
+
+// DeriverInt gets the exact results of the derived metrics before it, so an
+// expression over a derived metric doesn't compute on its rounded value.
+type DeriverInt interface {
+	derive(values []MetricValue, exact map[string]float64) int
+}
+
+func deriver(metric MetricGather) (DeriverInt, bool) {
+	if labeled, ok := metric.(LabeledMetric); ok {
+		metric = labeled.MetricGather
+	}
+	derived, ok := metric.(DeriverInt)
+	return derived, ok
+}
+
+type DerivedMetric struct {
+	Name       string
+	Expression *DerivedExpr
+	OnMissing  string
+	MaxValue   int
+	Scale      float64
+}
+
+func (metric DerivedMetric) name() string {
+	return metric.Name
+}
+
+func (metric DerivedMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+// gather has nothing to query, a derived metric gets its value from derive
+// once the other metrics of the tick are gathered.
+func (metric DerivedMetric) gather(ctx context.Context) int {
+	return -1
+}
+
+// derive rounds the result times scale only for the value of the tick, e.g.
+// scale 10000 keeps a ratio of errors / requests in basis points.
+func (metric DerivedMetric) derive(values []MetricValue, exact map[string]float64) int {
+	if metric.Expression == nil {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	result, ok := metric.Expression.eval(func(name string) (float64, bool) {
+		if value, ok := exact[name]; ok {
+			return value, true
+		}
+		for _, value := range values {
+			if value.name == name && hasValue(value.value) {
+				return float64(value.value), true
+			}
+		}
+		return 0, false
+	})
+	if !ok || math.IsInf(result, 0) || math.IsNaN(result) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if exact != nil {
+		exact[metric.Name] = result
+	}
+	scale := metric.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return int(math.Round(result * scale))
+}
+
+// validateDerivedMetrics checks the expressions of one metrics list; a
+// derived metric may use derived metrics defined before it.
+func validateDerivedMetrics(metrics []Metric) error {
+	names := map[string]bool{}
+	derived := map[string]bool{}
+	for _, metric := range metrics {
+		names[metric.Name] = true
+		if metric.Type == "derived" {
+			derived[metric.Name] = true
+		}
+	}
+	defined := map[string]bool{}
+	for _, metric := range metrics {
+		if metric.Type != "derived" {
+			continue
+		}
+		defined[metric.Name] = true
+		if metric.Scale < 0 {
+			return fmt.Errorf("metric %s: scale must not be negative", metric.Name)
+		}
+		expr, err := parseDerivedExpr(metric.Query)
+		if err != nil {
+			return fmt.Errorf("metric %s: %w", metric.Name, err)
+		}
+		refs := expr.refs()
+		if len(refs) == 0 {
+			return fmt.Errorf("metric %s: expression %q uses no metric", metric.Name, metric.Query)
+		}
+		for _, ref := range refs {
+			switch {
+			case ref == metric.Name:
+				return fmt.Errorf("metric %s: expression references itself", metric.Name)
+			case !names[ref]:
+				return fmt.Errorf("metric %s: expression references unknown metric %s", metric.Name, ref)
+			case derived[ref] && !defined[ref]:
+				return fmt.Errorf("metric %s: derived metric %s must be defined before it", metric.Name, ref)
+			}
+		}
+	}
+	return nil
+}
+
+func newDerivedMetric(metric Metric) DerivedMetric {
+	expr, _ := parseDerivedExpr(metric.Query)
+	return DerivedMetric{Name: metric.Name, Expression: expr, OnMissing: metric.OnMissing, MaxValue: metric.MaxValue, Scale: metric.Scale}
+}
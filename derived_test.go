@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerivedExpr(t *testing.T) {
+	requires := require.New(t)
+	values := map[string]float64{"errors": 5, "requests": 200, "limit": 1024, "usage": 800, "zero": 0}
+	lookup := func(name string) (float64, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+	variants := []struct {
+		expr  string
+		value float64
+		ok    bool
+		err   string
+	}{
+		{expr: "errors / requests", value: 0.025, ok: true},
+		{expr: "100 * errors / requests", value: 2.5, ok: true},
+		{expr: "limit - usage", value: 224, ok: true},
+		{expr: "(limit - usage) * 100 / limit", value: 21.875, ok: true},
+		{expr: "-errors + 10", value: 5, ok: true},
+		{expr: "2 + 3 * 4 - 6 / 2", value: 11, ok: true},
+		{expr: "errors / zero"},
+		{expr: "errors / missing"},
+		{expr: "errors /", err: `expression "errors /": unexpected end`},
+		{expr: "(errors", err: "missing ) at 8"},
+		{expr: "errors requests", err: `unexpected "requests" at 8`},
+		{expr: "errors % 2", err: `unexpected "% 2" at 8`},
+		{expr: "1..2", err: `invalid number "1..2"`},
+	}
+	for _, variant := range variants {
+		expr, err := parseDerivedExpr(variant.expr)
+		if variant.err != "" {
+			requires.ErrorContains(err, variant.err, variant.expr)
+			continue
+		}
+		requires.NoError(err, variant.expr)
+		value, ok := expr.eval(lookup)
+		requires.Equal(variant.ok, ok, variant.expr)
+		requires.InDelta(variant.value, value, 1e-9, variant.expr)
+	}
+}
+
+func TestDerivedMetric(t *testing.T) {
+	requires := require.New(t)
+	metrics := []Metric{
+		{Name: "errors", Query: "errors"},
+		{Name: "requests", Query: "requests"},
+		{Name: "error_pct", Type: "derived", Query: "100 * errors / requests", MaxValue: 1, Labels: map[string]string{"team": "core"}},
+		{Name: "bad_pct", Type: "derived", Query: "error_pct * 2", MaxValue: 100},
+	}
+	gathers := newMetricGathers(Sources{}, metrics)
+	gatherer := Gatherer{
+		metrics:  []MetricGather{ValueMetricGather{metricName: "errors", value: 3, max: 10}, ValueMetricGather{metricName: "requests", value: 200, max: 1000}, gathers[2], gathers[3]},
+		relative: map[string]RelativeThreshold{},
+		budget:   NewViolationBudget(),
+	}
+	values, ok := gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.False(ok)
+	requires.Equal([]MetricValue{
+		{name: "errors", value: 3},
+		{name: "requests", value: 200},
+		{name: "error_pct", value: 2, labels: map[string]string{"team": "core"}},
+		{name: "bad_pct", value: 3},
+	}, values.values)
+	requires.Equal([]MetricValue{{name: "error_pct", value: 2, labels: map[string]string{"team": "core"}}}, values.violations)
+
+	gatherer.metrics[1] = ValueMetricGather{metricName: "requests", value: 0, max: 1000}
+	values, ok = gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.True(ok)
+	requires.Equal(-1, values.values[2].value)
+	requires.Equal(-1, values.values[3].value)
+	requires.Equal(missingValue, DerivedMetric{Name: "a", OnMissing: "fail"}.derive(nil, nil))
+
+	ratios := newMetricGathers(Sources{}, []Metric{
+		{Name: "ratio", Type: "derived", Query: "errors / requests"},
+		{Name: "ratio_bp", Type: "derived", Query: "errors / requests", Scale: 10000},
+		{Name: "ratio_pct", Type: "derived", Query: "ratio * 100", Scale: 10},
+	})
+	gatherer.metrics = append([]MetricGather{ValueMetricGather{metricName: "errors", value: 3, max: 10}, ValueMetricGather{metricName: "requests", value: 200, max: 1000}}, ratios...)
+	values, _ = gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.Equal([]int{0, 150, 15}, []int{values.values[2].value, values.values[3].value, values.values[4].value})
+
+	variants := []struct {
+		metrics []Metric
+		err     string
+	}{
+		{metrics: []Metric{{Name: "a", Type: "derived", Query: "a + 1"}}, err: "metric a: expression references itself"},
+		{metrics: []Metric{{Name: "a", Type: "derived", Query: "b * 2"}}, err: "metric a: expression references unknown metric b"},
+		{metrics: []Metric{{Name: "a", Type: "derived", Query: "42"}}, err: `metric a: expression "42" uses no metric`},
+		{metrics: []Metric{{Name: "a", Type: "derived", Query: "b +"}}, err: `metric a: expression "b +": unexpected end`},
+		{metrics: []Metric{{Name: "a", Type: "derived", Query: "b"}, {Name: "b", Type: "derived", Query: "c"}, {Name: "c", Query: "c"}}, err: "metric a: derived metric b must be defined before it"},
+		{metrics: []Metric{{Name: "a", Type: "derived", Query: "b / 2", Scale: -1}, {Name: "b", Query: "b"}}, err: "metric a: scale must not be negative"},
+	}
+	for _, variant := range variants {
+		requires.ErrorContains(validateMetricTypes(Config{Metrics: variant.metrics}), variant.err)
+	}
+	requires.NoError(validateMetricTypes(Config{Metrics: metrics}))
+	requires.ErrorContains(validateMetricTypes(Config{
+		Metrics:   metrics[:3],
+		Scenarios: []Scenario{{Name: "s", Metrics: []Metric{{Name: "requests", Type: "derived", Query: "rps * 60"}}}},
+	}), "scenario s: metric requests: expression references unknown metric rps")
+	requires.ErrorContains(validateStartupProbes(Config{StartupProbes: []StartupProbe{{Metric: metrics[2]}}}), "startupProbes: probe error_pct: derived metrics are not supported")
+}
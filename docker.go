@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type DockerConfig struct {
+	Host    string `yaml:"host"`
+	Project string `yaml:"project"`
+}
+
+type DockerAPI struct {
+	client  *http.Client
+	baseURL string
+	project string
+}
+
+func NewDockerAPI(host string, project string) *DockerAPI {
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	api := &DockerAPI{client: &http.Client{Timeout: 30 * time.Second}, baseURL: host, project: project}
+	if socket, ok := strings.CutPrefix(host, "unix://"); ok {
+		api.baseURL = "http://docker"
+		api.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+	} else if address, ok := strings.CutPrefix(host, "tcp://"); ok {
+		api.baseURL = "http://" + address
+	}
+	return api
+}
+
+func (api *DockerAPI) get(ctx context.Context, path string, value any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker api %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+type DockerContainer struct {
+	ID string `json:"Id"`
+}
+
+func (api *DockerAPI) serviceContainers(ctx context.Context, service string) ([]DockerContainer, error) {
+	labels := []string{"com.docker.compose.service=" + service}
+	if api.project != "" {
+		labels = append(labels, "com.docker.compose.project="+api.project)
+	}
+	filters, err := json.Marshal(map[string][]string{"label": labels})
+	if err != nil {
+		return nil, err
+	}
+	containers := []DockerContainer{}
+	if err := api.get(ctx, "/containers/json?filters="+url.QueryEscape(string(filters)), &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+type DockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage uint64 `json:"total_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint64 `json:"online_cpus"`
+}
+
+type DockerStats struct {
+	CPUStats    DockerCPUStats `json:"cpu_stats"`
+	PreCPUStats DockerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+}
+
+func (stats DockerStats) value(stat string) (float64, error) {
+	switch stat {
+	case "cpu":
+		cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+		systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+		if systemDelta <= 0 || cpuDelta < 0 {
+			return 0, nil
+		}
+		return cpuDelta / systemDelta * float64(stats.CPUStats.OnlineCPUs) * 100, nil
+	case "memory":
+		cache := stats.MemoryStats.Stats["inactive_file"]
+		if cache > stats.MemoryStats.Usage {
+			cache = 0
+		}
+		return float64(stats.MemoryStats.Usage - cache), nil
+	case "network_rx", "network_tx":
+		sum := 0.0
+		for _, network := range stats.Networks {
+			if stat == "network_rx" {
+				sum += float64(network.RxBytes)
+			} else {
+				sum += float64(network.TxBytes)
+			}
+		}
+		return sum, nil
+	}
+	return 0, fmt.Errorf("unknown docker stat %s", stat)
+}
+
+type DockerMetric struct {
+	api      *DockerAPI
+	Name     string
+	Service  string
+	Stat     string
+	MaxValue int
+}
+
+func (metric DockerMetric) name() string {
+	return metric.Name
+}
+
+func (metric DockerMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric DockerMetric) query(ctx context.Context) (float64, error) {
+	containers, err := metric.api.serviceContainers(ctx, metric.Service)
+	if err != nil {
+		return 0, err
+	}
+	if len(containers) == 0 {
+		return 0, fmt.Errorf("no containers of service %s", metric.Service)
+	}
+	sum := 0.0
+	for _, container := range containers {
+		stats := DockerStats{}
+		if err := metric.api.get(ctx, "/containers/"+container.ID+"/stats?stream=false", &stats); err != nil {
+			return 0, err
+		}
+		value, err := stats.value(metric.Stat)
+		if err != nil {
+			return 0, err
+		}
+		sum += value
+	}
+	return sum, nil
+}
+
+func (metric DockerMetric) gather(ctx context.Context) int {
+	value, err := metric.query(ctx)
+	if err != nil {
+		log.Printf("Error querying Docker: %v\n", err)
+		return -1
+	}
+	return int(value)
+}
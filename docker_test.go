@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const dockerStatsJSON = `{
+	"cpu_stats": {"cpu_usage": {"total_usage": 3000}, "system_cpu_usage": 20000, "online_cpus": 2},
+	"precpu_stats": {"cpu_usage": {"total_usage": 1000}, "system_cpu_usage": 10000},
+	"memory_stats": {"usage": 1000, "stats": {"inactive_file": 200}},
+	"networks": {"eth0": {"rx_bytes": 10, "tx_bytes": 20}, "eth1": {"rx_bytes": 1, "tx_bytes": 2}}
+}`
+
+func dockerHandler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		filters := map[string][]string{}
+		require.NoError(t, json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters))
+		if filters["label"][0] != "com.docker.compose.service=api" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		require.Equal(t, []string{"com.docker.compose.service=api", "com.docker.compose.project=stand"}, filters["label"])
+		_, _ = w.Write([]byte(`[{"Id": "c1"}, {"Id": "c2"}]`))
+	})
+	mux.HandleFunc("GET /containers/{id}/stats", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "false", r.URL.Query().Get("stream"))
+		_, _ = w.Write([]byte(dockerStatsJSON))
+	})
+	return mux
+}
+
+func TestDockerMetricGather(t *testing.T) {
+	variants := []struct {
+		service string
+		stat    string
+		value   int
+	}{
+		{service: "api", stat: "cpu", value: 80},
+		{service: "api", stat: "memory", value: 1600},
+		{service: "api", stat: "network_rx", value: 22},
+		{service: "api", stat: "network_tx", value: 44},
+		{service: "api", stat: "disk", value: -1},
+		{service: "db", stat: "cpu", value: -1},
+	}
+	requires := require.New(t)
+	server := httptest.NewServer(dockerHandler(t))
+	defer server.Close()
+	api := NewDockerAPI(server.URL, "stand")
+	for n, variant := range variants {
+		metric := DockerMetric{api: api, Name: "a", Service: variant.service, Stat: variant.stat}
+		requires.Equal(variant.value, metric.gather(context.Background()), n)
+	}
+}
+
+func TestDockerAPIUnixSocket(t *testing.T) {
+	requires := require.New(t)
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	requires.NoError(err)
+	server := httptest.NewUnstartedServer(dockerHandler(t))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+	metric := DockerMetric{api: NewDockerAPI("unix://"+socket, "stand"), Name: "a", Service: "api", Stat: "memory"}
+	requires.Equal(1600, metric.gather(context.Background()))
+}
+
+func TestNewDockerAPI(t *testing.T) {
+	requires := require.New(t)
+	requires.Equal("http://docker", NewDockerAPI("", "").baseURL)
+	requires.Equal("http://remote:2375", NewDockerAPI("tcp://remote:2375", "").baseURL)
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Type: "docker", Query: "cpu"}}}))
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Type: "docker", Service: "api", Query: "disk"}}}))
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Type: "docker", Service: "api", Query: "cpu"}}}))
+}
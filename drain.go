@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const drainScenario = "drain"
+
+type DrainPending struct {
+	Metric string `json:"metric"`
+	Value  int    `json:"value"`
+	Limit  int    `json:"limit"`
+}
+
+type DrainResult struct {
+	Seconds float64        `json:"seconds"`
+	Limit   float64        `json:"limit"`
+	Drained bool           `json:"drained"`
+	Pending []DrainPending `json:"pending,omitempty"`
+}
+
+type Drain struct {
+	mutex    sync.Mutex
+	duration time.Duration
+	eventer  EventerInt
+	limits   map[string]int
+	started  time.Time
+	elapsed  time.Duration
+	drained  bool
+	pending  []DrainPending
+	stop     context.CancelFunc
+}
+
+func drainLimits(metrics []Metric) map[string]int {
+	limits := map[string]int{}
+	for _, metric := range metrics {
+		if metric.DrainMaxValue != nil {
+			limits[metric.Name] = *metric.DrainMaxValue
+		}
+	}
+	return limits
+}
+
+func (drain *Drain) start(stop context.CancelFunc, now time.Time) {
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+	drain.started = now
+	drain.stop = stop
+}
+
+func (drain *Drain) observe(values MetricValues) {
+	pending := make([]DrainPending, 0)
+	for _, value := range values.values {
+		if limit, ok := drain.limits[value.name]; ok && (!hasValue(value.value) || value.value > limit) {
+			pending = append(pending, DrainPending{Metric: value.name, Value: value.value, Limit: limit})
+		}
+	}
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+	drain.pending = pending
+	if len(drain.limits) == 0 || len(pending) > 0 || drain.drained {
+		return
+	}
+	drain.drained = true
+	drain.elapsed = values.timestamp.Sub(drain.started)
+	log.Println("drained after", drain.elapsed.Round(time.Second))
+	if drain.stop != nil {
+		drain.stop()
+	}
+}
+
+func (drain *Drain) finish(now time.Time) bool {
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+	if !drain.drained {
+		drain.elapsed = now.Sub(drain.started)
+	}
+	if drain.drained || len(drain.limits) == 0 {
+		return true
+	}
+	for _, pending := range drain.pending {
+		log.Println(" metric("+pending.Metric+"):", pending.Value, ">", pending.Limit, "after drain of", drain.duration)
+	}
+	return false
+}
+
+func (drain *Drain) result() *DrainResult {
+	if drain == nil {
+		return nil
+	}
+	drain.mutex.Lock()
+	defer drain.mutex.Unlock()
+	if drain.started.IsZero() {
+		return nil
+	}
+	result := &DrainResult{
+		Seconds: drain.elapsed.Seconds(),
+		Limit:   drain.duration.Seconds(),
+		Drained: drain.drained || len(drain.limits) == 0,
+	}
+	if !result.Drained {
+		result.Pending = slices.Clone(drain.pending)
+	}
+	return result
+}
+
+func (scheduler *Scheduler) runDrain() {
+	drain := scheduler.drain
+	if drain == nil || scheduler.status != 0 || scheduler.context().Err() != nil {
+		return
+	}
+	log.Println("=[ drain ]=============================")
+	parent := scheduler.ctx
+	ctx, cancel := context.WithCancel(scheduler.context())
+	defer cancel()
+	drain.start(cancel, clockOf(scheduler.clock).now())
+	scheduler.ctx = ctx
+	scheduler.progress.setScenario(drainScenario)
+	scheduler.scenario = drainScenario
+	scheduler.eventer = drain.eventer
+	scheduler.testDuration = drain.duration
+	scheduler.loop()
+	scheduler.ctx = parent
+	if !drain.finish(clockOf(scheduler.clock).now()) {
+		scheduler.markViolated()
+	}
+}
+
+func validateDrain(config Config) error {
+	if config.DrainDuration < 0 {
+		return fmt.Errorf("drainDuration must not be negative")
+	}
+	for _, metric := range config.Metrics {
+		if metric.DrainMaxValue != nil && config.DrainDuration == 0 {
+			return fmt.Errorf("metric %s: drainMaxValue needs drainDuration", metric.Name)
+		}
+	}
+	for _, scenario := range config.Scenarios {
+		for _, metric := range scenario.Metrics {
+			if metric.DrainMaxValue != nil {
+				return fmt.Errorf("scenario %s: metric %s: drainMaxValue is only supported on top-level metrics", scenario.Name, metric.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func renderDrain(result *DrainResult) string {
+	b := strings.Builder{}
+	b.WriteString("## Drain\n\n")
+	if result.Drained {
+		fmt.Fprintf(&b, "drained after %.1fs of %.1fs\n\n", result.Seconds, result.Limit)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "not drained after %.1fs\n\n| metric | value | drainMaxValue |\n|---|---|---|\n", result.Limit)
+	for _, pending := range result.Pending {
+		fmt.Fprintf(&b, "| %s | %d | %d |\n", pending.Metric, pending.Value, pending.Limit)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type SequenceMetricGather struct {
+	mutex      sync.Mutex
+	metricName string
+	values     []int
+	max        int
+}
+
+func (m *SequenceMetricGather) name() string  { return m.metricName }
+func (m *SequenceMetricGather) maxValue() int { return m.max }
+func (m *SequenceMetricGather) gather(ctx context.Context) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	value := m.values[0]
+	if len(m.values) > 1 {
+		m.values = m.values[1:]
+	}
+	return value
+}
+
+func drainScheduler(reporter *Reporter, duration time.Duration, values []int) *Scheduler {
+	drain := &Drain{duration: duration, limits: map[string]int{"queue": 10}}
+	scheduler := &Scheduler{eventer: &FakeEventer{}, testDuration: 50 * time.Millisecond, timeout: 20 * time.Millisecond, drain: drain}
+	drain.eventer = &Eventer{
+		scenario: drainScenario,
+		reporter: reporter,
+		gatherer: Gatherer{
+			metrics:   []MetricGather{&SequenceMetricGather{metricName: "queue", values: values, max: 1}, ValueMetricGather{metricName: "errors", value: 0, max: 1}},
+			unchecked: map[string]bool{"queue": true},
+		},
+		stoper:   func() { scheduler.sendDown() },
+		violated: func() { scheduler.markViolated() },
+		observed: drain.observe,
+	}
+	return scheduler
+}
+
+func TestSchedulerDrain(t *testing.T) {
+	requires := require.New(t)
+	reporter := &Reporter{}
+	scheduler := drainScheduler(reporter, time.Minute, []int{50, 20, 5})
+	started := time.Now()
+	scheduler.run()
+	requires.Less(time.Since(started), 10*time.Second)
+	requires.False(scheduler.failed())
+	values := reporter.snapshot()
+	requires.Len(values, 3)
+	for _, value := range values {
+		requires.Equal(drainScenario, value.scenario)
+		requires.Empty(value.violations)
+	}
+	result := scheduler.drain.result()
+	requires.True(result.Drained)
+	requires.Equal(60.0, result.Limit)
+	requires.Empty(result.Pending)
+
+	reporter = &Reporter{}
+	scheduler = drainScheduler(reporter, 100*time.Millisecond, []int{50, 40})
+	scheduler.run()
+	requires.True(scheduler.violated)
+	requires.Equal(0, scheduler.status)
+	result = scheduler.drain.result()
+	requires.False(result.Drained)
+	requires.Equal([]DrainPending{{Metric: "queue", Value: 40, Limit: 10}}, result.Pending)
+	requires.Contains(renderDrain(result), "not drained after 0.1s\n\n| metric | value | drainMaxValue |\n|---|---|---|\n| queue | 40 | 10 |\n")
+	requires.Equal("## Drain\n\ndrained after 12.0s of 60.0s\n\n", renderDrain(&DrainResult{Seconds: 12, Limit: 60, Drained: true}))
+
+	scheduler = drainScheduler(&Reporter{}, time.Minute, []int{50})
+	scheduler.status = 1
+	scheduler.run()
+	requires.Nil(scheduler.drain.result())
+	requires.Nil((*Drain)(nil).result())
+}
+
+func TestAppTuneDrain(t *testing.T) {
+	requires := require.New(t)
+	limit := 10
+	config := Config{
+		TestDuration:  Duration(time.Minute),
+		DrainDuration: Duration(2 * time.Minute),
+		Metrics:       []Metric{{Name: "queue", MaxValue: 1000, DrainMaxValue: &limit}, {Name: "errors", MaxValue: 1}},
+	}
+	scheduler := App{envManager: &FakeEnvManager{}}.tune(&Reporter{}, config, Sources{})
+	requires.Equal(map[string]int{"queue": 10}, scheduler.drain.limits)
+	requires.Equal(3*time.Minute, scheduler.plannedDuration())
+	gatherer := scheduler.drain.eventer.(*Eventer).gatherer.(Gatherer)
+	requires.Equal(map[string]bool{"queue": true}, gatherer.unchecked)
+	_, ok := gatherer.limit(gatherer.metrics[0], nil)
+	requires.False(ok)
+	limitValue, ok := gatherer.limit(gatherer.metrics[1], nil)
+	requires.True(ok)
+	requires.Equal(1.0, limitValue)
+
+	requires.Nil(App{envManager: &FakeEnvManager{}}.tune(&Reporter{}, Config{}, Sources{}).drain)
+}
+
+func TestValidateDrain(t *testing.T) {
+	requires := require.New(t)
+	limit := 0
+	requires.NoError(validateDrain(Config{DrainDuration: Duration(time.Minute), Metrics: []Metric{{Name: "queue", DrainMaxValue: &limit}}}))
+	requires.ErrorContains(validateDrain(Config{DrainDuration: Duration(-time.Second)}), "drainDuration must not be negative")
+	requires.ErrorContains(validateDrain(Config{Metrics: []Metric{{Name: "queue", DrainMaxValue: &limit}}}), "metric queue: drainMaxValue needs drainDuration")
+	requires.ErrorContains(validateDrain(Config{
+		DrainDuration: Duration(time.Minute),
+		Scenarios:     []Scenario{{Name: "ramp", Metrics: []Metric{{Name: "queue", DrainMaxValue: &limit}}}},
+	}), "scenario ramp: metric queue: drainMaxValue is only supported on top-level metrics")
+
+	config, err := App{}.parseConfig([]byte("drainDuration: 2m\nmetrics: [{name: queue, maxValue: 1000, drainMaxValue: 0}]"))
+	requires.NoError(err)
+	requires.Equal(Duration(2*time.Minute), config.DrainDuration)
+	requires.Equal(0, *config.Metrics[0].DrainMaxValue)
+}
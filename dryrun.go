@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func planMetrics(w io.Writer, metrics []Metric) {
+	for _, metric := range metrics {
+		kind := metric.Type
+		if kind == "" {
+			kind = "prometheus"
+		}
+		maxValue := strconv.Itoa(metric.MaxValue)
+		if metric.RelativeMax != nil {
+			maxValue = metric.RelativeMax.String()
+		}
+		if budgetOnly([]Metric{metric})[metric.Name] {
+			maxValue = "-"
+		}
+		if metric.MaxValueQuery != "" {
+			fmt.Fprintf(w, "    %s (%s) %s maxValueQuery %s", metric.Name, kind, metric.Query, metric.MaxValueQuery)
+		} else {
+			fmt.Fprintf(w, "    %s (%s) %s maxValue %s", metric.Name, kind, metric.Query, maxValue)
+		}
+		if metric.OnMissing != "" {
+			fmt.Fprintf(w, " onMissing %s", metric.OnMissing)
+		}
+		if metric.AllowedViolations > 0 {
+			fmt.Fprintf(w, " allowedViolations %d", metric.AllowedViolations)
+		}
+		if metric.RecoverTicks > 0 {
+			fmt.Fprintf(w, " recoverTicks %d", metric.RecoverTicks)
+		}
+		if metric.Anomaly != nil {
+			sigma := metric.Anomaly.Sigma
+			if sigma <= 0 {
+				sigma = defaultAnomalySigma
+			}
+			fmt.Fprintf(w, " anomaly %g sigma after %s", sigma, metric.Anomaly.Baseline)
+		}
+		if len(metric.Assertions) > 0 {
+			fmt.Fprintf(w, " assertions %s", strings.Join(metric.Assertions, ", "))
+		}
+		if len(metric.RangeAssertions) > 0 {
+			fmt.Fprintf(w, " rangeAssertions %s", strings.Join(metric.RangeAssertions, ", "))
+		}
+		if metric.FailOnWarnings {
+			fmt.Fprint(w, " failOnWarnings")
+		}
+		if len(metric.Budget) > 0 {
+			budget := make([]string, 0, len(metric.Budget))
+			for _, assertion := range metric.Budget {
+				budget = append(budget, assertion.String())
+			}
+			fmt.Fprintf(w, " budget %s", strings.Join(budget, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func (app App) plan(ctx context.Context, config Config, w io.Writer) error {
+	config, _, err := app.prepare(config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "=[ plan ]==============================")
+	fmt.Fprintln(w, "stand:", config.WorkDir)
+	if app.envManager != nil {
+		fmt.Fprintf(w, "  %T\n", app.envManager)
+	} else if config.EnvManager == "ssh" {
+		env := newSSHEnv(config, 0)
+		fmt.Fprintln(w, "  host:", env.user+"@"+env.addr)
+		for _, command := range env.startCommands {
+			fmt.Fprintln(w, "  start:", env.remoteCommand(command))
+		}
+		for _, command := range env.stopCommands {
+			fmt.Fprintln(w, "  stop: ", env.remoteCommand(command))
+		}
+	} else if len(config.Environments) > 0 {
+		for n, env := range newEnvironments(config, 0).environments {
+			fmt.Fprintf(w, "  %d. %s\n", n+1, env.name)
+			switch manager := env.manager.(type) {
+			case DockerCompose:
+				for _, command := range manager.startCommands() {
+					fmt.Fprintln(w, "     start:", strings.Join(command, " "))
+				}
+				fmt.Fprintln(w, "     stop: ", strings.Join(manager.compose(manager.down.args()...), " "))
+			case CommandEnv:
+				fmt.Fprintln(w, "     start:", strings.Join(manager.command, " "))
+				if len(manager.stopCmd) > 0 {
+					fmt.Fprintln(w, "     stop: ", strings.Join(manager.stopCmd, " "))
+				}
+			}
+			if env.ready != nil && env.ready.url != "" {
+				fmt.Fprintln(w, "     ready:", env.ready.url)
+			} else if env.ready != nil {
+				fmt.Fprintln(w, "     ready:", strings.Join(env.ready.command, " "))
+			}
+		}
+	} else {
+		compose := DockerCompose{
+			workDir:        config.WorkDir,
+			composeCommand: config.ComposeCommand,
+			pull:           config.Env.Pull == "always",
+			build:          config.Env.Build,
+			envFiles:       config.Env.EnvFile,
+			down:           config.Env.Down,
+		}
+		for _, command := range compose.startCommands() {
+			fmt.Fprintln(w, "  start:", strings.Join(command, " "))
+		}
+		fmt.Fprintln(w, "  stop: ", strings.Join(compose.compose(compose.down.args()...), " "))
+		for _, value := range standEnvironment(config.Env.Environment) {
+			fmt.Fprintln(w, "  env:  ", value)
+		}
+	}
+	if config.KeepEnvironment {
+		fmt.Fprintln(w, "  kept running when thresholds are violated")
+	}
+	fmt.Fprintln(w, "schedule:")
+	fmt.Fprintln(w, "  startDelay: ", config.StartDelay)
+	fmt.Fprintln(w, "  tick every: ", config.Timeout)
+	if config.TickTimeout > 0 {
+		fmt.Fprintln(w, "  tickTimeout:", config.TickTimeout)
+	}
+	if config.Jitter > 0 {
+		fmt.Fprintln(w, "  jitter:     ", config.Jitter)
+	}
+	if config.QueryLimits.MaxConcurrentQueries > 0 {
+		fmt.Fprintln(w, "  queries:    ", config.QueryLimits.MaxConcurrentQueries, "at once")
+	}
+	if config.QueryLimits.QueryRateLimit > 0 {
+		fmt.Fprintln(w, "  query rate: ", config.QueryLimits.QueryRateLimit, "per second")
+	}
+	if len(config.Scenarios) == 0 && config.Iterations > 0 {
+		fmt.Fprintln(w, "  iterations: ", config.Iterations)
+		fmt.Fprintln(w, "  metrics:")
+		planMetrics(w, config.Metrics)
+	} else if len(config.Scenarios) == 0 {
+		fmt.Fprintln(w, "  duration:   ", config.TestDuration)
+		fmt.Fprintln(w, "  metrics:")
+		planMetrics(w, config.Metrics)
+	}
+	for _, scenario := range config.Scenarios {
+		if scenario.Iterations > 0 {
+			fmt.Fprintln(w, "scenario", scenario.Name+":", scenario.Iterations, "iterations")
+		} else {
+			fmt.Fprintln(w, "scenario", scenario.Name+":", scenario.Duration)
+		}
+		if len(scenario.Load) > 0 {
+			fmt.Fprintln(w, "  load:", strings.Join(scenario.Load, " "))
+		}
+		fmt.Fprintln(w, "  metrics:")
+		planMetrics(w, mergeMetrics(config.Metrics, scenario.Metrics))
+	}
+	if len(config.StartupProbes) > 0 {
+		timeout := time.Duration(config.StartupTimeout)
+		if timeout == 0 {
+			timeout = defaultStartupTimeout
+		}
+		fmt.Fprintln(w, "startup probes within", timeout)
+		for _, probe := range config.StartupProbes {
+			fmt.Fprintf(w, "  %s %s ready %s\n", probe.Name, probe.Query, probe.ready())
+		}
+	}
+	if config.DrainDuration > 0 {
+		fmt.Fprintln(w, "drain:", config.DrainDuration)
+		for _, metric := range config.Metrics {
+			if metric.DrainMaxValue != nil {
+				fmt.Fprintf(w, "  %s drainMaxValue %d\n", metric.Name, *metric.DrainMaxValue)
+			}
+		}
+	}
+	if config.Trends.enabled() {
+		runs := config.Trends.Runs
+		if runs == 0 {
+			runs = defaultTrendRuns
+		}
+		fmt.Fprintf(w, "trends: %s vs the median of the last %d passed runs of branch %q, warn %g%% fail %g%%\n",
+			config.Trends.stat(), runs, config.Trends.Branch, config.Trends.Warn, config.Trends.Fail)
+	}
+	if config.Report.Template != "" {
+		fmt.Fprintf(w, "report: %s rendered to %s\n", config.Report.path(config.WorkDir), config.Report.output())
+	}
+	if config.Tracing.Endpoint != "" {
+		fmt.Fprintf(w, "tracing: spans of %s to %s\n", config.Tracing.service(), config.Tracing.endpoint())
+	}
+	for _, action := range newActions(config) {
+		fmt.Fprintf(w, "action %s at %s: %s\n", action.name, action.at, strings.Join(action.command, " "))
+	}
+	for _, exclusion := range config.Exclude {
+		fmt.Fprintf(w, "exclude %s from checks\n", exclusion.reason())
+	}
+	if app.checkQueries {
+		return app.checkPlanQueries(ctx, config, w)
+	}
+	return nil
+}
+
+func (app App) checkPlanQueries(ctx context.Context, config Config, w io.Writer) error {
+	sources, closeSources, err := newSources(config)
+	if err != nil {
+		return err
+	}
+	defer closeSources()
+	fmt.Fprintln(w, "=[ queries ]===========================")
+	for _, metric := range configMetrics(config) {
+		if metric.Type == "otlp" {
+			fmt.Fprintln(w, " ", metric.Name, "skipped, otlp values are pushed during the run")
+			continue
+		}
+		if metric.Type == "derived" {
+			fmt.Fprintln(w, " ", metric.Name, "skipped, derived from the other metrics of a tick")
+			continue
+		}
+		gather := newMetricGathers(sources, []Metric{metric})[0]
+		fmt.Fprintln(w, " ", metric.Name, gather.gather(ctx))
+	}
+	limits := newMaxValueQueries(config, sources)
+	if limits == nil {
+		return nil
+	}
+	for n, query := range limits.queries {
+		fmt.Fprintln(w, "  maxValueQuery", query, limits.gathers[n].gather(ctx))
+	}
+	return nil
+}
+
+func configMetrics(config Config) []Metric {
+	metrics := append([]Metric{}, config.Metrics...)
+	for _, scenario := range config.Scenarios {
+		metrics = mergeMetrics(metrics, scenario.Metrics)
+	}
+	return metrics
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppPlan(t *testing.T) {
+	requires := require.New(t)
+	env := &FakeEnvManager{}
+	app := App{envManager: env}
+	config := Config{
+		WorkDir:      "/stand",
+		StartDelay:   Duration(15 * time.Second),
+		TestDuration: Duration(time.Minute),
+		Timeout:      Duration(5 * time.Second),
+		Metrics:      []Metric{{Name: "errors", Query: "sum(errors)", Assertions: []string{"max < 1"}}},
+		Scenarios: []Scenario{
+			{Name: "baseline"},
+			{Name: "soak", Duration: Duration(10 * time.Minute), Load: []string{"k6", "run", "soak.js"},
+				Metrics: []Metric{{Name: "cpu", Type: "docker", Service: "api", Query: "cpu", MaxValue: 150, AllowedViolations: 2}}},
+		},
+		Actions: []ActionConfig{{Name: "restart", At: Duration(time.Minute), Command: []string{"./restart.sh"}}},
+	}
+	var b bytes.Buffer
+	requires.NoError(app.plan(context.Background(), config, &b))
+	requires.False(env.started)
+	requires.False(env.stopped)
+	out := b.String()
+	requires.Contains(out, "startDelay:  15s")
+	requires.Contains(out, "scenario baseline: 1m0s")
+	requires.Contains(out, "scenario soak: 10m0s\n  load: k6 run soak.js")
+	requires.Contains(out, "    errors (prometheus) sum(errors) maxValue 0 assertions max < 1")
+	requires.Contains(out, "    cpu (docker) cpu maxValue 150 allowedViolations 2")
+	requires.Contains(out, "action restart at 1m0s: ./restart.sh")
+	requires.NotContains(out, "=[ queries ]")
+
+	config.Metrics[0].Type = "unknown"
+	requires.Error(app.plan(context.Background(), config, &b))
+}
+
+func TestAppPlanComposeCommands(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	config := Config{TestDuration: Duration(time.Minute), ComposeCommand: []string{"podman-compose"}}
+	requires.NoError(App{}.plan(context.Background(), config, &b))
+	requires.Contains(b.String(), "  start: podman-compose up -d --remove-orphans\n  stop:  podman-compose down\n")
+	b.Reset()
+	config.Env = StandConfig{Pull: "always", Build: true}
+	requires.NoError(App{}.plan(context.Background(), config, &b))
+	requires.Contains(b.String(), "  start: podman-compose pull\n  start: podman-compose up -d --remove-orphans --build\n")
+	config.Env.Pull = "missing"
+	requires.EqualError(App{}.plan(context.Background(), config, &b), "unknown env.pull missing")
+}
+
+func TestAppPlanCheckQueries(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1704164641,"42"]}]}}`))
+	}))
+	defer server.Close()
+	app := App{envManager: &FakeEnvManager{}, checkQueries: true}
+	config := Config{
+		Host:         server.URL,
+		TestDuration: Duration(time.Minute),
+		Otlp:         OtlpConfig{Listen: "127.0.0.1:0"},
+		Metrics:      []Metric{{Name: "rps", Query: "sum(rate(http_requests_total[1m]))"}, {Name: "spans", Type: "otlp", Query: "spans"}},
+	}
+	var b bytes.Buffer
+	requires.NoError(app.plan(context.Background(), config, &b))
+	requires.Contains(b.String(), "=[ queries ]")
+	requires.Contains(b.String(), "  rps 42\n")
+	requires.Contains(b.String(), "  spans skipped")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type ElasticConfig struct {
+	URL                string `yaml:"url"`
+	Username           string `yaml:"username"`
+	Password           string `yaml:"password"`
+	CAFile             string `yaml:"caFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	TimeField          string `yaml:"timeField"`
+}
+
+type ElasticClient struct {
+	client    *http.Client
+	url       string
+	username  string
+	password  string
+	timeField string
+}
+
+var elasticAggregates = []string{"avg", "sum", "min", "max", "cardinality"}
+
+func NewElasticClient(config ElasticConfig) (*ElasticClient, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		b, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	timeField := config.TimeField
+	if timeField == "" {
+		timeField = "@timestamp"
+	}
+	return &ElasticClient{
+		client:    &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+		url:       strings.TrimSuffix(config.URL, "/"),
+		username:  config.Username,
+		password:  config.Password,
+		timeField: timeField,
+	}, nil
+}
+
+func parseElasticAggregate(text string) (string, string, error) {
+	if text == "" || text == "count" {
+		return "count", "", nil
+	}
+	aggregate, field, ok := strings.Cut(text, ":")
+	if !ok || field == "" {
+		return "", "", fmt.Errorf("aggregate %s: expected count or <aggregate>:<field>", text)
+	}
+	for _, known := range elasticAggregates {
+		if aggregate == known {
+			return aggregate, field, nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown aggregate %s", aggregate)
+}
+
+type ElasticResponse struct {
+	Hits struct {
+		Total struct {
+			Value float64 `json:"value"`
+		} `json:"total"`
+	} `json:"hits"`
+	Aggregations struct {
+		Value struct {
+			Value *float64 `json:"value"`
+		} `json:"value"`
+	} `json:"aggregations"`
+	Error json.RawMessage `json:"error"`
+}
+
+var errNoElasticValue = errors.New("no value")
+
+func (client *ElasticClient) search(ctx context.Context, index string, query string, aggregate string, window time.Duration) (float64, error) {
+	kind, field, err := parseElasticAggregate(aggregate)
+	if err != nil {
+		return 0, err
+	}
+	if query == "" {
+		query = "*"
+	}
+	filters := []any{
+		map[string]any{"query_string": map[string]any{"query": query}},
+	}
+	if window > 0 {
+		filters = append(filters, map[string]any{"range": map[string]any{
+			client.timeField: map[string]any{"gte": fmt.Sprintf("now-%ds", int(window.Seconds()))},
+		}})
+	}
+	body := map[string]any{
+		"size":             0,
+		"track_total_hits": true,
+		"query":            map[string]any{"bool": map[string]any{"filter": filters}},
+	}
+	if kind != "count" {
+		body["aggs"] = map[string]any{"value": map[string]any{kind: map[string]any{"field": field}}}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.url+"/"+index+"/_search", bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client.username != "" {
+		req.SetBasicAuth(client.username, client.password)
+	}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	response := ElasticResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("status %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status %s: %s", resp.Status, response.Error)
+	}
+	if kind == "count" {
+		return response.Hits.Total.Value, nil
+	}
+	if response.Aggregations.Value.Value == nil {
+		return 0, errNoElasticValue
+	}
+	return *response.Aggregations.Value.Value, nil
+}
+
+type ElasticMetric struct {
+	client    *ElasticClient
+	Name      string
+	Index     string
+	Query     string
+	Aggregate string
+	Window    time.Duration
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric ElasticMetric) name() string {
+	return metric.Name
+}
+
+func (metric ElasticMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric ElasticMetric) gather(ctx context.Context) int {
+	value, err := metric.client.search(ctx, metric.Index, metric.Query, metric.Aggregate, metric.Window)
+	if errors.Is(err, errNoElasticValue) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("Error querying Elasticsearch: %v\n", err)
+		return -1
+	}
+	return int(value)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseElasticAggregate(t *testing.T) {
+	variants := []struct {
+		text      string
+		aggregate string
+		field     string
+		err       bool
+	}{
+		{text: "", aggregate: "count"},
+		{text: "count", aggregate: "count"},
+		{text: "avg:duration_ms", aggregate: "avg", field: "duration_ms"},
+		{text: "cardinality:user.id", aggregate: "cardinality", field: "user.id"},
+		{text: "avg", err: true},
+		{text: "p95:duration_ms", err: true},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		aggregate, field, err := parseElasticAggregate(variant.text)
+		requires.Equal(variant.err, err != nil, variant.text)
+		requires.Equal(variant.aggregate, aggregate, variant.text)
+		requires.Equal(variant.field, field, variant.text)
+	}
+}
+
+func TestElasticMetricGather(t *testing.T) {
+	requires := require.New(t)
+	var body map[string]any
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if r.URL.Path != "/logs-*/_search" || username != "elastic" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"type":"security_exception"}}`))
+			return
+		}
+		body = map[string]any{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if _, ok := body["aggs"]; !ok {
+			_, _ = w.Write([]byte(`{"hits":{"total":{"value":17}}}`))
+			return
+		}
+		if body["aggs"].(map[string]any)["value"].(map[string]any)["max"] != nil {
+			_, _ = w.Write([]byte(`{"hits":{"total":{"value":0}},"aggregations":{"value":{"value":null}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"hits":{"total":{"value":3}},"aggregations":{"value":{"value":41.7}}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewElasticClient(ElasticConfig{URL: server.URL + "/", Username: "elastic", Password: "secret", InsecureSkipVerify: true})
+	requires.NoError(err)
+	metric := ElasticMetric{client: client, Name: "errors", Index: "logs-*", Query: "level:ERROR", Window: time.Minute}
+	requires.Equal(17, metric.gather(context.Background()))
+	filters := body["query"].(map[string]any)["bool"].(map[string]any)["filter"].([]any)
+	requires.Equal(map[string]any{"query_string": map[string]any{"query": "level:ERROR"}}, filters[0])
+	requires.Equal(map[string]any{"range": map[string]any{"@timestamp": map[string]any{"gte": "now-60s"}}}, filters[1])
+
+	metric.Aggregate = "avg:duration_ms"
+	requires.Equal(41, metric.gather(context.Background()))
+	requires.Equal(map[string]any{"value": map[string]any{"avg": map[string]any{"field": "duration_ms"}}}, body["aggs"])
+
+	metric.Aggregate = "max:duration_ms"
+	metric.OnMissing = "treatAsZero"
+	requires.Equal(0, metric.gather(context.Background()))
+
+	metric.Index = "other"
+	requires.Equal(-1, metric.gather(context.Background()))
+
+	strict, err := NewElasticClient(ElasticConfig{URL: server.URL})
+	requires.NoError(err)
+	requires.Equal(-1, ElasticMetric{client: strict, Index: "logs-*"}.gather(context.Background()))
+
+	_, err = NewElasticClient(ElasticConfig{CAFile: "missing.pem"})
+	requires.Error(err)
+}
+
+func TestValidateElasticMetric(t *testing.T) {
+	requires := require.New(t)
+	metric := Metric{Name: "a", Type: "elasticsearch", Index: "logs-*", Aggregate: "avg:x"}
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{metric}}))
+	config := Config{Elasticsearch: ElasticConfig{URL: "http://es:9200"}, Metrics: []Metric{metric}}
+	requires.NoError(validateMetricTypes(config))
+	config.Metrics[0].Index = ""
+	requires.Error(validateMetricTypes(config))
+	config.Metrics[0] = Metric{Name: "a", Type: "elasticsearch", Index: "logs-*", Aggregate: "median:x"}
+	requires.Error(validateMetricTypes(config))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type EmailConfig struct {
+	Addr         string   `yaml:"addr"`
+	Username     string   `yaml:"username"`
+	Password     string   `yaml:"password"`
+	From         string   `yaml:"from"`
+	To           []string `yaml:"to"`
+	PassTemplate string   `yaml:"passTemplate"`
+	FailTemplate string   `yaml:"failTemplate"`
+}
+
+var emailFuncs = htmltemplate.FuncMap{
+	"result": reportResult,
+	"key":    teamCityKey,
+	"time":   func(t time.Time) string { return t.Format(time.RFC3339) },
+}
+
+const emailSummary = `
+<h2>Summary</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>metric</th><th>samples</th><th>min</th><th>max</th><th>avg</th><th>p95</th><th>violations</th></tr>
+{{- range .Summaries }}
+<tr><td>{{ key .Scenario .Metric }}</td><td>{{ .Samples }}</td><td>{{ .Min }}</td><td>{{ .Max }}</td><td>{{ printf "%.2f" .Avg }}</td><td>{{ .P95 }}</td><td>{{ .Violations }}</td></tr>
+{{- end }}
+</table>`
+
+var defaultPassTemplate = `<html>
+<body>
+<h1>Run {{ .RunID }} passed</h1>
+<p>Finished {{ time .Finished }}.</p>
+{{- if .Artifacts }}
+<p>Artifacts: <a href="{{ .Artifacts }}">{{ .Artifacts }}</a></p>
+{{- end }}` + emailSummary + `
+</body>
+</html>
+`
+
+var defaultFailTemplate = `<html>
+<body>
+<h1>Run {{ .RunID }} failed</h1>
+<p>Finished {{ time .Finished }}.</p>
+{{- if .Artifacts }}
+<p>Artifacts: <a href="{{ .Artifacts }}">{{ .Artifacts }}</a></p>
+{{- end }}
+{{- if .FailedAssertions }}
+<h2>Failed assertions</h2>
+<ul>
+{{- range .FailedAssertions }}
+<li>{{ if .Scenario }}{{ .Scenario }}/{{ end }}{{ .Metric }}: {{ .Assertion }} (value {{ .Value }})</li>
+{{- end }}
+</ul>
+{{- end }}` + emailSummary + `
+</body>
+</html>
+`
+
+var emailAttachments = [][2]string{{"json", "application/json"}, {"csv", "text/csv"}}
+
+type EmailReporter struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	pass     *htmltemplate.Template
+	fail     *htmltemplate.Template
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func emailTemplate(workDir string, name string, fileName string, text string) (*htmltemplate.Template, error) {
+	if fileName == "" {
+		return htmltemplate.New(name).Funcs(emailFuncs).Parse(text)
+	}
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(workDir, fileName)
+	}
+	return htmltemplate.New(filepath.Base(fileName)).Funcs(emailFuncs).ParseFiles(fileName)
+}
+
+func validateEmail(config Config) error {
+	email := config.Email
+	if email.Addr == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(email.Addr); err != nil {
+		return fmt.Errorf("email.addr: %w", err)
+	}
+	if email.From == "" {
+		return errors.New("email.from is not set")
+	}
+	if len(email.To) == 0 {
+		return errors.New("email.to is not set")
+	}
+	if _, err := emailTemplate(config.WorkDir, "pass", email.PassTemplate, defaultPassTemplate); err != nil {
+		return fmt.Errorf("email.passTemplate: %w", err)
+	}
+	if _, err := emailTemplate(config.WorkDir, "fail", email.FailTemplate, defaultFailTemplate); err != nil {
+		return fmt.Errorf("email.failTemplate: %w", err)
+	}
+	return nil
+}
+
+func (App) email(config Config) *EmailReporter {
+	email := config.Email
+	if email.Addr == "" {
+		return nil
+	}
+	pass, err := emailTemplate(config.WorkDir, "pass", email.PassTemplate, defaultPassTemplate)
+	if err != nil {
+		log.Println("email error:", err)
+		return nil
+	}
+	fail, err := emailTemplate(config.WorkDir, "fail", email.FailTemplate, defaultFailTemplate)
+	if err != nil {
+		log.Println("email error:", err)
+		return nil
+	}
+	reporter := &EmailReporter{
+		addr:     email.Addr,
+		from:     email.From,
+		to:       email.To,
+		pass:     pass,
+		fail:     fail,
+		sendMail: smtp.SendMail,
+	}
+	if email.Username != "" {
+		host, _, _ := net.SplitHostPort(email.Addr)
+		reporter.auth = smtp.PlainAuth("", email.Username, email.Password, host)
+	}
+	return reporter
+}
+
+func renderEmailBody(tmpl *htmltemplate.Template, report RunReport) ([]byte, error) {
+	failed := make([]AssertionJSON, 0)
+	for _, assertion := range report.Assertions {
+		if !assertion.Ok {
+			failed = append(failed, assertion)
+		}
+	}
+	data := struct {
+		RunReport
+		Summaries        []MetricSummary
+		FailedAssertions []AssertionJSON
+	}{RunReport: report, Summaries: reportSummaries(report), FailedAssertions: failed}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeBase64(b *bytes.Buffer, content []byte) {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
+
+func (reporter EmailReporter) message(report RunReport) ([]byte, error) {
+	tmpl := reporter.pass
+	if !report.Passed {
+		tmpl = reporter.fail
+	}
+	body, err := renderEmailBody(tmpl, report)
+	if err != nil {
+		return nil, err
+	}
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "base64")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	var encoded bytes.Buffer
+	writeBase64(&encoded, body)
+	if _, err := part.Write(encoded.Bytes()); err != nil {
+		return nil, err
+	}
+	for _, attachment := range emailAttachments {
+		format, contentType := attachment[0], attachment[1]
+		content, err := renderReport(format, report)
+		if err != nil {
+			return nil, err
+		}
+		name := "report." + format
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Reset()
+		writeBase64(&encoded, content)
+		if _, err := part.Write(encoded.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	subject := fmt.Sprintf("metricsgatherer run %s %s", report.RunID, reportResult(report))
+	fmt.Fprintf(&b, "From: %s\r\n", reporter.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(reporter.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", report.Finished.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+	b.Write(parts.Bytes())
+	return b.Bytes(), nil
+}
+
+func (reporter EmailReporter) finished(report RunReport) {
+	msg, err := reporter.message(report)
+	if err != nil {
+		log.Println("email error:", err)
+		return
+	}
+	if err := reporter.sendMail(reporter.addr, reporter.auth, reporter.from, reporter.to, msg); err != nil {
+		log.Println("email error:", err)
+		return
+	}
+	log.Println("email sent to", strings.Join(reporter.to, ", "))
+}
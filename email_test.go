@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type SentMail struct {
+	addr string
+	from string
+	to   []string
+	msg  []byte
+}
+
+func recordingEmail(t *testing.T, config Config, sent *SentMail) *EmailReporter {
+	reporter := App{}.email(config)
+	require.NotNil(t, reporter)
+	reporter.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		*sent = SentMail{addr: addr, from: from, to: to, msg: msg}
+		return nil
+	}
+	return reporter
+}
+
+func readMail(t *testing.T, msg []byte) (*mail.Message, map[string][]byte) {
+	requires := require.New(t)
+	message, err := mail.ReadMessage(bytes.NewReader(msg))
+	requires.NoError(err)
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	requires.NoError(err)
+	requires.Equal("multipart/mixed", mediaType)
+	parts := map[string][]byte{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		requires.NoError(err)
+		b, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		requires.NoError(err)
+		name := part.FileName()
+		if name == "" {
+			name = "body"
+		}
+		parts[name] = b
+	}
+	return message, parts
+}
+
+func TestEmailReporter(t *testing.T) {
+	variants := []struct {
+		name    string
+		passed  bool
+		subject string
+		body    string
+	}{
+		{"pass", true, "metricsgatherer run r1 passed", "<h1>Run r1 passed</h1>"},
+		{"fail", false, "metricsgatherer run r1 failed", "<li>s/a: avg &lt; 5 (value 6)</li>"},
+	}
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			requires := require.New(t)
+			config := Config{Email: EmailConfig{Addr: "smtp.example.com:587", From: "gatherer@example.com", To: []string{"qa@example.com", "dev@example.com"}}}
+			sent := SentMail{}
+			report := outputReport()
+			report.Passed = variant.passed
+			if !variant.passed {
+				report.Assertions[0].Ok = false
+				report.Assertions[0].Value = 6
+			}
+			recordingEmail(t, config, &sent).finished(report)
+			requires.Equal("smtp.example.com:587", sent.addr)
+			requires.Equal("gatherer@example.com", sent.from)
+			requires.Equal([]string{"qa@example.com", "dev@example.com"}, sent.to)
+			message, parts := readMail(t, sent.msg)
+			requires.Equal(variant.subject, message.Header.Get("Subject"))
+			requires.Equal("qa@example.com, dev@example.com", message.Header.Get("To"))
+			requires.Contains(string(parts["body"]), variant.body)
+			requires.Contains(string(parts["body"]), "<tr><td>s/b</td><td>1</td><td>7</td><td>7</td><td>7.00</td><td>7</td><td>1</td></tr>")
+			jsonReport, err := renderReport("json", report)
+			requires.NoError(err)
+			requires.Equal(jsonReport, parts["report.json"])
+			csvReport, err := renderReport("csv", report)
+			requires.NoError(err)
+			requires.Equal(csvReport, parts["report.csv"])
+		})
+	}
+}
+
+func TestEmailTemplateFile(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "fail.html"), []byte("<p>{{ .RunID }} {{ result .RunReport }} {{ len .FailedAssertions }}</p>"), 0o644))
+	config := Config{WorkDir: dir, Email: EmailConfig{Addr: "smtp:25", From: "a@b", To: []string{"c@d"}, FailTemplate: "fail.html"}}
+	requires.NoError(validateEmail(config))
+	sent := SentMail{}
+	report := outputReport()
+	report.Assertions[0].Ok = false
+	recordingEmail(t, config, &sent).finished(report)
+	_, parts := readMail(t, sent.msg)
+	requires.Equal("<p>r1 failed 1</p>", string(parts["body"]))
+}
+
+func TestValidateEmail(t *testing.T) {
+	variants := []struct {
+		name  string
+		email EmailConfig
+		err   string
+	}{
+		{"disabled", EmailConfig{}, ""},
+		{"ok", EmailConfig{Addr: "smtp:25", From: "a@b", To: []string{"c@d"}}, ""},
+		{"addr", EmailConfig{Addr: "smtp", From: "a@b", To: []string{"c@d"}}, "email.addr: address smtp: missing port in address"},
+		{"from", EmailConfig{Addr: "smtp:25", To: []string{"c@d"}}, "email.from is not set"},
+		{"to", EmailConfig{Addr: "smtp:25", From: "a@b"}, "email.to is not set"},
+		{"template", EmailConfig{Addr: "smtp:25", From: "a@b", To: []string{"c@d"}, PassTemplate: "/missing/pass.html"},
+			"email.passTemplate: open /missing/pass.html: no such file or directory"},
+	}
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			err := validateEmail(Config{Email: variant.email})
+			if variant.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, variant.err)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+type ReadyConfig struct {
+	Command  []string `yaml:"command"`
+	URL      string   `yaml:"url"`
+	Timeout  Duration `yaml:"timeout"`
+	Interval Duration `yaml:"interval"`
+}
+
+type EnvironmentConfig struct {
+	Name        string      `yaml:"name"`
+	WorkDir     string      `yaml:"workDir"`
+	ComposeFile string      `yaml:"composeFile"`
+	Command     []string    `yaml:"command"`
+	Stop        []string    `yaml:"stop"`
+	Ready       ReadyConfig `yaml:"ready"`
+}
+
+type ReadyCheck struct {
+	workDir  string
+	command  []string
+	url      string
+	timeout  time.Duration
+	interval time.Duration
+}
+
+func (check ReadyCheck) probe(ctx context.Context) error {
+	if len(check.command) > 0 {
+		cmd := exec.CommandContext(ctx, check.command[0], check.command[1:]...)
+		cmd.Dir = check.workDir
+		return cmd.Run()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+func (check ReadyCheck) wait() error {
+	timeout := check.timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	interval := check.interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		probeCtx, probeCancel := context.WithTimeout(ctx, interval+5*time.Second)
+		err := check.probe(probeCtx)
+		probeCancel()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not ready after %s: %w", timeout, err)
+		case <-time.After(interval):
+		}
+	}
+}
+
+type CommandEnv struct {
+	workDir string
+	command []string
+	stopCmd []string
+}
+
+func (env CommandEnv) start() error {
+	return osexec("start: "+fmt.Sprint(env.command), env.workDir, nil, env.command...)
+}
+
+func (env CommandEnv) stop() error {
+	if len(env.stopCmd) == 0 {
+		return nil
+	}
+	return osexec("stop: "+fmt.Sprint(env.stopCmd), env.workDir, nil, env.stopCmd...)
+}
+
+type Environment struct {
+	name    string
+	manager EnvManagerInt
+	ready   *ReadyCheck
+}
+
+type Environments struct {
+	environments []Environment
+	started      int
+}
+
+func newEnvironments(config Config, teardownTimeout time.Duration) *Environments {
+	envs := &Environments{}
+	for _, env := range config.Environments {
+		workDir := env.WorkDir
+		if workDir == "" {
+			workDir = config.WorkDir
+		} else if !filepath.IsAbs(workDir) && config.WorkDir != "" {
+			workDir = filepath.Join(config.WorkDir, workDir)
+		}
+		environment := Environment{name: env.Name}
+		if len(env.Command) > 0 {
+			environment.manager = CommandEnv{workDir: workDir, command: env.Command, stopCmd: env.Stop}
+		} else {
+			environment.manager = DockerCompose{
+				workDir:           workDir,
+				dockerComposeFile: env.ComposeFile,
+				composeCommand:    config.ComposeCommand,
+				teardownTimeout:   teardownTimeout,
+				pull:              config.Env.Pull == "always",
+				build:             config.Env.Build,
+				envFiles:          config.Env.EnvFile,
+				environment:       standEnvironment(config.Env.Environment),
+				down:              config.Env.Down,
+			}
+		}
+		if len(env.Ready.Command) > 0 || env.Ready.URL != "" {
+			environment.ready = &ReadyCheck{
+				workDir:  workDir,
+				command:  env.Ready.Command,
+				url:      env.Ready.URL,
+				timeout:  time.Duration(env.Ready.Timeout),
+				interval: time.Duration(env.Ready.Interval),
+			}
+		}
+		envs.environments = append(envs.environments, environment)
+	}
+	return envs
+}
+
+func validateEnvironments(config Config) error {
+	for _, env := range config.Environments {
+		if env.Name == "" {
+			return errors.New("environment without name")
+		}
+		if len(env.Command) > 0 && env.ComposeFile != "" {
+			return fmt.Errorf("environment %s: command and composeFile are exclusive", env.Name)
+		}
+		if len(env.Ready.Command) > 0 && env.Ready.URL != "" {
+			return fmt.Errorf("environment %s: ready.command and ready.url are exclusive", env.Name)
+		}
+	}
+	return nil
+}
+
+func (envs *Environments) start() error {
+	for _, env := range envs.environments {
+		log.Println("=[ environment " + env.name + " ]")
+		err := env.manager.start()
+		if err == nil && env.ready != nil {
+			err = env.ready.wait()
+		}
+		envs.started++
+		if err != nil {
+			return fmt.Errorf("environment %s: %w", env.name, err)
+		}
+	}
+	return nil
+}
+
+func (envs *Environments) stop() error {
+	var errs []error
+	for n := envs.started - 1; n >= 0; n-- {
+		env := envs.environments[n]
+		if err := env.manager.stop(); err != nil {
+			errs = append(errs, fmt.Errorf("environment %s: %w", env.name, err))
+		}
+	}
+	envs.started = 0
+	return errors.Join(errs...)
+}
+
+func (envs *Environments) logs() ([]byte, error) {
+	var out []byte
+	var errs []error
+	for _, env := range envs.environments[:envs.started] {
+		collector, ok := env.manager.(LogCollector)
+		if !ok {
+			continue
+		}
+		b, err := collector.logs()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		out = append(out, []byte("=[ "+env.name+" ]\n")...)
+		out = append(out, b...)
+	}
+	return out, errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type RecordingEnv struct {
+	name     string
+	calls    *[]string
+	startErr error
+}
+
+func (env RecordingEnv) start() error {
+	*env.calls = append(*env.calls, "start "+env.name)
+	return env.startErr
+}
+
+func (env RecordingEnv) stop() error {
+	*env.calls = append(*env.calls, "stop "+env.name)
+	return nil
+}
+
+func TestEnvironmentsOrder(t *testing.T) {
+	requires := require.New(t)
+	calls := []string{}
+	envs := &Environments{environments: []Environment{
+		{name: "infra", manager: RecordingEnv{name: "infra", calls: &calls}},
+		{name: "app", manager: RecordingEnv{name: "app", calls: &calls}},
+		{name: "seed", manager: RecordingEnv{name: "seed", calls: &calls}},
+	}}
+	requires.NoError(envs.start())
+	requires.NoError(envs.stop())
+	requires.Equal([]string{"start infra", "start app", "start seed", "stop seed", "stop app", "stop infra"}, calls)
+
+	calls = []string{}
+	envs.environments[1].manager = RecordingEnv{name: "app", calls: &calls, startErr: errors.New("boom")}
+	requires.ErrorContains(envs.start(), "environment app")
+	requires.NoError(envs.stop())
+	requires.Equal([]string{"start infra", "start app", "stop app", "stop infra"}, calls)
+}
+
+func TestReadyCheck(t *testing.T) {
+	requires := require.New(t)
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probes.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	requires.NoError(ReadyCheck{url: server.URL, interval: 10 * time.Millisecond, timeout: 5 * time.Second}.wait())
+	requires.Equal(int32(3), probes.Load())
+
+	err := ReadyCheck{command: []string{"false"}, interval: 10 * time.Millisecond, timeout: 100 * time.Millisecond}.wait()
+	requires.ErrorContains(err, "not ready after 100ms")
+
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "ready"), nil, 0o644))
+	requires.NoError(ReadyCheck{workDir: dir, command: []string{"test", "-f", "ready"}}.wait())
+}
+
+func TestNewEnvironments(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		WorkDir:        "/stand",
+		ComposeCommand: []string{"docker", "compose"},
+		Environments: []EnvironmentConfig{
+			{Name: "infra", WorkDir: "infra", ComposeFile: "infra.yaml", Ready: ReadyConfig{URL: "http://localhost:9090/-/ready"}},
+			{Name: "app", ComposeFile: "app.yaml"},
+			{Name: "seed", WorkDir: "/seed", Command: []string{"./seed.sh"}},
+		},
+	}
+	requires.NoError(validateEnvironments(config))
+	envs := newEnvironments(config, time.Minute)
+	requires.Len(envs.environments, 3)
+	infra := envs.environments[0].manager.(DockerCompose)
+	requires.Equal("/stand/infra", infra.workDir)
+	requires.Equal([]string{"docker", "compose", "-f", "infra.yaml", "up"}, infra.compose("up"))
+	requires.Equal("http://localhost:9090/-/ready", envs.environments[0].ready.url)
+	requires.Equal("/stand", envs.environments[1].manager.(DockerCompose).workDir)
+	requires.Nil(envs.environments[1].ready)
+	requires.Equal(CommandEnv{workDir: "/seed", command: []string{"./seed.sh"}}, envs.environments[2].manager)
+
+	requires.Error(validateEnvironments(Config{Environments: []EnvironmentConfig{{}}}))
+	requires.Error(validateEnvironments(Config{Environments: []EnvironmentConfig{{Name: "a", Command: []string{"x"}, ComposeFile: "y"}}}))
+	requires.Error(validateEnvironments(Config{Environments: []EnvironmentConfig{{Name: "a", Ready: ReadyConfig{URL: "u", Command: []string{"x"}}}}}))
+}
+
+func TestCommandEnv(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	env := CommandEnv{workDir: dir, command: []string{"touch", "started"}, stopCmd: []string{"rm", "started"}}
+	requires.NoError(env.start())
+	requires.FileExists(filepath.Join(dir, "started"))
+	requires.NoError(env.stop())
+	requires.NoFileExists(filepath.Join(dir, "started"))
+	requires.NoError(CommandEnv{command: []string{"true"}}.stop())
+}
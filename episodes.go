@@ -0,0 +1,118 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+type Episode struct {
+	Scenario  string            `json:"scenario,omitempty"`
+	Metric    string            `json:"metric"`
+	Start     time.Time         `json:"start"`
+	End       time.Time         `json:"end,omitzero"`
+	Peak      int               `json:"peak"`
+	Series    map[string]string `json:"series,omitempty"`
+	Ticks     int               `json:"ticks"`
+	Recovered bool              `json:"recovered"`
+}
+
+type openEpisode struct {
+	index   int
+	inRange int
+	entered time.Time
+}
+
+type EpisodeTracker struct {
+	mutex        sync.Mutex
+	recoverTicks map[string]int
+	logRecovery  bool
+	open         map[string]*openEpisode
+	episodes     []Episode
+}
+
+func NewEpisodeTracker(recoverTicks map[string]int) *EpisodeTracker {
+	return &EpisodeTracker{recoverTicks: recoverTicks, open: map[string]*openEpisode{}}
+}
+
+func recoverTicks(config Config) map[string]int {
+	ticks := map[string]int{}
+	add := func(scenario string, metrics []Metric) {
+		for _, metric := range metrics {
+			if metric.RecoverTicks > 0 {
+				ticks[teamCityKey(scenario, metric.Name)] = metric.RecoverTicks
+			}
+		}
+	}
+	add("", config.Metrics)
+	for _, scenario := range config.Scenarios {
+		add(scenario.Name, mergeMetrics(config.Metrics, scenario.Metrics))
+	}
+	return ticks
+}
+
+func violatedValue(values MetricValues, name string) (MetricValue, bool) {
+	for _, violation := range values.violations {
+		if violation.name == name {
+			return violation, true
+		}
+	}
+	return MetricValue{}, false
+}
+
+func (tracker *EpisodeTracker) observe(values MetricValues) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for _, value := range values.values {
+		key := teamCityKey(values.scenario, value.name)
+		open := tracker.open[key]
+		if violated, ok := violatedValue(values, value.name); ok {
+			if open == nil {
+				tracker.episodes = append(tracker.episodes, Episode{
+					Scenario: values.scenario, Metric: value.name, Start: values.timestamp, Peak: violated.value, Series: violated.series,
+				})
+				open = &openEpisode{index: len(tracker.episodes) - 1}
+				tracker.open[key] = open
+			}
+			episode := &tracker.episodes[open.index]
+			if violated.value > episode.Peak {
+				episode.Peak, episode.Series = violated.value, violated.series
+			}
+			episode.Ticks++
+			open.inRange = 0
+			continue
+		}
+		if open == nil || !hasValue(value.value) {
+			continue
+		}
+		if open.inRange == 0 {
+			open.entered = values.timestamp
+		}
+		open.inRange++
+		if open.inRange < max(tracker.recoverTicks[key], 1) {
+			continue
+		}
+		episode := &tracker.episodes[open.index]
+		episode.End = open.entered
+		episode.Recovered = true
+		delete(tracker.open, key)
+		if tracker.logRecovery {
+			log.Printf(" metric(%s): recovered after %s, %d violated ticks, peak %d\n",
+				key, episode.End.Sub(episode.Start).Round(time.Millisecond), episode.Ticks, episode.Peak)
+		}
+	}
+}
+
+func (tracker *EpisodeTracker) list() []Episode {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	return append([]Episode{}, tracker.episodes...)
+}
+
+func violationEpisodes(values []MetricValues, recoverTicks map[string]int) []Episode {
+	tracker := NewEpisodeTracker(recoverTicks)
+	for _, tick := range values {
+		tracker.observe(tick)
+	}
+	return tracker.list()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func episodeTicks(started time.Time, scenario string, values ...int) []MetricValues {
+	ticks := make([]MetricValues, 0, len(values))
+	for n, value := range values {
+		tick := MetricValues{scenario: scenario, timestamp: started.Add(time.Duration(n) * time.Second), values: []MetricValue{{name: "a", value: value}}}
+		if value > 5 || value == missingValue {
+			tick.violations = []MetricValue{{name: "a", value: value}}
+		}
+		ticks = append(ticks, tick)
+	}
+	return ticks
+}
+
+func TestViolationEpisodes(t *testing.T) {
+	started := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	at := func(n int) time.Time { return started.Add(time.Duration(n) * time.Second) }
+	variants := []struct {
+		values       []int
+		recoverTicks int
+		episodes     []Episode
+	}{
+		{values: []int{1, 2, 3}, episodes: []Episode{}},
+		{
+			values: []int{1, 7, 9, 8, 2, 3},
+			episodes: []Episode{
+				{Metric: "a", Start: at(1), End: at(4), Peak: 9, Ticks: 3, Recovered: true},
+			},
+		},
+		{
+			values: []int{7, 2, 8, 1},
+			episodes: []Episode{
+				{Metric: "a", Start: at(0), End: at(1), Peak: 7, Ticks: 1, Recovered: true},
+				{Metric: "a", Start: at(2), End: at(3), Peak: 8, Ticks: 1, Recovered: true},
+			},
+		},
+		{
+			values:       []int{7, 2, 8, 1, -1, 2, 3},
+			recoverTicks: 3,
+			episodes: []Episode{
+				{Metric: "a", Start: at(0), End: at(3), Peak: 8, Ticks: 2, Recovered: true},
+			},
+		},
+		{
+			values:   []int{1, 6, missingValue, 9},
+			episodes: []Episode{{Metric: "a", Start: at(1), Peak: 9, Ticks: 3}},
+		},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		episodes := violationEpisodes(episodeTicks(started, "", variant.values...), map[string]int{"a": variant.recoverTicks})
+		requires.Equal(variant.episodes, episodes, variant.values)
+	}
+
+	ticks := append(episodeTicks(started, "s1", 7), episodeTicks(started.Add(time.Minute), "s2", 8, 1)...)
+	episodes := violationEpisodes(ticks, nil)
+	requires.Len(episodes, 2)
+	requires.False(episodes[0].Recovered)
+	requires.Equal("s2", episodes[1].Scenario)
+	requires.True(episodes[1].Recovered)
+}
+
+func TestViolationEpisodeSeries(t *testing.T) {
+	requires := require.New(t)
+	ticks := episodeTicks(time.Now(), "", 7, 9, 8)
+	for n, pod := range []string{"api-1", "api-2", "api-3"} {
+		ticks[n].violations[0].series = map[string]string{"pod": pod}
+	}
+	episodes := violationEpisodes(ticks, nil)
+	requires.Len(episodes, 1)
+	requires.Equal(9, episodes[0].Peak)
+	requires.Equal(map[string]string{"pod": "api-2"}, episodes[0].Series)
+	requires.Contains(string(renderMarkdown(RunReport{Episodes: episodes})), "| 3 | 9 | pod=api-2 |")
+}
+
+func TestRecoverTicks(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		Metrics:   []Metric{{Name: "a", RecoverTicks: 2}, {Name: "b"}},
+		Scenarios: []Scenario{{Name: "s", Metrics: []Metric{{Name: "a", RecoverTicks: 5}}}, {Name: "t"}},
+	}
+	requires.Equal(map[string]int{"a": 2, "s/a": 5, "t/a": 2}, recoverTicks(config))
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", RecoverTicks: -1}}}))
+
+	scheduler := Scheduler{recoverTicks: map[string]int{"a": 2}}
+	report := newRunReport(&scheduler, episodeTicks(time.Now(), "", 7, 1, 1))
+	requires.Len(report.Episodes, 1)
+	requires.True(report.Episodes[0].Recovered)
+	requires.Contains(string(renderMarkdown(report)), "## Violation episodes\n\n| metric | start | end | violated ticks | peak |")
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExclusionConfig is a window of the run excluded from checks: from/to
+// offsets from the start of gathering, or before/after an action.
+type ExclusionConfig struct {
+	From    Duration `yaml:"from"`
+	To      Duration `yaml:"to"`
+	Action  string   `yaml:"action"`
+	Before  Duration `yaml:"before"`
+	After   Duration `yaml:"after"`
+	Metrics []string `yaml:"metrics"`
+	Reason  string   `yaml:"reason"`
+}
+
+func (exclusion ExclusionConfig) reason() string {
+	switch {
+	case exclusion.Reason != "":
+		return exclusion.Reason
+	case exclusion.Action != "":
+		return "action " + exclusion.Action
+	}
+	return fmt.Sprintf("%s-%s", exclusion.From, exclusion.To)
+}
+
+type ExclusionWindow struct {
+	Reason  string    `json:"reason"`
+	Metrics []string  `json:"metrics,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	action  int
+	after   time.Duration
+	logged  bool
+}
+
+type Exclusions struct {
+	mutex   sync.Mutex
+	configs []ExclusionConfig
+	windows []ExclusionWindow
+}
+
+func validateExclusions(config Config) error {
+	names := map[string]bool{}
+	for _, metric := range config.Metrics {
+		names[metric.Name] = true
+	}
+	for _, scenario := range config.Scenarios {
+		for _, metric := range scenario.Metrics {
+			names[metric.Name] = true
+		}
+	}
+	for n, exclusion := range config.Exclude {
+		prefix := fmt.Sprintf("exclude %d", n+1)
+		if exclusion.From < 0 || exclusion.To < 0 || exclusion.Before < 0 || exclusion.After < 0 {
+			return fmt.Errorf("%s: negative offset", prefix)
+		}
+		if exclusion.Action != "" {
+			if exclusion.From != 0 || exclusion.To != 0 {
+				return fmt.Errorf("%s: action is exclusive with from and to", prefix)
+			}
+			if !slices.ContainsFunc(config.Actions, func(action ActionConfig) bool { return action.Name == exclusion.Action }) {
+				return fmt.Errorf("%s: unknown action %s", prefix, exclusion.Action)
+			}
+			if exclusion.Before == 0 && exclusion.After == 0 {
+				return fmt.Errorf("%s: before or after must be set", prefix)
+			}
+		} else {
+			if exclusion.Before != 0 || exclusion.After != 0 {
+				return fmt.Errorf("%s: before and after need an action", prefix)
+			}
+			if exclusion.To <= exclusion.From {
+				return fmt.Errorf("%s: to must be after from", prefix)
+			}
+		}
+		for _, metric := range exclusion.Metrics {
+			if !names[metric] {
+				return fmt.Errorf("%s: unknown metric %s", prefix, metric)
+			}
+		}
+	}
+	return nil
+}
+
+func newExclusions(config Config) *Exclusions {
+	if len(config.Exclude) == 0 {
+		return nil
+	}
+	return &Exclusions{configs: config.Exclude}
+}
+
+// start fixes the windows once gathering starts, the clock of the actions;
+// a window of an action is where the action is planned until its after.
+func (exclusions *Exclusions) start(now time.Time, actions []Action) {
+	if exclusions == nil {
+		return
+	}
+	exclusions.mutex.Lock()
+	defer exclusions.mutex.Unlock()
+	exclusions.windows = exclusions.windows[:0]
+	for _, exclusion := range exclusions.configs {
+		window := ExclusionWindow{Reason: exclusion.reason(), Metrics: exclusion.Metrics, action: -1}
+		if exclusion.Action == "" {
+			window.Start, window.End = now.Add(time.Duration(exclusion.From)), now.Add(time.Duration(exclusion.To))
+			exclusions.windows = append(exclusions.windows, window)
+			continue
+		}
+		for n, action := range actions {
+			if action.name == exclusion.Action {
+				window.action, window.after = n, time.Duration(exclusion.After)
+				window.Start = now.Add(action.at - time.Duration(exclusion.Before))
+				window.End = now.Add(action.at + window.after)
+				exclusions.windows = append(exclusions.windows, window)
+			}
+		}
+	}
+}
+
+// actionStarted moves the end of the windows of an action that starts late,
+// after slow actions before it.
+func (exclusions *Exclusions) actionStarted(action int, now time.Time) {
+	if exclusions == nil {
+		return
+	}
+	exclusions.mutex.Lock()
+	defer exclusions.mutex.Unlock()
+	for n := range exclusions.windows {
+		window := &exclusions.windows[n]
+		if window.action == action && now.Add(window.after).After(window.End) {
+			window.End = now.Add(window.after)
+		}
+	}
+}
+
+func (exclusions *Exclusions) excludes(metric string, at time.Time) bool {
+	if exclusions == nil {
+		return false
+	}
+	exclusions.mutex.Lock()
+	defer exclusions.mutex.Unlock()
+	for n := range exclusions.windows {
+		window := &exclusions.windows[n]
+		if at.Before(window.Start) || !at.Before(window.End) {
+			continue
+		}
+		if len(window.Metrics) > 0 && !slices.Contains(window.Metrics, metric) {
+			continue
+		}
+		if !window.logged {
+			window.logged = true
+			log.Println(" excluded from checks:", window.Reason, "until", window.End.Format(time.TimeOnly))
+		}
+		return true
+	}
+	return false
+}
+
+func (exclusions *Exclusions) result() []ExclusionWindow {
+	if exclusions == nil {
+		return nil
+	}
+	exclusions.mutex.Lock()
+	defer exclusions.mutex.Unlock()
+	return slices.Clone(exclusions.windows)
+}
+
+func renderExclusions(windows []ExclusionWindow) string {
+	b := strings.Builder{}
+	b.WriteString("## Excluded windows\n\n| reason | start | end | metrics |\n|---|---|---|---|\n")
+	for _, window := range windows {
+		metrics := "all"
+		if len(window.Metrics) > 0 {
+			metrics = strings.Join(window.Metrics, ", ")
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", window.Reason, window.Start.Format(time.RFC3339), window.End.Format(time.RFC3339), metrics)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExclusions(t *testing.T) {
+	requires := require.New(t)
+	base := Config{
+		Metrics: []Metric{{Name: "latency"}},
+		Actions: []ActionConfig{{Name: "restart api"}},
+	}
+	for _, variant := range []struct {
+		exclusion ExclusionConfig
+		err       string
+	}{
+		{ExclusionConfig{From: Duration(time.Minute), To: Duration(2 * time.Minute)}, ""},
+		{ExclusionConfig{Action: "restart api", After: Duration(time.Minute), Metrics: []string{"latency"}}, ""},
+		{ExclusionConfig{From: Duration(-time.Second), To: Duration(time.Minute)}, "exclude 1: negative offset"},
+		{ExclusionConfig{Action: "restart api", From: Duration(time.Minute), After: Duration(time.Minute)}, "action is exclusive with from and to"},
+		{ExclusionConfig{Action: "kill db", After: Duration(time.Minute)}, "unknown action kill db"},
+		{ExclusionConfig{Action: "restart api"}, "before or after must be set"},
+		{ExclusionConfig{To: Duration(time.Minute), After: Duration(time.Minute)}, "before and after need an action"},
+		{ExclusionConfig{From: Duration(time.Minute), To: Duration(time.Minute)}, "to must be after from"},
+		{ExclusionConfig{To: Duration(time.Minute), Metrics: []string{"errors"}}, "unknown metric errors"},
+	} {
+		config := base
+		config.Exclude = []ExclusionConfig{variant.exclusion}
+		if variant.err == "" {
+			requires.NoError(validateExclusions(config))
+		} else {
+			requires.ErrorContains(validateExclusions(config), variant.err)
+		}
+	}
+}
+
+func TestExclusions(t *testing.T) {
+	requires := require.New(t)
+	var exclusions *Exclusions
+	requires.False(exclusions.excludes("latency", time.Now()))
+	requires.Nil(newExclusions(Config{}))
+
+	exclusions = newExclusions(Config{Exclude: []ExclusionConfig{
+		{From: Duration(10 * time.Second), To: Duration(20 * time.Second), Reason: "failover"},
+		{Action: "restart api", Before: Duration(time.Second), After: Duration(5 * time.Second), Metrics: []string{"latency"}},
+	}})
+	now := time.Unix(1000, 0)
+	exclusions.start(now, []Action{{name: "seed", at: time.Second}, {name: "restart api", at: 30 * time.Second}})
+	requires.False(exclusions.excludes("errors", now.Add(9*time.Second)))
+	requires.True(exclusions.excludes("errors", now.Add(10*time.Second)))
+	requires.False(exclusions.excludes("errors", now.Add(20*time.Second)))
+	requires.True(exclusions.excludes("latency", now.Add(29*time.Second)))
+	requires.False(exclusions.excludes("errors", now.Add(30*time.Second)))
+	requires.False(exclusions.excludes("latency", now.Add(36*time.Second)))
+
+	// the action starts late, the window ends after it
+	exclusions.actionStarted(0, now.Add(40*time.Second))
+	requires.False(exclusions.excludes("latency", now.Add(36*time.Second)))
+	exclusions.actionStarted(1, now.Add(40*time.Second))
+	requires.True(exclusions.excludes("latency", now.Add(44*time.Second)))
+	requires.False(exclusions.excludes("latency", now.Add(45*time.Second)))
+
+	windows := exclusions.result()
+	requires.Len(windows, 2)
+	requires.Equal("failover", windows[0].Reason)
+	requires.Equal("action restart api", windows[1].Reason)
+	requires.Equal(now.Add(29*time.Second), windows[1].Start)
+	requires.Equal(now.Add(45*time.Second), windows[1].End)
+	requires.Contains(renderExclusions(windows), "## Excluded windows\n\n| reason | start | end | metrics |\n|---|---|---|---|\n| failover |")
+	requires.Contains(renderExclusions(windows), " | latency |\n")
+}
+
+func TestGathererExclusions(t *testing.T) {
+	requires := require.New(t)
+	now := time.Now()
+	exclusions := newExclusions(Config{Exclude: []ExclusionConfig{{To: Duration(time.Minute)}}})
+	exclusions.start(now, nil)
+	gatherer := Gatherer{metrics: []MetricGather{FakeMetricGather{}}, exclusions: exclusions}
+
+	values, check := gatherer.gatherAndCheck(context.Background(), now)
+	requires.True(check)
+	requires.Empty(values.violations)
+	requires.Equal([]MetricValue{{name: "a", value: 2, excluded: true}}, values.values)
+	requires.True(valuesJSON(values.values)[0].Excluded)
+
+	later, check := gatherer.gatherAndCheck(context.Background(), now.Add(time.Minute))
+	requires.False(check)
+	requires.Len(later.violations, 1)
+
+	ticks := []MetricValues{values, later}
+	requires.Equal([]float64{2}, metricSamples(ticks, "", "a"))
+	requires.Equal(1, summarize(ticks)[0].Samples)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var githubDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+type GitHubReporter struct {
+	writer      io.Writer
+	summaryFile string
+	outputFile  string
+}
+
+func (App) gitHub() *GitHubReporter {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return nil
+	}
+	return &GitHubReporter{
+		writer:      os.Stdout,
+		summaryFile: os.Getenv("GITHUB_STEP_SUMMARY"),
+		outputFile:  os.Getenv("GITHUB_OUTPUT"),
+	}
+}
+
+func (gitHub GitHubReporter) annotate(level string, title string, message string) {
+	_, _ = fmt.Fprintf(gitHub.writer, "::%s title=%s::%s\n", level,
+		githubPropertyEscaper.Replace(title), githubDataEscaper.Replace(message))
+}
+
+func (gitHub GitHubReporter) attach(reporter *Reporter) {
+	reporter.subscribe(func(values MetricValues) {
+		for _, violation := range values.violations {
+			key := teamCityKey(values.scenario, violation.name)
+			level := "error"
+			if violation.tolerated {
+				level = "warning"
+			}
+			gitHub.annotate(level, "maxValue "+key, fmt.Sprintf("%s is %d at %s, above maxValue",
+				key, violation.value, values.timestamp.Format("15:04:05")))
+		}
+	})
+}
+
+func (gitHub GitHubReporter) assertions(results []AssertionResult) {
+	for _, result := range results {
+		name := teamCityKey(result.scenario, result.metric) + " " + result.assertion.String()
+		switch {
+		case result.noData:
+			gitHub.annotate("error", "assertion", name+": no data")
+		case !result.ok:
+			gitHub.annotate("error", "assertion", name+": actual "+strconv.FormatFloat(result.value, 'f', -1, 64))
+		}
+	}
+}
+
+func appendToFile(fileName string, b []byte) error {
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(b); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func renderSummary(report RunReport) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "### metricsgatherer run %s: %s\n\n", report.RunID, reportResult(report))
+	if report.Artifacts != "" {
+		fmt.Fprintf(&b, "Artifacts: %s\n\n", report.Artifacts)
+	}
+	writeSummaryTable(&b, reportSummaries(report))
+	if len(report.Assertions) > 0 {
+		b.WriteString("\n| assertion | value | result |\n|---|---|---|\n")
+		for _, assertion := range report.Assertions {
+			result := "ok"
+			if !assertion.Ok {
+				result = "FAIL"
+			}
+			fmt.Fprintf(&b, "| %s %s | %g | %s |\n", teamCityKey(assertion.Scenario, assertion.Metric), assertion.Assertion, assertion.Value, result)
+		}
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+func (gitHub GitHubReporter) finished(report RunReport) {
+	if gitHub.summaryFile != "" {
+		if err := appendToFile(gitHub.summaryFile, renderSummary(report)); err != nil {
+			log.Println("github summary error:", err)
+		}
+	}
+	if gitHub.outputFile != "" {
+		output := "result=" + reportResult(report) + "\nrun-id=" + report.RunID + "\n"
+		if err := appendToFile(gitHub.outputFile, []byte(output)); err != nil {
+			log.Println("github output error:", err)
+		}
+	}
+}
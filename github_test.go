@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubAttach(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	reporter := &Reporter{}
+	GitHubReporter{writer: &b}.attach(reporter)
+	reporter.sendResult(MetricValues{values: []MetricValue{{name: "ok", value: 1}}})
+	reporter.sendResult(MetricValues{
+		scenario:   "soak",
+		timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		values:     []MetricValue{{name: "errors", value: 3}},
+		violations: []MetricValue{{name: "errors", value: 3}},
+	})
+	requires.Equal("::error title=maxValue soak/errors::soak/errors is 3 at 03:04:05, above maxValue\n", b.String())
+	b.Reset()
+	reporter.sendResult(MetricValues{
+		scenario:   "soak",
+		timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		violations: []MetricValue{{name: "errors", value: 3, tolerated: true}},
+	})
+	requires.Equal("::warning title=maxValue soak/errors::soak/errors is 3 at 03:04:05, above maxValue\n", b.String())
+}
+
+func TestGitHubAssertions(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	avg, _ := parseAssertion("avg < 5")
+	GitHubReporter{writer: &b}.assertions([]AssertionResult{
+		{RunAssertion: RunAssertion{metric: "a", assertion: avg}, value: 1, ok: true},
+		{RunAssertion: RunAssertion{metric: "b", assertion: avg}, value: 7.5},
+		{RunAssertion: RunAssertion{scenario: "s", metric: "c", assertion: avg}, noData: true},
+	})
+	requires.Equal("::error title=assertion::b avg < 5: actual 7.5\n"+
+		"::error title=assertion::s/c avg < 5: no data\n", b.String())
+}
+
+func TestGitHubEscape(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	GitHubReporter{writer: &b}.annotate("warning", "a:b,c", "50%\nnext")
+	requires.Equal("::warning title=a%3Ab%2Cc::50%25%0Anext\n", b.String())
+}
+
+func TestGitHubFinished(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	gitHub := GitHubReporter{summaryFile: filepath.Join(dir, "summary.md"), outputFile: filepath.Join(dir, "output")}
+	report := RunReport{
+		RunID: "r1",
+		Values: []MetricValuesJSON{
+			{Values: []ValueJSON{{Name: "a", Value: 3}, {Name: "b", Value: -1}}},
+			{Values: []ValueJSON{{Name: "a", Value: 9}}, Violations: []ValueJSON{{Name: "a", Value: 9}}},
+		},
+		Assertions: []AssertionJSON{{Metric: "a", Assertion: "max < 5", Value: 9}},
+		Artifacts:  "https://minio.example.com/perf/r1/",
+	}
+	gitHub.finished(report)
+	gitHub.finished(report)
+	summary, err := os.ReadFile(gitHub.summaryFile)
+	requires.NoError(err)
+	requires.Contains(string(summary), "### metricsgatherer run r1: failed\n\nArtifacts: https://minio.example.com/perf/r1/\n")
+	requires.Contains(string(summary), "| a | 2 | 3 | 9 | 6.00 | 9 | 1 | 0 |\n")
+	requires.Contains(string(summary), "| b | 0 | 0 | 0 | 0.00 | 0 | 0 | 0 |\n")
+	requires.Contains(string(summary), "| a max < 5 | 9 | FAIL |\n")
+	output, err := os.ReadFile(gitHub.outputFile)
+	requires.NoError(err)
+	requires.Equal("result=failed\nrun-id=r1\nresult=failed\nrun-id=r1\n", string(output))
+}
+
+func TestAppGitHub(t *testing.T) {
+	requires := require.New(t)
+	t.Setenv("GITHUB_ACTIONS", "")
+	requires.Nil(App{}.gitHub())
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_STEP_SUMMARY", "/tmp/summary")
+	requires.Equal("/tmp/summary", App{}.gitHub().summaryFile)
+}
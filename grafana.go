@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type GrafanaConfig struct {
+	URL   string   `yaml:"url"`
+	Token string   `yaml:"token"`
+	Tags  []string `yaml:"tags"`
+}
+
+type GrafanaAnnotation struct {
+	Time int64    `json:"time"`
+	Tags []string `json:"tags"`
+	Text string   `json:"text"`
+}
+
+type GrafanaNotifier struct {
+	url   string
+	token string
+	tags  []string
+}
+
+func (notifier GrafanaNotifier) annotate(runID string, timestamp time.Time, event string, text string) {
+	annotation := GrafanaAnnotation{
+		Time: timestamp.UnixMilli(),
+		Tags: append([]string{"metricsgatherer", "run:" + runID, event}, notifier.tags...),
+		Text: text,
+	}
+	b, err := json.Marshal(annotation)
+	if err != nil {
+		log.Println("grafana error:", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(notifier.url, "/")+"/api/annotations", bytes.NewReader(b))
+	if err != nil {
+		log.Println("grafana error:", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if notifier.token != "" {
+		req.Header.Set("Authorization", "Bearer "+notifier.token)
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Println("grafana error:", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Println("grafana error: status", resp.Status)
+	}
+}
+
+func (notifier GrafanaNotifier) started(runID string, timestamp time.Time) {
+	notifier.annotate(runID, timestamp, "start", "metricsgatherer run "+runID+" started")
+}
+
+func (notifier GrafanaNotifier) violated(runID string, values MetricValues) {
+	parts := make([]string, 0)
+	for _, violation := range values.violations {
+		parts = append(parts, fmt.Sprintf("%s=%d", violation.name, violation.value))
+	}
+	notifier.annotate(runID, values.timestamp, "violation", "threshold violation: "+strings.Join(parts, ", "))
+}
+
+func (notifier GrafanaNotifier) finished(runID string, timestamp time.Time, ok bool) {
+	result := "passed"
+	if !ok {
+		result = "failed"
+	}
+	notifier.annotate(runID, timestamp, "end", "metricsgatherer run "+runID+" "+result)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrafanaNotifier(t *testing.T) {
+	requires := require.New(t)
+	annotations := []GrafanaAnnotation{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requires.Equal("/api/annotations", r.URL.Path)
+		requires.Equal("Bearer secret", r.Header.Get("Authorization"))
+		annotation := GrafanaAnnotation{}
+		requires.NoError(json.NewDecoder(r.Body).Decode(&annotation))
+		annotations = append(annotations, annotation)
+	}))
+	defer server.Close()
+
+	notifier := GrafanaNotifier{url: server.URL + "/", token: "secret", tags: []string{"perf"}}
+	timestamp := time.UnixMilli(1700000000000)
+	notifier.started("run1", timestamp)
+	notifier.violated("run1", MetricValues{timestamp: timestamp, violations: []MetricValue{{name: "errors", value: 3}}})
+	notifier.finished("run1", timestamp, false)
+
+	requires.Len(annotations, 3)
+	requires.Equal(int64(1700000000000), annotations[0].Time)
+	requires.Equal([]string{"metricsgatherer", "run:run1", "start", "perf"}, annotations[0].Tags)
+	requires.Equal("threshold violation: errors=3", annotations[1].Text)
+	requires.Equal("metricsgatherer run run1 failed", annotations[2].Text)
+}
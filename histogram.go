@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+type HistogramBucket struct {
+	le    float64
+	count float64
+}
+
+type HistogramCounter struct {
+	le    float64
+	value float64
+}
+
+type HistogramSample struct {
+	mutex    sync.Mutex
+	baseline map[string]HistogramCounter
+	seen     bool
+}
+
+type HistogramMetric struct {
+	Host      string
+	Headers   http.Header
+	sample    *HistogramSample
+	Name      string
+	Query     string
+	Quantile  float64
+	Scale     float64
+	Window    time.Duration
+	MaxValue  int
+	OnMissing string
+}
+
+func validateHistogramMetric(metric Metric) error {
+	if metric.Query == "" {
+		return errors.New("query is not set")
+	}
+	if metric.Quantile <= 0 || metric.Quantile >= 1 {
+		return fmt.Errorf("quantile %v must be between 0 and 1", metric.Quantile)
+	}
+	if metric.Scale < 0 {
+		return errors.New("scale must not be negative")
+	}
+	if metric.Window < 0 {
+		return errors.New("window must not be negative")
+	}
+	return nil
+}
+
+func (metric HistogramMetric) cached() {}
+
+func (metric HistogramMetric) name() string {
+	return metric.Name
+}
+
+func (metric HistogramMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric HistogramMetric) query(ctx context.Context, v1api v1.API, now time.Time, offset time.Duration) (map[string]HistogramCounter, error) {
+	val, warnings, err := queryCacheFrom(ctx).query(ctx, queryKey(metric.Host, metric.Query, offset, 0), func() (model.Value, v1.Warnings, error) {
+		return v1api.Query(ctx, metric.Query, now.Add(-offset), v1.WithTimeout(5*time.Second))
+	})
+	if err != nil {
+		return nil, err
+	}
+	addQueryWarnings(ctx, metric.Name, warnings)
+	vector, ok := val.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("metric %s: expected a vector of _bucket series, got %s", metric.Name, val.Type())
+	}
+	counters := map[string]HistogramCounter{}
+	for _, sample := range vector {
+		label, ok := sample.Metric[model.BucketLabel]
+		if !ok {
+			return nil, fmt.Errorf("metric %s: series %s has no le label", metric.Name, sample.Metric)
+		}
+		le, err := strconv.ParseFloat(string(label), 64)
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: series %s: %w", metric.Name, sample.Metric, err)
+		}
+		counters[sample.Metric.String()] = HistogramCounter{le: le, value: float64(sample.Value)}
+	}
+	return counters, nil
+}
+
+func histogramBuckets(current map[string]HistogramCounter, baseline map[string]HistogramCounter) []HistogramBucket {
+	counts := map[float64]float64{}
+	for key, counter := range current {
+		value := counter.value
+		if previous, ok := baseline[key]; ok && value >= previous.value {
+			value -= previous.value
+		}
+		counts[counter.le] += value
+	}
+	buckets := make([]HistogramBucket, 0, len(counts))
+	for _, le := range slices.Sorted(maps.Keys(counts)) {
+		buckets = append(buckets, HistogramBucket{le: le, count: counts[le]})
+	}
+	return buckets
+}
+
+func bucketQuantile(quantile float64, buckets []HistogramBucket) (float64, error) {
+	if len(buckets) == 0 || !math.IsInf(buckets[len(buckets)-1].le, 1) {
+		return 0, errors.New("histogram has no +Inf bucket")
+	}
+	total := buckets[len(buckets)-1].count
+	if total == 0 {
+		return 0, errNoData
+	}
+	for n := 1; n < len(buckets); n++ {
+		if buckets[n].count < buckets[n-1].count {
+			buckets[n].count = buckets[n-1].count
+		}
+	}
+	rank := quantile * total
+	n, _ := slices.BinarySearchFunc(buckets, rank, func(bucket HistogramBucket, rank float64) int {
+		if bucket.count < rank {
+			return -1
+		}
+		return 1
+	})
+	if n == len(buckets)-1 {
+		if n == 0 {
+			return 0, errors.New("histogram has only the +Inf bucket")
+		}
+		return buckets[n-1].le, nil
+	}
+	lower, below := 0.0, 0.0
+	if n > 0 {
+		lower, below = buckets[n-1].le, buckets[n-1].count
+	} else if buckets[0].le <= 0 {
+		return buckets[0].le, nil
+	}
+	inBucket := buckets[n].count - below
+	if inBucket == 0 {
+		return buckets[n].le, nil
+	}
+	return lower + (buckets[n].le-lower)*(rank-below)/inBucket, nil
+}
+
+func (metric HistogramMetric) gather(ctx context.Context) int {
+	client, err := api.NewClient(api.Config{
+		Address:      metric.Host,
+		RoundTripper: HeaderRoundTripper{headers: metric.Headers, next: api.DefaultRoundTripper},
+	})
+	if err != nil {
+		log.Printf("Error creating client: %v\n", err)
+		return -1
+	}
+	v1api := v1.NewAPI(client)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	now := clockFrom(ctx).now()
+	current, err := metric.query(ctx, v1api, now, 0)
+	if err != nil {
+		log.Printf("Error querying Prometheus: %v\n", err)
+		return -1
+	}
+	var baseline map[string]HistogramCounter
+	if metric.Window > 0 {
+		if baseline, err = metric.query(ctx, v1api, now, metric.Window); err != nil {
+			log.Printf("Error querying Prometheus: %v\n", err)
+			return -1
+		}
+	} else {
+		var seen bool
+		if baseline, seen = metric.sample.start(current); !seen {
+			return -1
+		}
+	}
+	buckets := histogramBuckets(current, baseline)
+	if len(buckets) == 0 {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	value, err := bucketQuantile(metric.Quantile, buckets)
+	if errors.Is(err, errNoData) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("WARNING: metric %s: %v\n", metric.Name, err)
+		return -1
+	}
+	scale := metric.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return int(math.Round(value * scale))
+}
+
+func (sample *HistogramSample) start(current map[string]HistogramCounter) (map[string]HistogramCounter, bool) {
+	sample.mutex.Lock()
+	defer sample.mutex.Unlock()
+	if !sample.seen {
+		sample.baseline, sample.seen = current, true
+		return nil, false
+	}
+	return sample.baseline, true
+}
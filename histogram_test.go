@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketQuantile(t *testing.T) {
+	requires := require.New(t)
+	inf := math.Inf(1)
+	buckets := func() []HistogramBucket {
+		return []HistogramBucket{{le: 0.1, count: 50}, {le: 0.5, count: 90}, {le: 1, count: 100}, {le: inf, count: 100}}
+	}
+	variants := []struct {
+		quantile float64
+		buckets  []HistogramBucket
+		value    float64
+		err      string
+	}{
+		{quantile: 0.5, buckets: buckets(), value: 0.1},
+		{quantile: 0.25, buckets: buckets(), value: 0.05},
+		{quantile: 0.7, buckets: buckets(), value: 0.3},
+		{quantile: 0.95, buckets: buckets(), value: 0.75},
+		{quantile: 0.99, buckets: []HistogramBucket{{le: 0.1, count: 50}, {le: inf, count: 100}}, value: 0.1},
+		{quantile: 0.5, buckets: []HistogramBucket{{le: 0.1, count: 60}, {le: 0.5, count: 40}, {le: inf, count: 100}}, value: 0.1 * 50 / 60},
+		{quantile: 0.5, buckets: []HistogramBucket{{le: -1, count: 10}, {le: inf, count: 10}}, value: -1},
+		{quantile: 0.5, buckets: []HistogramBucket{{le: 0.1, count: 0}, {le: inf, count: 0}}, err: "no data"},
+		{quantile: 0.5, buckets: []HistogramBucket{{le: 0.1, count: 5}}, err: "no +Inf bucket"},
+		{quantile: 0.5, buckets: []HistogramBucket{{le: inf, count: 5}}, err: "only the +Inf bucket"},
+		{quantile: 0.5, err: "no +Inf bucket"},
+	}
+	for n, variant := range variants {
+		value, err := bucketQuantile(variant.quantile, variant.buckets)
+		if variant.err != "" {
+			requires.ErrorContains(err, variant.err, n)
+			continue
+		}
+		requires.NoError(err, n)
+		requires.InDelta(variant.value, value, 1e-9, n)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	requires := require.New(t)
+	current := map[string]HistogramCounter{
+		`a{le="0.1",pod="1"}`:  {le: 0.1, value: 30},
+		`a{le="+Inf",pod="1"}`: {le: math.Inf(1), value: 40},
+		`a{le="0.1",pod="2"}`:  {le: 0.1, value: 5},
+		`a{le="+Inf",pod="2"}`: {le: math.Inf(1), value: 8},
+	}
+	baseline := map[string]HistogramCounter{
+		`a{le="0.1",pod="1"}`:  {le: 0.1, value: 10},
+		`a{le="+Inf",pod="1"}`: {le: math.Inf(1), value: 15},
+		`a{le="0.1",pod="2"}`:  {le: 0.1, value: 50},
+		`a{le="+Inf",pod="2"}`: {le: math.Inf(1), value: 90},
+	}
+	requires.Equal([]HistogramBucket{{le: 0.1, count: 25}, {le: math.Inf(1), count: 33}}, histogramBuckets(current, baseline))
+	requires.Equal([]HistogramBucket{{le: 0.1, count: 35}, {le: math.Inf(1), count: 48}}, histogramBuckets(current, nil))
+}
+
+type FakeHistogramServer struct {
+	mutex   sync.Mutex
+	counts  [][]float64
+	queried []string
+}
+
+func (server *FakeHistogramServer) serve(t *testing.T) string {
+	bounds := []string{"0.1", "0.5", "+Inf"}
+	handler := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		server.queried = append(server.queried, r.FormValue("time"))
+		counts := server.counts[0]
+		if len(server.counts) > 1 {
+			server.counts = server.counts[1:]
+		}
+		if r.FormValue("query") != `http_seconds_bucket{job="api"}` {
+			counts = nil
+		}
+		results := make([]string, 0)
+		for n, count := range counts {
+			results = append(results, fmt.Sprintf(`{"metric":{"__name__":"http_seconds_bucket","le":%q},"value":[1,"%s"]}`,
+				bounds[n], strconv.FormatFloat(count, 'f', -1, 64)))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` + strings.Join(results, ",") + `]}}`))
+	}))
+	t.Cleanup(handler.Close)
+	return handler.URL
+}
+
+func TestHistogramMetric(t *testing.T) {
+	requires := require.New(t)
+	server := &FakeHistogramServer{counts: [][]float64{{100, 150, 200}, {150, 250, 300}, {150, 250, 300}}}
+	host := server.serve(t)
+	metric := Metric{Name: "p90", Type: "histogram", Query: `http_seconds_bucket{job="api"}`, Quantile: 0.9, Scale: 1000, MaxValue: 400}
+	gathers := newMetricGathers(Sources{host: host}, []Metric{metric})
+	requires.Equal(-1, gathers[0].gather(context.Background()))
+	requires.Equal(420, gathers[0].gather(context.Background()))
+	requires.Equal(420, gathers[0].gather(context.Background()))
+
+	server.counts = [][]float64{{150, 250, 300}, {100, 150, 200}}
+	server.queried = nil
+	metric.Window = Duration(5 * time.Minute)
+	metric.Quantile = 0.5
+	gathers = newMetricGathers(Sources{host: host}, []Metric{metric})
+	requires.Equal(100, gathers[0].gather(context.Background()))
+	requires.Len(server.queried, 2)
+	now, err := strconv.ParseFloat(server.queried[0], 64)
+	requires.NoError(err)
+	start, err := strconv.ParseFloat(server.queried[1], 64)
+	requires.NoError(err)
+	requires.InDelta(300, now-start, 0.01)
+
+	server.counts = [][]float64{{10, 10, 10}}
+	requires.Equal(-1, gathers[0].gather(context.Background()))
+	metric.OnMissing = "treatAsMax"
+	requires.Equal(400, newMetricGathers(Sources{host: host}, []Metric{metric})[0].gather(context.Background()))
+	metric.Query = "other_bucket"
+	requires.Equal(400, newMetricGathers(Sources{host: host}, []Metric{metric})[0].gather(context.Background()))
+}
+
+func TestValidateHistogramMetric(t *testing.T) {
+	requires := require.New(t)
+	metric := Metric{Name: "p99", Type: "histogram", Query: "http_seconds_bucket", Quantile: 0.99}
+	requires.NoError(validateHistogramMetric(metric))
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{metric}}))
+	requires.ErrorContains(validateHistogramMetric(Metric{Quantile: 0.5}), "query is not set")
+	requires.ErrorContains(validateHistogramMetric(Metric{Query: "a", Quantile: 1}), "quantile 1 must be between 0 and 1")
+	requires.ErrorContains(validateHistogramMetric(Metric{Query: "a"}), "quantile 0 must be between 0 and 1")
+	requires.ErrorContains(validateHistogramMetric(Metric{Query: "a", Quantile: 0.5, Scale: -1}), "scale must not be negative")
+	requires.ErrorContains(validateHistogramMetric(Metric{Query: "a", Quantile: 0.5, Window: -1}), "window must not be negative")
+	metric.Query = "http_seconds_bucket{"
+	requires.ErrorContains(validatePromQL(Config{Metrics: []Metric{metric}}), "metric p99: query")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+type HistoryConfig struct {
+	KeepRuns int `yaml:"keepRuns"`
+	KeepDays int `yaml:"keepDays"`
+}
+
+func (config HistoryConfig) enabled() bool {
+	return config.KeepRuns > 0 || config.KeepDays > 0
+}
+
+func validateHistory(config HistoryConfig) error {
+	if config.KeepRuns < 0 || config.KeepDays < 0 {
+		return errors.New("history: keepRuns and keepDays must not be negative")
+	}
+	return nil
+}
+
+// historyLabels is the label set runs are kept per: runs of the same
+// config (workDir and host) on the same trends.branch.
+func historyLabels(run RunMetadata) string {
+	return run.WorkDir + "\x00" + run.Host + "\x00" + run.Branch
+}
+
+// expiredRuns returns the runs beyond the last keepRuns or older than
+// keepDays of their label set; runs are sorted by start, oldest first.
+func expiredRuns(runs []RunMetadata, config HistoryConfig, now time.Time) []RunMetadata {
+	newer := map[string]int{}
+	expired := make([]RunMetadata, 0)
+	for n := len(runs) - 1; n >= 0; n-- {
+		run := runs[n]
+		labels := historyLabels(run)
+		newer[labels]++
+		switch {
+		case config.KeepRuns > 0 && newer[labels] > config.KeepRuns:
+		case config.KeepDays > 0 && run.Started.Before(now.AddDate(0, 0, -config.KeepDays)):
+		default:
+			continue
+		}
+		expired = append(expired, run)
+	}
+	return expired
+}
+
+func pruneHistory(baseDir string, config HistoryConfig, now time.Time, dryRun bool) ([]RunMetadata, error) {
+	runs, err := loadHistory(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	expired := expiredRuns(runs, config, now)
+	if dryRun {
+		return expired, nil
+	}
+	for n, run := range expired {
+		if err := os.RemoveAll(run.dir); err != nil {
+			return expired[:n], fmt.Errorf("run %s: %w", run.RunID, err)
+		}
+	}
+	return expired, nil
+}
+
+// pruneHistory runs after the metadata of a run is written, so the run
+// itself counts as the newest of its label set.
+func (app App) pruneHistory(config Config) {
+	if !config.History.enabled() {
+		return
+	}
+	pruned, err := pruneHistory(app.outputBaseDir(config), config.History, time.Now(), false)
+	if err != nil {
+		log.Println("history prune error:", err)
+	}
+	if len(pruned) > 0 {
+		log.Println("history: pruned", len(pruned), "runs")
+	}
+}
+
+func (app App) historyPrune(w io.Writer) int {
+	config, err := app.optionalConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if app.history.KeepRuns > 0 {
+		config.History.KeepRuns = app.history.KeepRuns
+	}
+	if app.history.KeepDays > 0 {
+		config.History.KeepDays = app.history.KeepDays
+	}
+	if !config.History.enabled() {
+		log.Println("no retention: set history.keepRuns or history.keepDays in --config, or --keep-runs or --keep-days")
+		return 2
+	}
+	pruned, err := pruneHistory(app.outputBaseDir(config), config.History, time.Now(), app.dryRun)
+	for _, run := range pruned {
+		fmt.Fprintf(w, "%s  %s  %s\n", run.RunID, run.Started.Format(time.DateTime), run.dir)
+	}
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	return 0
+}
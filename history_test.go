@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeHistory(t *testing.T, dir string, runs []RunMetadata) {
+	for _, metadata := range runs {
+		runDir := filepath.Join(dir, metadata.RunID)
+		require.NoError(t, os.MkdirAll(runDir, 0o755))
+		b, _ := json.Marshal(metadata)
+		require.NoError(t, os.WriteFile(filepath.Join(runDir, "metadata.json"), b, 0o644))
+	}
+}
+
+func runIDs(runs []RunMetadata) []string {
+	ids := make([]string, 0, len(runs))
+	for _, run := range runs {
+		ids = append(ids, run.RunID)
+	}
+	return ids
+}
+
+func TestExpiredRuns(t *testing.T) {
+	requires := require.New(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	runs := []RunMetadata{
+		{RunID: "m1", Started: now.AddDate(0, 0, -20), Branch: "main"},
+		{RunID: "f1", Started: now.AddDate(0, 0, -15), Branch: "feature"},
+		{RunID: "m2", Started: now.AddDate(0, 0, -10), Branch: "main"},
+		{RunID: "m3", Started: now.AddDate(0, 0, -5), Branch: "main"},
+		{RunID: "o1", Started: now.AddDate(0, 0, -4), Branch: "main", WorkDir: "other"},
+		{RunID: "m4", Started: now.AddDate(0, 0, -1), Branch: "main"},
+	}
+	variants := []struct {
+		config  HistoryConfig
+		expired []string
+	}{
+		{config: HistoryConfig{KeepRuns: 2}, expired: []string{"m2", "m1"}},
+		{config: HistoryConfig{KeepDays: 12}, expired: []string{"f1", "m1"}},
+		{config: HistoryConfig{KeepRuns: 3, KeepDays: 7}, expired: []string{"m2", "f1", "m1"}},
+		{config: HistoryConfig{KeepRuns: 10}, expired: []string{}},
+	}
+	for n, variant := range variants {
+		requires.Equal(variant.expired, runIDs(expiredRuns(runs, variant.config, now)), n)
+	}
+	requires.ErrorContains(validateHistory(HistoryConfig{KeepRuns: -1}), "must not be negative")
+	requires.ErrorContains(checkRemoteConfig(Config{History: HistoryConfig{KeepRuns: 5}}), "history retention is not allowed")
+}
+
+func TestCommandHistoryPrune(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	now := time.Now()
+	writeHistory(t, dir, []RunMetadata{
+		{RunID: "r1", Started: now.Add(-3 * time.Hour)},
+		{RunID: "r2", Started: now.Add(-2 * time.Hour)},
+		{RunID: "r3", Started: now.Add(-time.Hour)},
+	})
+	requires.NoError(os.MkdirAll(filepath.Join(dir, "crashed"), 0o755))
+
+	var b bytes.Buffer
+	requires.Equal(2, runMain([]string{"history", "prune", "--output-dir", dir}, &b))
+	requires.Equal(0, runMain([]string{"history", "prune", "--output-dir", dir, "--keep-runs", "1", "--dry-run"}, &b))
+	requires.Contains(b.String(), filepath.Join(dir, "r1"))
+	requires.DirExists(filepath.Join(dir, "r1"))
+
+	config := filepath.Join(dir, "config.yaml")
+	requires.NoError(os.WriteFile(config, []byte("outputDir: "+dir+"\nhistory:\n  keepRuns: 2\nmetrics:\n  - name: errors\n    query: errors\n"), 0o644))
+	b.Reset()
+	requires.Equal(0, runMain([]string{"history", "prune", "--config", config}, &b))
+	requires.Equal(1, bytes.Count(b.Bytes(), []byte("\n")))
+	requires.Contains(b.String(), "r1  ")
+	requires.NoDirExists(filepath.Join(dir, "r1"))
+	requires.DirExists(filepath.Join(dir, "r2"))
+	requires.DirExists(filepath.Join(dir, "crashed"))
+
+	App{outputDir: dir}.pruneHistory(Config{History: HistoryConfig{KeepRuns: 1}})
+	requires.Equal([]string{"r3"}, runIDs(mustLoadHistory(t, dir)))
+}
+
+func mustLoadHistory(t *testing.T, dir string) []RunMetadata {
+	runs, err := loadHistory(dir)
+	require.NoError(t, err)
+	return runs
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultProbeTimeout = 10 * time.Second
+
+var httpProbeStats = []string{"latency", "status", "status_class", "cert_days"}
+
+type HTTPProbeConfig struct {
+	Method             string            `yaml:"method"`
+	Headers            map[string]string `yaml:"headers"`
+	ExpectStatus       []int             `yaml:"expectStatus"`
+	MinCertDays        int               `yaml:"minCertDays"`
+	InsecureSkipVerify bool              `yaml:"insecureSkipVerify"`
+	Timeout            Duration          `yaml:"timeout"`
+}
+
+func (config HTTPProbeConfig) expected(status int) bool {
+	if len(config.ExpectStatus) == 0 {
+		return status < 400
+	}
+	return slices.Contains(config.ExpectStatus, status)
+}
+
+type HTTPProbeResult struct {
+	latency  time.Duration
+	status   int
+	tls      bool
+	certDays float64
+}
+
+type HTTPProbeMetric struct {
+	client   *http.Client
+	headers  http.Header
+	Name     string
+	URL      string
+	Stat     string
+	Probe    HTTPProbeConfig
+	MaxValue int
+}
+
+// newHTTPProbeClient opens a new connection for every probe, so the latency
+// includes DNS, connect and the TLS handshake like blackbox_exporter does.
+func newHTTPProbeClient(config HTTPProbeConfig) *http.Client {
+	timeout := time.Duration(config.Timeout)
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify},
+		},
+	}
+}
+
+func (metric HTTPProbeMetric) name() string {
+	return metric.Name
+}
+
+func (metric HTTPProbeMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric HTTPProbeMetric) probe(ctx context.Context) (HTTPProbeResult, error) {
+	result := HTTPProbeResult{}
+	method := metric.Probe.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, metric.URL, nil)
+	if err != nil {
+		return result, err
+	}
+	for key, values := range metric.headers {
+		req.Header[key] = values
+	}
+	for key, value := range metric.Probe.Headers {
+		req.Header.Set(key, value)
+	}
+	start := time.Now()
+	resp, err := metric.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return result, err
+	}
+	result.latency, result.status = time.Since(start), resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result.tls = true
+		result.certDays = time.Until(resp.TLS.PeerCertificates[0].NotAfter).Hours() / 24
+	}
+	switch {
+	case !metric.Probe.expected(result.status):
+		return result, fmt.Errorf("unexpected status %d", result.status)
+	case result.tls && result.certDays < 0:
+		return result, errors.New("certificate expired")
+	case result.tls && result.certDays < float64(metric.Probe.MinCertDays):
+		return result, fmt.Errorf("certificate expires in %.1f days, minCertDays is %d", result.certDays, metric.Probe.MinCertDays)
+	}
+	return result, nil
+}
+
+// gather turns a failed probe into a violation whatever the stat.
+func (metric HTTPProbeMetric) gather(ctx context.Context) int {
+	result, err := metric.probe(ctx)
+	if err != nil {
+		log.Printf("WARNING: probe %s failed: %v\n", metric.Name, err)
+		return missingValue
+	}
+	switch metric.Stat {
+	case "status":
+		return result.status
+	case "status_class":
+		return result.status / 100
+	case "cert_days":
+		return int(result.certDays)
+	}
+	return int(result.latency.Milliseconds())
+}
+
+func validateHTTPProbeMetric(metric Metric) error {
+	target, err := url.Parse(metric.Target)
+	if metric.Target == "" {
+		return errors.New("target is not set")
+	}
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("target %q must be an http or https URL", metric.Target)
+	}
+	if metric.Query != "" && !slices.Contains(httpProbeStats, metric.Query) {
+		return fmt.Errorf("unknown httpProbe stat %q, expected one of %s", metric.Query, strings.Join(httpProbeStats, ", "))
+	}
+	if metric.Query == "cert_days" && target.Scheme != "https" {
+		return errors.New("cert_days needs an https target")
+	}
+	for _, status := range metric.Probe.ExpectStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("probe.expectStatus %d is not an HTTP status", status)
+		}
+	}
+	if metric.Probe.MinCertDays < 0 {
+		return errors.New("probe.minCertDays must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPProbeMetric(t *testing.T) {
+	requires := require.New(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			requires.Equal("r1", r.Header.Get("X-Run-Id"))
+			requires.Equal("Bearer secret", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte("ok"))
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+	headers := http.Header{"X-Run-Id": {"r1"}}
+	authorized := HTTPProbeConfig{Headers: map[string]string{"Authorization": "Bearer secret"}}
+	variants := []struct {
+		url   string
+		stat  string
+		probe HTTPProbeConfig
+		check func(value int)
+	}{
+		{url: server.URL + "/health", stat: "status", probe: authorized, check: func(value int) { requires.Equal(200, value) }},
+		{url: server.URL + "/health", stat: "status_class", probe: authorized, check: func(value int) { requires.Equal(2, value) }},
+		{url: server.URL + "/slow", check: func(value int) { requires.GreaterOrEqual(value, 50) }},
+		{url: server.URL + "/down", stat: "status", check: func(value int) { requires.Equal(missingValue, value) }},
+		{url: server.URL + "/down", stat: "status", probe: HTTPProbeConfig{ExpectStatus: []int{503}}, check: func(value int) { requires.Equal(503, value) }},
+		{url: server.URL + "/created", stat: "status", probe: HTTPProbeConfig{ExpectStatus: []int{200}}, check: func(value int) { requires.Equal(missingValue, value) }},
+		{url: server.URL + "/slow", probe: HTTPProbeConfig{Timeout: Duration(10 * time.Millisecond)}, check: func(value int) { requires.Equal(missingValue, value) }},
+		{url: "http://127.0.0.1:1/", check: func(value int) { requires.Equal(missingValue, value) }},
+		{url: secure.URL + "/created", stat: "status", check: func(value int) { requires.Equal(missingValue, value) }},
+		{url: secure.URL + "/created", stat: "cert_days", probe: HTTPProbeConfig{InsecureSkipVerify: true}, check: func(value int) { requires.Greater(value, 365) }},
+		{url: secure.URL + "/created", stat: "cert_days", probe: HTTPProbeConfig{InsecureSkipVerify: true, MinCertDays: 1 << 20}, check: func(value int) { requires.Equal(missingValue, value) }},
+	}
+	for _, variant := range variants {
+		metric := HTTPProbeMetric{client: newHTTPProbeClient(variant.probe), headers: headers, Name: "probe", URL: variant.url, Stat: variant.stat, Probe: variant.probe}
+		variant.check(metric.gather(context.Background()))
+	}
+
+	gathers := newMetricGathers(Sources{}, []Metric{{Name: "probe", Type: "httpProbe", Target: server.URL + "/created", Query: "status", MaxValue: 299}})
+	requires.Equal(201, gathers[0].gather(context.Background()))
+	requires.Equal(299, gathers[0].maxValue())
+
+	config := redactConfig(Config{Scenarios: []Scenario{{Name: "s", Metrics: []Metric{{Name: "probe", Probe: authorized}}}}})
+	requires.Equal(redacted, config.Scenarios[0].Metrics[0].Probe.Headers["Authorization"])
+	requires.Equal("Bearer secret", authorized.Headers["Authorization"])
+}
+
+func TestValidateHTTPProbeMetric(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		metric Metric
+		err    string
+	}{
+		{metric: Metric{Target: "https://shop.example.com/health"}},
+		{metric: Metric{Target: "https://shop.example.com", Query: "cert_days", Probe: HTTPProbeConfig{MinCertDays: 14}}},
+		{metric: Metric{}, err: "target is not set"},
+		{metric: Metric{Target: "shop.example.com/health"}, err: `target "shop.example.com/health" must be an http or https URL`},
+		{metric: Metric{Target: "http://shop", Query: "ttfb"}, err: `unknown httpProbe stat "ttfb"`},
+		{metric: Metric{Target: "http://shop", Query: "cert_days"}, err: "cert_days needs an https target"},
+		{metric: Metric{Target: "http://shop", Probe: HTTPProbeConfig{ExpectStatus: []int{2}}}, err: "probe.expectStatus 2 is not an HTTP status"},
+		{metric: Metric{Target: "http://shop", Probe: HTTPProbeConfig{MinCertDays: -1}}, err: "probe.minCertDays must not be negative"},
+	}
+	for _, variant := range variants {
+		variant.metric.Name, variant.metric.Type = "probe", "httpProbe"
+		err := validateMetricTypes(Config{Metrics: []Metric{variant.metric}})
+		if variant.err == "" {
+			requires.NoError(err)
+			continue
+		}
+		requires.ErrorContains(err, "metric probe: "+variant.err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// packs are the bundled metric packs, included with include: [pack:<name>].
+//
+//go:embed packs/*.yaml
+var packs embed.FS
+
+func readInclude(path string) ([]byte, error) {
+	name, ok := strings.CutPrefix(path, "pack:")
+	if !ok {
+		return os.ReadFile(path)
+	}
+	b, err := packs.ReadFile("packs/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("unknown pack %s", name)
+	}
+	return b, nil
+}
+
+func includeConfigs(fileName string, b []byte) ([]byte, error) {
+	return resolveIncludes(fileName, b, []string{filepath.Clean(fileName)})
+}
+
+func resolveIncludes(fileName string, b []byte, chain []string) ([]byte, error) {
+	raw := ConfigYAML{}
+	if err := decodeStrict(b, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw.Include) == 0 {
+		return b, nil
+	}
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "include")
+	var merged any = map[string]any{}
+	for _, include := range raw.Include {
+		path := include
+		if !strings.HasPrefix(path, "pack:") {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(fileName), path)
+			}
+			path = filepath.Clean(path)
+		}
+		if slices.Contains(chain, path) {
+			return nil, fmt.Errorf("include %s: cycle %s", include, strings.Join(append(chain, path), " -> "))
+		}
+		included, err := readInclude(path)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", include, err)
+		}
+		included, err = resolveIncludes(path, expandEnv(included, os.LookupEnv), append(chain, path))
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", include, err)
+		}
+		part := map[string]any{}
+		if err := yaml.Unmarshal(included, &part); err != nil {
+			return nil, fmt.Errorf("include %s: %w", include, err)
+		}
+		merged = mergeValues(merged, part)
+	}
+	return yaml.Marshal(mergeValues(merged, doc))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		fileName := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fileName), fs.ModePerm))
+		require.NoError(t, os.WriteFile(fileName, []byte(content), fs.ModePerm))
+	}
+	return dir
+}
+
+func TestAppLoadConfigInclude(t *testing.T) {
+	requires := require.New(t)
+	t.Setenv("MG_JVM_LIMIT", "90")
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `
+include: [packs/common-metrics.yaml, packs/jvm-metrics.yaml]
+host: http://localhost:9090
+metrics:
+  - name: errors
+    maxValue: 5
+  - name: latency
+    query: p99
+    maxValue: 500
+`,
+		"packs/common-metrics.yaml": `
+testDuration: 60
+vars: {service: api}
+metrics:
+  - name: errors
+    query: sum(errors{service="{{ .service }}"})
+    maxValue: 1
+`,
+		"packs/jvm-metrics.yaml": `
+include: [gc.yaml]
+metrics:
+  - name: heap
+    query: jvm_memory_used_bytes
+    maxValue: ${MG_JVM_LIMIT}
+`,
+		"packs/gc.yaml": `
+metrics:
+  - name: gc_pause
+    query: jvm_gc_pause_seconds_max
+    maxValue: 1
+`,
+	})
+	config, err := App{}.loadConfig(filepath.Join(dir, "config.yaml"))
+	requires.NoError(err)
+	requires.Equal("http://localhost:9090", config.Host)
+	requires.Equal(Duration(60_000_000_000), config.TestDuration)
+	requires.Equal(map[string]string{"service": "api"}, config.Vars)
+	requires.Equal([]Metric{
+		{Name: "errors", Query: `sum(errors{service="{{ .service }}"})`, MaxValue: 5},
+		{Name: "gc_pause", Query: "jvm_gc_pause_seconds_max", MaxValue: 1},
+		{Name: "heap", Query: "jvm_memory_used_bytes", MaxValue: 90},
+		{Name: "latency", Query: "p99", MaxValue: 500},
+	}, config.Metrics)
+}
+
+func TestIncludeConfigsErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"missing.yaml": "include: [nope.yaml]",
+		"cycle.yaml":   "include: [loop.yaml]",
+		"loop.yaml":    "include: [cycle.yaml]",
+		"self.yaml":    "include: [./self.yaml]",
+		"typo.yaml":    "include: [bad.yaml]",
+		"bad.yaml":     "metrics:\n  - name: a\n    maxVaule: 1\n",
+		"scalar.yaml":  "include: common.yaml",
+	})
+	variants := []struct {
+		file string
+		err  string
+	}{
+		{file: "missing.yaml", err: "include nope.yaml: open " + filepath.Join(dir, "nope.yaml")},
+		{file: "cycle.yaml", err: "include cycle.yaml: cycle " + filepath.Join(dir, "cycle.yaml") + " -> " + filepath.Join(dir, "loop.yaml") + " -> " + filepath.Join(dir, "cycle.yaml")},
+		{file: "self.yaml", err: "include ./self.yaml: cycle"},
+		{file: "typo.yaml", err: "include bad.yaml: config errors:\n  line 3: unknown key maxVaule, did you mean maxValue?"},
+		{file: "scalar.yaml", err: "line 1: cannot unmarshal !!str `common....` into []string"},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		fileName := filepath.Join(dir, variant.file)
+		b, err := os.ReadFile(fileName)
+		requires.NoError(err)
+		_, err = includeConfigs(fileName, b)
+		requires.ErrorContains(err, variant.err, variant.file)
+	}
+
+	b := []byte("host: http://localhost:9090\n")
+	resolved, err := includeConfigs(filepath.Join(dir, "plain.yaml"), b)
+	requires.NoError(err)
+	requires.Equal(b, resolved)
+
+	_, err = App{}.parseConfig([]byte("include: [common.yaml]"))
+	requires.ErrorContains(err, "include is only supported in config files")
+}
+
+func TestIncludePack(t *testing.T) {
+	requires := require.New(t)
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `
+include: [pack:jvm]
+testDuration: 60
+jolokia: {url: http://localhost:8778/jolokia}
+metrics:
+  - name: jvm_heap_used_mb
+    maxValue: 1500
+`,
+	})
+	config, err := App{}.loadConfig(filepath.Join(dir, "config.yaml"))
+	requires.NoError(err)
+	requires.Len(config.Metrics, 6)
+	requires.Equal(Metric{Name: "jvm_heap_used_mb", Type: "jolokia", Query: "java.lang:type=Memory", Path: "HeapMemoryUsage/used",
+		Scale: 0.000001, Tags: []string{"jvm"}, MaxValue: 1500}, config.Metrics[0])
+	requires.NoError(validateMetricTypes(config))
+
+	_, err = includeConfigs(filepath.Join(dir, "unknown.yaml"), []byte("include: [pack:cobol]\n"))
+	requires.ErrorContains(err, "include pack:cobol: unknown pack cobol")
+}
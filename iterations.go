@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+func validateIterations(config Config) error {
+	if config.Iterations < 0 {
+		return errors.New("iterations must not be negative")
+	}
+	if config.Iterations > 0 && config.TestDuration > 0 {
+		return errors.New("testDuration and iterations are exclusive")
+	}
+	for _, scenario := range config.Scenarios {
+		if scenario.Iterations < 0 {
+			return fmt.Errorf("scenario %s: iterations must not be negative", scenario.Name)
+		}
+		if scenario.Iterations > 0 && scenario.Duration > 0 {
+			return fmt.Errorf("scenario %s: duration and iterations are exclusive", scenario.Name)
+		}
+	}
+	return nil
+}
+
+// expectedDuration is how long gathering takes: the duration, or for a
+// number of iterations the ticks at the tick interval.
+func expectedDuration(duration Duration, iterations int, interval Duration) time.Duration {
+	if iterations > 0 {
+		return time.Duration(iterations) * time.Duration(interval)
+	}
+	return time.Duration(duration)
+}
+
+func (scheduler *Scheduler) iterationsDone(ticks int) bool {
+	if scheduler.iterations <= 0 || ticks < scheduler.iterations {
+		return false
+	}
+	log.Println("=[ iterations done ]===================")
+	return true
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerIterations(t *testing.T) {
+	requires := require.New(t)
+	eventer := &SlowEventer{}
+	scheduler := &Scheduler{eventer: eventer, iterations: 3, timeout: 100 * time.Millisecond}
+	scheduler.run()
+	requires.Len(eventer.fired, 3)
+
+	clock := NewSimulatedClock(time.Now())
+	first, second := &FakeEventer{}, &FakeEventer{}
+	scheduler = &Scheduler{
+		clock: clock,
+		scenarios: []ScenarioRun{
+			{name: "baseline", eventer: first, iterations: 3},
+			{name: "soak", eventer: second, testDuration: time.Minute},
+		},
+		timeout: 10 * time.Second,
+	}
+	requires.Equal(90*time.Second, scheduler.plannedDuration())
+	scheduler.run()
+	requires.Equal(3, first.fired)
+	requires.Equal(6, second.fired)
+}
+
+func TestResolveIterations(t *testing.T) {
+	requires := require.New(t)
+	config, err := resolveScenarios(Config{Iterations: 10, Scenarios: []Scenario{{Name: "a"}, {Name: "b", Iterations: 3}, {Name: "c", Duration: Duration(time.Minute)}}})
+	requires.NoError(err)
+	requires.Equal([]Scenario{{Name: "a", Iterations: 10}, {Name: "b", Iterations: 3}, {Name: "c", Duration: Duration(time.Minute)}}, config.Scenarios)
+
+	overrides := Overrides{}
+	requires.NoError(overrides.duration.Set("30s"))
+	config, err = overrides.apply(Config{Iterations: 10, Scenarios: []Scenario{{Name: "a", Iterations: 3}}})
+	requires.NoError(err)
+	requires.Zero(config.Iterations)
+	requires.Equal(Scenario{Name: "a", Duration: Duration(30 * time.Second)}, config.Scenarios[0])
+
+	requires.Equal(30*time.Second, expectedDuration(0, 3, Duration(10*time.Second)))
+	requires.Equal(time.Minute, expectedDuration(Duration(time.Minute), 0, Duration(10*time.Second)))
+
+	variants := []struct {
+		config Config
+		err    string
+	}{
+		{config: Config{Iterations: -1}, err: "iterations must not be negative"},
+		{config: Config{Iterations: 5, TestDuration: Duration(time.Minute)}, err: "testDuration and iterations are exclusive"},
+		{config: Config{Scenarios: []Scenario{{Name: "a", Iterations: -2}}}, err: "scenario a: iterations must not be negative"},
+		{config: Config{Scenarios: []Scenario{{Name: "a", Iterations: 2, Duration: Duration(time.Minute)}}}, err: "scenario a: duration and iterations are exclusive"},
+	}
+	for _, variant := range variants {
+		requires.ErrorContains(validateIterations(variant.config), variant.err)
+	}
+	requires.NoError(validateIterations(Config{Iterations: 5}))
+}
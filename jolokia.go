@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type JolokiaConfig struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type JolokiaClient struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+}
+
+func NewJolokiaClient(config JolokiaConfig) *JolokiaClient {
+	return &JolokiaClient{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		url:      strings.TrimSuffix(config.URL, "/"),
+		username: config.Username,
+		password: config.Password,
+	}
+}
+
+type JolokiaRequest struct {
+	Type      string `json:"type"`
+	MBean     string `json:"mbean"`
+	Attribute string `json:"attribute"`
+	Path      string `json:"path,omitempty"`
+}
+
+type JolokiaResponse struct {
+	Status    int    `json:"status"`
+	Value     any    `json:"value"`
+	Error     string `json:"error"`
+	ErrorType string `json:"error_type"`
+}
+
+var errNoJolokiaValue = errors.New("no such mbean or attribute")
+
+// read reads an attribute of an MBean; path is the attribute and, after a
+// slash, the path inside a composite value such as HeapMemoryUsage/used.
+func (client *JolokiaClient) read(ctx context.Context, target string, mbean string, path string) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	attribute, inner, _ := strings.Cut(path, "/")
+	body, err := json.Marshal(JolokiaRequest{Type: "read", MBean: mbean, Attribute: attribute, Path: inner})
+	if err != nil {
+		return nil, err
+	}
+	url := client.url
+	if target != "" {
+		url = strings.TrimSuffix(target, "/")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client.username != "" {
+		req.SetBasicAuth(client.username, client.password)
+	}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	response := JolokiaResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	switch response.Status {
+	case http.StatusOK:
+		return response.Value, nil
+	case http.StatusNotFound:
+		return nil, errNoJolokiaValue
+	}
+	return nil, fmt.Errorf("jolokia %d: %s", response.Status, response.Error)
+}
+
+// jolokiaSum is the value of a number, the sum of the numbers of an object,
+// e.g. CollectionTime of every collector read with an MBean pattern.
+func jolokiaSum(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case map[string]any:
+		sum, found := 0.0, false
+		for _, item := range value {
+			if number, ok := jolokiaSum(item); ok {
+				sum, found = sum+number, true
+			}
+		}
+		return sum, found
+	}
+	return 0, false
+}
+
+type JolokiaMetric struct {
+	client    *JolokiaClient
+	Name      string
+	Target    string
+	MBean     string
+	Path      string
+	Scale     float64
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric JolokiaMetric) name() string {
+	return metric.Name
+}
+
+func (metric JolokiaMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric JolokiaMetric) gather(ctx context.Context) int {
+	result, err := metric.client.read(ctx, metric.Target, metric.MBean, metric.Path)
+	if errors.Is(err, errNoJolokiaValue) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("Error querying Jolokia: %v\n", err)
+		return -1
+	}
+	value, ok := jolokiaSum(result)
+	if !ok {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	scale := metric.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return int(math.Round(value * scale))
+}
+
+func validateJolokiaMetric(config Config, metric Metric) error {
+	if config.Jolokia.URL == "" && metric.Target == "" {
+		return fmt.Errorf("jolokia.url or target is not set")
+	}
+	if metric.Query == "" {
+		return fmt.Errorf("query is not set (the mbean)")
+	}
+	if metric.Path == "" {
+		return fmt.Errorf("path is not set (the attribute, e.g. HeapMemoryUsage/used)")
+	}
+	if metric.Scale < 0 {
+		return fmt.Errorf("scale must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJolokiaMetric(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		requires.Equal("perf:secret", username+":"+password)
+		request := JolokiaRequest{}
+		requires.NoError(json.NewDecoder(r.Body).Decode(&request))
+		response := JolokiaResponse{Status: http.StatusOK}
+		switch request.MBean + " " + request.Attribute + " " + request.Path {
+		case "java.lang:type=Memory HeapMemoryUsage used":
+			response.Value = 268435456
+		case "java.lang:type=GarbageCollector,name=* CollectionTime ":
+			response.Value = map[string]any{
+				"java.lang:name=G1 Young Generation,type=GarbageCollector": map[string]any{"CollectionTime": 120},
+				"java.lang:name=G1 Old Generation,type=GarbageCollector":   map[string]any{"CollectionTime": 30},
+			}
+		case "java.lang:type=Threading ThreadCount ":
+			response.Value = 42
+		case "java.lang:type=Runtime VmName ":
+			response.Value = "OpenJDK 64-Bit Server VM"
+		case "java.lang:type=Memory Broken ":
+			response = JolokiaResponse{Status: http.StatusInternalServerError, Error: "broken"}
+		default:
+			response = JolokiaResponse{Status: http.StatusNotFound, Error: "javax.management.InstanceNotFoundException"}
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	variants := []struct {
+		mbean     string
+		path      string
+		scale     float64
+		onMissing string
+		value     int
+	}{
+		{mbean: "java.lang:type=Memory", path: "HeapMemoryUsage/used", scale: 0.000001, value: 268},
+		{mbean: "java.lang:type=GarbageCollector,name=*", path: "CollectionTime", value: 150},
+		{mbean: "java.lang:type=Threading", path: "ThreadCount", value: 42},
+		{mbean: "java.lang:type=Runtime", path: "VmName", value: -1},
+		{mbean: "java.lang:type=Memory", path: "Broken", onMissing: "treatAsZero", value: -1},
+		{mbean: "java.lang:type=Nope", path: "Count", onMissing: "treatAsZero", value: 0},
+	}
+	sources := Sources{jolokia: NewJolokiaClient(JolokiaConfig{URL: server.URL + "/", Username: "perf", Password: "secret"})}
+	for n, variant := range variants {
+		gathers := newMetricGathers(sources, []Metric{{Name: "jvm", Type: "jolokia", Query: variant.mbean, Path: variant.path,
+			Scale: variant.scale, OnMissing: variant.onMissing}})
+		requires.Equal(variant.value, gathers[0].gather(context.Background()), n)
+	}
+
+	metric := JolokiaMetric{client: NewJolokiaClient(JolokiaConfig{Username: "perf", Password: "secret"}), Name: "threads",
+		Target: server.URL, MBean: "java.lang:type=Threading", Path: "ThreadCount"}
+	requires.Equal(42, metric.gather(context.Background()))
+}
+
+func TestValidateJolokiaMetric(t *testing.T) {
+	requires := require.New(t)
+	config := Config{Jolokia: JolokiaConfig{URL: "http://localhost:8778/jolokia"}}
+	requires.NoError(validateJolokiaMetric(config, Metric{Query: "java.lang:type=Threading", Path: "ThreadCount"}))
+	requires.NoError(validateJolokiaMetric(Config{}, Metric{Target: "http://app:8778/jolokia", Query: "java.lang:type=Threading", Path: "ThreadCount"}))
+	requires.ErrorContains(validateJolokiaMetric(Config{}, Metric{Query: "java.lang:type=Threading"}), "jolokia.url or target is not set")
+	requires.ErrorContains(validateJolokiaMetric(config, Metric{Path: "ThreadCount"}), "query is not set")
+	requires.ErrorContains(validateJolokiaMetric(config, Metric{Query: "java.lang:type=Threading"}), "path is not set")
+	requires.ErrorContains(validateJolokiaMetric(config, Metric{Query: "java.lang:type=Threading", Path: "ThreadCount", Scale: -1}), "scale must not be negative")
+
+	config.Metrics = []Metric{{Name: "threads", Type: "jolokia"}}
+	requires.ErrorContains(validateMetricTypes(config), "metric threads: query is not set")
+
+	redactedConfig := redactConfig(Config{Jolokia: JolokiaConfig{Password: "secret"}})
+	requires.Equal(redacted, redactedConfig.Jolokia.Password)
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var kubernetesStats = []string{"cpu", "memory", "node_cpu", "node_memory", "pods", "pods_ready", "restarts", "nodes", "nodes_ready"}
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+type KubernetesConfig struct {
+	Kubeconfig         string `yaml:"kubeconfig"`
+	Context            string `yaml:"context"`
+	URL                string `yaml:"url"`
+	Token              string `yaml:"token"`
+	CAFile             string `yaml:"caFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	InCluster          bool   `yaml:"inCluster"`
+	Namespace          string `yaml:"namespace"`
+}
+
+func (config KubernetesConfig) enabled() bool {
+	return config.Kubeconfig != "" || config.URL != "" || config.InCluster
+}
+
+type KubeconfigCluster struct {
+	Name    string `yaml:"name"`
+	Cluster struct {
+		Server                   string `yaml:"server"`
+		CertificateAuthority     string `yaml:"certificate-authority"`
+		CertificateAuthorityData string `yaml:"certificate-authority-data"`
+		InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+	} `yaml:"cluster"`
+}
+
+type KubeconfigUser struct {
+	Name string `yaml:"name"`
+	User struct {
+		Token                 string `yaml:"token"`
+		TokenFile             string `yaml:"tokenFile"`
+		ClientCertificate     string `yaml:"client-certificate"`
+		ClientCertificateData string `yaml:"client-certificate-data"`
+		ClientKey             string `yaml:"client-key"`
+		ClientKeyData         string `yaml:"client-key-data"`
+	} `yaml:"user"`
+}
+
+type KubeconfigContext struct {
+	Name    string `yaml:"name"`
+	Context struct {
+		Cluster   string `yaml:"cluster"`
+		User      string `yaml:"user"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"context"`
+}
+
+type KubeconfigFile struct {
+	CurrentContext string              `yaml:"current-context"`
+	Clusters       []KubeconfigCluster `yaml:"clusters"`
+	Users          []KubeconfigUser    `yaml:"users"`
+	Contexts       []KubeconfigContext `yaml:"contexts"`
+}
+
+type KubernetesClient struct {
+	client    *http.Client
+	url       string
+	token     string
+	tokenFile string
+	namespace string
+}
+
+// kubeconfigData reads an inline base64 value or else a file relative to dir.
+func kubeconfigData(data string, fileName string, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if fileName == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(dir, fileName)
+	}
+	return os.ReadFile(fileName)
+}
+
+func NewKubernetesClient(config KubernetesConfig, workDir string) (*KubernetesClient, error) {
+	client := &KubernetesClient{url: strings.TrimSuffix(config.URL, "/"), token: config.Token, namespace: config.Namespace}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	var ca []byte
+	var err error
+	switch {
+	case config.URL != "":
+		if ca, err = kubeconfigData("", config.CAFile, workDir); err != nil {
+			return nil, err
+		}
+	case config.InCluster:
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, errors.New("kubernetes.inCluster: KUBERNETES_SERVICE_HOST is not set")
+		}
+		client.url = "https://" + net.JoinHostPort(host, port)
+		client.tokenFile = filepath.Join(serviceAccountDir, "token")
+		if ca, err = os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt")); err != nil {
+			return nil, err
+		}
+		if client.namespace == "" {
+			if b, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+				client.namespace = strings.TrimSpace(string(b))
+			}
+		}
+	default:
+		if ca, err = client.loadKubeconfig(config, workDir, tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("kubernetes: no certificates in the cluster CA")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if client.namespace == "" {
+		client.namespace = "default"
+	}
+	client.client = &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	return client, nil
+}
+
+func (client *KubernetesClient) loadKubeconfig(config KubernetesConfig, workDir string, tlsConfig *tls.Config) ([]byte, error) {
+	fileName := config.Kubeconfig
+	if home, ok := strings.CutPrefix(fileName, "~/"); ok {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		fileName = filepath.Join(dir, home)
+	} else if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(workDir, fileName)
+	}
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig := KubeconfigFile{}
+	if err := yaml.Unmarshal(b, &kubeconfig); err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %w", fileName, err)
+	}
+	name := config.Context
+	if name == "" {
+		name = kubeconfig.CurrentContext
+	}
+	contextIndex := slices.IndexFunc(kubeconfig.Contexts, func(c KubeconfigContext) bool { return c.Name == name })
+	if contextIndex < 0 {
+		return nil, fmt.Errorf("kubeconfig %s: no context %q", fileName, name)
+	}
+	kubeContext := kubeconfig.Contexts[contextIndex].Context
+	if client.namespace == "" {
+		client.namespace = kubeContext.Namespace
+	}
+	dir := filepath.Dir(fileName)
+	clusterIndex := slices.IndexFunc(kubeconfig.Clusters, func(c KubeconfigCluster) bool { return c.Name == kubeContext.Cluster })
+	if clusterIndex < 0 {
+		return nil, fmt.Errorf("kubeconfig %s: no cluster %q", fileName, kubeContext.Cluster)
+	}
+	cluster := kubeconfig.Clusters[clusterIndex].Cluster
+	client.url = strings.TrimSuffix(cluster.Server, "/")
+	tlsConfig.InsecureSkipVerify = tlsConfig.InsecureSkipVerify || cluster.InsecureSkipTLSVerify
+	ca, err := kubeconfigData(cluster.CertificateAuthorityData, cluster.CertificateAuthority, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range kubeconfig.Users {
+		if user.Name != kubeContext.User {
+			continue
+		}
+		client.token = user.User.Token
+		if user.User.TokenFile != "" {
+			client.tokenFile = user.User.TokenFile
+			if !filepath.IsAbs(client.tokenFile) {
+				client.tokenFile = filepath.Join(dir, client.tokenFile)
+			}
+		}
+		cert, err := kubeconfigData(user.User.ClientCertificateData, user.User.ClientCertificate, dir)
+		if err != nil {
+			return nil, err
+		}
+		key, err := kubeconfigData(user.User.ClientKeyData, user.User.ClientKey, dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(cert) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("kubeconfig %s: user %s: %w", fileName, user.Name, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	return ca, nil
+}
+
+var errNoKubernetesObjects = errors.New("no matching objects")
+
+func (client *KubernetesClient) get(ctx context.Context, path string, selector string, value any) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if selector != "" {
+		path += "?" + url.Values{"labelSelector": {selector}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.url+path, nil)
+	if err != nil {
+		return err
+	}
+	token := client.token
+	if client.tokenFile != "" {
+		b, err := os.ReadFile(client.tokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kubernetes api %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+type KubernetesUsage struct {
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+type KubernetesMetricsList struct {
+	Items []struct {
+		Usage      KubernetesUsage `json:"usage"`
+		Containers []struct {
+			Usage KubernetesUsage `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+type KubernetesCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+type KubernetesObjectList struct {
+	Items []struct {
+		Status struct {
+			Conditions        []KubernetesCondition `json:"conditions"`
+			ContainerStatuses []struct {
+				RestartCount int `json:"restartCount"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func (list KubernetesObjectList) ready() int {
+	ready := 0
+	for _, item := range list.Items {
+		if slices.Contains(item.Status.Conditions, KubernetesCondition{Type: "Ready", Status: "True"}) {
+			ready++
+		}
+	}
+	return ready
+}
+
+// parseQuantity converts a resource quantity like 250m, 12345678n, 1.5 or 512Mi.
+func parseQuantity(text string) (float64, error) {
+	suffixes := []struct {
+		suffix string
+		factor float64
+	}{
+		{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+		{"n", 1e-9}, {"u", 1e-6}, {"m", 1e-3}, {"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+	}
+	for _, suffix := range suffixes {
+		if number, ok := strings.CutSuffix(text, suffix.suffix); ok {
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("quantity %q: %w", text, err)
+			}
+			return value * suffix.factor, nil
+		}
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(value) {
+		return 0, fmt.Errorf("quantity %q is not a number", text)
+	}
+	return value, nil
+}
+
+type KubernetesMetric struct {
+	client    *KubernetesClient
+	Name      string
+	Stat      string
+	Namespace string
+	Selector  string
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric KubernetesMetric) name() string {
+	return metric.Name
+}
+
+func (metric KubernetesMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric KubernetesMetric) namespace() string {
+	if metric.Namespace != "" {
+		return metric.Namespace
+	}
+	return metric.client.namespace
+}
+
+func (metric KubernetesMetric) usage(ctx context.Context, nodes bool) (float64, error) {
+	path := "/apis/metrics.k8s.io/v1beta1/namespaces/" + url.PathEscape(metric.namespace()) + "/pods"
+	if nodes {
+		path = "/apis/metrics.k8s.io/v1beta1/nodes"
+	}
+	list := KubernetesMetricsList{}
+	if err := metric.client.get(ctx, path, metric.Selector, &list); err != nil {
+		return 0, err
+	}
+	if len(list.Items) == 0 {
+		return 0, errNoKubernetesObjects
+	}
+	usages := make([]KubernetesUsage, 0)
+	for _, item := range list.Items {
+		if nodes {
+			usages = append(usages, item.Usage)
+		}
+		for _, container := range item.Containers {
+			usages = append(usages, container.Usage)
+		}
+	}
+	sum := 0.0
+	for _, usage := range usages {
+		quantity := usage.Memory
+		if metric.Stat == "cpu" || metric.Stat == "node_cpu" {
+			quantity = usage.CPU
+		}
+		value, err := parseQuantity(quantity)
+		if err != nil {
+			return 0, err
+		}
+		sum += value
+	}
+	if metric.Stat == "cpu" || metric.Stat == "node_cpu" {
+		return sum * 1000, nil
+	}
+	return sum, nil
+}
+
+func (metric KubernetesMetric) query(ctx context.Context) (float64, error) {
+	switch metric.Stat {
+	case "cpu", "memory":
+		return metric.usage(ctx, false)
+	case "node_cpu", "node_memory":
+		return metric.usage(ctx, true)
+	case "nodes", "nodes_ready":
+		list := KubernetesObjectList{}
+		if err := metric.client.get(ctx, "/api/v1/nodes", metric.Selector, &list); err != nil {
+			return 0, err
+		}
+		if metric.Stat == "nodes_ready" {
+			return float64(list.ready()), nil
+		}
+		return float64(len(list.Items)), nil
+	}
+	list := KubernetesObjectList{}
+	if err := metric.client.get(ctx, "/api/v1/namespaces/"+url.PathEscape(metric.namespace())+"/pods", metric.Selector, &list); err != nil {
+		return 0, err
+	}
+	switch metric.Stat {
+	case "pods_ready":
+		return float64(list.ready()), nil
+	case "restarts":
+		restarts := 0
+		for _, item := range list.Items {
+			for _, container := range item.Status.ContainerStatuses {
+				restarts += container.RestartCount
+			}
+		}
+		return float64(restarts), nil
+	}
+	return float64(len(list.Items)), nil
+}
+
+func (metric KubernetesMetric) gather(ctx context.Context) int {
+	value, err := metric.query(ctx)
+	if errors.Is(err, errNoKubernetesObjects) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("Error querying Kubernetes: %v\n", err)
+		return -1
+	}
+	return int(value)
+}
+
+func validateKubernetesMetric(config Config, metric Metric) error {
+	if !config.Kubernetes.enabled() {
+		return fmt.Errorf("kubernetes.kubeconfig, kubernetes.url or kubernetes.inCluster is not set")
+	}
+	if !slices.Contains(kubernetesStats, metric.Query) {
+		return fmt.Errorf("unknown kubernetes stat %q, expected one of %s", metric.Query, strings.Join(kubernetesStats, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func kubernetesServer(t *testing.T) *httptest.Server {
+	requires := require.New(t)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requires.Equal("Bearer secret", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		selector := r.URL.Query().Get("labelSelector")
+		switch {
+		case selector == "app=missing":
+			_, _ = w.Write([]byte(`{"items":[]}`))
+		case r.URL.Path == "/apis/metrics.k8s.io/v1beta1/namespaces/shop/pods" && selector == "app=api":
+			_, _ = w.Write([]byte(`{"items":[
+				{"containers":[{"usage":{"cpu":"250m","memory":"128Mi"}},{"usage":{"cpu":"5000000n","memory":"64Mi"}}]},
+				{"containers":[{"usage":{"cpu":"1","memory":"256Mi"}}]}]}`))
+		case r.URL.Path == "/apis/metrics.k8s.io/v1beta1/nodes":
+			_, _ = w.Write([]byte(`{"items":[{"usage":{"cpu":"1500m","memory":"2Gi"}},{"usage":{"cpu":"500m","memory":"1Gi"}}]}`))
+		case r.URL.Path == "/api/v1/namespaces/shop/pods":
+			_, _ = w.Write([]byte(`{"items":[
+				{"status":{"conditions":[{"type":"Ready","status":"True"}],"containerStatuses":[{"restartCount":2},{"restartCount":1}]}},
+				{"status":{"conditions":[{"type":"Ready","status":"False"}],"containerStatuses":[{"restartCount":4}]}}]}`))
+		case r.URL.Path == "/api/v1/nodes":
+			_, _ = w.Write([]byte(`{"items":[{"status":{"conditions":[{"type":"Ready","status":"True"}]}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestKubernetesMetric(t *testing.T) {
+	requires := require.New(t)
+	server := kubernetesServer(t)
+	defer server.Close()
+	client, err := NewKubernetesClient(KubernetesConfig{URL: server.URL + "/", Token: "secret", Namespace: "shop"}, "")
+	requires.NoError(err)
+	variants := []struct {
+		stat      string
+		selector  string
+		namespace string
+		onMissing string
+		value     int
+	}{
+		{stat: "cpu", selector: "app=api", value: 1255},
+		{stat: "memory", selector: "app=api", value: 448 << 20},
+		{stat: "node_cpu", value: 2000},
+		{stat: "node_memory", value: 3 << 30},
+		{stat: "pods", selector: "app=api", value: 2},
+		{stat: "pods_ready", value: 1},
+		{stat: "restarts", value: 7},
+		{stat: "nodes", value: 1},
+		{stat: "nodes_ready", value: 1},
+		{stat: "cpu", selector: "app=missing", value: -1},
+		{stat: "cpu", selector: "app=missing", onMissing: "treatAsZero", value: 0},
+		{stat: "pods", selector: "app=missing", value: 0},
+		{stat: "pods", namespace: "other", value: -1},
+	}
+	for _, variant := range variants {
+		metric := KubernetesMetric{client: client, Name: "api", Stat: variant.stat, Selector: variant.selector, Namespace: variant.namespace, MaxValue: 100, OnMissing: variant.onMissing}
+		requires.Equal(variant.value, metric.gather(context.Background()), variant.stat+" "+variant.selector)
+	}
+
+	gathers := newMetricGathers(Sources{kube: client}, []Metric{{Name: "restarts", Type: "kubernetes", Query: "restarts", Namespace: "shop"}})
+	requires.Equal(7, gathers[0].gather(context.Background()))
+	requires.Equal(redacted, redactConfig(Config{Kubernetes: KubernetesConfig{Token: "secret"}}).Kubernetes.Token)
+}
+
+func TestKubernetesKubeconfig(t *testing.T) {
+	requires := require.New(t)
+	server := kubernetesServer(t)
+	defer server.Close()
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0o600))
+	kubeconfig := `current-context: kind
+contexts:
+  - name: kind
+    context: {cluster: kind, user: loader, namespace: shop}
+  - name: broken
+    context: {cluster: absent, user: loader}
+clusters:
+  - name: kind
+    cluster: {server: "` + server.URL + `"}
+users:
+  - name: loader
+    user: {tokenFile: token}
+`
+	requires.NoError(os.WriteFile(filepath.Join(dir, "kubeconfig"), []byte(kubeconfig), 0o600))
+
+	client, err := NewKubernetesClient(KubernetesConfig{Kubeconfig: "kubeconfig"}, dir)
+	requires.NoError(err)
+	requires.Equal("shop", client.namespace)
+	requires.Equal(2, KubernetesMetric{client: client, Name: "pods", Stat: "pods"}.gather(context.Background()))
+
+	client, err = NewKubernetesClient(KubernetesConfig{Kubeconfig: filepath.Join(dir, "kubeconfig"), Namespace: "other"}, "")
+	requires.NoError(err)
+	requires.Equal("other", client.namespace)
+
+	_, err = NewKubernetesClient(KubernetesConfig{Kubeconfig: "kubeconfig", Context: "absent"}, dir)
+	requires.ErrorContains(err, `no context "absent"`)
+	_, err = NewKubernetesClient(KubernetesConfig{Kubeconfig: "kubeconfig", Context: "broken"}, dir)
+	requires.ErrorContains(err, `no cluster "absent"`)
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	_, err = NewKubernetesClient(KubernetesConfig{InCluster: true}, dir)
+	requires.ErrorContains(err, "KUBERNETES_SERVICE_HOST is not set")
+}
+
+func TestParseQuantity(t *testing.T) {
+	variants := []struct {
+		text  string
+		value float64
+		err   bool
+	}{
+		{text: "250m", value: 0.25},
+		{text: "12345678n", value: 0.012345678},
+		{text: "2", value: 2},
+		{text: "1.5", value: 1.5},
+		{text: "512Ki", value: 512 << 10},
+		{text: "3Gi", value: 3 << 30},
+		{text: "2M", value: 2e6},
+		{text: "1e3", value: 1000},
+		{text: "fast", err: true},
+		{text: "xMi", err: true},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		value, err := parseQuantity(variant.text)
+		if variant.err {
+			requires.Error(err, variant.text)
+			continue
+		}
+		requires.NoError(err, variant.text)
+		requires.InDelta(variant.value, value, 1e-12, variant.text)
+	}
+}
+
+func TestValidateKubernetesMetric(t *testing.T) {
+	requires := require.New(t)
+	metric := Metric{Name: "restarts", Type: "kubernetes", Query: "restarts", Selector: "app=api"}
+	requires.NoError(validateMetricTypes(Config{Kubernetes: KubernetesConfig{InCluster: true}, Metrics: []Metric{metric}}))
+	requires.ErrorContains(validateMetricTypes(Config{Metrics: []Metric{metric}}), "metric restarts: kubernetes.kubeconfig, kubernetes.url or kubernetes.inCluster is not set")
+	requires.ErrorContains(validateMetricTypes(Config{Kubernetes: KubernetesConfig{URL: "https://k8s:6443"}, Metrics: []Metric{{Name: "cpu", Type: "kubernetes", Query: "load"}}}), `metric cpu: unknown kubernetes stat "load"`)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func writeNDJSON(w http.ResponseWriter, r *http.Request, reporter *Reporter) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for values := range reporter.stream(r.Context(), 64) {
+		if err := encoder.Encode(metricValuesJSON(values)); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvents(w http.ResponseWriter, r *http.Request, reporter *Reporter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+	id := 0
+	for values := range reporter.stream(r.Context(), 64) {
+		b, err := json.Marshal(metricValuesJSON(values))
+		if err != nil {
+			log.Println("live error:", err)
+			continue
+		}
+		id++
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: values\ndata: %s\n\n", id, b); err != nil {
+			return
+		}
+		flush()
+	}
+	if r.Context().Err() == nil {
+		_, _ = fmt.Fprint(w, "event: end\ndata: {}\n\n")
+		flush()
+	}
+}
+
+type LiveServer struct {
+	addr   net.Addr
+	server *http.Server
+}
+
+func startLive(port int, reporter *Reporter) (*LiveServer, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeEvents(w, r, reporter)
+	})
+	mux.HandleFunc("GET /stream", func(w http.ResponseWriter, r *http.Request) {
+		writeNDJSON(w, r, reporter)
+	})
+	live := &LiveServer{addr: listener.Addr(), server: &http.Server{Handler: mux}}
+	log.Println("live events: http://" + listener.Addr().String() + "/events")
+	go func() {
+		if err := live.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Println("live error:", err)
+		}
+	}()
+	return live, nil
+}
+
+func (live *LiveServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := live.server.Shutdown(ctx); err != nil {
+		log.Println("live error:", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func waitStreams(reporter *Reporter, count int) {
+	for {
+		reporter.mutex.Lock()
+		subscribed := len(reporter.streams) >= count
+		reporter.mutex.Unlock()
+		if subscribed {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLiveEvents(t *testing.T) {
+	requires := require.New(t)
+	reporter := &Reporter{}
+	live, err := startLive(0, reporter)
+	requires.NoError(err)
+	defer live.stop()
+
+	resp, err := http.Get("http://" + live.addr.String() + "/events")
+	requires.NoError(err)
+	defer resp.Body.Close()
+	requires.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	requires.Equal("*", resp.Header.Get("Access-Control-Allow-Origin"))
+	waitStreams(reporter, 1)
+	reporter.sendResult(MetricValues{scenario: "s", values: []MetricValue{{name: "a", value: 1}}})
+	reporter.sendResult(MetricValues{scenario: "s", values: []MetricValue{{name: "a", value: 2}}})
+	reporter.close()
+	b, err := io.ReadAll(resp.Body)
+	requires.NoError(err)
+
+	events := strings.Split(strings.TrimSuffix(string(b), "\n\n"), "\n\n")
+	requires.Len(events, 3)
+	lines := strings.Split(events[1], "\n")
+	requires.Equal([]string{"id: 2", "event: values"}, lines[:2])
+	values := MetricValuesJSON{}
+	requires.NoError(json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &values))
+	requires.Equal(2, values.Values[0].Value)
+	requires.Equal("event: end\ndata: {}", events[2])
+
+	resp, err = http.Get("http://" + live.addr.String() + "/stream")
+	requires.NoError(err)
+	defer resp.Body.Close()
+	requires.Equal("application/x-ndjson", resp.Header.Get("Content-Type"))
+}
+
+func TestServerEvents(t *testing.T) {
+	requires := require.New(t)
+	reporter := &Reporter{}
+	server := NewServer(App{})
+	handler := server.handler()
+	requires.Equal(http.StatusNotFound, serverRequest(handler, http.MethodGet, "/events", "").Code)
+	server.current = &ServerRun{reporter: reporter, state: "running", cancel: func() {}}
+	done := make(chan string)
+	go func() {
+		done <- serverRequest(handler, http.MethodGet, "/events", "").Body.String()
+	}()
+	waitStreams(reporter, 1)
+	reporter.sendResult(MetricValues{scenario: "a", values: []MetricValue{{name: "a", value: 1}}})
+	reporter.close()
+	body := <-done
+	requires.True(strings.HasPrefix(body, "id: 1\nevent: values\ndata: {\"scenario\":\"a\""), body)
+	requires.True(strings.HasSuffix(body, "event: end\ndata: {}\n\n"))
+}
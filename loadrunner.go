@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+type RunnerConfig struct {
+	Type    string   `yaml:"type"`
+	Script  string   `yaml:"script"`
+	Users   int      `yaml:"users"`
+	Rate    float64  `yaml:"rate"`
+	Host    string   `yaml:"host"`
+	Args    []string `yaml:"args"`
+	Command []string `yaml:"command"`
+}
+
+// LoadRunner turns a scenario runner into its load command; results is the
+// .Results of the command and file the results file it leaves in workDir.
+type LoadRunner struct {
+	command []string
+	format  string
+	results string
+	file    string
+}
+
+var loadRunners = map[string]LoadRunner{
+	"k6": {
+		command: []string{"k6", "run", "{{ if .Users }}--vus={{ .Users }}{{ end }}", "--duration={{ .Duration }}s", "--out=json={{ .Results }}", "{{ .Script }}"},
+		format:  "k6",
+		results: "k6-results.json",
+		file:    "k6-results.json",
+	},
+	"locust": {
+		command: []string{"locust", "--locustfile={{ .Script }}", "--headless", "{{ if .Users }}--users={{ .Users }}{{ end }}", "{{ if .Rate }}--spawn-rate={{ .Rate }}{{ end }}",
+			"--run-time={{ .Duration }}s", "{{ if .Host }}--host={{ .Host }}{{ end }}", "--csv={{ .Results }}"},
+		format:  "locust",
+		results: "locust",
+		file:    "locust_stats_history.csv",
+	},
+	"gatling": {
+		command: []string{"env", "JAVA_OPTS=-Dusers={{ .Users }} -Drate={{ .Rate }} -Dduration={{ .Duration }} -DbaseUrl={{ .Host }}",
+			"gatling.sh", "--simulation", "{{ .Script }}", "--results-folder", "{{ .Results }}", "--run-description", "{{ .Scenario }}", "--no-reports"},
+		format:  "gatling",
+		results: "gatling-results",
+		file:    "gatling-results/*/simulation.log",
+	},
+}
+
+type RunnerData struct {
+	Scenario string
+	Script   string
+	Users    int
+	Rate     float64
+	Duration int
+	Host     string
+	Results  string
+	Vars     map[string]string
+}
+
+func (runner LoadRunner) render(config RunnerConfig, data RunnerData) ([]string, error) {
+	command := runner.command
+	if len(config.Command) > 0 {
+		command = config.Command
+	}
+	rendered := make([]string, 0, len(command)+len(config.Args))
+	for _, arg := range command {
+		tmpl, err := template.New("command").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, err
+		}
+		if b.Len() > 0 {
+			rendered = append(rendered, b.String())
+		}
+	}
+	return append(rendered, config.Args...), nil
+}
+
+func renderRunner(runner RunnerConfig, vars map[string]string) (RunnerConfig, error) {
+	fields := []*string{&runner.Script, &runner.Host}
+	runner.Args = slices.Clone(runner.Args)
+	for n := range runner.Args {
+		fields = append(fields, &runner.Args[n])
+	}
+	for _, field := range fields {
+		rendered, err := renderQuery(*field, vars)
+		if err != nil {
+			return runner, err
+		}
+		*field = rendered
+	}
+	return runner, nil
+}
+
+// expandRunners replaces the runner of every scenario with its load command;
+// unless results is set the results metrics read the file the runner leaves.
+func expandRunners(config Config) (Config, error) {
+	scenarios := slices.Clone(config.Scenarios)
+	results := ""
+	for n := range scenarios {
+		scenario := &scenarios[n]
+		runner := scenario.Runner
+		if runner.Type == "" {
+			if runner.Script != "" || len(runner.Command) > 0 {
+				return config, fmt.Errorf("scenario %s: runner.type is not set", scenario.Name)
+			}
+			continue
+		}
+		load, ok := loadRunners[runner.Type]
+		switch {
+		case !ok:
+			return config, fmt.Errorf("scenario %s: unknown runner %s (available: %s)", scenario.Name, runner.Type, strings.Join(slices.Sorted(maps.Keys(loadRunners)), ", "))
+		case len(scenario.Load) > 0:
+			return config, fmt.Errorf("scenario %s: load and runner are exclusive", scenario.Name)
+		case runner.Script == "":
+			return config, fmt.Errorf("scenario %s: runner.script is not set", scenario.Name)
+		case runner.Users < 0 || runner.Rate < 0:
+			return config, fmt.Errorf("scenario %s: runner.users and runner.rate must not be negative", scenario.Name)
+		}
+		command, err := load.render(runner, RunnerData{
+			Scenario: scenario.Name,
+			Script:   runner.Script,
+			Users:    runner.Users,
+			Rate:     runner.Rate,
+			Duration: int(expectedDuration(scenario.Duration, scenario.Iterations, config.Timeout).Seconds()),
+			Host:     runner.Host,
+			Results:  load.results,
+			Vars:     config.Vars,
+		})
+		if err != nil {
+			return config, fmt.Errorf("scenario %s: runner.command: %w", scenario.Name, err)
+		}
+		scenario.Load, scenario.Runner = command, RunnerConfig{}
+		if config.Results.Format == "" {
+			if results != "" && results != runner.Type {
+				return config, errors.New("scenarios use different runners, set results.format and results.file")
+			}
+			results = runner.Type
+		}
+	}
+	config.Scenarios = scenarios
+	if results != "" {
+		config.Results.Format, config.Results.File = loadRunners[results].format, loadRunners[results].file
+	}
+	return config, nil
+}
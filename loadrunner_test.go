@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandRunners(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		runner  RunnerConfig
+		load    []string
+		results ResultsConfig
+	}{
+		{
+			runner:  RunnerConfig{Type: "k6", Script: "soak.js", Users: 20, Args: []string{"--tag", "env=ci"}},
+			load:    []string{"k6", "run", "--vus=20", "--duration=90s", "--out=json=k6-results.json", "soak.js", "--tag", "env=ci"},
+			results: ResultsConfig{Format: "k6", File: "k6-results.json"},
+		},
+		{
+			runner:  RunnerConfig{Type: "k6", Script: "soak.js"},
+			load:    []string{"k6", "run", "--duration=90s", "--out=json=k6-results.json", "soak.js"},
+			results: ResultsConfig{Format: "k6", File: "k6-results.json"},
+		},
+		{
+			runner:  RunnerConfig{Type: "locust", Script: "locustfile.py", Users: 50, Rate: 2.5, Host: "http://localhost:8080"},
+			load:    []string{"locust", "--locustfile=locustfile.py", "--headless", "--users=50", "--spawn-rate=2.5", "--run-time=90s", "--host=http://localhost:8080", "--csv=locust"},
+			results: ResultsConfig{Format: "locust", File: "locust_stats_history.csv"},
+		},
+		{
+			runner: RunnerConfig{Type: "gatling", Script: "shop.CheckoutSimulation", Users: 10, Rate: 1, Host: "http://shop"},
+			load: []string{"env", "JAVA_OPTS=-Dusers=10 -Drate=1 -Dduration=90 -DbaseUrl=http://shop", "gatling.sh", "--simulation", "shop.CheckoutSimulation",
+				"--results-folder", "gatling-results", "--run-description", "peak", "--no-reports"},
+			results: ResultsConfig{Format: "gatling", File: "gatling-results/*/simulation.log"},
+		},
+		{
+			runner:  RunnerConfig{Type: "gatling", Script: "Checkout", Users: 5, Command: []string{"./mvnw", "gatling:test", "-Dgatling.simulationClass={{ .Script }}", "-Dusers={{ .Users }}", "-Denv={{ .Vars.env }}"}},
+			load:    []string{"./mvnw", "gatling:test", "-Dgatling.simulationClass=Checkout", "-Dusers=5", "-Denv=ci"},
+			results: ResultsConfig{Format: "gatling", File: "gatling-results/*/simulation.log"},
+		},
+	}
+	for _, variant := range variants {
+		config := Config{Vars: map[string]string{"env": "ci"}, Scenarios: []Scenario{{Name: "peak", Duration: Duration(90 * time.Second), Runner: variant.runner}}}
+		expanded, err := expandRunners(config)
+		requires.NoError(err, variant.runner.Type)
+		requires.Equal(variant.load, expanded.Scenarios[0].Load)
+		requires.Empty(expanded.Scenarios[0].Runner)
+		requires.Equal(variant.results, expanded.Results)
+		requires.Equal(variant.runner, config.Scenarios[0].Runner)
+	}
+
+	config := Config{Results: ResultsConfig{Format: "jtl", File: "out.jtl"}, Scenarios: []Scenario{
+		{Name: "a", Duration: Duration(time.Minute), Runner: RunnerConfig{Type: "k6", Script: "a.js"}},
+		{Name: "b", Duration: Duration(time.Minute), Runner: RunnerConfig{Type: "locust", Script: "b.py"}},
+	}}
+	expanded, err := expandRunners(config)
+	requires.NoError(err)
+	requires.Equal(config.Results, expanded.Results)
+	config.Results = ResultsConfig{}
+	_, err = expandRunners(config)
+	requires.ErrorContains(err, "scenarios use different runners, set results.format and results.file")
+
+	invalid := []struct {
+		scenario Scenario
+		err      string
+	}{
+		{Scenario{Name: "a", Runner: RunnerConfig{Type: "jmeter", Script: "a.jmx"}}, "scenario a: unknown runner jmeter (available: gatling, k6, locust)"},
+		{Scenario{Name: "a", Runner: RunnerConfig{Script: "a.js"}}, "scenario a: runner.type is not set"},
+		{Scenario{Name: "a", Runner: RunnerConfig{Type: "k6"}}, "scenario a: runner.script is not set"},
+		{Scenario{Name: "a", Load: []string{"k6"}, Runner: RunnerConfig{Type: "k6", Script: "a.js"}}, "scenario a: load and runner are exclusive"},
+		{Scenario{Name: "a", Runner: RunnerConfig{Type: "k6", Script: "a.js", Users: -1}}, "scenario a: runner.users and runner.rate must not be negative"},
+		{Scenario{Name: "a", Runner: RunnerConfig{Type: "k6", Script: "a.js", Command: []string{"{{ .Vars.team }}"}}}, "scenario a: runner.command:"},
+	}
+	for _, variant := range invalid {
+		_, err := expandRunners(Config{Scenarios: []Scenario{variant.scenario}})
+		requires.ErrorContains(err, variant.err)
+	}
+}
+
+func TestPrepareRunner(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		TestDuration: Duration(time.Minute),
+		Vars:         map[string]string{"script": "soak.js"},
+		Scenarios:    []Scenario{{Name: "soak", Runner: RunnerConfig{Type: "k6", Script: "{{ .script }}", Args: []string{"--tag=team={{ .team }}"}}}},
+		Metrics:      []Metric{{Name: "client_p95", Type: "results", Query: "latency_p95", MaxValue: 800}},
+	}
+	prepared, _, err := App{vars: VarsFlag{"team": "core"}}.prepare(config)
+	requires.NoError(err)
+	requires.Equal([]string{"k6", "run", "--duration=60s", "--out=json=k6-results.json", "soak.js", "--tag=team=core"}, prepared.Scenarios[0].Load)
+	requires.Equal("k6", prepared.Results.Format)
+	requires.ErrorContains(checkRemoteConfig(config), "scenario soak: load is not allowed in posted configs")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LokiConfig struct {
+	URL string `yaml:"url"`
+}
+
+type LokiResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []any             `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+type LokiMetric struct {
+	Host     string
+	Name     string
+	Query    string
+	MaxValue int
+}
+
+func (metric LokiMetric) name() string {
+	return metric.Name
+}
+
+func (metric LokiMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric LokiMetric) query(ctx context.Context) (float64, error) {
+	params := url.Values{}
+	params.Set("query", metric.Query)
+	params.Set("time", strconv.FormatInt(clockFrom(ctx).now().UnixNano(), 10))
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(metric.Host, "/")+"/loki/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	response := LokiResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("status %s: %w", resp.Status, err)
+	}
+	if response.Status != "success" {
+		return 0, fmt.Errorf("status %s: %s", resp.Status, response.Error)
+	}
+	if response.Data.ResultType != "vector" {
+		return 0, fmt.Errorf("unsupported result type %s", response.Data.ResultType)
+	}
+	sum := 0.0
+	for _, sample := range response.Data.Result {
+		if len(sample.Value) != 2 {
+			return 0, fmt.Errorf("bad sample %v", sample.Value)
+		}
+		text, _ := sample.Value[1].(string)
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return 0, err
+		}
+		sum += value
+	}
+	return sum, nil
+}
+
+func (metric LokiMetric) gather(ctx context.Context) int {
+	value, err := metric.query(ctx)
+	if err != nil {
+		log.Printf("Error querying Loki: %v\n", err)
+		return -1
+	}
+	return int(value)
+}
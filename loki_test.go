@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLokiMetricGather(t *testing.T) {
+	variants := []struct {
+		response string
+		value    int
+	}{
+		{response: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"12"]}]}}`, value: 12},
+		{response: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"app":"a"},"value":[1,"2"]},{"metric":{"app":"b"},"value":[1,"3.5"]}]}}`, value: 5},
+		{response: `{"status":"success","data":{"resultType":"vector","result":[]}}`, value: 0},
+		{response: `{"status":"success","data":{"resultType":"streams","result":[]}}`, value: -1},
+		{response: `{"status":"error","error":"parse error"}`, value: -1},
+		{response: `not json`, value: -1},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requires.Equal("/loki/api/v1/query", r.URL.Path)
+			requires.Equal(`count_over_time({app="api"} |= "ERROR" [1m])`, r.URL.Query().Get("query"))
+			_, _ = w.Write([]byte(variant.response))
+		}))
+		metric := LokiMetric{Host: server.URL, Name: "errors", Query: `count_over_time({app="api"} |= "ERROR" [1m])`}
+		requires.Equal(variant.value, metric.gather(context.Background()), n)
+		server.Close()
+	}
+}
+
+func TestNewMetricGathersLoki(t *testing.T) {
+	requires := require.New(t)
+	gathers := newMetricGathers(Sources{loki: "http://loki:3100"}, []Metric{{Name: "errors", Type: "loki", MaxValue: 3}})
+	requires.Equal(LokiMetric{Host: "http://loki:3100", Name: "errors", MaxValue: 3}, gathers[0])
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "errors", Type: "loki"}}}))
+}
@@ -1,114 +1,652 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"math/rand/v2"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"gopkg.in/yaml.v3"
 )
 
 type MetricValue struct {
-	name  string
-	value int
+	name      string
+	value     int
+	labels    map[string]string
+	series    map[string]string
+	tolerated bool
+	excluded  bool
+	warnings  []string
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (value MetricValue) String() string {
+	if len(value.labels) == 0 {
+		return value.name + "=" + strconv.Itoa(value.value)
+	}
+	return value.name + "{" + formatLabels(value.labels) + "}=" + strconv.Itoa(value.value)
 }
 
 type MetricValues struct {
-	timestamp time.Time
-	values    []MetricValue
+	scenario   string
+	timestamp  time.Time
+	values     []MetricValue
+	violations []MetricValue
+}
+
+func (values MetricValues) failing() bool {
+	for _, violation := range values.violations {
+		if !violation.tolerated {
+			return true
+		}
+	}
+	return false
 }
 
 type Reporter struct {
-	values []MetricValues
+	mutex      sync.Mutex
+	values     []MetricValues
+	listeners  []func(MetricValues)
+	streams    []chan MetricValues
+	closed     bool
+	limit      int
+	spill      *os.File
+	spilled    int
+	aggregates *SpoolAggregates
 }
 
 func (reporter *Reporter) sendResult(result MetricValues) {
+	reporter.mutex.Lock()
 	reporter.values = append(reporter.values, result)
+	if reporter.aggregates != nil {
+		reporter.aggregates.observe(result)
+	}
+	if reporter.spill != nil && len(reporter.values) > reporter.limit {
+		reporter.flush()
+	}
+	listeners := append([]func(MetricValues){}, reporter.listeners...)
+	for _, stream := range reporter.streams {
+		select {
+		case stream <- result:
+		default:
+			log.Println("WARNING: stream is full, values dropped")
+		}
+	}
+	reporter.mutex.Unlock()
+	for _, listener := range listeners {
+		listener(result)
+	}
+}
+
+func (reporter *Reporter) subscribe(listener func(MetricValues)) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.listeners = append(reporter.listeners, listener)
+}
+
+func (reporter *Reporter) stream(ctx context.Context, size int) <-chan MetricValues {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	stream := make(chan MetricValues, size)
+	if reporter.closed {
+		close(stream)
+		return stream
+	}
+	reporter.streams = append(reporter.streams, stream)
+	go func() {
+		<-ctx.Done()
+		reporter.unstream(stream)
+	}()
+	return stream
+}
+
+func (reporter *Reporter) unstream(stream chan MetricValues) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	for n := range reporter.streams {
+		if reporter.streams[n] == stream {
+			reporter.streams = append(reporter.streams[:n], reporter.streams[n+1:]...)
+			close(stream)
+			return
+		}
+	}
+}
+
+func (reporter *Reporter) close() {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	for _, stream := range reporter.streams {
+		close(stream)
+	}
+	reporter.streams = nil
+	reporter.closed = true
+	if reporter.spill != nil {
+		reporter.flush()
+		if err := reporter.spill.Close(); err != nil {
+			log.Println("spill error:", err)
+		}
+	}
 }
 
-func (reporter *Reporter) report() {
+func (reporter *Reporter) snapshot() []MetricValues {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	if reporter.spill == nil {
+		return append([]MetricValues{}, reporter.values...)
+	}
+	return append(reporter.spilledValues(), reporter.values...)
+}
+
+func (reporter *Reporter) report(colors bool) {
 	log.Println("=[ report ]==================")
-	for _, value := range reporter.values {
-		log.Println(" ", value.timestamp.Format(time.RFC3339), value.values)
+	if reporter.aggregates != nil {
+		log.Println(" values are spooled to", reporter.spill.Name())
+		logSummaries(reporter.summaries(), colors)
+		log.Println("=[ end ]=====================")
+		return
 	}
+	values := reporter.snapshot()
+	for start := 0; start < len(values); {
+		end := start + 1
+		for end < len(values) && values[end].scenario == values[start].scenario {
+			end++
+		}
+		if values[start].scenario != "" {
+			log.Println(" scenario:", values[start].scenario)
+		}
+		logTable(valuesTable(values[start:end]), colors)
+		start = end
+	}
+	logSummaries(summarize(values), colors)
 	log.Println("=[ end ]=====================")
 }
 
 type Eventer struct {
+	clock    ClockInt
+	scenario string
 	gatherer GathererInt
 	reporter *Reporter
+	aborter  AborterInt
 	stoper   func()
+	violated func()
+	observed func(MetricValues)
 }
 
-func (eventer *Eventer) Fire() {
-	result, ok := eventer.gatherer.gatherAndCheck(time.Now())
+func (eventer *Eventer) Fire(ctx context.Context) {
+	clock := clockOf(eventer.clock)
+	result, ok := eventer.gatherer.gatherAndCheck(withClock(ctx, clock), clock.now())
+	if ctx.Err() != nil {
+		log.Println("tick cancelled:", ctx.Err())
+		return
+	}
+	result.scenario = eventer.scenario
 	eventer.reporter.sendResult(result)
+	if eventer.observed != nil {
+		eventer.observed(result)
+	}
+	if result.failing() && eventer.violated != nil {
+		eventer.violated()
+	}
 	if !ok {
+		if eventer.aborter != nil {
+			eventer.aborter.abort(result.violations)
+		}
 		eventer.stoper()
 	}
 }
 
+type AborterInt interface {
+	abort(violations []MetricValue)
+}
+
+type AbortHook struct {
+	workDir string
+	webhook string
+	command []string
+	timeout time.Duration
+}
+
+func (hook AbortHook) runCommand(args []string) {
+	timeout := hook.timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = hook.workDir
+	if err := cmd.Run(); err != nil {
+		log.Println("abort command error:", err)
+	}
+}
+
+type ValueJSON struct {
+	Name     string            `json:"name"`
+	Value    int               `json:"value"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Series   map[string]string `json:"series,omitempty"`
+	Excluded bool              `json:"excluded,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+func valuesJSON(values []MetricValue) []ValueJSON {
+	result := make([]ValueJSON, 0)
+	for _, value := range values {
+		result = append(result, ValueJSON{Name: value.name, Value: value.value, Labels: value.labels, Series: value.series, Excluded: value.excluded, Warnings: value.warnings})
+	}
+	return result
+}
+
+func (hook AbortHook) abort(violations []MetricValue) {
+	if len(hook.command) > 0 {
+		for _, violation := range violations {
+			args := append(append([]string{}, hook.command...), violation.name, strconv.Itoa(violation.value))
+			log.Println("abort command:", args)
+			hook.runCommand(args)
+		}
+	}
+	if hook.webhook != "" {
+		b, err := json.Marshal(map[string][]ValueJSON{"violations": valuesJSON(violations)})
+		if err != nil {
+			log.Println("abort webhook error:", err)
+			return
+		}
+		log.Println("abort webhook:", hook.webhook)
+		client := http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Post(hook.webhook, "application/json", bytes.NewReader(b))
+		if err != nil {
+			log.Println("abort webhook error:", err)
+			return
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Println("abort webhook error: status", resp.Status)
+		}
+	}
+}
+
 type EnvManager interface {
-	start()
-	stop()
+	start() error
+	stop() error
 }
 
 type DockerCompose struct {
 	workDir           string
 	dockerComposeFile string
+	composeCommand    []string
+	teardownTimeout   time.Duration
+	pull              bool
+	build             bool
+	dockerCommand     []string
+	envFiles          []string
+	environment       []string
+	down              DownConfig
+}
+
+type StandConfig struct {
+	Pull        string            `yaml:"pull"`
+	Build       bool              `yaml:"build"`
+	EnvFile     []string          `yaml:"envFile"`
+	Environment map[string]string `yaml:"environment"`
+	Down        DownConfig        `yaml:"down"`
+}
+
+func validateStand(config Config) error {
+	if config.Env.Pull != "" && config.Env.Pull != "always" {
+		return fmt.Errorf("unknown env.pull %s", config.Env.Pull)
+	}
+	if err := validateDown(config.Env.Down); err != nil {
+		return err
+	}
+	return validateStandEnvironment(config)
+}
+
+const defaultTeardownTimeout = 2 * time.Minute
+
+var composeCommands = [][]string{
+	{"docker", "compose"},
+	{"docker-compose"},
+	{"podman", "compose"},
+	{"podman-compose"},
+}
+
+func probeCommand(args []string) bool {
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return false
+	}
+	cmd := exec.Command(args[0], append(args[1:], "version")...)
+	return cmd.Run() == nil
+}
+
+func detectCompose(probe func(args []string) bool) []string {
+	for _, command := range composeCommands {
+		if probe(command) {
+			return command
+		}
+	}
+	return composeCommands[0]
+}
+
+func (envManager DockerCompose) compose(args ...string) []string {
+	command := envManager.composeCommand
+	if len(command) == 0 {
+		command = detectCompose(probeCommand)
+	}
+	command = append([]string{}, command...)
+	if envManager.dockerComposeFile != "" {
+		command = append(command, "-f", envManager.dockerComposeFile)
+	}
+	for _, envFile := range envManager.envFiles {
+		command = append(command, "--env-file", envFile)
+	}
+	return append(command, args...)
 }
 
-func osexec(logMsg string, workDir string, args ...string) {
+func osexec(logMsg string, workDir string, env []string, args ...string) error {
 	log.Println(logMsg)
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Dir = workDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	if err := cmd.Run(); err != nil {
-		log.Fatalln(err)
+		return fmt.Errorf("%s: %w", logMsg, err)
+	}
+	return nil
+}
+
+func osexecTimeout(logMsg string, workDir string, env []string, timeout time.Duration, args ...string) error {
+	log.Println(logMsg)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = workDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s: timed out after %s", logMsg, timeout)
+		}
+		return fmt.Errorf("%s: %w", logMsg, err)
+	}
+	return nil
+}
+
+func (envManager DockerCompose) startCommands() [][]string {
+	commands := make([][]string, 0)
+	if envManager.pull {
+		commands = append(commands, envManager.compose("pull"))
+	}
+	args := []string{"up", "-d", "--remove-orphans"}
+	if envManager.build {
+		args = append(args, "--build")
+	}
+	return append(commands, envManager.compose(args...))
+}
+
+func (envManager DockerCompose) start() error {
+	for _, command := range envManager.startCommands() {
+		if err := osexec("start stand", envManager.workDir, envManager.environment, command...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (envManager DockerCompose) stop() error {
+	timeout := envManager.teardownTimeout
+	if timeout <= 0 {
+		timeout = defaultTeardownTimeout
 	}
+	down := envManager.down.args()
+	steps := [][]string{
+		envManager.compose(down...),
+		envManager.compose(append(down, "--timeout", "0")...),
+		envManager.compose("kill"),
+	}
+	var err error
+	for _, step := range steps {
+		if err = osexecTimeout("stop stand: "+strings.Join(step, " "), envManager.workDir, envManager.environment, timeout, step...); err == nil {
+			return nil
+		}
+		log.Println(err)
+	}
+	return err
+}
+
+type LogCollector interface {
+	logs() ([]byte, error)
+}
+
+func (envManager DockerCompose) logs() ([]byte, error) {
+	args := envManager.compose("logs", "--no-color", "--timestamps")
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = envManager.workDir
+	if len(envManager.environment) > 0 {
+		cmd.Env = append(os.Environ(), envManager.environment...)
+	}
+	b, err := cmd.Output()
+	if err != nil {
+		return b, fmt.Errorf("collect logs: %w", err)
+	}
+	return b, nil
+}
+
+type CommandLoad struct {
+	workDir string
+	command []string
+	cmd     *exec.Cmd
 }
 
-func (envManager DockerCompose) start() {
-	osexec("start stand", envManager.workDir, "docker", "compose", "up", "-d", "--remove-orphans")
+func (load *CommandLoad) start() error {
+	log.Println("start load:", load.command)
+	load.cmd = exec.Command(load.command[0], load.command[1:]...)
+	load.cmd.Dir = load.workDir
+	if err := load.cmd.Start(); err != nil {
+		load.cmd = nil
+		return fmt.Errorf("start load: %w", err)
+	}
+	return nil
 }
 
-func (envManager DockerCompose) stop() {
-	osexec("stop stand", envManager.workDir, "docker", "compose", "down")
+func (load *CommandLoad) stop() error {
+	if load.cmd == nil {
+		return nil
+	}
+	log.Println("stop load:", load.command)
+	err := load.cmd.Process.Kill()
+	_ = load.cmd.Wait()
+	load.cmd = nil
+	if err != nil {
+		return fmt.Errorf("stop load: %w", err)
+	}
+	return nil
 }
 
 type Metric struct {
-	Name     string `yaml:"name"`
-	Query    string `yaml:"query"`
-	MaxValue int    `yaml:"maxValue"`
+	Name              string             `yaml:"name"`
+	Type              string             `yaml:"type"`
+	Query             string             `yaml:"query"`
+	Service           string             `yaml:"service"`
+	Attributes        map[string]string  `yaml:"attributes"`
+	Labels            map[string]string  `yaml:"labels"`
+	Tags              []string           `yaml:"tags"`
+	Disabled          bool               `yaml:"disabled"`
+	Index             string             `yaml:"index"`
+	Target            string             `yaml:"target"`
+	Path              string             `yaml:"path"`
+	Queue             string             `yaml:"queue"`
+	Command           string             `yaml:"command"`
+	Database          string             `yaml:"database"`
+	Namespace         string             `yaml:"namespace"`
+	Selector          string             `yaml:"selector"`
+	Probe             HTTPProbeConfig    `yaml:"probe"`
+	Plugin            []string           `yaml:"plugin"`
+	Options           map[string]any     `yaml:"options"`
+	Aggregate         string             `yaml:"aggregate"`
+	Window            Duration           `yaml:"window"`
+	Offset            Duration           `yaml:"offset"`
+	Align             Duration           `yaml:"align"`
+	Quantile          float64            `yaml:"quantile"`
+	Scale             float64            `yaml:"scale"`
+	MaxValue          int                `yaml:"maxValue"`
+	MaxValueQuery     string             `yaml:"maxValueQuery"`
+	RelativeMax       *RelativeThreshold `yaml:"-"`
+	DrainMaxValue     *int               `yaml:"drainMaxValue"`
+	OnMissing         string             `yaml:"onMissing"`
+	OnViolation       string             `yaml:"onViolation"`
+	AllowedViolations int                `yaml:"allowedViolations"`
+	RecoverTicks      int                `yaml:"recoverTicks"`
+	Anomaly           *AnomalyConfig     `yaml:"anomaly"`
+	Assertions        []string           `yaml:"assertions"`
+	RangeAssertions   []string           `yaml:"rangeAssertions"`
+	FailOnWarnings    bool               `yaml:"failOnWarnings"`
+	Budget            []Assertion        `yaml:"-"`
+}
+
+type LabelerInt interface {
+	labels() map[string]string
+}
+
+type SeriesGathererInt interface {
+	gatherSeries(ctx context.Context) (int, map[string]string)
+}
+
+type LabeledMetric struct {
+	MetricGather
+	Labels map[string]string
+}
+
+func (metric LabeledMetric) labels() map[string]string {
+	return metric.Labels
+}
+
+func (metric LabeledMetric) gatherSeries(ctx context.Context) (int, map[string]string) {
+	return gatherSeries(ctx, metric.MetricGather)
+}
+
+func gatherSeries(ctx context.Context, metric MetricGather) (int, map[string]string) {
+	if series, ok := metric.(SeriesGathererInt); ok {
+		return series.gatherSeries(ctx)
+	}
+	return metric.gather(ctx), nil
 }
 
 type GathererInt interface {
-	gatherAndCheck(startTime time.Time) (MetricValues, bool)
+	gatherAndCheck(ctx context.Context, startTime time.Time) (MetricValues, bool)
 }
 
 type Gatherer struct {
-	metrics []MetricGather
-	host    string
+	metrics    []MetricGather
+	host       string
+	continueOn map[string]bool
+	allowed    map[string]int
+	relative   map[string]RelativeThreshold
+	queried    map[string]string
+	limits     *MaxValueQueries
+	budget     *ViolationBudget
+	anomalies  *AnomalyDetector
+	unchecked  map[string]bool
+	exclusions *Exclusions
+	failOn     map[string]bool
+	queries    *QueryLimiter
+	self       *SelfMetrics
 }
 
 type MetricGather interface {
-	gather() int
+	gather(ctx context.Context) int
 	name() string
 	maxValue() int
 }
 
 type PrometheusMetric struct {
-	Host     string
-	Name     string
-	Query    string
-	MaxValue int
+	Host      string
+	Headers   http.Header
+	Name      string
+	Query     string
+	MaxValue  int
+	OnMissing string
+	Offset    time.Duration
+	Align     time.Duration
+	Reduce    string
+}
+
+var matrixReductions = []string{"", "last", "max"}
+
+var errNoData = errors.New("no data")
+
+type GatherError struct {
+	Metric string
+	Reason string
+}
+
+func (err GatherError) Error() string {
+	return "metric " + err.Metric + ": " + err.Reason
+}
+
+func (metric PrometheusMetric) evalTime(now time.Time) time.Time {
+	at := now.Add(-metric.Offset)
+	if metric.Align > 0 {
+		at = at.Truncate(metric.Align)
+	}
+	return at
+}
+
+const missingValue = -2
+
+var missingPolicies = []string{"", "ignore", "fail", "treatAsZero", "treatAsMax"}
+
+func hasValue(value int) bool {
+	return value != -1 && value != missingValue
+}
+
+func missing(name string, policy string, maxValue int) int {
+	log.Println("WARNING: no data for metric", name)
+	switch policy {
+	case "fail":
+		return missingValue
+	case "treatAsZero":
+		return 0
+	case "treatAsMax":
+		return maxValue
+	}
+	return -1
 }
 
+func (metric PrometheusMetric) cached() {}
+
 func (metric PrometheusMetric) name() string {
 	return metric.Name
 }
@@ -117,81 +655,341 @@ func (metric PrometheusMetric) maxValue() int {
 	return metric.MaxValue
 }
 
-func (metric PrometheusMetric) gather() int {
+func (metric PrometheusMetric) gather(ctx context.Context) int {
+	value, _ := metric.gatherSeries(ctx)
+	return value
+}
+
+func (metric PrometheusMetric) gatherSeries(ctx context.Context) (int, map[string]string) {
 	client, err := api.NewClient(api.Config{
-		Address: metric.Host,
+		Address:      metric.Host,
+		RoundTripper: HeaderRoundTripper{headers: metric.Headers, next: api.DefaultRoundTripper},
 	})
 	if err != nil {
 		fmt.Printf("Error creating client: %v\n", err)
-		return -1
+		return -1, nil
 	}
 
 	v1api := v1.NewAPI(client)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	val, warnings, err := v1api.Query(ctx, metric.Query, time.Now(), v1.WithTimeout(5*time.Second))
+	key := queryKey(metric.Host, metric.Query, metric.Offset, metric.Align)
+	val, warnings, err := queryCacheFrom(ctx).query(ctx, key, func() (model.Value, v1.Warnings, error) {
+		return v1api.Query(ctx, metric.Query, metric.evalTime(clockFrom(ctx).now()), v1.WithTimeout(5*time.Second))
+	})
 	if err != nil {
 		log.Printf("Error querying Prometheus: %v\n", err)
-		return -1
+		return -1, nil
+	}
+	addQueryWarnings(ctx, metric.Name, warnings)
+	value, series, err := metric.value(val)
+	if errors.Is(err, errNoData) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue), nil
+	}
+	if err != nil {
+		log.Println("WARNING:", err)
+		return -1, nil
+	}
+	return value, series
+}
+
+func seriesLabels(labels model.Metric) map[string]string {
+	series := map[string]string{}
+	for name, value := range labels {
+		if name != model.MetricNameLabel {
+			series[string(name)] = string(value)
+		}
 	}
-	if len(warnings) > 0 {
-		log.Printf("Warnings: %v\n", warnings)
+	if len(series) == 0 {
+		return nil
 	}
-	switch {
-	case val.Type() == model.ValVector:
-		vectorVal := val.(model.Vector)
-		if vectorVal.Len() != 1 {
-			log.Println("WARNING: too many values ", vectorVal.Len())
-			return -1
+	return series
+}
+
+func (metric PrometheusMetric) value(val model.Value) (int, map[string]string, error) {
+	switch val := val.(type) {
+	case *model.Scalar:
+		return int(val.Value), nil, nil
+	case model.Vector:
+		if len(val) == 0 {
+			return 0, nil, errNoData
+		}
+		if len(val) != 1 {
+			return 0, nil, GatherError{Metric: metric.Name, Reason: fmt.Sprintf("too many values %d", len(val))}
 		}
-		for _, elem := range vectorVal {
-			return int(elem.Value)
+		return int(val[0].Value), seriesLabels(val[0].Metric), nil
+	case model.Matrix:
+		if len(val) == 0 || len(val[0].Values) == 0 {
+			return 0, nil, errNoData
 		}
-	default:
-		log.Printf("   Result:\n%v\n", val)
-		panic(val.Type().String())
+		if len(val) != 1 {
+			return 0, nil, GatherError{Metric: metric.Name, Reason: fmt.Sprintf("too many series %d", len(val))}
+		}
+		return int(reduceSamples(metric.Reduce, val[0].Values)), seriesLabels(val[0].Metric), nil
+	case nil:
+		return 0, nil, GatherError{Metric: metric.Name, Reason: "empty result"}
 	}
-	return -1
+	return 0, nil, GatherError{Metric: metric.Name, Reason: "unsupported result type " + val.Type().String()}
 }
 
-func (gatherer Gatherer) gatherAndCheck(startTime time.Time) (MetricValues, bool) {
-	flag := true
+func reduceSamples(reduce string, samples []model.SamplePair) model.SampleValue {
+	if reduce == "max" {
+		value := samples[0].Value
+		for _, sample := range samples[1:] {
+			value = max(value, sample.Value)
+		}
+		return value
+	}
+	return samples[len(samples)-1].Value
+}
+
+func (gatherer Gatherer) limit(metric MetricGather, values []MetricValue) (float64, bool) {
+	if gatherer.unchecked[metric.name()] {
+		return 0, false
+	}
+	if query, ok := gatherer.queried[metric.name()]; ok {
+		limit, resolved := gatherer.limits.value(query)
+		if !resolved {
+			log.Println("WARNING: metric("+metric.name()+"): no value of maxValueQuery", query)
+		}
+		return float64(limit), resolved
+	}
+	relative, ok := gatherer.relative[metric.name()]
+	if !ok {
+		return float64(metric.maxValue()), true
+	}
+	for _, value := range values {
+		if value.name == relative.Ref && hasValue(value.value) {
+			return relative.Factor * float64(value.value), true
+		}
+	}
+	log.Println("WARNING: metric("+metric.name()+"): no value of", relative.Ref, "for maxValue")
+	return 0, false
+}
+
+func (gatherer Gatherer) gatherAndCheck(ctx context.Context, startTime time.Time) (MetricValues, bool) {
 	metricValues := MetricValues{timestamp: startTime, values: []MetricValue{}}
-	for _, metric := range gatherer.metrics {
-		value := metric.gather()
-		metricValues.values = append(metricValues.values, MetricValue{name: metric.name(), value: value})
-		if value > metric.maxValue() {
-			log.Println(" metric("+metric.name()+"):", value, ">", metric.maxValue())
-			flag = false
+	ctx = withQueryCache(ctx, gatherer.queries)
+	metricValues.values = make([]MetricValue, len(gatherer.metrics))
+	gather := func(n int, metric MetricGather) {
+		var labels map[string]string
+		if labeled, ok := metric.(LabelerInt); ok {
+			labels = labeled.labels()
+		}
+		if _, ok := deriver(metric); ok {
+			metricValues.values[n] = MetricValue{name: metric.name(), value: -1, labels: labels}
+			return
+		}
+		if !cachedQuery(metric) {
+			release, err := gatherer.queries.acquire(ctx)
+			if err != nil {
+				metricValues.values[n] = MetricValue{name: metric.name(), value: -1, labels: labels}
+				return
+			}
+			defer release()
+		}
+		queryCtx, span := startSpan(ctx, "query", attribute.String("metric", metric.name()))
+		queryCtx, warnings := withQueryWarnings(queryCtx)
+		queryStart := time.Now()
+		value, series := gatherSeries(queryCtx, metric)
+		elapsed := time.Since(queryStart)
+		gatherer.self.query(metric.name(), elapsed, value)
+		debugf("metric(%s): %d in %s", metric.name(), value, elapsed.Round(time.Millisecond))
+		span.SetAttributes(attribute.Int("value", value))
+		if !hasValue(value) {
+			span.SetStatus(codes.Error, "no value")
+		}
+		span.End()
+		metricValues.values[n] = MetricValue{name: metric.name(), value: value, labels: labels, series: series, warnings: warnings.list()}
+	}
+	if gatherer.queries.concurrent() {
+		wg := sync.WaitGroup{}
+		for n, metric := range gatherer.metrics {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				gather(n, metric)
+			}()
+		}
+		wg.Wait()
+	} else {
+		for n, metric := range gatherer.metrics {
+			gather(n, metric)
+		}
+	}
+	exact := map[string]float64{}
+	for n, metric := range gatherer.metrics {
+		if derived, ok := deriver(metric); ok {
+			metricValues.values[n].value = derived.derive(metricValues.values, exact)
+		}
+	}
+	for n, metric := range gatherer.metrics {
+		if gatherer.exclusions.excludes(metric.name(), startTime) {
+			metricValues.values[n].excluded = true
+		}
+	}
+	return gatherer.check(metricValues)
+}
+
+// check finds the violations of the gathered values of a tick.
+func (gatherer Gatherer) check(metricValues MetricValues) (MetricValues, bool) {
+	flag := true
+	startTime := metricValues.timestamp
+	metricValues.violations = nil
+	for n, metric := range gatherer.metrics {
+		if metricValues.values[n].excluded {
+			continue
+		}
+		value, labels, series := metricValues.values[n].value, metricValues.values[n].labels, metricValues.values[n].series
+		limit, ok := gatherer.limit(metric, metricValues.values)
+		_, relative := gatherer.relative[metric.name()]
+		_, queried := gatherer.queried[metric.name()]
+		if gatherer.anomalies.enabled(metric.name()) && metric.maxValue() == 0 && !relative && !queried {
+			ok = false
+		}
+		exceeded := ok && float64(value) > limit
+		anomalous := gatherer.anomalies.observe(metric.name(), startTime, value)
+		warned := gatherer.failOn[metric.name()] && len(metricValues.values[n].warnings) > 0
+		if warned {
+			log.Println(" metric("+metric.name()+"): query warnings:", strings.Join(metricValues.values[n].warnings, "; "))
+		}
+		if exceeded || value == missingValue {
+			if len(series) > 0 {
+				log.Println(" metric("+metric.name()+"){"+formatLabels(series)+"}:", value, ">", limit)
+			} else {
+				log.Println(" metric("+metric.name()+"):", value, ">", limit)
+			}
+		}
+		if exceeded || anomalous || warned || value == missingValue {
+			tolerated := gatherer.budget.spend(metric.name(), gatherer.allowed[metric.name()])
+			metricValues.violations = append(metricValues.violations, MetricValue{name: metric.name(), value: value, labels: labels, series: series, tolerated: tolerated})
+			gatherer.self.violation(metric.name())
+			if !tolerated && !gatherer.continueOn[metric.name()] {
+				flag = false
+			}
 		}
 	}
 	return metricValues, flag
 }
 
 type EventerInt interface {
-	Fire()
+	Fire(ctx context.Context)
 }
 
 type EnvManagerInt interface {
-	start()
-	stop()
+	start() error
+	stop() error
 }
 
-type Scheduler struct {
-	envManager   EnvManagerInt
+type ScenarioRun struct {
+	name         string
 	eventer      EventerInt
-	status       int
-	startDelay   int
-	testDuration int
-	timeout      int
+	load         EnvManagerInt
+	testDuration time.Duration
+	iterations   int
+}
+
+type Scheduler struct {
+	ctx              context.Context
+	clock            ClockInt
+	envManager       EnvManagerInt
+	eventer          EventerInt
+	scenarios        []ScenarioRun
+	status           int
+	startDelay       time.Duration
+	testDuration     time.Duration
+	iterations       int
+	timeout          time.Duration
+	tickTimeout      time.Duration
+	jitter           time.Duration
+	actions          []Action
+	teardownDeadline time.Duration
+	keepOnFailure    bool
+	runID            string
+	assertions       []RunAssertion
+	results          []AssertionResult
+	assertsFailed    bool
+	trends           []TrendResult
+	trendsFailed     bool
+	violated         bool
+	self             *SelfMetrics
+	heartbeat        time.Duration
+	progress         *Progress
+	watchdog         *Watchdog
+	scenario         string
+	stalls           []Stall
+	stalled          bool
+	probes           *StartupProbes
+	limits           *MaxValueQueries
+	notReady         bool
+	startRetries     int
+	startRetryDelay  time.Duration
+	start            *StandStart
+	recoverTicks     map[string]int
+	anomalies        *AnomalyDetector
+	drain            *Drain
+	ranges           *RangeQueries
+	windows          []RangeWindow
+	exclusions       *Exclusions
+	runState         *RunProgress
+}
+
+func (scheduler *Scheduler) init() error {
+	if scheduler.runState.isResumed() {
+		log.Println("resume run", scheduler.runID, "on the running stand")
+		return nil
+	}
+	_, span := startSpan(scheduler.context(), "env.start")
+	err := scheduler.startStand()
+	endSpan(span, err)
+	return err
+}
+
+func (scheduler Scheduler) down() error {
+	_, span := startSpan(scheduler.context(), "env.stop")
+	err := scheduler.teardown()
+	endSpan(span, err)
+	return err
+}
+
+func (scheduler Scheduler) teardown() error {
+	if scheduler.keepOnFailure && (scheduler.status == 1 || scheduler.violated) {
+		log.Println("keep stand running after failure")
+		return nil
+	}
+	if scheduler.teardownDeadline <= 0 {
+		return scheduler.envManager.stop()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- scheduler.envManager.stop()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(scheduler.teardownDeadline):
+		return fmt.Errorf("teardown did not finish in %s", scheduler.teardownDeadline)
+	}
 }
 
-func (scheduler Scheduler) init() {
-	scheduler.envManager.start()
+func (scheduler *Scheduler) failed() bool {
+	return scheduler.status != 0 || scheduler.violated || scheduler.assertsFailed || scheduler.trendsFailed || scheduler.startFailed()
 }
 
-func (scheduler Scheduler) down() {
-	scheduler.envManager.stop()
+func (scheduler *Scheduler) checkAssertions(reporter *Reporter) {
+	sampled, ranged := splitRanged(scheduler.assertions)
+	results, ok := reporter.checkAssertions(sampled)
+	if len(ranged) > 0 {
+		rangeResults, rangeOk := checkSampleAssertions(scheduler.ranges.samples, ranged)
+		results, ok = append(results, rangeResults...), ok && rangeOk
+	}
+	scheduler.results = results
+	scheduler.assertsFailed = !ok
+}
+
+func (scheduler *Scheduler) markViolated() {
+	scheduler.violated = true
+	scheduler.progress.fail()
 }
 
 func (scheduler *Scheduler) sendDown() {
@@ -199,119 +997,1448 @@ func (scheduler *Scheduler) sendDown() {
 		return
 	}
 	scheduler.status = 1
+	scheduler.progress.fail()
 }
 
-func (scheduler *Scheduler) tick() {
+func (scheduler *Scheduler) tick(ctx context.Context) {
 	if scheduler.status != 0 {
 		return
 	}
-	scheduler.eventer.Fire()
-}
-
-type Config struct {
-	Host         string   `yaml:"host"`
-	Metrics      []Metric `yaml:"metrics"`
-	StartDelay   int      `yaml:"startDelay"`
-	TestDuration int      `yaml:"testDuration"`
-	WorkDir      string   `yaml:"workDir"`
-	Timeout      int      `yaml:"timeout"`
+	ctx, span := startSpan(ctx, "tick", attribute.String("scenario", scheduler.scenario))
+	defer span.End()
+	if scheduler.tickTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, scheduler.tickTimeout)
+		defer cancel()
+	}
+	stall, stalled := scheduler.watchdog.watch(ctx, scheduler.clock, scheduler.scenario, scheduler.eventer.Fire)
+	if stalled {
+		scheduler.stalls = append(scheduler.stalls, stall)
+		span.SetStatus(codes.Error, "stalled")
+		if stall.Aborted {
+			log.Println("=[ stalled ]===========================")
+			scheduler.stalled = true
+			scheduler.sendDown()
+			return
+		}
+	}
+	scheduler.progress.tick()
 }
 
-type App struct {
-}
+type Duration time.Duration
 
-func (app App) run() {
-	config, err := app.loadConfig("./config.yaml")
+func (duration *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var seconds int
+	if err := node.Decode(&seconds); err == nil {
+		*duration = Duration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+	var text string
+	if err := node.Decode(&text); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(text)
 	if err != nil {
-		log.Fatalln(err)
-		return
+		return fmt.Errorf("line %d: %w", node.Line, err)
 	}
-	log.Println("=[ info ]==============================")
-	log.Println("      workDir:", config.WorkDir)
-	log.Println("   startDelay:", config.StartDelay)
-	log.Println(" testDuration:", config.TestDuration)
-	log.Println("      timeout:", config.Timeout)
-	log.Println("=[ init ]==============================")
+	*duration = Duration(parsed)
+	return nil
+}
 
-	reporter := Reporter{}
-	scheduler := app.tune(reporter, config)
-	scheduler.init()
+func (duration Duration) MarshalYAML() (any, error) {
+	return duration.String(), nil
+}
 
-	scheduler.run()
-	log.Println("=[ stop ]==============================")
-	scheduler.down()
-	reporter.report()
-}
-
-func (App) tune(reporter Reporter, config Config) Scheduler {
-
-	metrics := make([]MetricGather, 0)
-	for _, metric := range config.Metrics {
-		metrics = append(metrics, PrometheusMetric{
-			Host: config.Host,
-			Name: metric.Name, Query: metric.Query,
-			MaxValue: metric.MaxValue})
-	}
-
-	eventer := Eventer{
-		reporter: &reporter,
-		gatherer: Gatherer{
-			host:    config.Host,
-			metrics: metrics,
-		},
-	}
-	scheduler := Scheduler{
-		envManager: DockerCompose{
-			workDir:           config.WorkDir,
-			dockerComposeFile: "docker-compose.yaml",
-		},
-		eventer:      &eventer,
-		status:       0,
-		startDelay:   config.StartDelay,
-		testDuration: config.TestDuration,
-		timeout:      config.Timeout,
-	}
-	eventer.stoper = func() { scheduler.sendDown() }
-	return scheduler
+func (duration Duration) String() string {
+	return time.Duration(duration).String()
 }
 
-func (App) loadConfig(fileName string) (Config, error) {
+type Scenario struct {
+	Name       string       `yaml:"name"`
+	Duration   Duration     `yaml:"duration"`
+	Iterations int          `yaml:"iterations"`
+	Load       []string     `yaml:"load"`
+	Runner     RunnerConfig `yaml:"runner"`
+	Metrics    []Metric     `yaml:"metrics"`
+}
+
+type AbortConfig struct {
+	Webhook string   `yaml:"webhook"`
+	Command []string `yaml:"command"`
+	Timeout Duration `yaml:"timeout"`
+}
+
+type Config struct {
+	Host            string               `yaml:"host"`
+	Metrics         []Metric             `yaml:"metrics"`
+	Scenarios       []Scenario           `yaml:"scenarios"`
+	StartDelay      Duration             `yaml:"startDelay"`
+	TestDuration    Duration             `yaml:"testDuration"`
+	Iterations      int                  `yaml:"iterations"`
+	DrainDuration   Duration             `yaml:"drainDuration"`
+	StartupProbes   []StartupProbe       `yaml:"startupProbes"`
+	StartupTimeout  Duration             `yaml:"startupTimeout"`
+	StartRetries    int                  `yaml:"startRetries"`
+	StartRetryDelay Duration             `yaml:"startRetryDelay"`
+	WorkDir         string               `yaml:"workDir"`
+	Timeout         Duration             `yaml:"timeout"`
+	TickTimeout     Duration             `yaml:"tickTimeout"`
+	Jitter          Duration             `yaml:"jitter"`
+	Heartbeat       Duration             `yaml:"heartbeat"`
+	Watchdog        WatchdogConfig       `yaml:"watchdog"`
+	OnAbort         AbortConfig          `yaml:"onAbort"`
+	KeepEnvironment bool                 `yaml:"keepEnvironment"`
+	Vars            map[string]string    `yaml:"vars"`
+	Matrix          map[string][]string  `yaml:"matrix"`
+	Budgets         string               `yaml:"budgets"`
+	Stands          []string             `yaml:"-"`
+	Grafana         GrafanaConfig        `yaml:"grafana"`
+	Webhooks        []WebhookConfig      `yaml:"webhooks"`
+	ComposeCommand  []string             `yaml:"composeCommand"`
+	Env             StandConfig          `yaml:"env"`
+	Otlp            OtlpConfig           `yaml:"otlp"`
+	Loki            LokiConfig           `yaml:"loki"`
+	Docker          DockerConfig         `yaml:"docker"`
+	Actions         []ActionConfig       `yaml:"actions"`
+	SQL             SQLConfig            `yaml:"sql"`
+	ReportBuffer    int                  `yaml:"reportBuffer"`
+	Spool           bool                 `yaml:"spool"`
+	QueryLimits     QueryLimitsConfig    `yaml:",inline"`
+	Report          ReportConfig         `yaml:"report"`
+	Results         ResultsConfig        `yaml:"results"`
+	TeardownTimeout Duration             `yaml:"teardownTimeout"`
+	Elasticsearch   ElasticConfig        `yaml:"elasticsearch"`
+	Environments    []EnvironmentConfig  `yaml:"environments"`
+	OnViolation     string               `yaml:"onViolation"`
+	OutputDir       string               `yaml:"outputDir"`
+	Trends          TrendsConfig         `yaml:"trends"`
+	History         HistoryConfig        `yaml:"history"`
+	Ranges          RangesConfig         `yaml:"ranges"`
+	Exclude         []ExclusionConfig    `yaml:"exclude"`
+	SelfMetrics     SelfMetricsConfig    `yaml:"selfMetrics"`
+	Tracing         TracingConfig        `yaml:"tracing"`
+	EnvManager      string               `yaml:"envManager"`
+	Testcontainers  TestcontainersConfig `yaml:"testcontainers"`
+	SSH             SSHConfig            `yaml:"ssh"`
+	Nomad           NomadConfig          `yaml:"nomad"`
+	Email           EmailConfig          `yaml:"email"`
+	SNMP            SNMPConfig           `yaml:"snmp"`
+	System          SystemConfig         `yaml:"system"`
+	RabbitMQ        RabbitMQConfig       `yaml:"rabbitmq"`
+	Zabbix          ZabbixConfig         `yaml:"zabbix"`
+	Jolokia         JolokiaConfig        `yaml:"jolokia"`
+	MongoDB         MongoDBConfig        `yaml:"mongodb"`
+	ClickHouse      ClickHouseConfig     `yaml:"clickhouse"`
+	Kubernetes      KubernetesConfig     `yaml:"kubernetes"`
+	AzureMonitor    AzureMonitorConfig   `yaml:"azureMonitor"`
+	CloudMonitoring GCPMonitoringConfig  `yaml:"cloudMonitoring"`
+	Parquet         ParquetConfig        `yaml:"parquet"`
+	RunIDHeader     string               `yaml:"runIdHeader"`
+	S3              S3Config             `yaml:"s3"`
+}
+
+type VarsFlag map[string]string
+
+func (vars VarsFlag) String() string {
+	return fmt.Sprint(map[string]string(vars))
+}
+
+func (vars VarsFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("var %q: expected name=value", value)
+	}
+	vars[name] = val
+	return nil
+}
+
+type App struct {
+	configFile     string
+	keepOnFailure  bool
+	vars           VarsFlag
+	listen         string
+	token          string
+	reportFile     string
+	outputDir      string
+	teamCityOutput bool
+	dryRun         bool
+	once           bool
+	fakeTime       bool
+	profile        string
+	checkQueries   bool
+	tolerance      float64
+	livePort       int
+	format         string
+	output         string
+	input          string
+	template       string
+	stand          string
+	overrides      Overrides
+	selection      Selection
+	budgets        string
+	history        HistoryConfig
+	controlSocket  string
+	resume         string
+	abort          context.CancelFunc
+	noColor        bool
+	args           []string
+	envManager     EnvManagerInt
+}
+
+func renderQuery(query string, vars map[string]string) (string, error) {
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func renderMetrics(metrics []Metric, vars map[string]string) error {
+	for n := range metrics {
+		query, err := renderQuery(metrics[n].Query, vars)
+		if err != nil {
+			return fmt.Errorf("metric %s: %w", metrics[n].Name, err)
+		}
+		metrics[n].Query = query
+		maxValueQuery, err := renderQuery(metrics[n].MaxValueQuery, vars)
+		if err != nil {
+			return fmt.Errorf("metric %s: maxValueQuery: %w", metrics[n].Name, err)
+		}
+		metrics[n].MaxValueQuery = maxValueQuery
+	}
+	return nil
+}
+
+func (app App) applyVars(config Config) (Config, error) {
+	vars := map[string]string{}
+	for name, value := range config.Vars {
+		vars[name] = value
+	}
+	for name, value := range app.vars {
+		vars[name] = value
+	}
+	config.Vars = vars
+	config.Metrics = slices.Clone(config.Metrics)
+	if err := renderMetrics(config.Metrics, vars); err != nil {
+		return config, err
+	}
+	config.Scenarios = slices.Clone(config.Scenarios)
+	for n := range config.Scenarios {
+		scenario := &config.Scenarios[n]
+		scenario.Metrics = slices.Clone(scenario.Metrics)
+		if err := renderMetrics(scenario.Metrics, vars); err != nil {
+			return config, err
+		}
+		scenario.Load = slices.Clone(scenario.Load)
+		for i, arg := range scenario.Load {
+			rendered, err := renderQuery(arg, vars)
+			if err != nil {
+				return config, fmt.Errorf("scenario %s: load: %w", scenario.Name, err)
+			}
+			scenario.Load[i] = rendered
+		}
+		runner, err := renderRunner(scenario.Runner, vars)
+		if err != nil {
+			return config, fmt.Errorf("scenario %s: runner: %w", scenario.Name, err)
+		}
+		scenario.Runner = runner
+	}
+	return config, nil
+}
+
+func resolveScenarios(config Config) (Config, error) {
+	scenarios := make([]Scenario, 0, len(config.Scenarios))
+	for _, scenario := range config.Scenarios {
+		if scenario.Duration <= 0 && scenario.Iterations <= 0 {
+			switch {
+			case config.Iterations > 0:
+				log.Println("scenario", scenario.Name, "has no duration, using iterations", config.Iterations)
+				scenario.Iterations = config.Iterations
+			case config.TestDuration <= 0:
+				return config, fmt.Errorf("scenario %s: duration is not set", scenario.Name)
+			default:
+				log.Println("scenario", scenario.Name, "has no duration, using testDuration", config.TestDuration)
+				scenario.Duration = config.TestDuration
+			}
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	config.Scenarios = scenarios
+	return config, nil
+}
+
+func (app App) run() {
+	config, err := app.loadConfig(app.configFile)
+	if err != nil {
+		log.Fatalln(err)
+		return
+	}
+	if app.dryRun {
+		plan := app.plan
+		if len(config.Matrix) > 0 {
+			plan = app.planMatrix
+		}
+		if len(config.Stands) > 0 {
+			plan = app.planStands
+		}
+		if err := plan(context.Background(), config, os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	app.abort = stop
+	if app.once {
+		ok, err := app.gatherOnce(ctx, config, os.Stdout)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+	if app.resume != "" && (len(config.Stands) > 0 || len(config.Matrix) > 0) {
+		log.Fatalln("--resume does not work with stands or matrix")
+	}
+	if len(config.Stands) > 0 {
+		app.runStands(ctx, config)
+		return
+	}
+	if len(config.Matrix) > 0 {
+		app.runMatrix(ctx, config)
+		return
+	}
+	reporter := Reporter{}
+	if app.livePort > 0 {
+		live, err := startLive(app.livePort, &reporter)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer live.stop()
+	}
+	scheduler, err := app.execute(ctx, config, &reporter)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if app.reportFile != "" {
+		if err := saveRunReport(app.reportFile, reporter.runReport(scheduler)); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if ctx.Err() != nil {
+		log.Println("=[ interrupted ]=======================")
+		os.Exit(130)
+	}
+	if scheduler.stalled {
+		log.Println("=[ stalled ]===========================")
+		os.Exit(stalledExitCode)
+	}
+	if scheduler.startFailed() {
+		log.Println("=[ stand failed to start ]=============")
+		os.Exit(startFailedExitCode)
+	}
+	if scheduler.notReady {
+		log.Println("=[ stand never became ready ]==========")
+		os.Exit(notReadyExitCode)
+	}
+	if scheduler.failed() {
+		log.Println("=[ failed ]============================")
+		os.Exit(1)
+	}
+	log.Println("=[ passed ]============================")
+}
+
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+func (app App) prepare(config Config) (Config, []RunAssertion, error) {
+	config.KeepEnvironment = config.KeepEnvironment || app.keepOnFailure
+	config, err := app.applyVars(config)
+	if err != nil {
+		return config, nil, err
+	}
+	if config, err = app.overrides.apply(config); err != nil {
+		return config, nil, err
+	}
+	if config, err = app.applyBudgets(config); err != nil {
+		return config, nil, err
+	}
+	if config, err = app.selection.apply(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateIterations(config); err != nil {
+		return config, nil, err
+	}
+	if config, err = resolveScenarios(config); err != nil {
+		return config, nil, err
+	}
+	if config, err = expandRunners(config); err != nil {
+		return config, nil, err
+	}
+	assertions, err := collectAssertions(config)
+	if err != nil {
+		return config, nil, err
+	}
+	if err := validateMetricTypes(config); err != nil {
+		return config, nil, err
+	}
+	if err := validatePromQL(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateActions(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateExclusions(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateDrain(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateStartupProbes(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateStartRetries(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateTrends(config.Trends); err != nil {
+		return config, nil, err
+	}
+	if err := validateHistory(config.History); err != nil {
+		return config, nil, err
+	}
+	if err := validateRanges(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateWarnings(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateQueryLimits(config.QueryLimits); err != nil {
+		return config, nil, err
+	}
+	if err := validateTracing(config.Tracing); err != nil {
+		return config, nil, err
+	}
+	if err := validateSpool(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateReport(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateEnvironments(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateEnvManager(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateWebhooks(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateWatchdog(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateStand(config); err != nil {
+		return config, nil, err
+	}
+	if err := validateS3(config.S3); err != nil {
+		return config, nil, err
+	}
+	if err := validateEmail(config); err != nil {
+		return config, nil, err
+	}
+	return config, assertions, nil
+}
+
+func (app App) execute(ctx context.Context, config Config, reporter *Reporter) (*Scheduler, error) {
+	config, assertions, err := app.prepare(config)
+	if err != nil {
+		return nil, err
+	}
+	resume, resumeDir, err := app.resumeState(config)
+	if err != nil {
+		return nil, err
+	}
+	runID, started := newRunID(), time.Now()
+	if resume != nil {
+		runID, started = resume.RunID, resume.Started
+	}
+	defer logRunID(runID)()
+	ctx, finishTracing := startTracing(ctx, config.Tracing, runID)
+	defer finishTracing()
+	sources, closeSources, err := newSources(config)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSources()
+	sources.headers = http.Header{http.CanonicalHeaderKey(config.runIDHeader()): {runID}}
+	if config.Otlp.Listen != "" {
+		sources.otlp = NewOtlpReceiver()
+		if err := sources.otlp.start(config.Otlp.Listen); err != nil {
+			return nil, err
+		}
+		defer sources.otlp.stop()
+	}
+	if config.SelfMetrics.Listen != "" {
+		sources.self = NewSelfMetrics()
+		sources.self.run(runID)
+		if err := sources.self.start(config.SelfMetrics.Listen); err != nil {
+			return nil, err
+		}
+		defer sources.self.stop()
+	}
+	var output *RunOutput
+	if resume != nil {
+		output, err = resumeRunOutput(resumeDir)
+	} else {
+		output, err = newRunOutput(app.outputBaseDir(config), runID, started)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer output.close()
+	output.writeConfig(config)
+	abort := app.abort
+	if abort == nil {
+		ctx, abort = context.WithCancel(ctx)
+		defer abort()
+	}
+	defer app.startControl(output.dir, runID, started, reporter, abort)()
+	vars, err := newStandVars(config, runID, output.dir)
+	if err != nil {
+		return nil, err
+	}
+	if config.Env.Environment, err = renderStandEnvironment(config.Env.Environment, vars); err != nil {
+		return nil, err
+	}
+	if config.Spool {
+		if err := reporter.spoolTo(filepath.Join(output.dir, "values.ndjson"), recoverTicks(config)); err != nil {
+			return nil, err
+		}
+	} else if config.ReportBuffer > 0 {
+		if err := reporter.spillTo(filepath.Join(output.dir, "values.ndjson"), config.ReportBuffer); err != nil {
+			return nil, err
+		}
+	}
+	if config.Parquet.Enabled {
+		parquet, err := NewParquetWriter(filepath.Join(output.dir, "values.parquet"), runID, config.Parquet)
+		if err != nil {
+			return nil, err
+		}
+		reporter.subscribe(parquet.observe)
+		defer parquet.close()
+	}
+	var replayed []MetricValues
+	if resume != nil {
+		if replayed, err = readTicks(output.dir); err != nil {
+			return nil, err
+		}
+		log.Println("resume run", runID, "with", len(replayed), "gathered ticks")
+	}
+	log.Println("=[ info ]==============================")
+	log.Println("        runID:", runID)
+	log.Println("      workDir:", config.WorkDir)
+	log.Println("   startDelay:", config.StartDelay)
+	if len(config.Scenarios) == 0 && config.Iterations > 0 {
+		log.Println("   iterations:", config.Iterations)
+	} else if len(config.Scenarios) == 0 {
+		log.Println(" testDuration:", config.TestDuration)
+	}
+	log.Println("      timeout:", config.Timeout)
+	log.Println("  tickTimeout:", config.TickTimeout)
+	log.Println("       jitter:", config.Jitter)
+	log.Println("    heartbeat:", config.Heartbeat)
+	if config.Watchdog.Factor > 0 {
+		log.Println("     watchdog:", config.Watchdog.Factor, "x timeout, abort", config.Watchdog.Abort)
+	}
+	for _, scenario := range config.Scenarios {
+		if scenario.Iterations > 0 {
+			log.Println("     scenario:", scenario.Name, scenario.Iterations, "iterations")
+		} else {
+			log.Println("     scenario:", scenario.Name, scenario.Duration)
+		}
+	}
+	if config.DrainDuration > 0 {
+		log.Println("drainDuration:", config.DrainDuration)
+	}
+	log.Println("=[ init ]==============================")
+
+	scheduler := app.tune(reporter, config, sources)
+	episodes := NewEpisodeTracker(scheduler.recoverTicks)
+	episodes.logRecovery = true
+	reporter.subscribe(episodes.observe)
+	scheduler.ctx = ctx
+	scheduler.assertions = assertions
+	scheduler.runID = runID
+	runState := RunState{RunID: runID, Started: started}
+	if resume != nil {
+		runState = *resume
+	}
+	scheduler.runState = newRunProgress(output.dir, runState, resume != nil)
+	if err := scheduler.init(); err != nil {
+		log.Println(err)
+		log.Println("=[ stop ]==============================")
+		if collector, ok := scheduler.envManager.(LogCollector); ok {
+			if b, err := collector.logs(); err == nil {
+				output.writeFile("compose.log", b)
+			}
+		}
+		if err := scheduler.envManager.stop(); err != nil {
+			log.Println(err)
+		}
+		runReport := reporter.runReport(scheduler)
+		output.writeReports(runReport)
+		output.writeMetadata(RunMetadata{
+			RunID:     runID,
+			Started:   started,
+			Finished:  time.Now(),
+			Host:      config.Host,
+			WorkDir:   config.WorkDir,
+			Branch:    config.Trends.Branch,
+			Scenarios: scenarioNames(config),
+		})
+		app.pruneHistory(config)
+		return scheduler, nil
+	}
+
+	if resume != nil {
+		scheduler.replay(replayed)
+	}
+	journal, err := openTickJournal(output.dir)
+	if err != nil {
+		return nil, err
+	}
+	reporter.subscribe(journal.observe)
+	defer journal.close()
+	notifiers := app.notifiers(config, reporter)
+	notifyViolations(notifiers, runID, reporter)
+	if resume == nil {
+		notifyStarted(notifiers, runID)
+	}
+	teamCity := app.teamCity()
+	if teamCity != nil {
+		teamCity.message("setParameter", "name", "metricsgatherer.runId", "value", runID)
+		teamCity.attach(reporter)
+	}
+	gitHub := app.gitHub()
+	if gitHub != nil {
+		gitHub.attach(reporter)
+	}
+	scheduler.run()
+	if scheduler.context().Err() == nil {
+		scheduler.ranges.query(scheduler.context(), scheduler.windows)
+	}
+	scheduler.checkAssertions(reporter)
+	if config.Trends.enabled() {
+		if history, err := loadTrendHistory(app.outputBaseDir(config), config.Trends); err != nil {
+			log.Println("trends error:", err)
+		} else {
+			scheduler.checkTrends(config.Trends, history, reporter.summaries())
+		}
+	}
+	if teamCity != nil {
+		teamCity.assertions(scheduler.results)
+	}
+	if gitHub != nil {
+		gitHub.assertions(scheduler.results)
+	}
+	notifyFinished(notifiers, runID, !scheduler.failed())
+	log.Println("=[ stop ]==============================")
+	if collector, ok := scheduler.envManager.(LogCollector); ok {
+		if b, err := collector.logs(); err != nil {
+			log.Println(err)
+		} else {
+			output.writeFile("compose.log", b)
+		}
+	}
+	snapshot := takeSnapshot(scheduler.envManager)
+	err = scheduler.down()
+	reporter.close()
+	reporter.report(app.colors())
+	reportAssertions(scheduler.results)
+	runReport := reporter.runReport(scheduler)
+	runReport.Snapshot = snapshot
+	output.writeReports(runReport)
+	output.writeTemplateReport(config.Report, config.WorkDir, runReport)
+	output.writeMetadata(RunMetadata{
+		RunID:     runID,
+		Started:   started,
+		Finished:  time.Now(),
+		Passed:    !scheduler.failed(),
+		Host:      config.Host,
+		WorkDir:   config.WorkDir,
+		Branch:    config.Trends.Branch,
+		Scenarios: scenarioNames(config),
+	})
+	app.pruneHistory(config)
+	if config.S3.Bucket != "" {
+		key := ArtifactKey{RunID: runID, Date: started.Format("20060102-150405"), Result: reportResult(runReport), Vars: config.Vars}
+		if url, err := uploadArtifacts(config.S3, output.dir, key); err != nil {
+			log.Println("upload error:", err)
+		} else {
+			log.Println("artifacts:", url)
+			runReport.Artifacts = url
+		}
+	}
+	if gitHub != nil {
+		gitHub.finished(runReport)
+	}
+	if email := app.email(config); email != nil {
+		email.finished(runReport)
+	}
+	return scheduler, err
+}
+
+func scenarioNames(config Config) []string {
+	names := make([]string, 0)
+	for _, scenario := range config.Scenarios {
+		names = append(names, scenario.Name)
+	}
+	return names
+}
+
+func (app App) tune(reporter *Reporter, config Config, sources Sources) *Scheduler {
+	teardownTimeout := time.Duration(config.TeardownTimeout)
+	if teardownTimeout <= 0 {
+		teardownTimeout = defaultTeardownTimeout
+	}
+	var envManager EnvManagerInt = DockerCompose{
+		workDir:         config.WorkDir,
+		composeCommand:  config.ComposeCommand,
+		teardownTimeout: teardownTimeout,
+		pull:            config.Env.Pull == "always",
+		build:           config.Env.Build,
+		envFiles:        config.Env.EnvFile,
+		environment:     standEnvironment(config.Env.Environment),
+		down:            config.Env.Down,
+	}
+	if len(config.Environments) > 0 {
+		envManager = newEnvironments(config, teardownTimeout)
+	}
+	if config.EnvManager == "testcontainers" {
+		envManager = newTestcontainersEnv(config, teardownTimeout)
+	}
+	if config.EnvManager == "ssh" {
+		envManager = newSSHEnv(config, teardownTimeout)
+	}
+	if config.EnvManager == "nomad" {
+		envManager = newNomadEnv(config, teardownTimeout)
+	}
+	if app.envManager != nil {
+		envManager = app.envManager
+	}
+	var clock ClockInt = RealClock{}
+	if app.fakeTime {
+		clock = NewSimulatedClock(time.Now())
+	}
+	scheduler := &Scheduler{
+		clock:            clock,
+		envManager:       envManager,
+		status:           0,
+		startDelay:       time.Duration(config.StartDelay),
+		testDuration:     time.Duration(config.TestDuration),
+		iterations:       config.Iterations,
+		timeout:          time.Duration(config.Timeout),
+		tickTimeout:      time.Duration(config.TickTimeout),
+		jitter:           time.Duration(config.Jitter),
+		keepOnFailure:    config.KeepEnvironment,
+		actions:          newActions(config),
+		teardownDeadline: 3*teardownTimeout + 10*time.Second,
+		self:             sources.self,
+		heartbeat:        time.Duration(config.Heartbeat),
+		watchdog:         newWatchdog(config.Watchdog, time.Duration(config.Timeout)),
+		recoverTicks:     recoverTicks(config),
+		startRetries:     config.StartRetries,
+		startRetryDelay:  time.Duration(config.StartRetryDelay),
+	}
+	var aborter AborterInt
+	if config.OnAbort.Webhook != "" || len(config.OnAbort.Command) > 0 {
+		aborter = AbortHook{
+			workDir: config.WorkDir,
+			webhook: config.OnAbort.Webhook,
+			command: config.OnAbort.Command,
+			timeout: time.Duration(config.OnAbort.Timeout),
+		}
+	}
+	budget := NewViolationBudget()
+	scheduler.anomalies = NewAnomalyDetector(config)
+	scheduler.limits = newMaxValueQueries(config, sources)
+	scheduler.ranges = newRangeQueries(config, sources)
+	scheduler.exclusions = newExclusions(config)
+	newEventer := func(scenario string, metrics []Metric) *Eventer {
+		return &Eventer{
+			clock:    clock,
+			aborter:  aborter,
+			scenario: scenario,
+			reporter: reporter,
+			gatherer: Gatherer{
+				host:       config.Host,
+				metrics:    newMetricGathers(sources, metrics),
+				continueOn: continueOnViolation(config.OnViolation, metrics),
+				allowed:    allowedViolations(metrics),
+				relative:   relativeThresholds(metrics),
+				unchecked:  budgetOnly(metrics),
+				exclusions: scheduler.exclusions,
+				failOn:     failOnWarnings(metrics),
+				queries:    sources.queries,
+				queried:    maxValueQueries(metrics),
+				limits:     scheduler.limits,
+				budget:     budget,
+				anomalies:  scheduler.anomalies,
+				self:       sources.self,
+			},
+			stoper:   func() { scheduler.sendDown() },
+			violated: func() { scheduler.markViolated() },
+		}
+	}
+	scheduler.eventer = newEventer("", config.Metrics)
+	for _, scenario := range config.Scenarios {
+		run := ScenarioRun{
+			name:         scenario.Name,
+			eventer:      newEventer(scenario.Name, mergeMetrics(config.Metrics, scenario.Metrics)),
+			testDuration: time.Duration(scenario.Duration),
+			iterations:   scenario.Iterations,
+		}
+		if len(scenario.Load) > 0 {
+			run.load = &CommandLoad{workDir: config.WorkDir, command: scenario.Load}
+		}
+		scheduler.scenarios = append(scheduler.scenarios, run)
+	}
+	if config.DrainDuration > 0 {
+		drain := &Drain{duration: time.Duration(config.DrainDuration), limits: drainLimits(config.Metrics)}
+		eventer := newEventer(drainScenario, config.Metrics)
+		gatherer := eventer.gatherer.(Gatherer)
+		for name := range drain.limits {
+			gatherer.unchecked[name] = true
+		}
+		eventer.gatherer = gatherer
+		eventer.observed = drain.observe
+		drain.eventer = eventer
+		scheduler.drain = drain
+	}
+	scheduler.probes = newStartupProbes(config, sources)
+	return scheduler
+}
+
+type Sources struct {
+	host    string
+	headers http.Header
+	workDir string
+	queries *QueryLimiter
+	otlp    *OtlpReceiver
+	loki    string
+	docker  *DockerAPI
+	sql     *sql.DB
+	results *ResultsTail
+	elastic *ElasticClient
+	snmp    *SNMPClient
+	system  SystemConfig
+	rabbit  *RabbitMQClient
+	zabbix  *ZabbixClient
+	jolokia *JolokiaClient
+	mongo   *MongoDBClient
+	click   *ClickHouseClient
+	kube    *KubernetesClient
+	azure   *AzureMonitorClient
+	gcp     *CloudMonitoringClient
+	self    *SelfMetrics
+}
+
+func newSources(config Config) (Sources, func(), error) {
+	sources := Sources{
+		host:    config.Host,
+		workDir: config.WorkDir,
+		queries: newQueryLimiter(config.QueryLimits),
+		loki:    config.Loki.URL,
+		docker:  NewDockerAPI(config.Docker.Host, config.Docker.Project),
+		snmp:    NewSNMPClient(config.SNMP),
+		system:  config.System,
+		rabbit:  NewRabbitMQClient(config.RabbitMQ),
+		jolokia: NewJolokiaClient(config.Jolokia),
+	}
+	if config.Zabbix.URL != "" {
+		sources.zabbix = NewZabbixClient(config.Zabbix)
+	}
+	if config.Results.File != "" {
+		sources.results = NewResultsTail(config.Results, config.WorkDir)
+	}
+	if config.ClickHouse.URL != "" {
+		sources.click = NewClickHouseClient(config.ClickHouse)
+	}
+	if config.AzureMonitor.Token != "" || config.AzureMonitor.TenantID != "" {
+		sources.azure = NewAzureMonitorClient(config.AzureMonitor)
+	}
+	if config.CloudMonitoring.Project != "" {
+		client, err := NewCloudMonitoringClient(config.CloudMonitoring, config.WorkDir)
+		if err != nil {
+			return sources, nil, err
+		}
+		sources.gcp = client
+	}
+	if config.Kubernetes.enabled() {
+		client, err := NewKubernetesClient(config.Kubernetes, config.WorkDir)
+		if err != nil {
+			return sources, nil, err
+		}
+		sources.kube = client
+	}
+	if config.Elasticsearch.URL != "" {
+		client, err := NewElasticClient(config.Elasticsearch)
+		if err != nil {
+			return sources, nil, err
+		}
+		sources.elastic = client
+	}
+	cleanup := func() {}
+	if config.MongoDB.URI != "" {
+		client, err := NewMongoDBClient(config.MongoDB)
+		if err != nil {
+			return sources, nil, err
+		}
+		sources.mongo = client
+		cleanup = client.close
+	}
+	if config.SQL.DSN != "" {
+		db, err := openSQL(config.SQL)
+		if err != nil {
+			cleanup()
+			return sources, nil, err
+		}
+		sources.sql = db
+		return sources, func() { _ = db.Close(); cleanup() }, nil
+	}
+	return sources, cleanup, nil
+}
+
+func newMetricGathers(sources Sources, metrics []Metric) []MetricGather {
+	gathers := make([]MetricGather, 0)
+	for _, metric := range metrics {
+		switch metric.Type {
+		case "otlp":
+			gathers = append(gathers, OtlpMetric{
+				receiver: sources.otlp,
+				Name:     metric.Name, Query: metric.Query, Attributes: metric.Attributes,
+				MaxValue: metric.MaxValue})
+		case "loki":
+			gathers = append(gathers, LokiMetric{
+				Host: sources.loki,
+				Name: metric.Name, Query: metric.Query,
+				MaxValue: metric.MaxValue})
+		case "elasticsearch":
+			gathers = append(gathers, ElasticMetric{
+				client: sources.elastic,
+				Name:   metric.Name, Index: metric.Index, Query: metric.Query,
+				Aggregate: metric.Aggregate, Window: time.Duration(metric.Window),
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "results":
+			gathers = append(gathers, ResultsMetric{
+				tail: sources.results,
+				Name: metric.Name, Stat: metric.Query,
+				MaxValue: metric.MaxValue})
+		case "snmp":
+			target := metric.Target
+			if target == "" {
+				target = sources.snmp.config.Target
+			}
+			gathers = append(gathers, SNMPMetric{
+				client: sources.snmp, sample: &SNMPSample{},
+				Name: metric.Name, Target: target, OID: metric.Query,
+				Delta:    metric.Aggregate == "delta",
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "plugin":
+			gathers = append(gathers, PluginMetric{
+				workDir: sources.workDir, command: metric.Plugin,
+				Name: metric.Name, Query: metric.Query, Labels: metric.Labels, Options: metric.Options,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "sql":
+			gathers = append(gathers, SQLMetric{
+				db:   sources.sql,
+				Name: metric.Name, Query: metric.Query,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "rabbitmq":
+			gathers = append(gathers, RabbitMQMetric{
+				client: sources.rabbit,
+				Name:   metric.Name, Queue: metric.Queue, Stat: metric.Query,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "jolokia":
+			gathers = append(gathers, JolokiaMetric{
+				client: sources.jolokia,
+				Name:   metric.Name, Target: metric.Target, MBean: metric.Query, Path: metric.Path, Scale: metric.Scale,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "zabbix":
+			gathers = append(gathers, ZabbixMetric{
+				client: sources.zabbix,
+				Name:   metric.Name, Host: metric.Target, Key: metric.Query,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "azureMonitor":
+			gathers = append(gathers, newAzureMonitorMetric(sources.azure, metric))
+		case "cloudMonitoring":
+			gathers = append(gathers, newCloudMonitoringMetric(sources.gcp, metric))
+		case "clickhouse":
+			gathers = append(gathers, ClickHouseMetric{
+				client: sources.click,
+				Name:   metric.Name, Query: metric.Query,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "kubernetes":
+			gathers = append(gathers, KubernetesMetric{
+				client: sources.kube,
+				Name:   metric.Name, Stat: metric.Query, Namespace: metric.Namespace, Selector: metric.Selector,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "mongodb":
+			gathers = append(gathers, MongoDBMetric{
+				client: sources.mongo,
+				Name:   metric.Name, Command: metric.Command, Database: metric.Database, Field: metric.Query,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "system":
+			gathers = append(gathers, SystemMetric{
+				hostProc: sources.system.HostProc, sample: &SystemSample{},
+				Name: metric.Name, Stat: metric.Query, Path: metric.Path,
+				MaxValue: metric.MaxValue})
+		case "histogram":
+			gathers = append(gathers, HistogramMetric{
+				Host: sources.host, Headers: sources.headers, sample: &HistogramSample{},
+				Name: metric.Name, Query: metric.Query, Quantile: metric.Quantile, Scale: metric.Scale,
+				Window:   time.Duration(metric.Window),
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing})
+		case "derived":
+			gathers = append(gathers, newDerivedMetric(metric))
+		case "httpProbe":
+			gathers = append(gathers, HTTPProbeMetric{
+				client: newHTTPProbeClient(metric.Probe), headers: sources.headers,
+				Name: metric.Name, URL: metric.Target, Stat: metric.Query, Probe: metric.Probe,
+				MaxValue: metric.MaxValue})
+		case "docker":
+			gathers = append(gathers, DockerMetric{
+				api:  sources.docker,
+				Name: metric.Name, Service: metric.Service, Stat: metric.Query,
+				MaxValue: metric.MaxValue})
+		default:
+			gathers = append(gathers, PrometheusMetric{
+				Host: sources.host, Headers: sources.headers,
+				Name: metric.Name, Query: metric.Query,
+				MaxValue: metric.MaxValue, OnMissing: metric.OnMissing,
+				Offset: time.Duration(metric.Offset), Align: time.Duration(metric.Align),
+				Reduce: metric.Aggregate})
+		}
+		if len(metric.Labels) > 0 {
+			gathers[len(gathers)-1] = LabeledMetric{MetricGather: gathers[len(gathers)-1], Labels: metric.Labels}
+		}
+	}
+	return gathers
+}
+
+var violationPolicies = []string{"", "stop", "continue"}
+
+func continueOnViolation(policy string, metrics []Metric) map[string]bool {
+	continueOn := map[string]bool{}
+	for _, metric := range metrics {
+		if metric.OnViolation != "" {
+			continueOn[metric.Name] = metric.OnViolation == "continue"
+		} else {
+			continueOn[metric.Name] = policy == "continue"
+		}
+	}
+	return continueOn
+}
+
+func validateMetricTypes(config Config) error {
+	metrics := append([]Metric{}, config.Metrics...)
+	for _, scenario := range config.Scenarios {
+		metrics = append(metrics, scenario.Metrics...)
+	}
+	if !slices.Contains(violationPolicies, config.OnViolation) {
+		return fmt.Errorf("unknown onViolation %s", config.OnViolation)
+	}
+	if len(config.Scenarios) == 0 {
+		if err := validateRelativeThresholds(config.Metrics); err != nil {
+			return err
+		}
+	}
+	for _, scenario := range config.Scenarios {
+		if err := validateRelativeThresholds(mergeMetrics(config.Metrics, scenario.Metrics)); err != nil {
+			return fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+	}
+	if len(config.Scenarios) == 0 {
+		if err := validateDerivedMetrics(config.Metrics); err != nil {
+			return err
+		}
+	}
+	for _, scenario := range config.Scenarios {
+		if err := validateDerivedMetrics(mergeMetrics(config.Metrics, scenario.Metrics)); err != nil {
+			return fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+	}
+	for _, metric := range metrics {
+		if !slices.Contains(missingPolicies, metric.OnMissing) {
+			return fmt.Errorf("metric %s: unknown onMissing %s", metric.Name, metric.OnMissing)
+		}
+		if !slices.Contains(violationPolicies, metric.OnViolation) {
+			return fmt.Errorf("metric %s: unknown onViolation %s", metric.Name, metric.OnViolation)
+		}
+		if metric.AllowedViolations < 0 {
+			return fmt.Errorf("metric %s: allowedViolations must not be negative", metric.Name)
+		}
+		if metric.RecoverTicks < 0 {
+			return fmt.Errorf("metric %s: recoverTicks must not be negative", metric.Name)
+		}
+		if err := validateAnomaly(metric); err != nil {
+			return fmt.Errorf("metric %s: %w", metric.Name, err)
+		}
+		if err := validateMaxValueQuery(metric); err != nil {
+			return fmt.Errorf("metric %s: %w", metric.Name, err)
+		}
+		switch metric.Type {
+		case "", "prometheus":
+			if !slices.Contains(matrixReductions, metric.Aggregate) {
+				return fmt.Errorf("metric %s: unknown prometheus aggregate %s", metric.Name, metric.Aggregate)
+			}
+		case "otlp":
+			if config.Otlp.Listen == "" {
+				return fmt.Errorf("metric %s: otlp.listen is not set", metric.Name)
+			}
+		case "loki":
+			if config.Loki.URL == "" {
+				return fmt.Errorf("metric %s: loki.url is not set", metric.Name)
+			}
+		case "elasticsearch":
+			if config.Elasticsearch.URL == "" {
+				return fmt.Errorf("metric %s: elasticsearch.url is not set", metric.Name)
+			}
+			if metric.Index == "" {
+				return fmt.Errorf("metric %s: index is not set", metric.Name)
+			}
+			if _, _, err := parseElasticAggregate(metric.Aggregate); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "results":
+			if config.Results.File == "" {
+				return fmt.Errorf("metric %s: results.file is not set", metric.Name)
+			}
+			if !slices.Contains(resultsFormats, config.Results.Format) {
+				return fmt.Errorf("metric %s: unknown results.format %s", metric.Name, config.Results.Format)
+			}
+			if !validResultsStat(config.Results.Format, metric.Query) {
+				return fmt.Errorf("metric %s: unknown results stat %s", metric.Name, metric.Query)
+			}
+		case "sql":
+			if config.SQL.DSN == "" {
+				return fmt.Errorf("metric %s: sql.dsn is not set", metric.Name)
+			}
+			if _, ok := sqlDrivers[config.SQL.Driver]; !ok {
+				return fmt.Errorf("metric %s: unknown sql.driver %s", metric.Name, config.SQL.Driver)
+			}
+		case "docker":
+			if metric.Service == "" {
+				return fmt.Errorf("metric %s: service is not set", metric.Name)
+			}
+			if _, err := (DockerStats{}).value(metric.Query); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "rabbitmq":
+			if err := validateRabbitMQMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "jolokia":
+			if err := validateJolokiaMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "zabbix":
+			if err := validateZabbixMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "azureMonitor":
+			if err := validateAzureMonitorMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "cloudMonitoring":
+			if err := validateCloudMonitoringMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "mongodb":
+			if err := validateMongoDBMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "clickhouse":
+			if err := validateClickHouseMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "kubernetes":
+			if err := validateKubernetesMetric(config, metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "histogram":
+			if err := validateHistogramMetric(metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "derived":
+		case "httpProbe":
+			if err := validateHTTPProbeMetric(metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "system":
+			if err := validateSystemMetric(metric); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		case "plugin":
+			if len(metric.Plugin) == 0 {
+				return fmt.Errorf("metric %s: plugin is not set", metric.Name)
+			}
+		case "snmp":
+			if metric.Target == "" && config.SNMP.Target == "" {
+				return fmt.Errorf("metric %s: snmp.target is not set", metric.Name)
+			}
+			if metric.Query == "" {
+				return fmt.Errorf("metric %s: query (oid) is not set", metric.Name)
+			}
+			if metric.Aggregate != "" && metric.Aggregate != "delta" {
+				return fmt.Errorf("metric %s: unknown snmp aggregate %s", metric.Name, metric.Aggregate)
+			}
+			if err := validateSNMP(config.SNMP); err != nil {
+				return fmt.Errorf("metric %s: %w", metric.Name, err)
+			}
+		default:
+			return fmt.Errorf("metric %s: unknown type %s", metric.Name, metric.Type)
+		}
+	}
+	return nil
+}
+
+func mergeMetrics(base []Metric, overrides []Metric) []Metric {
+	merged := append([]Metric{}, base...)
+	for _, override := range overrides {
+		found := false
+		for n := range merged {
+			if merged[n].Name == override.Name {
+				merged[n] = override
+				found = true
+			}
+		}
+		if !found {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
+
+func (app App) loadConfig(fileName string) (Config, error) {
 	b, err := os.ReadFile(fileName)
 	if err != nil {
 		log.Fatalln(err)
 		return Config{}, err
 	}
-	config := Config{}
-	if err := yaml.Unmarshal(b, &config); err != nil {
+	b, err = includeConfigs(fileName, expandEnv(b, os.LookupEnv))
+	if err != nil {
+		log.Fatalln(err)
+		return Config{}, err
+	}
+	config, err := app.parseConfig(b)
+	if err != nil {
 		log.Fatalln(err)
 		return Config{}, err
 	}
 	return config, nil
 }
 
+var envPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+func expandEnv(b []byte, lookup func(string) (string, bool)) []byte {
+	return envPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+		if string(match) == "$$" {
+			return []byte("$")
+		}
+		groups := envPattern.FindSubmatch(match)
+		if value, ok := lookup(string(groups[1])); ok && value != "" {
+			return []byte(value)
+		}
+		return groups[3]
+	})
+}
+
+func (app App) parseConfig(b []byte) (Config, error) {
+	raw := ConfigYAML{}
+	if err := decodeStrict(b, &raw); err != nil {
+		return Config{}, err
+	}
+	if len(raw.Include) > 0 {
+		return Config{}, errors.New("include is only supported in config files")
+	}
+	b, err := applyProfile(b, app.profile)
+	if err != nil {
+		return Config{}, err
+	}
+	b, stands, err := applyStand(b, app.stand)
+	if err != nil {
+		return Config{}, err
+	}
+	config := Config{}
+	if err := decodeStrict(b, &config); err != nil {
+		return Config{}, err
+	}
+	config.Stands = stands
+	return config, nil
+}
+
 func (scheduler *Scheduler) run() {
+	scheduler.progress = NewProgress(clockOf(scheduler.clock).now(), scheduler.plannedDuration())
+	scheduler.progress.ticks = scheduler.runState.ticks()
+	if scheduler.heartbeat > 0 {
+		stopProgress := scheduler.progress.start(scheduler.clock, scheduler.heartbeat)
+		defer stopProgress()
+	}
+	if !scheduler.waitReady() || !scheduler.resolveLimits() {
+		return
+	}
 	log.Println("=[ delay ]=============================")
-	time.Sleep(time.Duration(scheduler.startDelay) * time.Second)
+	ctx, span := startSpan(scheduler.context(), "warmup")
+	now := clockOf(scheduler.clock).now()
+	gathering := scheduler.runState.gathering(now, scheduler.startDelay)
+	slept := scheduler.sleep(ctx, gathering.Sub(now))
+	span.End()
+	if !slept {
+		return
+	}
+	scheduler.exclusions.start(gathering, scheduler.actions)
+	stopActions := scheduler.startActions(gathering)
+	defer stopActions()
+	if len(scheduler.scenarios) == 0 {
+		scheduler.loopWindow(0)
+		scheduler.runDrain()
+		return
+	}
+	for n, scenario := range scheduler.scenarios {
+		if scheduler.status != 0 || scheduler.context().Err() != nil {
+			return
+		}
+		log.Println("=[ scenario " + scenario.name + " ]")
+		scheduler.progress.setScenario(scenario.name)
+		scheduler.scenario = scenario.name
+		scheduler.eventer = scenario.eventer
+		scheduler.testDuration = scenario.testDuration
+		scheduler.iterations = scenario.iterations
+		if scenario.load != nil {
+			if err := scenario.load.start(); err != nil {
+				log.Println(err)
+			}
+		}
+		scheduler.loopWindow(n)
+		if scenario.load != nil {
+			if err := scenario.load.stop(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	scheduler.runDrain()
+}
+
+// loopWindow gathers like loop and records the time window of the scenario
+// for the range queries; of a resumed run it skips the finished scenarios
+// and gathers what is left of the interrupted one.
+func (scheduler *Scheduler) loopWindow(n int) {
+	clock := clockOf(scheduler.clock)
+	state, resumed := scheduler.runState.scenario(n, scheduler.scenario, clock.now())
+	window := RangeWindow{scenario: scheduler.scenario, start: state.Started, end: state.Ended}
+	if !state.Ended.IsZero() {
+		log.Println("scenario", scheduler.scenario, "is done in the resumed run")
+		scheduler.windows = append(scheduler.windows, window)
+		return
+	}
+	gather := true
+	if resumed {
+		scheduler.testDuration, scheduler.iterations, gather = state.remaining(clock.now(), scheduler.testDuration, scheduler.iterations)
+		log.Println("resume scenario", scheduler.scenario, "after", state.Ticks, "ticks")
+	}
+	if gather {
+		scheduler.loop()
+	}
+	window.end = clock.now()
+	scheduler.runState.ended(window.end)
+	scheduler.windows = append(scheduler.windows, window)
+}
+
+func (scheduler *Scheduler) context() context.Context {
+	if scheduler.ctx == nil {
+		return context.Background()
+	}
+	return scheduler.ctx
+}
+
+func (scheduler *Scheduler) sleep(ctx context.Context, duration time.Duration) bool {
+	return clockOf(scheduler.clock).sleep(ctx, duration)
+}
+
+func (scheduler *Scheduler) loop() {
 	log.Println("=[ start gathers ]=====================")
-	ctx := context.Background()
-	ctx, cancelFunc := context.WithTimeout(ctx, time.Duration(scheduler.testDuration)*time.Second)
-	defer cancelFunc()
+	ctx, span := startSpan(scheduler.context(), "gather", attribute.String("scenario", scheduler.scenario))
+	defer span.End()
+	clock := clockOf(scheduler.clock)
+	deadline := time.Time{}
+	if scheduler.iterations <= 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = clock.withTimeout(ctx, scheduler.testDuration)
+		defer cancelFunc()
+		deadline = clock.now().Add(scheduler.testDuration)
+	}
 
+	started := clock.now()
+	next, woke := started, started
+	ticks := 0
 	for {
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
 			log.Println("=[ timeout ]============================")
 			return
-		default:
-			if scheduler.status == 1 {
-				return
-			}
-			scheduler.tick()
-			time.Sleep(time.Duration(scheduler.timeout) * time.Second)
 		}
+		if scheduler.status == 1 {
+			return
+		}
+		drift := clock.now().Sub(next)
+		if scheduler.sleep(ctx, scheduler.jitterDelay()) {
+			scheduler.self.tick(drift)
+			scheduler.tick(ctx)
+			scheduler.runState.tick()
+			ticks++
+		}
+		if scheduler.iterationsDone(ticks) {
+			return
+		}
+		if scheduler.timeout <= 0 {
+			next = clock.now()
+			woke = next
+			continue
+		}
+		next = nextTick(started, woke, scheduler.timeout)
+		if !deadline.IsZero() && !next.Before(deadline) {
+			// no tick at the deadline, its queries would be cancelled
+			scheduler.sleep(ctx, deadline.Sub(clock.now()))
+			log.Println("=[ timeout ]============================")
+			return
+		}
+		scheduler.sleep(ctx, next.Sub(clock.now()))
+		woke = clock.now()
+	}
+}
+
+func nextTick(started time.Time, now time.Time, interval time.Duration) time.Time {
+	return started.Add((now.Sub(started)/interval + 1) * interval)
+}
+
+func (scheduler *Scheduler) jitterDelay() time.Duration {
+	if scheduler.jitter <= 0 {
+		return 0
 	}
+	return time.Duration(rand.Int64N(int64(scheduler.jitter)))
 }
 
 func main() {
-	App{}.run()
+	os.Exit(runMain(os.Args[1:], os.Stdout))
 }
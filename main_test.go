@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestPrometheusMetric(t *testing.T) {
@@ -20,7 +28,134 @@ func TestPrometheusMetric(t *testing.T) {
 func TestPrometheusMetricGather(t *testing.T) {
 	requires := require.New(t)
 	metric := PrometheusMetric{Name: "a", MaxValue: 1}
-	requires.Equal(-1, metric.gather())
+	requires.Equal(-1, metric.gather(context.Background()))
+}
+
+func TestPrometheusMetricGatherCancel(t *testing.T) {
+	requires := require.New(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	metric := PrometheusMetric{Host: server.URL, Name: "a", Query: "up"}
+	requires.Equal(-1, metric.gather(ctx))
+	requires.Less(time.Since(started), 5*time.Second)
+}
+
+func TestPrometheusMetricEvalTime(t *testing.T) {
+	requires := require.New(t)
+	now := time.Date(2024, 1, 2, 3, 4, 37, 500, time.UTC)
+	requires.Equal(now, PrometheusMetric{}.evalTime(now))
+	requires.Equal(now.Add(-30*time.Second), PrometheusMetric{Offset: 30 * time.Second}.evalTime(now))
+	requires.Equal(time.Date(2024, 1, 2, 3, 4, 30, 0, time.UTC), PrometheusMetric{Align: 15 * time.Second}.evalTime(now))
+	requires.Equal(time.Date(2024, 1, 2, 3, 4, 15, 0, time.UTC), PrometheusMetric{Offset: 10 * time.Second, Align: 15 * time.Second}.evalTime(now))
+
+	var evaluated string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evaluated = r.FormValue("time")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"7"]}]}}`))
+	}))
+	defer server.Close()
+	gathers := newMetricGathers(Sources{host: server.URL}, []Metric{{Name: "a", Query: "up", Offset: Duration(time.Hour), Align: Duration(time.Minute)}})
+	requires.Equal(7, gathers[0].gather(context.Background()))
+	seconds, err := strconv.ParseFloat(evaluated, 64)
+	requires.NoError(err)
+	at := time.Unix(int64(seconds), 0)
+	requires.Equal(0, at.Second())
+	requires.WithinDuration(time.Now().Add(-time.Hour), at, 2*time.Minute)
+}
+
+func TestPrometheusMetricGatherMissing(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+	variants := []struct {
+		onMissing string
+		value     int
+		violated  bool
+	}{
+		{onMissing: "", value: -1},
+		{onMissing: "ignore", value: -1},
+		{onMissing: "fail", value: missingValue, violated: true},
+		{onMissing: "treatAsZero", value: 0},
+		{onMissing: "treatAsMax", value: 5},
+	}
+	for _, variant := range variants {
+		metric := PrometheusMetric{Host: server.URL, Name: "a", Query: "up", MaxValue: 5, OnMissing: variant.onMissing}
+		values, ok := Gatherer{metrics: []MetricGather{metric}}.gatherAndCheck(context.Background(), time.Now())
+		requires.Equal(variant.value, values.values[0].value, variant.onMissing)
+		requires.Equal(variant.violated, !ok, variant.onMissing)
+	}
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", OnMissing: "zero"}}}))
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", OnMissing: "fail"}}}))
+	requires.False(hasValue(missingValue))
+}
+
+func TestPrometheusMetricGatherResultTypes(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		data   string
+		reduce string
+		value  int
+	}{
+		{data: `{"resultType":"scalar","result":[1,"4"]}`, value: 4},
+		{data: `{"resultType":"vector","result":[{"metric":{},"value":[1,"7"]}]}`, value: 7},
+		{data: `{"resultType":"vector","result":[{"metric":{"a":"1"},"value":[1,"7"]},{"metric":{"a":"2"},"value":[1,"8"]}]}`, value: -1},
+		{data: `{"resultType":"matrix","result":[{"metric":{},"values":[[1,"3"],[2,"9"],[3,"5"]]}]}`, value: 5},
+		{data: `{"resultType":"matrix","result":[{"metric":{},"values":[[1,"3"],[2,"9"],[3,"5"]]}]}`, reduce: "max", value: 9},
+		{data: `{"resultType":"matrix","result":[]}`, value: 0},
+		{data: `{"resultType":"matrix","result":[{"metric":{"a":"1"},"values":[[1,"3"]]},{"metric":{"a":"2"},"values":[[1,"3"]]}]}`, value: -1},
+		{data: `{"resultType":"string","result":[1,"up"]}`, value: -1},
+	}
+	for _, variant := range variants {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":` + variant.data + `}`))
+		}))
+		metric := PrometheusMetric{Host: server.URL, Name: "a", Query: "up", OnMissing: "treatAsZero", Reduce: variant.reduce}
+		requires.Equal(variant.value, metric.gather(context.Background()), variant.data)
+		server.Close()
+	}
+
+	_, _, err := PrometheusMetric{Name: "a"}.value(nil)
+	var gatherError GatherError
+	requires.ErrorAs(err, &gatherError)
+	requires.Equal("metric a: empty result", err.Error())
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Aggregate: "avg"}}}))
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Aggregate: "max"}}}))
+}
+
+func TestPrometheusMetricSeriesLabels(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+			`{"metric":{"__name__":"http_requests","instance":"api-1:8080","handler":"/orders"},"value":[1,"9"]}]}}`))
+	}))
+	defer server.Close()
+	gathers := newMetricGathers(Sources{host: server.URL}, []Metric{{Name: "a", Query: "up", MaxValue: 5, Labels: map[string]string{"team": "core"}}})
+	values, ok := Gatherer{metrics: gathers}.gatherAndCheck(context.Background(), time.Now())
+	requires.False(ok)
+	series := map[string]string{"instance": "api-1:8080", "handler": "/orders"}
+	requires.Equal(series, values.values[0].series)
+	requires.Equal(map[string]string{"team": "core"}, values.values[0].labels)
+	requires.Equal(series, values.violations[0].series)
+	requires.Equal(series, valuesJSON(values.violations)[0].Series)
+
+	_, series, err := PrometheusMetric{}.value(model.Vector{{Metric: model.Metric{"__name__": "up"}, Value: 1}})
+	requires.NoError(err)
+	requires.Nil(series)
+	values, _ = Gatherer{metrics: []MetricGather{FakeMetricGather{}}}.gatherAndCheck(context.Background(), time.Now())
+	requires.Nil(values.values[0].series)
 }
 
 func TestSendResult(t *testing.T) {
@@ -30,10 +165,44 @@ func TestSendResult(t *testing.T) {
 	requires.Len(reporter.values, 1)
 }
 
+func TestReporterSubscribe(t *testing.T) {
+	requires := require.New(t)
+	reporter := Reporter{}
+	received := []MetricValues{}
+	reporter.subscribe(func(values MetricValues) {
+		received = append(received, values)
+	})
+	stream := reporter.stream(context.Background(), 2)
+	reporter.sendResult(MetricValues{scenario: "a"})
+	reporter.sendResult(MetricValues{scenario: "b"})
+	reporter.sendResult(MetricValues{scenario: "c"})
+	reporter.close()
+	requires.Len(received, 3)
+	streamed := []string{}
+	for values := range stream {
+		streamed = append(streamed, values.scenario)
+	}
+	requires.Equal([]string{"a", "b"}, streamed)
+	_, ok := <-reporter.stream(context.Background(), 1)
+	requires.False(ok)
+}
+
+func TestReporterStreamCancel(t *testing.T) {
+	requires := require.New(t)
+	reporter := Reporter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := reporter.stream(ctx, 0)
+	cancel()
+	_, ok := <-stream
+	requires.False(ok)
+	reporter.sendResult(MetricValues{})
+	reporter.close()
+}
+
 type FakeGatherer struct {
 }
 
-func (gatherer FakeGatherer) gatherAndCheck(startTime time.Time) (MetricValues, bool) {
+func (gatherer FakeGatherer) gatherAndCheck(ctx context.Context, startTime time.Time) (MetricValues, bool) {
 	return MetricValues{}, false
 }
 
@@ -46,16 +215,33 @@ func TestEventerFire(t *testing.T) {
 		stoper: func() {
 			counter++
 		}}
-	eventer.Fire()
+	eventer.Fire(context.Background())
 	requires.Equal(1, counter)
 }
 
 type FakeMetricGather struct {
 }
 
-func (m FakeMetricGather) name() string  { return "a" }
-func (m FakeMetricGather) gather() int   { return 2 }
-func (m FakeMetricGather) maxValue() int { return 1 }
+func (m FakeMetricGather) name() string                   { return "a" }
+func (m FakeMetricGather) gather(ctx context.Context) int { return 2 }
+func (m FakeMetricGather) maxValue() int                  { return 1 }
+
+func TestGathererLabels(t *testing.T) {
+	requires := require.New(t)
+	labels := map[string]string{"team": "core", "severity": "high"}
+	gathers := newMetricGathers(Sources{}, []Metric{{Name: "a", Labels: labels}, {Name: "b"}})
+	gatherer := Gatherer{metrics: []MetricGather{
+		LabeledMetric{MetricGather: FakeMetricGather{}, Labels: labels},
+	}}
+	values, _ := gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.IsType(LabeledMetric{}, gathers[0])
+	requires.IsType(PrometheusMetric{}, gathers[1])
+	requires.Equal(labels, values.values[0].labels)
+	requires.Equal(labels, values.violations[0].labels)
+	requires.Equal("a{severity=high,team=core}=2", values.values[0].String())
+	requires.Equal("a=2", MetricValue{name: "a", value: 2}.String())
+	requires.Equal(labels, valuesJSON(values.values)[0].Labels)
+}
 
 func TestGathererGatherAndCheck(t *testing.T) {
 	requires := require.New(t)
@@ -63,9 +249,10 @@ func TestGathererGatherAndCheck(t *testing.T) {
 		FakeMetricGather{},
 	}}
 	startTime := time.Now()
-	values, check := gatherer.gatherAndCheck(startTime)
+	values, check := gatherer.gatherAndCheck(context.Background(), startTime)
 	requires.Equal(values.timestamp, startTime)
 	requires.Len(values.values, 1)
+	requires.Equal([]MetricValue{{name: "a", value: 2}}, values.violations)
 	requires.False(check)
 }
 
@@ -81,7 +268,7 @@ type FakeEventer struct {
 	stoper func()
 }
 
-func (eventer *FakeEventer) Fire() {
+func (eventer *FakeEventer) Fire(ctx context.Context) {
 	eventer.fired = eventer.fired + 1
 	if eventer.stoper != nil {
 		eventer.stoper()
@@ -92,36 +279,39 @@ func TestSchedulerTick(t *testing.T) {
 	requires := require.New(t)
 	fakeEventer := FakeEventer{}
 	scheduler := Scheduler{eventer: &fakeEventer}
-	scheduler.tick()
+	scheduler.tick(context.Background())
 	requires.Equal(fakeEventer.fired, 1)
 }
 
 type FakeEnvManager struct {
-	started bool
-	stopped bool
+	started  bool
+	stopped  bool
+	startErr error
 }
 
-func (env *FakeEnvManager) start() {
+func (env *FakeEnvManager) start() error {
 	env.started = true
+	return env.startErr
 }
 
-func (env *FakeEnvManager) stop() {
+func (env *FakeEnvManager) stop() error {
 	env.stopped = true
+	return nil
 }
 
 func TestSchedulerInitAndDown(t *testing.T) {
 	env := FakeEnvManager{}
 	scheduler := Scheduler{envManager: &env}
-	scheduler.init()
-	scheduler.down()
 	requires := require.New(t)
+	requires.NoError(scheduler.init())
+	requires.NoError(scheduler.down())
 	requires.True(env.started)
 	requires.True(env.stopped)
 }
 
 func TestSchedulerRun(t *testing.T) {
 	variants := []struct {
-		testDuration int
+		testDuration time.Duration
 		result       int
 	}{
 		{
@@ -129,7 +319,7 @@ func TestSchedulerRun(t *testing.T) {
 			result:       0,
 		},
 		{
-			testDuration: 1,
+			testDuration: time.Second,
 			result:       1,
 		},
 	}
@@ -178,11 +368,11 @@ func TestAppTune(t *testing.T) {
 	requires := require.New(t)
 	for _, variant := range variants {
 		a := App{}
-		scheduler := a.tune(Reporter{}, variant.config)
+		scheduler := a.tune(&Reporter{}, variant.config, Sources{})
 		requires.Equal(0, scheduler.status)
-		requires.Equal(0, scheduler.startDelay)   //   config.StartDelay,
-		requires.Equal(0, scheduler.testDuration) // config.TestDuration,
-		requires.Equal(0, scheduler.timeout)      //      config.Timeout,
+		requires.Equal(time.Duration(0), scheduler.startDelay)   //   config.StartDelay,
+		requires.Equal(time.Duration(0), scheduler.testDuration) // config.TestDuration,
+		requires.Equal(time.Duration(0), scheduler.timeout)      //      config.Timeout,
 		// o, err := yaml.Marshal(scheduler)
 		// requires.NoError(err)
 		// fmt.Println("result:", string(o))
@@ -190,3 +380,511 @@ func TestAppTune(t *testing.T) {
 		// requires.Equal(DockerCompose(scheduler.envManager).WorkDir, "/tmp/project")
 	}
 }
+
+func TestSchedulerRunScenarios(t *testing.T) {
+	requires := require.New(t)
+	first := FakeEventer{}
+	second := FakeEventer{}
+	load := FakeEnvManager{}
+	scheduler := Scheduler{
+		scenarios: []ScenarioRun{
+			{name: "baseline", eventer: &first, testDuration: time.Second},
+			{name: "ramp", eventer: &second, load: &load, testDuration: time.Second},
+		},
+		timeout: time.Second,
+	}
+	scheduler.run()
+	requires.Positive(first.fired)
+	requires.Positive(second.fired)
+	requires.True(load.started)
+	requires.True(load.stopped)
+}
+
+func TestSchedulerRunScenariosStop(t *testing.T) {
+	requires := require.New(t)
+	first := FakeEventer{}
+	second := FakeEventer{}
+	scheduler := Scheduler{
+		scenarios: []ScenarioRun{
+			{name: "baseline", eventer: &first, testDuration: time.Second},
+			{name: "ramp", eventer: &second, testDuration: time.Second},
+		},
+	}
+	first.stoper = func() { scheduler.sendDown() }
+	scheduler.run()
+	requires.Equal(1, first.fired)
+	requires.Equal(0, second.fired)
+}
+
+func TestMergeMetrics(t *testing.T) {
+	requires := require.New(t)
+	base := []Metric{{Name: "a", MaxValue: 1}, {Name: "b", MaxValue: 2}}
+	merged := mergeMetrics(base, []Metric{{Name: "b", MaxValue: 5}, {Name: "c", MaxValue: 3}})
+	requires.Equal([]Metric{{Name: "a", MaxValue: 1}, {Name: "b", MaxValue: 5}, {Name: "c", MaxValue: 3}}, merged)
+	requires.Equal(2, base[1].MaxValue)
+}
+
+func TestAppTuneScenarios(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		Metrics: []Metric{{Name: "a"}},
+		Scenarios: []Scenario{
+			{Name: "baseline", Duration: Duration(10 * time.Second)},
+			{Name: "soak", Duration: Duration(20 * time.Second), Load: []string{"sleep", "1"}, Metrics: []Metric{{Name: "b"}}},
+		},
+	}
+	scheduler := App{}.tune(&Reporter{}, config, Sources{})
+	requires.Len(scheduler.scenarios, 2)
+	requires.Equal("baseline", scheduler.scenarios[0].name)
+	requires.Nil(scheduler.scenarios[0].load)
+	requires.Equal(20*time.Second, scheduler.scenarios[1].testDuration)
+	requires.NotNil(scheduler.scenarios[1].load)
+	eventer := scheduler.scenarios[1].eventer.(*Eventer)
+	requires.Equal("soak", eventer.scenario)
+	requires.Len(eventer.gatherer.(Gatherer).metrics, 2)
+}
+
+func TestEventerFireScenario(t *testing.T) {
+	requires := require.New(t)
+	reporter := Reporter{}
+	eventer := Eventer{
+		scenario: "ramp",
+		gatherer: FakeGatherer{},
+		reporter: &reporter,
+		stoper:   func() {}}
+	eventer.Fire(context.Background())
+	requires.Equal("ramp", reporter.values[0].scenario)
+}
+
+func TestEventerFireCancelled(t *testing.T) {
+	requires := require.New(t)
+	reporter := Reporter{}
+	counter := 0
+	eventer := Eventer{
+		gatherer: FakeGatherer{},
+		reporter: &reporter,
+		stoper:   func() { counter++ }}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	eventer.Fire(ctx)
+	requires.Empty(reporter.values)
+	requires.Equal(0, counter)
+}
+
+type FakeAborter struct {
+	violations []MetricValue
+}
+
+func (aborter *FakeAborter) abort(violations []MetricValue) {
+	aborter.violations = violations
+}
+
+func TestEventerFireAbort(t *testing.T) {
+	requires := require.New(t)
+	aborter := FakeAborter{}
+	eventer := Eventer{
+		gatherer: Gatherer{metrics: []MetricGather{FakeMetricGather{}}},
+		reporter: &Reporter{},
+		aborter:  &aborter,
+		stoper:   func() {}}
+	eventer.Fire(context.Background())
+	requires.Equal([]MetricValue{{name: "a", value: 2}}, aborter.violations)
+}
+
+func TestAbortHookWebhook(t *testing.T) {
+	requires := require.New(t)
+	var body map[string][]ValueJSON
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requires.NoError(json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+	AbortHook{webhook: server.URL}.abort([]MetricValue{{name: "errors", value: 3}})
+	requires.Equal([]ValueJSON{{Name: "errors", Value: 3}}, body["violations"])
+}
+
+func TestAbortHookCommandTimeout(t *testing.T) {
+	requires := require.New(t)
+	started := time.Now()
+	hook := AbortHook{workDir: t.TempDir(), command: []string{"sleep", "10"}, timeout: 100 * time.Millisecond}
+	hook.abort([]MetricValue{{name: "errors", value: 3}})
+	requires.Less(time.Since(started), 5*time.Second)
+}
+
+func TestAbortHookCommand(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	hook := AbortHook{workDir: dir, command: []string{"sh", "-c", "echo $0 $1 >> abort.txt"}}
+	hook.abort([]MetricValue{{name: "errors", value: 3}, {name: "infos", value: 5}})
+	b, err := os.ReadFile(filepath.Join(dir, "abort.txt"))
+	requires.NoError(err)
+	requires.Equal("errors 3\ninfos 5\n", string(b))
+}
+
+func TestSchedulerDownKeepOnFailure(t *testing.T) {
+	variants := []struct {
+		keepOnFailure bool
+		status        int
+		stopped       bool
+	}{
+		{keepOnFailure: false, status: 1, stopped: true},
+		{keepOnFailure: true, status: 0, stopped: true},
+		{keepOnFailure: true, status: 1, stopped: false},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		env := FakeEnvManager{}
+		scheduler := Scheduler{envManager: &env, keepOnFailure: variant.keepOnFailure, status: variant.status}
+		requires.NoError(scheduler.down())
+		requires.Equal(variant.stopped, env.stopped, n)
+	}
+}
+
+func TestAppParseArgs(t *testing.T) {
+	requires := require.New(t)
+	app := App{}
+	_, err := app.parseArgs([]string{})
+	requires.NoError(err)
+	requires.Equal("./config.yaml", app.configFile)
+	requires.False(app.keepOnFailure)
+	_, err = app.parseArgs([]string{"--config", "perf.yaml", "--keep-on-failure"})
+	requires.NoError(err)
+	requires.Equal("perf.yaml", app.configFile)
+	requires.True(app.keepOnFailure)
+}
+
+func TestRenderQuery(t *testing.T) {
+	variants := []struct {
+		query  string
+		result string
+		err    bool
+	}{
+		{query: "up", result: "up"},
+		{query: `rate(http_requests_total{service="{{ .service }}"}[1m])`, result: `rate(http_requests_total{service="checkout"}[1m])`},
+		{query: "{{ .unknown }}", err: true},
+		{query: "{{ .service", err: true},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		result, err := renderQuery(variant.query, map[string]string{"service": "checkout"})
+		if variant.err {
+			requires.Error(err, n)
+			continue
+		}
+		requires.NoError(err, n)
+		requires.Equal(variant.result, result, n)
+	}
+}
+
+func TestAppApplyVars(t *testing.T) {
+	requires := require.New(t)
+	app := App{}
+	_, err := app.parseArgs([]string{"--var", "service=checkout"})
+	requires.NoError(err)
+	config, err := app.applyVars(Config{
+		Vars:      map[string]string{"service": "cart", "env": "perf"},
+		Metrics:   []Metric{{Name: "a", Query: "{{ .service }}"}},
+		Scenarios: []Scenario{{Metrics: []Metric{{Name: "b", Query: "{{ .env }}"}}}},
+	})
+	requires.NoError(err)
+	requires.Equal("checkout", config.Metrics[0].Query)
+	requires.Equal("perf", config.Scenarios[0].Metrics[0].Query)
+	_, err = app.applyVars(Config{Metrics: []Metric{{Name: "a", Query: "{{ .unknown }}"}}})
+	requires.Error(err)
+	_, err = app.parseArgs([]string{"--var", "service"})
+	requires.Error(err)
+}
+
+func TestResolveScenarios(t *testing.T) {
+	requires := require.New(t)
+	config, err := resolveScenarios(Config{
+		TestDuration: Duration(30 * time.Second),
+		Scenarios:    []Scenario{{Name: "baseline"}, {Name: "soak", Duration: Duration(time.Minute)}},
+	})
+	requires.NoError(err)
+	requires.Equal(Duration(30*time.Second), config.Scenarios[0].Duration)
+	requires.Equal(Duration(time.Minute), config.Scenarios[1].Duration)
+	_, err = resolveScenarios(Config{Scenarios: []Scenario{{Name: "baseline"}}})
+	requires.Error(err)
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	variants := []struct {
+		yaml     string
+		duration time.Duration
+		err      bool
+	}{
+		{yaml: "timeout: 5", duration: 5 * time.Second},
+		{yaml: "timeout: 500ms", duration: 500 * time.Millisecond},
+		{yaml: "timeout: 15m", duration: 15 * time.Minute},
+		{yaml: "timeout: \"30s\"", duration: 30 * time.Second},
+		{yaml: "timeout: soon", err: true},
+		{yaml: "timeout: [1]", err: true},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		var config Config
+		err := yaml.Unmarshal([]byte(variant.yaml), &config)
+		if variant.err {
+			requires.Error(err, variant.yaml)
+			continue
+		}
+		requires.NoError(err, variant.yaml)
+		requires.Equal(variant.duration, time.Duration(config.Timeout), variant.yaml)
+	}
+	b, err := yaml.Marshal(Config{Timeout: Duration(90 * time.Second)})
+	requires.NoError(err)
+	requires.Contains(string(b), "timeout: 1m30s")
+}
+
+func TestSchedulerFailed(t *testing.T) {
+	requires := require.New(t)
+	scheduler := Scheduler{}
+	requires.False(scheduler.failed())
+	max, _ := parseAssertion("max < 1")
+	scheduler.assertions = []RunAssertion{{metric: "a", assertion: max}}
+	scheduler.checkAssertions(&Reporter{values: []MetricValues{{values: []MetricValue{{name: "a", value: 2}}}}})
+	requires.True(scheduler.failed())
+	requires.Len(scheduler.results, 1)
+}
+
+func TestDetectCompose(t *testing.T) {
+	variants := []struct {
+		available string
+		command   []string
+	}{
+		{available: "docker compose", command: []string{"docker", "compose"}},
+		{available: "docker-compose", command: []string{"docker-compose"}},
+		{available: "podman compose", command: []string{"podman", "compose"}},
+		{available: "podman-compose", command: []string{"podman-compose"}},
+		{available: "", command: []string{"docker", "compose"}},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		probe := func(args []string) bool { return strings.Join(args, " ") == variant.available }
+		requires.Equal(variant.command, detectCompose(probe), variant.available)
+	}
+}
+
+func TestDockerComposeCommand(t *testing.T) {
+	requires := require.New(t)
+	envManager := DockerCompose{composeCommand: []string{"podman-compose"}}
+	requires.Equal([]string{"podman-compose", "up", "-d"}, envManager.compose("up", "-d"))
+	requires.Equal([]string{"podman-compose"}, envManager.composeCommand)
+	requires.ErrorContains(checkRemoteConfig(Config{ComposeCommand: []string{"sh", "-c", "id"}}), "composeCommand is not allowed in posted configs")
+}
+
+func TestDockerComposeStartPullBuild(t *testing.T) {
+	variants := []struct {
+		pull  bool
+		build bool
+		fail  bool
+		calls string
+	}{
+		{calls: "up -d --remove-orphans\n"},
+		{pull: true, build: true, calls: "pull\nup -d --remove-orphans --build\n"},
+		{pull: true, fail: true, calls: "pull\n"},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		dir := t.TempDir()
+		script := "true"
+		if variant.fail {
+			script = "false"
+		}
+		envManager := DockerCompose{
+			workDir:        dir,
+			composeCommand: []string{"sh", "-c", `echo "$@" >> calls; ` + script, "sh"},
+			pull:           variant.pull,
+			build:          variant.build,
+		}
+		requires.Equal(variant.fail, envManager.start() != nil, variant.calls)
+		calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+		requires.Equal(variant.calls, string(calls))
+	}
+	scheduler := App{}.tune(&Reporter{}, Config{Env: StandConfig{Pull: "always", Build: true}}, Sources{})
+	requires.True(scheduler.envManager.(DockerCompose).pull)
+	requires.True(scheduler.envManager.(DockerCompose).build)
+}
+
+func TestDockerComposeStopEscalation(t *testing.T) {
+	variants := []struct {
+		script string
+		calls  string
+		err    bool
+	}{
+		{script: `true`, calls: "down\n"},
+		{script: `[ "$1" = kill ]`, calls: "down\ndown --timeout 0\nkill\n"},
+		{script: `if [ "$*" = down ]; then sleep 10; fi`, calls: "down\ndown --timeout 0\n"},
+		{script: `false`, calls: "down\ndown --timeout 0\nkill\n", err: true},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		dir := t.TempDir()
+		envManager := DockerCompose{
+			workDir:         dir,
+			composeCommand:  []string{"sh", "-c", `echo "$@" >> calls; ` + variant.script, "sh"},
+			teardownTimeout: 300 * time.Millisecond,
+		}
+		started := time.Now()
+		err := envManager.stop()
+		requires.Equal(variant.err, err != nil, variant.script)
+		requires.Less(time.Since(started), 5*time.Second, variant.script)
+		calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+		requires.Equal(variant.calls, string(calls), variant.script)
+	}
+}
+
+type HangingEnvManager struct {
+	release chan struct{}
+}
+
+func (env HangingEnvManager) start() error {
+	return nil
+}
+
+func (env HangingEnvManager) stop() error {
+	<-env.release
+	return nil
+}
+
+func TestSchedulerDownDeadline(t *testing.T) {
+	requires := require.New(t)
+	env := HangingEnvManager{release: make(chan struct{})}
+	defer close(env.release)
+	scheduler := Scheduler{envManager: env, teardownDeadline: 100 * time.Millisecond}
+	started := time.Now()
+	requires.Error(scheduler.down())
+	requires.Less(time.Since(started), 5*time.Second)
+
+	fake := FakeEnvManager{}
+	scheduler = Scheduler{envManager: &fake, teardownDeadline: time.Second}
+	requires.NoError(scheduler.down())
+	requires.True(fake.stopped)
+}
+
+func TestAppTuneOnViolation(t *testing.T) {
+	variants := []struct {
+		global string
+		metric string
+		ticks  int
+		status int
+	}{
+		{global: "", metric: "", ticks: 1, status: 1},
+		{global: "continue", metric: "", ticks: 3, status: 0},
+		{global: "continue", metric: "stop", ticks: 1, status: 1},
+		{global: "stop", metric: "continue", ticks: 3, status: 0},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		reporter := &Reporter{}
+		config := Config{
+			TestDuration: Duration(time.Second),
+			Timeout:      Duration(300 * time.Millisecond),
+			OnViolation:  variant.global,
+			Metrics:      []Metric{{Name: "a", Query: "up", MaxValue: -2, OnViolation: variant.metric}},
+		}
+		requires.NoError(validateMetricTypes(config), n)
+		scheduler := App{envManager: &FakeEnvManager{}}.tune(reporter, config, Sources{host: "http://127.0.0.1:1"})
+		scheduler.run()
+		requires.GreaterOrEqual(len(reporter.snapshot()), variant.ticks, n)
+		requires.Equal(variant.status, scheduler.status, n)
+		requires.True(scheduler.violated, n)
+		requires.True(scheduler.failed(), n)
+	}
+	requires.Error(validateMetricTypes(Config{OnViolation: "pause"}))
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", OnViolation: "pause"}}}))
+}
+
+type SlowEventer struct {
+	fired []time.Time
+}
+
+func (eventer *SlowEventer) Fire(ctx context.Context) {
+	eventer.fired = append(eventer.fired, time.Now())
+	time.Sleep(600 * time.Millisecond)
+}
+
+func TestSchedulerLoopTicker(t *testing.T) {
+	requires := require.New(t)
+	eventer := SlowEventer{}
+	scheduler := Scheduler{eventer: &eventer, testDuration: 3 * time.Second, timeout: time.Second}
+	scheduler.loop()
+	requires.GreaterOrEqual(len(eventer.fired), 3)
+	for n := 1; n < len(eventer.fired); n++ {
+		requires.InDelta(time.Second, eventer.fired[n].Sub(eventer.fired[n-1]), float64(200*time.Millisecond))
+	}
+}
+
+type DeadlineEventer struct {
+	fired     int
+	cancelled int
+}
+
+func (eventer *DeadlineEventer) Fire(ctx context.Context) {
+	eventer.fired++
+	time.Sleep(10 * time.Millisecond)
+	if ctx.Err() != nil {
+		eventer.cancelled++
+	}
+}
+
+func TestSchedulerLoopDeadline(t *testing.T) {
+	requires := require.New(t)
+	eventer := DeadlineEventer{}
+	scheduler := Scheduler{eventer: &eventer, testDuration: 300 * time.Millisecond, timeout: 100 * time.Millisecond}
+	started := time.Now()
+	scheduler.loop()
+	requires.Equal(DeadlineEventer{fired: 3}, eventer)
+	requires.GreaterOrEqual(time.Since(started), 300*time.Millisecond)
+
+	eventer = DeadlineEventer{}
+	scheduler = Scheduler{clock: NewSimulatedClock(started), eventer: &eventer, testDuration: time.Hour, timeout: 10 * time.Minute}
+	scheduler.loop()
+	requires.Equal(DeadlineEventer{fired: 6}, eventer)
+	requires.Equal(started.Add(time.Hour), scheduler.clock.now())
+}
+
+func TestSchedulerJitterDelay(t *testing.T) {
+	requires := require.New(t)
+	requires.Equal(time.Duration(0), (&Scheduler{}).jitterDelay())
+	scheduler := Scheduler{jitter: 2 * time.Second}
+	for range 10 {
+		delay := scheduler.jitterDelay()
+		requires.GreaterOrEqual(delay, time.Duration(0))
+		requires.Less(delay, 2*time.Second)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	variants := []struct {
+		content string
+		result  string
+	}{
+		{content: "host: ${HOST}", result: "host: http://prometheus:9090"},
+		{content: "host: ${HOST:-http://localhost:9090}", result: "host: http://prometheus:9090"},
+		{content: "workDir: ${WORK_DIR:-/tmp/stand}", result: "workDir: /tmp/stand"},
+		{content: "token: ${EMPTY:-none}", result: "token: none"},
+		{content: "token: ${MISSING}", result: "token: "},
+		{content: "query: $$x and $x", result: "query: $x and $x"},
+	}
+	requires := require.New(t)
+	env := map[string]string{"HOST": "http://prometheus:9090", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	for _, variant := range variants {
+		requires.Equal(variant.result, string(expandEnv([]byte(variant.content), lookup)), variant.content)
+	}
+}
+
+func TestAppLoadConfigEnv(t *testing.T) {
+	requires := require.New(t)
+	t.Setenv("MG_WORK_DIR", "/tmp/from-env")
+	fileName := filepath.Join(t.TempDir(), "config.yaml")
+	requires.NoError(os.WriteFile(fileName, []byte("workDir: ${MG_WORK_DIR}\nhost: ${MG_HOST:-http://localhost:9090}"), fs.ModePerm))
+	config, err := App{}.loadConfig(fileName)
+	requires.NoError(err)
+	requires.Equal("/tmp/from-env", config.WorkDir)
+	requires.Equal("http://localhost:9090", config.Host)
+}
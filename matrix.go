@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+type MatrixCell struct {
+	Vars   map[string]string `json:"vars"`
+	Report RunReport         `json:"report"`
+}
+
+type MatrixReport struct {
+	RunID      string       `json:"runId"`
+	Passed     bool         `json:"passed"`
+	Dimensions []string     `json:"dimensions"`
+	Cells      []MatrixCell `json:"cells"`
+}
+
+func expandMatrix(matrix map[string][]string) []map[string]string {
+	cells := []map[string]string{{}}
+	for _, name := range slices.Sorted(maps.Keys(matrix)) {
+		expanded := make([]map[string]string, 0, len(cells)*len(matrix[name]))
+		for _, cell := range cells {
+			for _, value := range matrix[name] {
+				vars := maps.Clone(cell)
+				vars[name] = value
+				expanded = append(expanded, vars)
+			}
+		}
+		cells = expanded
+	}
+	return cells
+}
+
+func validateMatrix(matrix map[string][]string) error {
+	for name, values := range matrix {
+		if len(values) == 0 {
+			return fmt.Errorf("matrix %s: no values", name)
+		}
+	}
+	return nil
+}
+
+func matrixCellName(vars map[string]string) string {
+	parts := make([]string, 0, len(vars))
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		parts = append(parts, name+"="+vars[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+func (app App) matrixCell(vars map[string]string) App {
+	cell := app
+	cell.vars = VarsFlag{}
+	maps.Copy(cell.vars, app.vars)
+	maps.Copy(cell.vars, vars)
+	return cell
+}
+
+func (app App) executeMatrix(ctx context.Context, config Config) (MatrixReport, error) {
+	if err := validateMatrix(config.Matrix); err != nil {
+		return MatrixReport{}, err
+	}
+	report := MatrixReport{
+		RunID:      newRunID(),
+		Passed:     true,
+		Dimensions: slices.Sorted(maps.Keys(config.Matrix)),
+		Cells:      make([]MatrixCell, 0),
+	}
+	output, err := newRunOutput(app.outputBaseDir(config), "matrix-"+report.RunID, time.Now())
+	if err != nil {
+		return report, err
+	}
+	defer output.close()
+	cells := expandMatrix(config.Matrix)
+	for n, vars := range cells {
+		log.Printf("=[ matrix %d/%d: %s ]====================\n", n+1, len(cells), matrixCellName(vars))
+		reporter := Reporter{}
+		scheduler, err := app.matrixCell(vars).execute(ctx, config, &reporter)
+		if err != nil {
+			return report, fmt.Errorf("matrix %s: %w", matrixCellName(vars), err)
+		}
+		cell := MatrixCell{Vars: vars, Report: reporter.runReport(scheduler)}
+		report.Cells = append(report.Cells, cell)
+		report.Passed = report.Passed && cell.Report.Passed
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	grid := renderMatrixGrid(report)
+	log.Println("=[ matrix ]============================")
+	log.Print("\n" + string(grid))
+	output.writeFile("matrix.md", grid)
+	if b, err := json.MarshalIndent(report, "", "  "); err != nil {
+		log.Println("output error:", err)
+	} else {
+		output.writeFile("matrix.json", b)
+	}
+	return report, nil
+}
+
+func matrixColumns(report MatrixReport) []string {
+	columns := make([]string, 0)
+	for _, cell := range report.Cells {
+		for _, summary := range reportSummaries(cell.Report) {
+			key := teamCityKey(summary.Scenario, summary.Metric)
+			if !slices.Contains(columns, key) {
+				columns = append(columns, key)
+			}
+		}
+	}
+	return columns
+}
+
+func renderMatrixGrid(report MatrixReport) []byte {
+	var b bytes.Buffer
+	columns := matrixColumns(report)
+	header := append(append(append([]string{}, report.Dimensions...), "result"), columns...)
+	fmt.Fprintf(&b, "| %s |\n|%s\n", strings.Join(header, " | "), strings.Repeat("---|", len(header)))
+	for _, cell := range report.Cells {
+		row := make([]string, 0, len(header))
+		for _, name := range report.Dimensions {
+			row = append(row, cell.Vars[name])
+		}
+		row = append(row, reportResult(cell.Report))
+		maxes := map[string]string{}
+		for _, summary := range reportSummaries(cell.Report) {
+			value := fmt.Sprintf("%g", summary.Max)
+			if summary.Violations > 0 {
+				value += " **!**"
+			}
+			maxes[teamCityKey(summary.Scenario, summary.Metric)] = value
+		}
+		for _, column := range columns {
+			row = append(row, maxes[column])
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+	}
+	return b.Bytes()
+}
+
+func (app App) runMatrix(ctx context.Context, config Config) {
+	report, err := app.executeMatrix(ctx, config)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if app.reportFile != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(app.reportFile, b, 0o644)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if ctx.Err() != nil {
+		log.Println("=[ interrupted ]=======================")
+		os.Exit(130)
+	}
+	if !report.Passed {
+		log.Println("=[ failed ]============================")
+		os.Exit(1)
+	}
+	log.Println("=[ passed ]============================")
+}
+
+func (app App) planMatrix(ctx context.Context, config Config, w io.Writer) error {
+	if err := validateMatrix(config.Matrix); err != nil {
+		return err
+	}
+	cells := expandMatrix(config.Matrix)
+	for n, vars := range cells {
+		fmt.Fprintf(w, "=[ matrix %d/%d: %s ]====================\n", n+1, len(cells), matrixCellName(vars))
+		if err := app.matrixCell(vars).plan(ctx, config, w); err != nil {
+			return fmt.Errorf("matrix %s: %w", matrixCellName(vars), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	requires := require.New(t)
+	cells := expandMatrix(map[string][]string{"payload": {"small", "large"}, "concurrency": {"10", "50"}})
+	requires.Equal([]map[string]string{
+		{"concurrency": "10", "payload": "small"},
+		{"concurrency": "10", "payload": "large"},
+		{"concurrency": "50", "payload": "small"},
+		{"concurrency": "50", "payload": "large"},
+	}, cells)
+	requires.Equal("concurrency=10 payload=small", matrixCellName(cells[0]))
+	requires.Equal([]map[string]string{{}}, expandMatrix(nil))
+	requires.Error(validateMatrix(map[string][]string{"concurrency": {}}))
+
+	config := Config{}
+	requires.NoError(yaml.Unmarshal([]byte("matrix: {concurrency: [10, 50, 100]}"), &config))
+	requires.Equal([]string{"10", "50", "100"}, config.Matrix["concurrency"])
+}
+
+func TestMatrixApplyVars(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		Metrics:   []Metric{{Name: "a", Query: "rate{c=\"{{ .concurrency }}\"}"}},
+		Scenarios: []Scenario{{Name: "s", Load: []string{"k6", "--vus", "{{ .concurrency }}"}}},
+	}
+	first, err := App{}.matrixCell(map[string]string{"concurrency": "10"}).applyVars(config)
+	requires.NoError(err)
+	second, err := App{}.matrixCell(map[string]string{"concurrency": "50"}).applyVars(config)
+	requires.NoError(err)
+	requires.Equal("rate{c=\"10\"}", first.Metrics[0].Query)
+	requires.Equal("rate{c=\"50\"}", second.Metrics[0].Query)
+	requires.Equal([]string{"k6", "--vus", "50"}, second.Scenarios[0].Load)
+	requires.Equal("{{ .concurrency }}", config.Scenarios[0].Load[2])
+
+	app := App{vars: VarsFlag{"concurrency": "1", "env": "ci"}}.matrixCell(map[string]string{"concurrency": "10"})
+	requires.Equal(VarsFlag{"concurrency": "10", "env": "ci"}, app.vars)
+}
+
+func TestExecuteMatrix(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"` + r.FormValue("query") + `"]}]}}`))
+	}))
+	defer server.Close()
+	config := Config{}
+	requires.NoError(yaml.Unmarshal([]byte("testDuration: 1\ntimeout: 1\nmetrics: [{name: a, query: '{{ .concurrency }}', maxValue: 2}]\nmatrix: {concurrency: [1, 3]}"), &config))
+	config.Host = server.URL
+	dir := t.TempDir()
+	report, err := App{envManager: &FakeEnvManager{}, outputDir: dir}.executeMatrix(context.Background(), config)
+	requires.NoError(err)
+	requires.False(report.Passed)
+	requires.Equal([]string{"concurrency"}, report.Dimensions)
+	requires.Len(report.Cells, 2)
+	requires.True(report.Cells[0].Report.Passed)
+	requires.False(report.Cells[1].Report.Passed)
+
+	grid := string(renderMatrixGrid(report))
+	requires.Equal("| concurrency | result | a |\n|---|---|---|\n| 1 | passed | 1 |\n| 3 | failed | 3 **!** |\n", grid)
+	matches, err := filepath.Glob(filepath.Join(dir, "matrix-*", "matrix.md"))
+	requires.NoError(err)
+	requires.Len(matches, 1)
+	b, err := os.ReadFile(matches[0])
+	requires.NoError(err)
+	requires.Equal(grid, string(b))
+	b, err = os.ReadFile(strings.TrimSuffix(matches[0], ".md") + ".json")
+	requires.NoError(err)
+	saved := MatrixReport{}
+	requires.NoError(json.Unmarshal(b, &saved))
+	requires.Equal("3", saved.Cells[1].Vars["concurrency"])
+}
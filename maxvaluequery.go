@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+type QueryLimit struct {
+	Query string `json:"query"`
+	Value int    `json:"value"`
+}
+
+// MaxValueQueries fetches the limits of metrics with maxValueQuery once when
+// the stand is ready; every scenario looks its limits up by query.
+type MaxValueQueries struct {
+	mutex   sync.Mutex
+	queries []string
+	gathers []MetricGather
+	limiter *QueryLimiter
+	values  map[string]int
+}
+
+func newMaxValueQueries(config Config, sources Sources) *MaxValueQueries {
+	metrics := append([]Metric{}, config.Metrics...)
+	for _, scenario := range config.Scenarios {
+		metrics = append(metrics, scenario.Metrics...)
+	}
+	queries := make([]string, 0)
+	for _, metric := range metrics {
+		if metric.MaxValueQuery != "" && !slices.Contains(queries, metric.MaxValueQuery) {
+			queries = append(queries, metric.MaxValueQuery)
+		}
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+	limitMetrics := make([]Metric, 0, len(queries))
+	for _, query := range queries {
+		limitMetrics = append(limitMetrics, Metric{Name: "maxValueQuery", Query: query, OnMissing: "fail"})
+	}
+	return &MaxValueQueries{queries: queries, gathers: newMetricGathers(sources, limitMetrics), limiter: sources.queries, values: map[string]int{}}
+}
+
+func (limits *MaxValueQueries) resolve(ctx context.Context) bool {
+	if limits == nil {
+		return true
+	}
+	ctx = withQueryCache(ctx, limits.limiter)
+	ok := true
+	for n, query := range limits.queries {
+		value := limits.gathers[n].gather(ctx)
+		if !hasValue(value) {
+			log.Println(" maxValueQuery", query, "returned no value")
+			ok = false
+			continue
+		}
+		log.Println(" maxValueQuery", query, "=", value)
+		limits.mutex.Lock()
+		limits.values[query] = value
+		limits.mutex.Unlock()
+	}
+	return ok
+}
+
+// restore takes the limits of a resumed run instead of querying them again.
+func (limits *MaxValueQueries) restore(resolved []QueryLimit) {
+	if limits == nil {
+		return
+	}
+	limits.mutex.Lock()
+	defer limits.mutex.Unlock()
+	for _, limit := range resolved {
+		limits.values[limit.Query] = limit.Value
+	}
+}
+
+func (limits *MaxValueQueries) value(query string) (int, bool) {
+	if limits == nil {
+		return 0, false
+	}
+	limits.mutex.Lock()
+	defer limits.mutex.Unlock()
+	value, ok := limits.values[query]
+	return value, ok
+}
+
+func (limits *MaxValueQueries) result() []QueryLimit {
+	if limits == nil {
+		return nil
+	}
+	result := make([]QueryLimit, 0)
+	for _, query := range limits.queries {
+		if value, ok := limits.value(query); ok {
+			result = append(result, QueryLimit{Query: query, Value: value})
+		}
+	}
+	return result
+}
+
+func maxValueQueries(metrics []Metric) map[string]string {
+	queries := map[string]string{}
+	for _, metric := range metrics {
+		if metric.MaxValueQuery != "" {
+			queries[metric.Name] = metric.MaxValueQuery
+		}
+	}
+	return queries
+}
+
+func validateMaxValueQuery(metric Metric) error {
+	if metric.MaxValueQuery == "" {
+		return nil
+	}
+	if metric.MaxValue != 0 || metric.RelativeMax != nil {
+		return errors.New("maxValue and maxValueQuery are exclusive")
+	}
+	if metric.OnMissing == "treatAsMax" {
+		return errors.New("onMissing treatAsMax needs a fixed maxValue")
+	}
+	if _, err := parser.ParseExpr(metric.MaxValueQuery); err != nil {
+		return fmt.Errorf("maxValueQuery %q: %w", metric.MaxValueQuery, err)
+	}
+	return nil
+}
+
+func (scheduler *Scheduler) resolveLimits() bool {
+	if scheduler.limits == nil {
+		return true
+	}
+	if scheduler.runState.isResumed() && len(scheduler.runState.limits()) > 0 {
+		return true
+	}
+	log.Println("=[ limits ]============================")
+	if scheduler.limits.resolve(scheduler.context()) {
+		scheduler.runState.resolved(scheduler.limits.result())
+		return true
+	}
+	if scheduler.context().Err() == nil {
+		scheduler.sendDown()
+	}
+	return false
+}
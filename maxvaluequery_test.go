@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func limitServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.FormValue("query") {
+		case "capacity_rps":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"80"]}]}}`))
+		case "release_p95":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"250.5"]}]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}
+	}))
+}
+
+func TestMaxValueQueries(t *testing.T) {
+	requires := require.New(t)
+	server := limitServer()
+	defer server.Close()
+	config := Config{
+		Metrics: []Metric{{Name: "rps", Query: "rps", MaxValueQuery: "capacity_rps"}},
+		Scenarios: []Scenario{
+			{Name: "peak", Metrics: []Metric{{Name: "rps", Query: "rps", MaxValueQuery: "capacity_rps"}, {Name: "latency", Query: "p95", MaxValueQuery: "release_p95"}}},
+		},
+	}
+	limits := newMaxValueQueries(config, Sources{host: server.URL})
+	requires.Equal([]string{"capacity_rps", "release_p95"}, limits.queries)
+	requires.True(limits.resolve(context.Background()))
+	requires.Equal([]QueryLimit{{Query: "capacity_rps", Value: 80}, {Query: "release_p95", Value: 250}}, limits.result())
+	requires.Nil(newMaxValueQueries(Config{Metrics: []Metric{{Name: "rps", Query: "rps", MaxValue: 10}}}, Sources{}))
+
+	variants := []struct {
+		value      int
+		resolved   bool
+		violations int
+	}{
+		{value: 80, resolved: true, violations: 0},
+		{value: 81, resolved: true, violations: 1},
+		{value: 1000, resolved: false, violations: 0},
+	}
+	for n, variant := range variants {
+		gatherer := Gatherer{
+			metrics: []MetricGather{ValueMetricGather{metricName: "rps", value: variant.value}},
+			queried: map[string]string{"rps": "capacity_rps"},
+			limits:  limits,
+		}
+		if !variant.resolved {
+			gatherer.limits = &MaxValueQueries{values: map[string]int{}}
+		}
+		values, _ := gatherer.gatherAndCheck(context.Background(), time.Now())
+		requires.Len(values.violations, variant.violations, n)
+	}
+
+	limits = newMaxValueQueries(Config{Metrics: []Metric{{Name: "lag", Query: "lag", MaxValueQuery: "absent"}}}, Sources{host: server.URL})
+	requires.False(limits.resolve(context.Background()))
+	requires.Empty(limits.result())
+}
+
+func TestSchedulerMaxValueQuery(t *testing.T) {
+	requires := require.New(t)
+	server := limitServer()
+	defer server.Close()
+	config := Config{Host: server.URL, Metrics: []Metric{{Name: "lag", Query: "lag", MaxValueQuery: "absent"}}}
+	scheduler := App{}.tune(&Reporter{}, config, Sources{host: server.URL})
+	requires.NotNil(scheduler.limits)
+	requires.Equal(scheduler.limits, scheduler.eventer.(*Eventer).gatherer.(Gatherer).limits)
+	eventer := &FakeEventer{}
+	scheduler.eventer = eventer
+	scheduler.testDuration = time.Second
+	scheduler.timeout = 100 * time.Millisecond
+	scheduler.run()
+	requires.Equal(0, eventer.fired)
+	requires.True(scheduler.failed())
+
+	config.Metrics[0].MaxValueQuery = "capacity_rps"
+	scheduler = App{}.tune(&Reporter{}, config, Sources{host: server.URL})
+	requires.True(scheduler.resolveLimits())
+	requires.Equal([]QueryLimit{{Query: "capacity_rps", Value: 80}}, newRunReport(scheduler, nil).Limits)
+
+	w := &bytes.Buffer{}
+	planMetrics(w, config.Metrics)
+	requires.Contains(w.String(), "lag (prometheus) lag maxValueQuery capacity_rps")
+}
+
+func TestValidateMaxValueQuery(t *testing.T) {
+	variants := []struct {
+		metric Metric
+		err    string
+	}{
+		{metric: Metric{Name: "rps", Query: "rps", MaxValueQuery: "max(capacity_rps)"}},
+		{metric: Metric{Name: "rps", Query: "rps", MaxValue: 10, MaxValueQuery: "capacity_rps"}, err: "metric rps: maxValue and maxValueQuery are exclusive"},
+		{metric: Metric{Name: "rps", Query: "rps", RelativeMax: &RelativeThreshold{Factor: 1, Ref: "lag"}, MaxValueQuery: "capacity_rps"}, err: "maxValue and maxValueQuery are exclusive"},
+		{metric: Metric{Name: "rps", Query: "rps", OnMissing: "treatAsMax", MaxValueQuery: "capacity_rps"}, err: "onMissing treatAsMax needs a fixed maxValue"},
+		{metric: Metric{Name: "rps", Query: "rps", MaxValueQuery: "capacity_rps{"}, err: `maxValueQuery "capacity_rps{"`},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		err := validateMetricTypes(Config{Metrics: []Metric{variant.metric, {Name: "lag", Query: "lag"}}})
+		if variant.err == "" {
+			requires.NoError(err, n)
+			continue
+		}
+		requires.ErrorContains(err, variant.err, n)
+	}
+
+	metrics := []Metric{{Name: "rps", Query: "rps", MaxValueQuery: `capacity_rps{env="{{ .env }}"}`}}
+	requires.NoError(renderMetrics(metrics, map[string]string{"env": "stage"}))
+	requires.Equal(`capacity_rps{env="stage"}`, metrics[0].MaxValueQuery)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var mongoDBCommands = []string{"", "serverStatus", "dbStats"}
+
+type MongoDBConfig struct {
+	URI string `yaml:"uri"`
+}
+
+type MongoDBClient struct {
+	client   *mongo.Client
+	database string
+}
+
+// NewMongoDBClient doesn't connect yet, the driver connects on the first
+// command and reconnects after errors.
+func NewMongoDBClient(config MongoDBConfig) (*MongoDBClient, error) {
+	uri, err := url.Parse(config.URI)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(options.Client().ApplyURI(config.URI).SetAppName("metricsgatherer"))
+	if err != nil {
+		return nil, fmt.Errorf("mongodb: %w", err)
+	}
+	database := strings.TrimPrefix(uri.Path, "/")
+	if database == "" {
+		database = "admin"
+	}
+	return &MongoDBClient{client: client, database: database}, nil
+}
+
+func (client *MongoDBClient) close() {
+	if err := client.client.Disconnect(context.Background()); err != nil {
+		log.Println("mongodb error:", err)
+	}
+}
+
+func (client *MongoDBClient) command(ctx context.Context, database string, command bson.D) (bson.Raw, error) {
+	return client.client.Database(database).RunCommand(ctx, command).Raw()
+}
+
+// mongoDBField is false for a path that is not in the reply.
+func mongoDBField(reply bson.Raw, path string) (float64, bool, error) {
+	value, err := reply.LookupErr(strings.Split(path, ".")...)
+	if err != nil {
+		return 0, false, nil
+	}
+	if number, ok := value.AsFloat64OK(); ok {
+		return number, true, nil
+	}
+	if flag, ok := value.BooleanOK(); ok {
+		if flag {
+			return 1, true, nil
+		}
+		return 0, true, nil
+	}
+	return 0, true, fmt.Errorf("mongodb field %s is not a number", path)
+}
+
+type MongoDBMetric struct {
+	client    *MongoDBClient
+	Name      string
+	Command   string
+	Database  string
+	Field     string
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric MongoDBMetric) name() string {
+	return metric.Name
+}
+
+func (metric MongoDBMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric MongoDBMetric) gather(ctx context.Context) int {
+	command, database := bson.D{{Key: "serverStatus", Value: 1}}, "admin"
+	if metric.Command == "dbStats" {
+		command, database = bson.D{{Key: "dbStats", Value: 1}}, metric.Database
+		if database == "" {
+			database = metric.client.database
+		}
+	}
+	reply, err := metric.client.command(ctx, database, command)
+	if err != nil {
+		log.Printf("Error querying MongoDB: %v\n", err)
+		return -1
+	}
+	value, ok, err := mongoDBField(reply, metric.Field)
+	if err != nil {
+		log.Printf("WARNING: metric(%s): %v\n", metric.Name, err)
+		return -1
+	}
+	if !ok {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	return int(value)
+}
+
+func validateMongoDBMetric(config Config, metric Metric) error {
+	if config.MongoDB.URI == "" {
+		return fmt.Errorf("mongodb.uri is not set")
+	}
+	if metric.Query == "" {
+		return fmt.Errorf("query is not set")
+	}
+	if !slices.Contains(mongoDBCommands, metric.Command) {
+		return fmt.Errorf("unknown mongodb command %s", metric.Command)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+//go:build testcontainers
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// the image restarts mongod after creating the root user, so the second
+// "Waiting for connections" is the server with auth
+func mongoDBContainer(t *testing.T) string {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mongo:7",
+			ExposedPorts: []string{"27017/tcp"},
+			Env:          map[string]string{"MONGO_INITDB_ROOT_USERNAME": "perf", "MONGO_INITDB_ROOT_PASSWORD": "secret"},
+			WaitingFor: wait.ForAll(
+				wait.ForLog("Waiting for connections").WithOccurrence(2),
+				wait.ForListeningPort("27017/tcp"),
+			).WithDeadline(2 * time.Minute),
+		},
+		Started: true,
+	})
+	testcontainers.CleanupContainer(t, container)
+	require.NoError(t, err)
+	endpoint, err := container.PortEndpoint(ctx, "27017/tcp", "")
+	require.NoError(t, err)
+	return endpoint
+}
+
+func TestMongoDBContainer(t *testing.T) {
+	requires := require.New(t)
+	address := mongoDBContainer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	setup, err := NewMongoDBClient(MongoDBConfig{URI: "mongodb://perf:secret@" + address + "/?authSource=admin"})
+	requires.NoError(err)
+	_, err = setup.client.Database("orders").Collection("items").InsertMany(ctx, []any{bson.D{{Key: "n", Value: 1}}, bson.D{{Key: "n", Value: 2}}})
+	requires.NoError(err)
+	setup.close()
+
+	variants := []struct {
+		uri       string
+		command   string
+		database  string
+		field     string
+		onMissing string
+		value     int
+	}{
+		{field: "ok", value: 1},
+		{field: "connections.missing", value: -1},
+		{field: "connections.missing", onMissing: "treatAsZero", value: 0},
+		{field: "host", value: -1},
+		{field: "objects", command: "dbStats", value: 2},
+		{field: "collections", command: "dbStats", database: "orders", uri: "mongodb://perf:secret@" + address + "/?authSource=admin", value: 1},
+		{field: "ok", uri: "mongodb://perf:secret@" + address + "/orders?authSource=admin&authMechanism=SCRAM-SHA-1", value: 1},
+		{field: "ok", uri: "mongodb://perf:wrong@" + address + "/?authSource=admin", value: -1},
+	}
+	for n, variant := range variants {
+		uri := variant.uri
+		if uri == "" {
+			uri = "mongodb://perf:secret@" + address + "/orders?authSource=admin"
+		}
+		client, err := NewMongoDBClient(MongoDBConfig{URI: uri})
+		requires.NoError(err, n)
+		gathers := newMetricGathers(Sources{mongo: client}, []Metric{{
+			Name: "mongo", Type: "mongodb", Command: variant.command, Database: variant.database, Query: variant.field, OnMissing: variant.onMissing,
+		}})
+		requires.Equal(variant.value, gathers[0].gather(ctx), n)
+		requires.Equal(variant.value, gathers[0].gather(ctx), n)
+		client.close()
+	}
+
+	client, err := NewMongoDBClient(MongoDBConfig{URI: "mongodb://perf:secret@" + address + "/?authSource=admin"})
+	requires.NoError(err)
+	defer client.close()
+	requires.Positive(MongoDBMetric{client: client, Name: "connections", Field: "connections.current"}.gather(ctx))
+}
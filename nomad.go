@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNomadAddress = "http://127.0.0.1:4646"
+	defaultNomadTimeout = 5 * time.Minute
+)
+
+type NomadConfig struct {
+	Address   string   `yaml:"address"`
+	Token     string   `yaml:"token"`
+	Namespace string   `yaml:"namespace"`
+	JobFile   string   `yaml:"jobFile"`
+	Timeout   Duration `yaml:"timeout"`
+	Purge     bool     `yaml:"purge"`
+}
+
+type NomadEnv struct {
+	client          *http.Client
+	baseURL         string
+	token           string
+	namespace       string
+	jobFile         string
+	timeout         time.Duration
+	teardownTimeout time.Duration
+	purge           bool
+	pollInterval    time.Duration
+	jobID           string
+}
+
+func validateNomad(config NomadConfig) error {
+	if config.JobFile == "" {
+		return errors.New("envManager nomad: nomad.jobFile is not set")
+	}
+	if config.Timeout < 0 {
+		return errors.New("envManager nomad: nomad.timeout must not be negative")
+	}
+	return nil
+}
+
+func newNomadEnv(config Config, teardownTimeout time.Duration) *NomadEnv {
+	nomad := config.Nomad
+	address := nomad.Address
+	if address == "" {
+		address = defaultNomadAddress
+	}
+	jobFile := nomad.JobFile
+	if !filepath.IsAbs(jobFile) {
+		jobFile = filepath.Join(config.WorkDir, jobFile)
+	}
+	timeout := time.Duration(nomad.Timeout)
+	if timeout == 0 {
+		timeout = defaultNomadTimeout
+	}
+	return &NomadEnv{
+		client:          &http.Client{Timeout: 30 * time.Second},
+		baseURL:         strings.TrimSuffix(address, "/"),
+		token:           nomad.Token,
+		namespace:       nomad.Namespace,
+		jobFile:         jobFile,
+		timeout:         timeout,
+		teardownTimeout: teardownTimeout,
+		purge:           nomad.Purge,
+		pollInterval:    2 * time.Second,
+	}
+}
+
+func (env *NomadEnv) request(ctx context.Context, method string, path string, query url.Values, body any, value any) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	if env.namespace != "" {
+		query.Set("namespace", env.namespace)
+	}
+	target := env.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if env.token != "" {
+		req.Header.Set("X-Nomad-Token", env.token)
+	}
+	resp, err := env.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("nomad api %s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(b)))
+	}
+	if value == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+func (env *NomadEnv) loadJob(ctx context.Context) (map[string]any, error) {
+	b, err := os.ReadFile(env.jobFile)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		spec := map[string]any{}
+		if err := json.Unmarshal(trimmed, &spec); err != nil {
+			return nil, fmt.Errorf("%s: %w", env.jobFile, err)
+		}
+		if job, ok := spec["Job"].(map[string]any); ok {
+			return job, nil
+		}
+		return spec, nil
+	}
+	job := map[string]any{}
+	if err := env.request(ctx, http.MethodPost, "/v1/jobs/parse", nil, map[string]any{"JobHCL": string(b), "Canonicalize": true}, &job); err != nil {
+		return nil, fmt.Errorf("%s: %w", env.jobFile, err)
+	}
+	return job, nil
+}
+
+type NomadAllocation struct {
+	ID               string `json:"ID"`
+	Name             string `json:"Name"`
+	DesiredStatus    string `json:"DesiredStatus"`
+	ClientStatus     string `json:"ClientStatus"`
+	DeploymentStatus *struct {
+		Healthy *bool `json:"Healthy"`
+	} `json:"DeploymentStatus"`
+}
+
+func nomadAllocationsReady(allocations []NomadAllocation) (bool, error) {
+	ready := 0
+	for _, allocation := range allocations {
+		if allocation.DesiredStatus != "run" {
+			continue
+		}
+		switch allocation.ClientStatus {
+		case "failed", "lost":
+			return false, fmt.Errorf("allocation %s is %s", allocation.Name, allocation.ClientStatus)
+		case "running":
+		default:
+			return false, nil
+		}
+		if status := allocation.DeploymentStatus; status != nil && status.Healthy != nil {
+			if !*status.Healthy {
+				return false, fmt.Errorf("allocation %s is unhealthy", allocation.Name)
+			}
+		} else if status != nil {
+			return false, nil
+		}
+		ready++
+	}
+	return ready > 0, nil
+}
+
+func (env *NomadEnv) start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), env.timeout)
+	defer cancel()
+	job, err := env.loadJob(ctx)
+	if err != nil {
+		return fmt.Errorf("start stand: %w", err)
+	}
+	id, _ := job["ID"].(string)
+	if id == "" {
+		return fmt.Errorf("start stand: %s: job has no ID", env.jobFile)
+	}
+	env.jobID = id
+	log.Println("start stand: nomad job", id, env.baseURL)
+	if err := env.request(ctx, http.MethodPost, "/v1/jobs", nil, map[string]any{"Job": job}, nil); err != nil {
+		return fmt.Errorf("start stand: %w", err)
+	}
+	for {
+		allocations := []NomadAllocation{}
+		if err := env.request(ctx, http.MethodGet, "/v1/job/"+url.PathEscape(id)+"/allocations", nil, nil, &allocations); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("start stand: nomad job %s: allocations are not healthy after %s", id, env.timeout)
+			}
+			return fmt.Errorf("start stand: %w", err)
+		}
+		ready, err := nomadAllocationsReady(allocations)
+		if err != nil {
+			return fmt.Errorf("start stand: nomad job %s: %w", id, err)
+		}
+		if ready {
+			log.Println("start stand: nomad job", id, "is healthy")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("start stand: nomad job %s: allocations are not healthy after %s", id, env.timeout)
+		case <-time.After(env.pollInterval):
+		}
+	}
+}
+
+func (env *NomadEnv) stop() error {
+	if env.jobID == "" {
+		return nil
+	}
+	ctx := context.Background()
+	if env.teardownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, env.teardownTimeout)
+		defer cancel()
+	}
+	query := url.Values{}
+	if env.purge {
+		query.Set("purge", "true")
+	}
+	log.Println("stop stand: nomad job", env.jobID)
+	if err := env.request(ctx, http.MethodDelete, "/v1/job/"+url.PathEscape(env.jobID), query, nil, nil); err != nil {
+		return fmt.Errorf("stop stand: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type FakeNomad struct {
+	mutex       sync.Mutex
+	requests    []string
+	job         map[string]any
+	allocations [][]NomadAllocation
+}
+
+func (nomad *FakeNomad) serve(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nomad.mutex.Lock()
+		defer nomad.mutex.Unlock()
+		nomad.requests = append(nomad.requests, r.Method+" "+r.URL.RequestURI())
+		if r.Header.Get("X-Nomad-Token") != "secret" {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+		body := map[string]any{}
+		if r.Body != nil {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /v1/jobs/parse":
+			_ = json.NewEncoder(w).Encode(map[string]any{"ID": "stand", "HCL": body["JobHCL"]})
+		case "POST /v1/jobs":
+			nomad.job = body["Job"].(map[string]any)
+			_ = json.NewEncoder(w).Encode(map[string]any{"EvalID": "eval"})
+		case "GET /v1/job/stand/allocations":
+			allocations := nomad.allocations[0]
+			if len(nomad.allocations) > 1 {
+				nomad.allocations = nomad.allocations[1:]
+			}
+			_ = json.NewEncoder(w).Encode(allocations)
+		case "DELETE /v1/job/stand":
+			_ = json.NewEncoder(w).Encode(map[string]any{"EvalID": "eval"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func nomadHealthy(healthy bool) *struct {
+	Healthy *bool `json:"Healthy"`
+} {
+	return &struct {
+		Healthy *bool `json:"Healthy"`
+	}{Healthy: &healthy}
+}
+
+func TestNomadEnv(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "stand.nomad.hcl"), []byte(`job "stand" {}`), 0o644))
+	requires.NoError(os.WriteFile(filepath.Join(dir, "stand.json"), []byte(`{"Job": {"ID": "stand", "Type": "service"}}`), 0o644))
+	nomad := &FakeNomad{allocations: [][]NomadAllocation{
+		{},
+		{{Name: "stand.api[0]", DesiredStatus: "run", ClientStatus: "pending"}},
+		{
+			{Name: "stand.api[0]", DesiredStatus: "run", ClientStatus: "running", DeploymentStatus: nomadHealthy(true)},
+			{Name: "stand.db[0]", DesiredStatus: "run", ClientStatus: "running"},
+			{Name: "stand.api[1]", DesiredStatus: "stop", ClientStatus: "failed"},
+		},
+	}}
+	address := nomad.serve(t)
+	config := Config{WorkDir: dir, Nomad: NomadConfig{Address: address + "/", Token: "secret", Namespace: "perf", JobFile: "stand.nomad.hcl", Purge: true}}
+	env := newNomadEnv(config, time.Second)
+	env.pollInterval = time.Millisecond
+	requires.Equal(address, env.baseURL)
+	requires.Equal(defaultNomadTimeout, env.timeout)
+	requires.NoError(env.start())
+	requires.NoError(env.stop())
+	requires.Equal([]string{
+		"POST /v1/jobs/parse?namespace=perf",
+		"POST /v1/jobs?namespace=perf",
+		"GET /v1/job/stand/allocations?namespace=perf",
+		"GET /v1/job/stand/allocations?namespace=perf",
+		"GET /v1/job/stand/allocations?namespace=perf",
+		"DELETE /v1/job/stand?namespace=perf&purge=true",
+	}, nomad.requests)
+	requires.Equal(map[string]any{"ID": "stand", "HCL": `job "stand" {}`}, nomad.job)
+
+	nomad.requests = nil
+	config.Nomad = NomadConfig{Address: address, Token: "secret", JobFile: filepath.Join(dir, "stand.json")}
+	env = newNomadEnv(config, time.Second)
+	requires.NoError(env.start())
+	requires.NoError(env.stop())
+	requires.Equal([]string{"POST /v1/jobs", "GET /v1/job/stand/allocations", "DELETE /v1/job/stand"}, nomad.requests)
+	requires.Equal(map[string]any{"ID": "stand", "Type": "service"}, nomad.job)
+
+	nomad.allocations = [][]NomadAllocation{{{Name: "stand.api[0]", DesiredStatus: "run", ClientStatus: "running", DeploymentStatus: nomadHealthy(false)}}}
+	requires.ErrorContains(newNomadEnv(config, time.Second).start(), "nomad job stand: allocation stand.api[0] is unhealthy")
+
+	nomad.allocations = [][]NomadAllocation{{}}
+	config.Nomad.Timeout = Duration(20 * time.Millisecond)
+	env = newNomadEnv(config, time.Second)
+	env.pollInterval = time.Millisecond
+	requires.ErrorContains(env.start(), "allocations are not healthy after 20ms")
+
+	config.Nomad.Token = "wrong"
+	env = newNomadEnv(config, time.Second)
+	requires.ErrorContains(env.start(), "nomad api POST /v1/jobs: 403 Forbidden Permission denied")
+	requires.NoError(newNomadEnv(config, time.Second).stop())
+}
+
+func TestNomadAllocationsReady(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		allocations []NomadAllocation
+		ready       bool
+		err         string
+	}{
+		{},
+		{allocations: []NomadAllocation{{DesiredStatus: "run", ClientStatus: "running"}}, ready: true},
+		{allocations: []NomadAllocation{{DesiredStatus: "run", ClientStatus: "running", DeploymentStatus: nomadHealthy(true)}}, ready: true},
+		{allocations: []NomadAllocation{{DesiredStatus: "run", ClientStatus: "running", DeploymentStatus: nomadHealthy(true)}, {DesiredStatus: "run", ClientStatus: "pending"}}},
+		{allocations: []NomadAllocation{{DesiredStatus: "run", ClientStatus: "running", DeploymentStatus: &struct {
+			Healthy *bool `json:"Healthy"`
+		}{}}}},
+		{allocations: []NomadAllocation{{DesiredStatus: "stop", ClientStatus: "complete"}}},
+		{allocations: []NomadAllocation{{Name: "a", DesiredStatus: "run", ClientStatus: "failed"}}, err: "allocation a is failed"},
+		{allocations: []NomadAllocation{{Name: "a", DesiredStatus: "run", ClientStatus: "lost"}}, err: "allocation a is lost"},
+	}
+	for n, variant := range variants {
+		ready, err := nomadAllocationsReady(variant.allocations)
+		if variant.err != "" {
+			requires.ErrorContains(err, variant.err, n)
+			continue
+		}
+		requires.NoError(err, n)
+		requires.Equal(variant.ready, ready, n)
+	}
+}
+
+func TestValidateNomad(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateEnvManager(Config{EnvManager: "nomad", Nomad: NomadConfig{JobFile: "stand.nomad.hcl"}}))
+	requires.ErrorContains(validateEnvManager(Config{EnvManager: "nomad"}), "envManager nomad: nomad.jobFile is not set")
+	requires.ErrorContains(validateNomad(NomadConfig{JobFile: "stand.nomad.hcl", Timeout: -1}), "nomad.timeout must not be negative")
+	requires.ErrorContains(validateEnvManager(Config{EnvManager: "nomad", Environments: []EnvironmentConfig{{}}}), "does not support environments")
+	requires.Equal(redacted, redactConfig(Config{Nomad: NomadConfig{Token: "secret"}}).Nomad.Token)
+
+	scheduler := App{}.tune(&Reporter{}, Config{WorkDir: "/stand", EnvManager: "nomad", Nomad: NomadConfig{JobFile: "stand.nomad.hcl"}}, Sources{})
+	env, ok := scheduler.envManager.(*NomadEnv)
+	requires.True(ok)
+	requires.Equal("/stand/stand.nomad.hcl", env.jobFile)
+	requires.Equal(defaultNomadAddress, env.baseURL)
+}
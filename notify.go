@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+)
+
+type NotifierInt interface {
+	started(runID string, timestamp time.Time)
+	violated(runID string, values MetricValues)
+	finished(runID string, timestamp time.Time, ok bool)
+}
+
+func (App) notifiers(config Config, reporter *Reporter) []NotifierInt {
+	notifiers := make([]NotifierInt, 0)
+	if config.Grafana.URL != "" {
+		notifiers = append(notifiers, GrafanaNotifier{
+			url:   config.Grafana.URL,
+			token: config.Grafana.Token,
+			tags:  config.Grafana.Tags,
+		})
+	}
+	return append(notifiers, newWebhookNotifiers(config, reporter)...)
+}
+
+func notifyStarted(notifiers []NotifierInt, runID string) {
+	for _, notifier := range notifiers {
+		notifier.started(runID, time.Now())
+	}
+}
+
+func notifyViolations(notifiers []NotifierInt, runID string, reporter *Reporter) {
+	reporter.subscribe(func(values MetricValues) {
+		if len(values.violations) == 0 {
+			return
+		}
+		for _, notifier := range notifiers {
+			notifier.violated(runID, values)
+		}
+	})
+}
+
+func notifyFinished(notifiers []NotifierInt, runID string, ok bool) {
+	for _, notifier := range notifiers {
+		notifier.finished(runID, time.Now(), ok)
+	}
+}
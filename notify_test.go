@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type FakeNotifier struct {
+	events []string
+}
+
+func (notifier *FakeNotifier) started(runID string, timestamp time.Time) {
+	notifier.events = append(notifier.events, "started "+runID)
+}
+
+func (notifier *FakeNotifier) violated(runID string, values MetricValues) {
+	notifier.events = append(notifier.events, "violated "+values.violations[0].name)
+}
+
+func (notifier *FakeNotifier) finished(runID string, timestamp time.Time, ok bool) {
+	if ok {
+		notifier.events = append(notifier.events, "passed "+runID)
+		return
+	}
+	notifier.events = append(notifier.events, "failed "+runID)
+}
+
+func TestNotify(t *testing.T) {
+	requires := require.New(t)
+	notifier := FakeNotifier{}
+	notifiers := []NotifierInt{&notifier}
+	reporter := Reporter{}
+	notifyViolations(notifiers, "r1", &reporter)
+	notifyStarted(notifiers, "r1")
+	reporter.sendResult(MetricValues{})
+	reporter.sendResult(MetricValues{violations: []MetricValue{{name: "errors", value: 1}}})
+	notifyFinished(notifiers, "r1", false)
+	requires.Equal([]string{"started r1", "violated errors", "failed r1"}, notifier.events)
+}
+
+func TestAppNotifiers(t *testing.T) {
+	requires := require.New(t)
+	requires.Empty(App{}.notifiers(Config{}, nil))
+	requires.Len(App{}.notifiers(Config{Grafana: GrafanaConfig{URL: "http://grafana:3000"}}, nil), 1)
+}
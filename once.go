@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+func (app App) gatherOnce(ctx context.Context, config Config, w io.Writer) (bool, error) {
+	if len(config.Matrix) > 0 {
+		return false, errors.New("--once does not support matrix configs")
+	}
+	if len(config.Stands) > 0 {
+		return false, errors.New("--once needs --stand with a config with stands")
+	}
+	config, assertions, err := app.prepare(config)
+	if err != nil {
+		return false, err
+	}
+	sources, closeSources, err := newSources(config)
+	if err != nil {
+		return false, err
+	}
+	defer closeSources()
+	metrics := make([]Metric, 0)
+	for _, metric := range configMetrics(config) {
+		if metric.Type == "otlp" {
+			fmt.Fprintln(w, " skip", metric.Name, "otlp values are pushed during the run")
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	gatherer := Gatherer{
+		host:     config.Host,
+		metrics:  newMetricGathers(sources, metrics),
+		relative: relativeThresholds(metrics),
+		queried:  maxValueQueries(metrics),
+		limits:   newMaxValueQueries(Config{Metrics: metrics}, sources),
+	}
+	gatherer.limits.resolve(ctx)
+	values, _ := gatherer.gatherAndCheck(ctx, time.Now())
+	fmt.Fprintln(w, "=[ once ]==============================")
+	for n, metric := range gatherer.metrics {
+		value := values.values[n]
+		status := " ok  "
+		if violated(values, metric.name()) {
+			status = " FAIL"
+		}
+		limit, checked := gatherer.limit(metric, values.values)
+		switch {
+		case !hasValue(value.value):
+			fmt.Fprintln(w, status, metric.name(), "no data")
+		case !checked:
+			fmt.Fprintln(w, status, metric.name(), value.value)
+		default:
+			fmt.Fprintln(w, status, metric.name(), value.value, "limit", limit)
+		}
+	}
+	sampled, _ := splitRanged(assertions)
+	results, asserted := checkAssertions([]MetricValues{values}, sampled)
+	if len(results) > 0 {
+		fmt.Fprintln(w, "=[ assertions ]==============")
+		for _, line := range assertionLines(results) {
+			fmt.Fprint(w, line)
+		}
+	}
+	return len(values.violations) == 0 && asserted, nil
+}
+
+func violated(values MetricValues, name string) bool {
+	for _, violation := range values.violations {
+		if violation.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatherOnce(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.FormValue("query") {
+		case "errors":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"5"]}]}}`))
+		case "lag":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"42"]}]}}`))
+		}
+	}))
+	defer server.Close()
+	env := &FakeEnvManager{}
+	app := App{envManager: env}
+
+	w := &bytes.Buffer{}
+	ok, err := app.gatherOnce(context.Background(), Config{Host: server.URL, Otlp: OtlpConfig{Listen: "127.0.0.1:0"}, Metrics: []Metric{
+		{Name: "rps", Query: "rps", MaxValue: 100, Assertions: []string{"max < 50"}},
+		{Name: "lag", Query: "lag", MaxValue: 10},
+		{Name: "pushed", Type: "otlp", Service: "api"},
+	}}, w)
+	requires.NoError(err)
+	requires.True(ok)
+	requires.False(env.started)
+	requires.Equal(" skip pushed otlp values are pushed during the run\n=[ once ]==============================\n ok   rps 42 limit 100\n ok   lag no data\n=[ assertions ]==============\n ok   rps max < 50 actual 42\n", w.String())
+
+	w.Reset()
+	ok, err = app.gatherOnce(context.Background(), Config{Host: server.URL, OnViolation: "continue", Metrics: []Metric{
+		{Name: "rps", Query: "rps", MaxValue: 100, Assertions: []string{"max < 40"}},
+		{Name: "errors", Query: "errors", MaxValue: 1},
+	}}, w)
+	requires.NoError(err)
+	requires.False(ok)
+	requires.Contains(w.String(), " FAIL errors 5 limit 1\n")
+	requires.Contains(w.String(), " FAIL rps max < 40 actual 42\n")
+
+	_, err = app.gatherOnce(context.Background(), Config{Matrix: map[string][]string{"env": {"a"}}}, w)
+	requires.ErrorContains(err, "--once does not support matrix configs")
+	_, err = app.gatherOnce(context.Background(), Config{Metrics: []Metric{{Name: "rps", Query: "rps{"}}}, w)
+	requires.Error(err)
+
+	command, err := app.parseArgs([]string{"--once", "--config", "stand.yaml"})
+	requires.NoError(err)
+	requires.Equal("run", command.name)
+	requires.True(app.once)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+)
+
+type OtlpConfig struct {
+	Listen string `yaml:"listen"`
+}
+
+type OtlpSeries struct {
+	attributes map[string]string
+	value      float64
+}
+
+type OtlpReceiver struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	mutex  sync.Mutex
+	series map[string]map[string]OtlpSeries
+	server *grpc.Server
+	addr   net.Addr
+}
+
+func NewOtlpReceiver() *OtlpReceiver {
+	return &OtlpReceiver{series: map[string]map[string]OtlpSeries{}}
+}
+
+func (receiver *OtlpReceiver) start(listen string) error {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	receiver.addr = listener.Addr()
+	receiver.server = grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(receiver.server, receiver)
+	log.Println("otlp receiver:", listener.Addr())
+	go func() {
+		if err := receiver.server.Serve(listener); err != nil {
+			log.Println("otlp receiver error:", err)
+		}
+	}()
+	return nil
+}
+
+func (receiver *OtlpReceiver) stop() {
+	if receiver.server != nil {
+		receiver.server.GracefulStop()
+	}
+}
+
+func otlpAttributes(attributes ...[]*commonpb.KeyValue) map[string]string {
+	result := map[string]string{}
+	for _, list := range attributes {
+		for _, attribute := range list {
+			result[attribute.GetKey()] = attribute.GetValue().GetStringValue()
+		}
+	}
+	return result
+}
+
+func otlpSeriesKey(attributes map[string]string) string {
+	key := ""
+	for _, name := range slices.Sorted(maps.Keys(attributes)) {
+		key += name + "=" + attributes[name] + ","
+	}
+	return key
+}
+
+func (receiver *OtlpReceiver) Export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	for _, resourceMetrics := range request.GetResourceMetrics() {
+		resource := resourceMetrics.GetResource().GetAttributes()
+		for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+			for _, metric := range scopeMetrics.GetMetrics() {
+				var points []*metricspb.NumberDataPoint
+				switch data := metric.GetData().(type) {
+				case *metricspb.Metric_Gauge:
+					points = data.Gauge.GetDataPoints()
+				case *metricspb.Metric_Sum:
+					points = data.Sum.GetDataPoints()
+				default:
+					continue
+				}
+				for _, point := range points {
+					attributes := otlpAttributes(resource, point.GetAttributes())
+					value := point.GetAsDouble()
+					if v, ok := point.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+						value = float64(v.AsInt)
+					}
+					if receiver.series[metric.GetName()] == nil {
+						receiver.series[metric.GetName()] = map[string]OtlpSeries{}
+					}
+					receiver.series[metric.GetName()][otlpSeriesKey(attributes)] = OtlpSeries{attributes: attributes, value: value}
+				}
+			}
+		}
+	}
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func (receiver *OtlpReceiver) value(name string, filter map[string]string) (float64, bool) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	sum := 0.0
+	found := false
+	for _, series := range receiver.series[name] {
+		matched := true
+		for key, value := range filter {
+			if series.attributes[key] != value {
+				matched = false
+			}
+		}
+		if matched {
+			sum += series.value
+			found = true
+		}
+	}
+	return sum, found
+}
+
+type OtlpMetric struct {
+	receiver   *OtlpReceiver
+	Name       string
+	Query      string
+	Attributes map[string]string
+	MaxValue   int
+}
+
+func (metric OtlpMetric) name() string {
+	return metric.Name
+}
+
+func (metric OtlpMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric OtlpMetric) gather(ctx context.Context) int {
+	value, ok := metric.receiver.value(metric.Query, metric.Attributes)
+	if !ok {
+		log.Println("WARNING: no otlp data for", metric.Query)
+		return -1
+	}
+	return int(value)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/stretchr/testify/require"
+)
+
+func otlpAttribute(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func otlpRequest() *colmetricspb.ExportMetricsServiceRequest {
+	point := func(route string, value int64) *metricspb.NumberDataPoint {
+		return &metricspb.NumberDataPoint{
+			Attributes: []*commonpb.KeyValue{otlpAttribute("route", route)},
+			Value:      &metricspb.NumberDataPoint_AsInt{AsInt: value},
+		}
+	}
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{otlpAttribute("service.name", "api")}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Metrics: []*metricspb.Metric{
+					{
+						Name: "http.errors",
+						Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{DataPoints: []*metricspb.NumberDataPoint{
+							point("/a", 3), point("/b", 4),
+						}}},
+					},
+					{
+						Name: "queue.size",
+						Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+							{Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 12.5}},
+						}}},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+func TestOtlpReceiverExport(t *testing.T) {
+	requires := require.New(t)
+	receiver := NewOtlpReceiver()
+	_, err := receiver.Export(context.Background(), otlpRequest())
+	requires.NoError(err)
+
+	variants := []struct {
+		metric OtlpMetric
+		value  int
+	}{
+		{metric: OtlpMetric{Query: "http.errors"}, value: 7},
+		{metric: OtlpMetric{Query: "http.errors", Attributes: map[string]string{"route": "/b"}}, value: 4},
+		{metric: OtlpMetric{Query: "http.errors", Attributes: map[string]string{"service.name": "api"}}, value: 7},
+		{metric: OtlpMetric{Query: "http.errors", Attributes: map[string]string{"route": "/c"}}, value: -1},
+		{metric: OtlpMetric{Query: "queue.size"}, value: 12},
+		{metric: OtlpMetric{Query: "unknown"}, value: -1},
+	}
+	for n, variant := range variants {
+		variant.metric.receiver = receiver
+		requires.Equal(variant.value, variant.metric.gather(context.Background()), n)
+	}
+}
+
+func TestOtlpReceiverGrpc(t *testing.T) {
+	requires := require.New(t)
+	receiver := NewOtlpReceiver()
+	requires.NoError(receiver.start("127.0.0.1:0"))
+	defer receiver.stop()
+
+	conn, err := grpc.NewClient(receiver.addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	requires.NoError(err)
+	defer func() { _ = conn.Close() }()
+	_, err = colmetricspb.NewMetricsServiceClient(conn).Export(context.Background(), otlpRequest())
+	requires.NoError(err)
+	value, ok := receiver.value("http.errors", nil)
+	requires.True(ok)
+	requires.Equal(7.0, value)
+}
+
+func TestValidateMetricTypes(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{{Name: "a"}, {Name: "b", Type: "prometheus"}}}))
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Type: "otlp"}}}))
+	requires.NoError(validateMetricTypes(Config{Otlp: OtlpConfig{Listen: ":4317"}, Metrics: []Metric{{Name: "a", Type: "otlp"}}}))
+	requires.Error(validateMetricTypes(Config{Scenarios: []Scenario{{Metrics: []Metric{{Name: "a", Type: "graphite"}}}}}))
+}
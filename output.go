@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type RunMetadata struct {
+	RunID     string    `json:"runId"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Passed    bool      `json:"passed"`
+	Host      string    `json:"host"`
+	WorkDir   string    `json:"workDir"`
+	Branch    string    `json:"branch,omitempty"`
+	Scenarios []string  `json:"scenarios,omitempty"`
+	dir       string
+}
+
+type RunOutput struct {
+	dir     string
+	logFile *os.File
+	writer  io.Writer
+}
+
+func newRunOutput(baseDir string, runID string, started time.Time) (*RunOutput, error) {
+	dir := filepath.Join(baseDir, runID+"-"+started.Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	logFile, err := os.Create(filepath.Join(dir, "run.log"))
+	if err != nil {
+		return nil, err
+	}
+	return attachRunOutput(dir, logFile), nil
+}
+
+// resumeRunOutput continues run.log in the directory of a resumed run.
+func resumeRunOutput(dir string) (*RunOutput, error) {
+	logFile, err := os.OpenFile(filepath.Join(dir, "run.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return attachRunOutput(dir, logFile), nil
+}
+
+func attachRunOutput(dir string, logFile *os.File) *RunOutput {
+	output := &RunOutput{dir: dir, logFile: logFile, writer: log.Writer()}
+	log.SetOutput(io.MultiWriter(output.writer, PlainWriter{writer: logFile}))
+	log.Println("outputDir:", dir)
+	return output
+}
+
+func (output *RunOutput) writeFile(name string, b []byte) {
+	if err := os.WriteFile(filepath.Join(output.dir, name), b, 0o644); err != nil {
+		log.Println("output error:", err)
+	}
+}
+
+func (output *RunOutput) writeReports(report RunReport) {
+	for _, format := range reportFormats {
+		if format == "csv" && report.Spool != "" {
+			if err := writeSpoolCSV(filepath.Join(output.dir, report.Spool), filepath.Join(output.dir, "report.csv")); err != nil {
+				log.Println("output error:", err)
+			}
+			continue
+		}
+		b, err := renderReport(format, report)
+		if err != nil {
+			log.Println("output error:", err)
+			continue
+		}
+		output.writeFile("report."+format, b)
+	}
+}
+
+const redacted = "<redacted>"
+
+func redactConfig(config Config) Config {
+	if config.Nomad.Token != "" {
+		config.Nomad.Token = redacted
+	}
+	if config.Grafana.Token != "" {
+		config.Grafana.Token = redacted
+	}
+	if config.SQL.DSN != "" {
+		config.SQL.DSN = redacted
+	}
+	if config.Elasticsearch.Password != "" {
+		config.Elasticsearch.Password = redacted
+	}
+	if config.RabbitMQ.Password != "" {
+		config.RabbitMQ.Password = redacted
+	}
+	if config.Jolokia.Password != "" {
+		config.Jolokia.Password = redacted
+	}
+	if config.Zabbix.Token != "" {
+		config.Zabbix.Token = redacted
+	}
+	if config.Zabbix.Password != "" {
+		config.Zabbix.Password = redacted
+	}
+	if config.AzureMonitor.ClientSecret != "" {
+		config.AzureMonitor.ClientSecret = redacted
+	}
+	if config.AzureMonitor.Token != "" {
+		config.AzureMonitor.Token = redacted
+	}
+	if config.CloudMonitoring.Token != "" {
+		config.CloudMonitoring.Token = redacted
+	}
+	if config.ClickHouse.Password != "" {
+		config.ClickHouse.Password = redacted
+	}
+	if config.Kubernetes.Token != "" {
+		config.Kubernetes.Token = redacted
+	}
+	if config.MongoDB.URI != "" {
+		config.MongoDB.URI = redacted
+	}
+	if config.Email.Password != "" {
+		config.Email.Password = redacted
+	}
+	if config.S3.SecretKey != "" {
+		config.S3.SecretKey = redacted
+	}
+	if config.SNMP.Community != "" {
+		config.SNMP.Community = redacted
+	}
+	if config.SNMP.V3.AuthPassword != "" {
+		config.SNMP.V3.AuthPassword = redacted
+	}
+	if config.SNMP.V3.PrivPassword != "" {
+		config.SNMP.V3.PrivPassword = redacted
+	}
+	webhooks := make([]WebhookConfig, 0, len(config.Webhooks))
+	for _, webhook := range config.Webhooks {
+		headers := map[string]string{}
+		for name := range webhook.Headers {
+			headers[name] = redacted
+		}
+		webhook.Headers = headers
+		webhooks = append(webhooks, webhook)
+	}
+	config.Webhooks = webhooks
+	if len(config.Tracing.Headers) > 0 {
+		headers := map[string]string{}
+		for name := range config.Tracing.Headers {
+			headers[name] = redacted
+		}
+		config.Tracing.Headers = headers
+	}
+	config.Metrics = redactProbes(config.Metrics)
+	scenarios := make([]Scenario, 0, len(config.Scenarios))
+	for _, scenario := range config.Scenarios {
+		scenario.Metrics = redactProbes(scenario.Metrics)
+		scenarios = append(scenarios, scenario)
+	}
+	config.Scenarios = scenarios
+	return config
+}
+
+func redactProbes(metrics []Metric) []Metric {
+	redactedMetrics := make([]Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		if len(metric.Probe.Headers) > 0 {
+			headers := map[string]string{}
+			for name := range metric.Probe.Headers {
+				headers[name] = redacted
+			}
+			metric.Probe.Headers = headers
+		}
+		redactedMetrics = append(redactedMetrics, metric)
+	}
+	return redactedMetrics
+}
+
+func (output *RunOutput) writeConfig(config Config) {
+	b, err := yaml.Marshal(redactConfig(config))
+	if err != nil {
+		log.Println("output error:", err)
+		return
+	}
+	output.writeFile("config.yaml", b)
+}
+
+func (output *RunOutput) writeMetadata(metadata RunMetadata) {
+	b, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		log.Println("output error:", err)
+		return
+	}
+	output.writeFile("metadata.json", b)
+}
+
+func (output *RunOutput) close() {
+	log.SetOutput(output.writer)
+	if err := output.logFile.Close(); err != nil {
+		log.Println("output error:", err)
+	}
+}
+
+func (app App) outputBaseDir(config Config) string {
+	if app.outputDir != "" {
+		return app.outputDir
+	}
+	if config.OutputDir != "" {
+		return config.OutputDir
+	}
+	return "./results"
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func outputReport() RunReport {
+	return RunReport{
+		RunID: "r1",
+		Values: []MetricValuesJSON{{
+			Scenario:   "s",
+			Timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Values:     []ValueJSON{{Name: "a", Value: 1}, {Name: "b", Value: 7, Labels: map[string]string{"team": "core", "component": "api"}, Series: map[string]string{"pod": "api-1"}}},
+			Violations: []ValueJSON{{Name: "b", Value: 7}},
+		}},
+		Assertions: []AssertionJSON{{Scenario: "s", Metric: "a", Assertion: "avg < 5", Value: 1, Ok: true}},
+	}
+}
+
+func TestRenderReport(t *testing.T) {
+	requires := require.New(t)
+	report := outputReport()
+
+	b, err := renderReport("csv", report)
+	requires.NoError(err)
+	requires.Equal("timestamp,scenario,metric,labels,value,violation,series\n"+
+		"2024-01-02T03:04:05Z,s,a,,1,false,\n"+
+		"2024-01-02T03:04:05Z,s,b,\"component=api,team=core\",7,true,pod=api-1\n", string(b))
+
+	b, err = renderReport("md", report)
+	requires.NoError(err)
+	requires.Contains(string(b), "Result: **failed**")
+	requires.Contains(string(b), "| s | a | avg < 5 | 1 | ok |")
+	requires.Contains(string(b), "| 2024-01-02T03:04:05Z | s | b | component=api,team=core | 7 **!** | pod=api-1 |")
+
+	b, err = renderReport("html", report)
+	requires.NoError(err)
+	requires.Contains(string(b), `<tr class="fail"><td>2024-01-02T03:04:05Z</td><td>s</td><td>b</td><td>7</td><td>pod=api-1</td></tr>`)
+	requires.Contains(string(b), `<h2>Values component=api,team=core</h2>`)
+	requires.Contains(string(b), `Result: <b>failed</b>`)
+
+	b, err = renderReport("json", report)
+	requires.NoError(err)
+	var loaded RunReport
+	requires.NoError(json.Unmarshal(b, &loaded))
+	requires.Equal(report.RunID, loaded.RunID)
+
+	_, err = renderReport("xml", report)
+	requires.Error(err)
+}
+
+func TestRunOutput(t *testing.T) {
+	requires := require.New(t)
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	output, err := newRunOutput(t.TempDir(), "r1", started)
+	requires.NoError(err)
+	requires.Equal("r1-20240102-030405", filepath.Base(output.dir))
+	log.Println("hello output")
+	output.writeReports(outputReport())
+	output.writeConfig(Config{Host: "http://prometheus", Grafana: GrafanaConfig{Token: "secret"}, Email: EmailConfig{Password: "secret"}, S3: S3Config{SecretKey: "secret"}})
+	output.writeMetadata(RunMetadata{RunID: "r1", Passed: true})
+	output.close()
+	log.Println("after close")
+
+	b, err := os.ReadFile(filepath.Join(output.dir, "run.log"))
+	requires.NoError(err)
+	requires.Contains(string(b), "hello output")
+	requires.NotContains(string(b), "after close")
+	for _, name := range []string{"report.json", "report.csv", "report.md", "report.html", "metadata.json"} {
+		requires.FileExists(filepath.Join(output.dir, name))
+	}
+	b, err = os.ReadFile(filepath.Join(output.dir, "config.yaml"))
+	requires.NoError(err)
+	requires.True(strings.Contains(string(b), "host: http://prometheus"))
+	requires.NotContains(string(b), ": secret")
+}
+
+func TestAppOutputBaseDir(t *testing.T) {
+	requires := require.New(t)
+	requires.Equal("./results", App{}.outputBaseDir(Config{}))
+	requires.Equal("cfg", App{}.outputBaseDir(Config{OutputDir: "cfg"}))
+	requires.Equal("flag", App{outputDir: "flag"}.outputBaseDir(Config{OutputDir: "cfg"}))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type DurationFlag struct {
+	value Duration
+	set   bool
+}
+
+func (duration *DurationFlag) String() string {
+	if duration == nil || !duration.set {
+		return ""
+	}
+	return duration.value.String()
+}
+
+func (duration *DurationFlag) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if seconds, intErr := strconv.Atoi(value); intErr == nil {
+		parsed, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil {
+		return err
+	}
+	if parsed < 0 {
+		return fmt.Errorf("negative duration %s", value)
+	}
+	duration.value, duration.set = Duration(parsed), true
+	return nil
+}
+
+type Overrides struct {
+	duration   DurationFlag
+	startDelay DurationFlag
+	interval   DurationFlag
+}
+
+func (overrides Overrides) apply(config Config) (Config, error) {
+	if overrides.interval.set && overrides.interval.value <= 0 {
+		return config, fmt.Errorf("--interval must be positive")
+	}
+	if overrides.startDelay.set {
+		config.StartDelay = overrides.startDelay.value
+	}
+	if overrides.interval.set {
+		config.Timeout = overrides.interval.value
+	}
+	if overrides.duration.set {
+		config.TestDuration, config.Iterations = overrides.duration.value, 0
+		scenarios := make([]Scenario, len(config.Scenarios))
+		for n, scenario := range config.Scenarios {
+			scenario.Duration, scenario.Iterations = overrides.duration.value, 0
+			scenarios[n] = scenario
+		}
+		config.Scenarios = scenarios
+	}
+	return config, nil
+}
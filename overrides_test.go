@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationFlag(t *testing.T) {
+	variants := []struct {
+		value    string
+		duration time.Duration
+		err      bool
+	}{
+		{value: "90s", duration: 90 * time.Second},
+		{value: "2m30s", duration: 150 * time.Second},
+		{value: "45", duration: 45 * time.Second},
+		{value: "0", duration: 0},
+		{value: "-5s", err: true},
+		{value: "soon", err: true},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		duration := DurationFlag{}
+		err := duration.Set(variant.value)
+		if variant.err {
+			requires.Error(err, variant.value)
+			continue
+		}
+		requires.NoError(err, variant.value)
+		requires.True(duration.set)
+		requires.Equal(Duration(variant.duration), duration.value, variant.value)
+	}
+	requires.Equal("", (&DurationFlag{}).String())
+}
+
+func TestOverridesApply(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		StartDelay:   Duration(time.Minute),
+		TestDuration: Duration(10 * time.Minute),
+		Timeout:      Duration(5 * time.Second),
+		Scenarios:    []Scenario{{Name: "baseline", Duration: Duration(time.Hour)}, {Name: "soak"}},
+	}
+
+	applied, err := Overrides{}.apply(config)
+	requires.NoError(err)
+	requires.Equal(config, applied)
+
+	app := App{}
+	_, err = app.parseArgs([]string{"run", "--duration", "30s", "--start-delay", "0", "--interval", "1s"})
+	requires.NoError(err)
+	applied, err = app.overrides.apply(config)
+	requires.NoError(err)
+	requires.Equal(Duration(0), applied.StartDelay)
+	requires.Equal(Duration(time.Second), applied.Timeout)
+	requires.Equal(Duration(30*time.Second), applied.TestDuration)
+	requires.Equal(Duration(30*time.Second), applied.Scenarios[0].Duration)
+	requires.Equal(Duration(30*time.Second), applied.Scenarios[1].Duration)
+	requires.Equal(Duration(time.Hour), config.Scenarios[0].Duration)
+
+	_, err = app.parseArgs([]string{"run", "--interval", "0"})
+	requires.NoError(err)
+	_, err = app.overrides.apply(config)
+	requires.ErrorContains(err, "--interval must be positive")
+	_, err = app.parseArgs([]string{"run", "--duration", "-1s"})
+	requires.Error(err)
+}
+
+func TestOverridesPlan(t *testing.T) {
+	requires := require.New(t)
+	app := App{envManager: &FakeEnvManager{}}
+	requires.NoError(app.overrides.startDelay.Set("2s"))
+	requires.NoError(app.overrides.duration.Set("10s"))
+	var b bytes.Buffer
+	config := Config{StartDelay: Duration(time.Minute), TestDuration: Duration(time.Hour), Timeout: Duration(time.Second)}
+	requires.NoError(app.plan(context.Background(), config, &b))
+	requires.Contains(b.String(), "startDelay:  2s")
+	requires.Contains(b.String(), "duration:    10s")
+}
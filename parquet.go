@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const defaultParquetRowGroupSize = 10000
+
+type ParquetConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	RowGroupSize int  `yaml:"rowGroupSize"`
+}
+
+// ParquetRow is a sample of values.parquet, a missing value is null.
+type ParquetRow struct {
+	RunID     string `parquet:"run_id"`
+	Scenario  string `parquet:"scenario"`
+	Timestamp int64  `parquet:"timestamp,timestamp(millisecond)"`
+	Metric    string `parquet:"metric"`
+	Value     *int64 `parquet:"value,optional"`
+	Violated  bool   `parquet:"violated"`
+	Series    string `parquet:"series"`
+}
+
+type ParquetWriter struct {
+	mutex  sync.Mutex
+	file   *os.File
+	writer *parquet.GenericWriter[ParquetRow]
+	runID  string
+	size   int
+	rows   []ParquetRow
+	failed bool
+}
+
+func NewParquetWriter(fileName string, runID string, config ParquetConfig) (*ParquetWriter, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	size := config.RowGroupSize
+	if size <= 0 {
+		size = defaultParquetRowGroupSize
+	}
+	writer := parquet.NewGenericWriter[ParquetRow](file, parquet.CreatedBy("metricsgatherer", version, ""))
+	return &ParquetWriter{file: file, writer: writer, runID: runID, size: size}, nil
+}
+
+func (writer *ParquetWriter) fail(err error) {
+	if err != nil && !writer.failed {
+		log.Println("parquet error:", err)
+		writer.failed = true
+	}
+}
+
+func (writer *ParquetWriter) observe(values MetricValues) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	for _, value := range values.values {
+		_, violated := violatedValue(values, value.name)
+		row := ParquetRow{
+			RunID:     writer.runID,
+			Scenario:  values.scenario,
+			Timestamp: values.timestamp.UnixMilli(),
+			Metric:    value.name,
+			Violated:  violated,
+			Series:    formatLabels(value.series),
+		}
+		if hasValue(value.value) {
+			sample := int64(value.value)
+			row.Value = &sample
+		}
+		writer.rows = append(writer.rows, row)
+	}
+	if len(writer.rows) >= writer.size {
+		writer.flush()
+	}
+}
+
+// flush writes the buffered rows as a row group.
+func (writer *ParquetWriter) flush() {
+	if len(writer.rows) == 0 || writer.failed {
+		writer.rows = writer.rows[:0]
+		return
+	}
+	_, err := writer.writer.Write(writer.rows)
+	if err == nil {
+		err = writer.writer.Flush()
+	}
+	writer.fail(err)
+	writer.rows = writer.rows[:0]
+}
+
+func (writer *ParquetWriter) close() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.flush()
+	if !writer.failed {
+		writer.fail(writer.writer.Close())
+	}
+	if err := writer.file.Close(); err != nil {
+		log.Println("parquet error:", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParquetWriter(t *testing.T) {
+	requires := require.New(t)
+	fileName := filepath.Join(t.TempDir(), "values.parquet")
+	writer, err := NewParquetWriter(fileName, "01RUN", ParquetConfig{RowGroupSize: 4})
+	requires.NoError(err)
+	at := time.UnixMilli(1700000000000)
+	writer.observe(MetricValues{timestamp: at, values: []MetricValue{{name: "errors", value: 0}, {name: "latency", value: 120, series: map[string]string{"pod": "api-1"}}}})
+	writer.observe(MetricValues{
+		scenario:   "ramp",
+		timestamp:  at.Add(time.Second),
+		values:     []MetricValue{{name: "errors", value: 3}, {name: "latency", value: -1}},
+		violations: []MetricValue{{name: "errors", value: 3}},
+	})
+	requires.Empty(writer.rows)
+	writer.observe(MetricValues{scenario: "ramp", timestamp: at.Add(2 * time.Second), values: []MetricValue{{name: "errors", value: missingValue}}})
+	writer.close()
+
+	file, err := os.Open(fileName)
+	requires.NoError(err)
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	requires.NoError(err)
+	parquetFile, err := parquet.OpenFile(file, info.Size())
+	requires.NoError(err)
+	requires.Equal(int64(5), parquetFile.NumRows())
+	requires.Contains(parquetFile.Metadata().CreatedBy, "metricsgatherer version dev")
+	requires.Len(parquetFile.RowGroups(), 2)
+	requires.Equal([]int64{4, 1}, []int64{parquetFile.RowGroups()[0].NumRows(), parquetFile.RowGroups()[1].NumRows()})
+	schema := parquetFile.Schema()
+	requires.Equal(7, len(schema.Fields()))
+	timestamp, ok := schema.Lookup("timestamp")
+	requires.True(ok)
+	requires.Equal("TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)", timestamp.Node.Type().LogicalType().String())
+	value, ok := schema.Lookup("value")
+	requires.True(ok)
+	requires.True(value.Node.Optional())
+	metric, ok := schema.Lookup("metric")
+	requires.True(ok)
+	requires.Equal("STRING", metric.Node.Type().LogicalType().String())
+
+	rows, err := parquet.ReadFile[ParquetRow](fileName)
+	requires.NoError(err)
+	sample := func(value int64) *int64 { return &value }
+	requires.Equal([]ParquetRow{
+		{RunID: "01RUN", Timestamp: 1700000000000, Metric: "errors", Value: sample(0)},
+		{RunID: "01RUN", Timestamp: 1700000000000, Metric: "latency", Value: sample(120), Series: "pod=api-1"},
+		{RunID: "01RUN", Scenario: "ramp", Timestamp: 1700000001000, Metric: "errors", Value: sample(3), Violated: true},
+		{RunID: "01RUN", Scenario: "ramp", Timestamp: 1700000001000, Metric: "latency"},
+		{RunID: "01RUN", Scenario: "ramp", Timestamp: 1700000002000, Metric: "errors"},
+	}, rows)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Budgets maps "metric" or "scenario/metric" to limits of run-level
+// aggregates, e.g. latency: {p95: 800, max: 2000}.
+type Budgets map[string]map[string]float64
+
+func (app App) budgetsFile(config Config) string {
+	if app.budgets != "" {
+		return app.budgets
+	}
+	if config.Budgets == "" || filepath.IsAbs(config.Budgets) {
+		return config.Budgets
+	}
+	return filepath.Join(config.WorkDir, config.Budgets)
+}
+
+func loadBudgets(fileName string) (Budgets, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	budgets := Budgets{}
+	if err := yaml.Unmarshal(expandEnv(b, os.LookupEnv), &budgets); err != nil {
+		return nil, fmt.Errorf("budgets %s: %w", fileName, err)
+	}
+	return budgets, nil
+}
+
+func budgetAssertions(limits map[string]float64) ([]Assertion, error) {
+	if len(limits) == 0 {
+		return nil, errors.New("no limits")
+	}
+	assertions := make([]Assertion, 0, len(limits))
+	for _, aggregate := range slices.Sorted(maps.Keys(limits)) {
+		if _, err := aggregateValues(aggregate, []float64{0}); err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, Assertion{aggregate: aggregate, operator: "<=", limit: limits[aggregate]})
+	}
+	return assertions, nil
+}
+
+func withBudget(metric Metric, assertions []Assertion) Metric {
+	budget := slices.Clone(metric.Budget)
+	for _, assertion := range assertions {
+		n := slices.IndexFunc(budget, func(limit Assertion) bool { return limit.aggregate == assertion.aggregate })
+		if n < 0 {
+			budget = append(budget, assertion)
+		} else {
+			budget[n] = assertion
+		}
+	}
+	metric.Budget = budget
+	return metric
+}
+
+// applyBudgets puts the budgets on the metrics: a metric budget applies in
+// every scenario, a scenario/metric budget only in that scenario and wins
+// over the metric budget for the same aggregate.
+func applyBudgets(config Config, budgets Budgets) (Config, error) {
+	config.Metrics = slices.Clone(config.Metrics)
+	config.Scenarios = slices.Clone(config.Scenarios)
+	for n := range config.Scenarios {
+		config.Scenarios[n].Metrics = slices.Clone(config.Scenarios[n].Metrics)
+	}
+	keys := slices.Sorted(maps.Keys(budgets))
+	for _, scoped := range []bool{false, true} {
+		for _, key := range keys {
+			scenarioName, metricName, found := strings.Cut(key, "/")
+			if found != scoped {
+				continue
+			}
+			assertions, err := budgetAssertions(budgets[key])
+			if err != nil {
+				return config, fmt.Errorf("budget %s: %w", key, err)
+			}
+			if !scoped {
+				matched := false
+				for _, metrics := range append([][]Metric{config.Metrics}, scenarioMetrics(config)...) {
+					for n := range metrics {
+						if metrics[n].Name == key {
+							metrics[n] = withBudget(metrics[n], assertions)
+							matched = true
+						}
+					}
+				}
+				if !matched {
+					return config, fmt.Errorf("budget %s: unknown metric", key)
+				}
+				continue
+			}
+			byName := func(metric Metric) bool { return metric.Name == metricName }
+			s := slices.IndexFunc(config.Scenarios, func(scenario Scenario) bool { return scenario.Name == scenarioName })
+			if s < 0 {
+				return config, fmt.Errorf("budget %s: unknown scenario %s", key, scenarioName)
+			}
+			scenario := &config.Scenarios[s]
+			n := slices.IndexFunc(scenario.Metrics, byName)
+			if n < 0 {
+				top := slices.IndexFunc(config.Metrics, byName)
+				if top < 0 {
+					return config, fmt.Errorf("budget %s: unknown metric %s", key, metricName)
+				}
+				scenario.Metrics = append(scenario.Metrics, config.Metrics[top])
+				n = len(scenario.Metrics) - 1
+			}
+			scenario.Metrics[n] = withBudget(scenario.Metrics[n], assertions)
+		}
+	}
+	return config, nil
+}
+
+func scenarioMetrics(config Config) [][]Metric {
+	metrics := make([][]Metric, 0, len(config.Scenarios))
+	for _, scenario := range config.Scenarios {
+		metrics = append(metrics, scenario.Metrics)
+	}
+	return metrics
+}
+
+func (app App) applyBudgets(config Config) (Config, error) {
+	fileName := app.budgetsFile(config)
+	if fileName == "" {
+		return config, nil
+	}
+	budgets, err := loadBudgets(fileName)
+	if err != nil {
+		return config, err
+	}
+	return applyBudgets(config, budgets)
+}
+
+// budgetOnly returns the metrics gated only by their budget: without
+// maxValue, maxValueQuery or a relative maxValue they are not checked on
+// every tick.
+func budgetOnly(metrics []Metric) map[string]bool {
+	unchecked := map[string]bool{}
+	for _, metric := range metrics {
+		if len(metric.Budget) > 0 && metric.MaxValue == 0 && metric.MaxValueQuery == "" && metric.RelativeMax == nil {
+			unchecked[metric.Name] = true
+		}
+	}
+	return unchecked
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBudgets(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		Metrics: []Metric{{Name: "latency", Query: "latency"}, {Name: "rss", Query: "rss", MaxValue: 512}},
+		Scenarios: []Scenario{
+			{Name: "warm", Metrics: []Metric{{Name: "latency", Query: "latency_warm"}}},
+			{Name: "peak"},
+		},
+	}
+	budgeted, err := applyBudgets(config, Budgets{
+		"latency":      {"p95": 800, "max": 2000},
+		"peak/latency": {"p95": 1200},
+		"peak/rss":     {"max": 600},
+	})
+	requires.NoError(err)
+	requires.Empty(config.Metrics[0].Budget)
+	requires.Equal([]Assertion{{"max", "<=", 2000}, {"p95", "<=", 800}}, budgeted.Metrics[0].Budget)
+	requires.Equal([]Assertion{{"max", "<=", 2000}, {"p95", "<=", 800}}, budgeted.Scenarios[0].Metrics[0].Budget)
+	requires.Equal([]Metric{
+		{Name: "latency", Query: "latency", Budget: []Assertion{{"max", "<=", 2000}, {"p95", "<=", 1200}}},
+		{Name: "rss", Query: "rss", MaxValue: 512, Budget: []Assertion{{"max", "<=", 600}}},
+	}, budgeted.Scenarios[1].Metrics)
+	requires.Empty(config.Scenarios[1].Metrics)
+	requires.Equal(map[string]bool{"latency": true}, budgetOnly(mergeMetrics(budgeted.Metrics, budgeted.Scenarios[1].Metrics)))
+
+	assertions, err := collectAssertions(budgeted)
+	requires.NoError(err)
+	requires.Len(assertions, 5)
+	requires.Equal(RunAssertion{scenario: "peak", metric: "rss", assertion: Assertion{"max", "<=", 600}, budget: true}, assertions[4])
+	requires.Equal([]string{" FAIL peak/rss budget max <= 600 actual 700\n"},
+		assertionLines([]AssertionResult{{RunAssertion: assertions[4], value: 700}}))
+
+	variants := []struct {
+		budgets Budgets
+		err     string
+	}{
+		{budgets: Budgets{"gc": {"max": 1}}, err: "budget gc: unknown metric"},
+		{budgets: Budgets{"cold/latency": {"max": 1}}, err: "budget cold/latency: unknown scenario cold"},
+		{budgets: Budgets{"peak/gc": {"max": 1}}, err: "budget peak/gc: unknown metric gc"},
+		{budgets: Budgets{"latency": {"p100.5": 1}}, err: "budget latency: unknown aggregate p100.5"},
+		{budgets: Budgets{"latency": {}}, err: "budget latency: no limits"},
+	}
+	for _, variant := range variants {
+		_, err := applyBudgets(config, variant.budgets)
+		requires.ErrorContains(err, variant.err)
+	}
+}
+
+func TestPrepareBudgets(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "budgets.yaml"), []byte("latency: {p95: 800}\n"), 0o644))
+	requires.NoError(os.WriteFile(filepath.Join(dir, "strict.yaml"), []byte("latency: {p95: 500}\n"), 0o644))
+	config := Config{WorkDir: dir, Budgets: "budgets.yaml", Metrics: []Metric{{Name: "latency", Query: "latency"}}}
+
+	prepared, assertions, err := App{}.prepare(config)
+	requires.NoError(err)
+	requires.Equal([]RunAssertion{{metric: "latency", assertion: Assertion{"p95", "<=", 800}, budget: true}}, assertions)
+	requires.Equal(map[string]bool{"latency": true}, budgetOnly(prepared.Metrics))
+
+	_, assertions, err = App{budgets: filepath.Join(dir, "strict.yaml")}.prepare(config)
+	requires.NoError(err)
+	requires.Equal(500.0, assertions[0].assertion.limit)
+
+	_, _, err = App{selection: Selection{skip: SelectorsFlag{{key: "name", pattern: "latency"}}}}.prepare(Config{
+		WorkDir: dir, Budgets: "budgets.yaml", Metrics: []Metric{{Name: "latency", Query: "latency"}, {Name: "rss", Query: "rss"}},
+	})
+	requires.NoError(err)
+
+	config.Budgets = "missing.yaml"
+	_, _, err = App{}.prepare(config)
+	requires.ErrorContains(err, "missing.yaml")
+	requires.ErrorContains(checkRemoteConfig(Config{Budgets: "budgets.yaml"}), "budgets is not allowed in posted configs")
+	requires.Contains(App{configFile: "perf.yaml", budgets: "strict.yaml"}.standArgs("v1", "v1.json"), "strict.yaml")
+}
+
+func TestBudgetOnlyIsNotCheckedPerTick(t *testing.T) {
+	requires := require.New(t)
+	metrics := []Metric{{Name: "latency", Budget: []Assertion{{"p95", "<=", 800}}}, {Name: "errors"}}
+	gatherer := Gatherer{
+		metrics:   []MetricGather{ValueMetricGather{metricName: "latency", value: 900}, ValueMetricGather{metricName: "errors", value: 0}},
+		unchecked: budgetOnly(metrics),
+		budget:    NewViolationBudget(),
+	}
+	values, ok := gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.True(ok)
+	requires.Empty(values.violations)
+
+	gatherer.metrics = []MetricGather{ValueMetricGather{metricName: "latency", value: 900}, ValueMetricGather{metricName: "errors", value: 1}}
+	values, ok = gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.False(ok)
+	requires.Equal([]MetricValue{{name: "errors", value: 1}}, values.violations)
+}
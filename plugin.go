@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const pluginProtocolVersion = 1
+
+const defaultPluginTimeout = 10 * time.Second
+
+type PluginRequest struct {
+	Version int               `json:"version"`
+	Metric  string            `json:"metric"`
+	Query   string            `json:"query,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Options map[string]any    `json:"options,omitempty"`
+}
+
+type PluginResponse struct {
+	Value *float64 `json:"value"`
+	Error string   `json:"error,omitempty"`
+}
+
+type PluginMetric struct {
+	workDir   string
+	command   []string
+	Name      string
+	Query     string
+	Labels    map[string]string
+	Options   map[string]any
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric PluginMetric) name() string {
+	return metric.Name
+}
+
+func (metric PluginMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric PluginMetric) call(ctx context.Context) (PluginResponse, error) {
+	request, err := json.Marshal(PluginRequest{
+		Version: pluginProtocolVersion,
+		Metric:  metric.Name,
+		Query:   metric.Query,
+		Labels:  metric.Labels,
+		Options: metric.Options,
+	})
+	if err != nil {
+		return PluginResponse{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultPluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, metric.command[0], metric.command[1:]...)
+	cmd.Dir = metric.workDir
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.WaitDelay = time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return PluginResponse{}, fmt.Errorf("plugin %s: %w", metric.command[0], ctx.Err())
+		}
+		return PluginResponse{}, fmt.Errorf("plugin %s: %w: %s", metric.command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	response := PluginResponse{}
+	if err := json.Unmarshal(out, &response); err != nil {
+		return PluginResponse{}, fmt.Errorf("plugin %s: invalid response: %w", metric.command[0], err)
+	}
+	return response, nil
+}
+
+func (metric PluginMetric) gather(ctx context.Context) int {
+	response, err := metric.call(ctx)
+	if err != nil {
+		log.Printf("Error querying plugin: %v\n", err)
+		return -1
+	}
+	if response.Error != "" {
+		log.Printf("Error querying plugin: %s: %s\n", metric.command[0], response.Error)
+		return -1
+	}
+	if response.Value == nil {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	return int(*response.Value)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginMetric(t *testing.T) {
+	variants := []struct {
+		name      string
+		script    string
+		onMissing string
+		value     int
+	}{
+		{"value", `echo '{"value": 12.7}'`, "", 12},
+		{"missing", `echo '{"value": null}'`, "treatAsZero", 0},
+		{"error", `echo '{"error": "broker down"}'`, "", -1},
+		{"invalid", `echo 'lag=5'`, "", -1},
+		{"exit", `echo oops >&2; exit 3`, "", -1},
+	}
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			requires := require.New(t)
+			dir := t.TempDir()
+			script := "cat > request.json\n" + variant.script + "\n"
+			requires.NoError(os.WriteFile(filepath.Join(dir, "plugin.sh"), []byte(script), 0o755))
+			gathers := newMetricGathers(Sources{workDir: dir}, []Metric{{
+				Name: "lag", Type: "plugin", Plugin: []string{"sh", "plugin.sh"}, Query: "orders",
+				Options: map[string]any{"brokers": []any{"kafka:9092"}, "group": "billing"}, OnMissing: variant.onMissing,
+			}})
+			requires.Equal(variant.value, gathers[0].gather(context.Background()))
+			b, err := os.ReadFile(filepath.Join(dir, "request.json"))
+			requires.NoError(err)
+			request := PluginRequest{}
+			requires.NoError(json.Unmarshal(b, &request))
+			requires.Equal(PluginRequest{
+				Version: 1, Metric: "lag", Query: "orders",
+				Options: map[string]any{"brokers": []any{"kafka:9092"}, "group": "billing"},
+			}, request)
+		})
+	}
+}
+
+func TestPluginMetricCall(t *testing.T) {
+	requires := require.New(t)
+	metric := PluginMetric{command: []string{"sh", "-c", "echo oops >&2; exit 3"}, Name: "lag"}
+	_, err := metric.call(context.Background())
+	requires.EqualError(err, "plugin sh: exit status 3: oops")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = metric.call(ctx)
+	requires.EqualError(err, "plugin sh: context canceled")
+	requires.EqualError(validateMetricTypes(Config{Metrics: []Metric{{Name: "lag", Type: "plugin"}}}), "metric lag: plugin is not set")
+}
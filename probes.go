@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultStartupTimeout = 5 * time.Minute
+	defaultProbeInterval  = time.Second
+	defaultProbeReady     = ">= 1"
+	notReadyExitCode      = 4
+)
+
+type StartupProbe struct {
+	Metric `yaml:",inline"`
+	Ready  string `yaml:"ready"`
+}
+
+type StartupPending struct {
+	Probe string `json:"probe"`
+	Value int    `json:"value"`
+	Ready string `json:"ready"`
+}
+
+type StartupResult struct {
+	Seconds float64          `json:"seconds"`
+	Limit   float64          `json:"limit"`
+	Ready   bool             `json:"ready"`
+	Pending []StartupPending `json:"pending,omitempty"`
+}
+
+type StartupProbes struct {
+	gathers    []MetricGather
+	conditions []Assertion
+	timeout    time.Duration
+	interval   time.Duration
+	startup    *StartupResult
+}
+
+func (probe *StartupProbe) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		content := make([]*yaml.Node, 0, len(node.Content))
+		for n := 0; n+1 < len(node.Content); n += 2 {
+			key, value := node.Content[n], node.Content[n+1]
+			if key.Value == "ready" {
+				if err := value.Decode(&probe.Ready); err != nil {
+					return err
+				}
+				continue
+			}
+			content = append(content, key, value)
+		}
+		copied := *node
+		copied.Content = content
+		node = &copied
+	}
+	return node.Decode(&probe.Metric)
+}
+
+func (probe StartupProbe) ready() string {
+	if probe.Ready == "" {
+		return defaultProbeReady
+	}
+	return probe.Ready
+}
+
+func parseReady(text string) (Assertion, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return Assertion{}, fmt.Errorf("ready %q: expected \"<operator> <value>\"", text)
+	}
+	if !validOperator(fields[0]) {
+		return Assertion{}, fmt.Errorf("ready %q: unknown operator %s", text, fields[0])
+	}
+	limit, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("ready %q: %w", text, err)
+	}
+	return Assertion{operator: fields[0], limit: limit}, nil
+}
+
+func probeMetrics(probes []StartupProbe) []Metric {
+	metrics := make([]Metric, 0, len(probes))
+	for _, probe := range probes {
+		metrics = append(metrics, probe.Metric)
+	}
+	return metrics
+}
+
+func validateStartupProbes(config Config) error {
+	if config.StartupTimeout < 0 {
+		return errors.New("startupTimeout must not be negative")
+	}
+	if len(config.StartupProbes) == 0 {
+		return nil
+	}
+	for _, probe := range config.StartupProbes {
+		if probe.Name == "" {
+			return errors.New("startupProbes: name is not set")
+		}
+		if _, err := parseReady(probe.ready()); err != nil {
+			return fmt.Errorf("startupProbes: probe %s: %w", probe.Name, err)
+		}
+		if probe.Type == "derived" {
+			return fmt.Errorf("startupProbes: probe %s: derived metrics are not supported", probe.Name)
+		}
+	}
+	probes := config
+	probes.Metrics = probeMetrics(config.StartupProbes)
+	probes.Scenarios = nil
+	if err := validateMetricTypes(probes); err != nil {
+		return fmt.Errorf("startupProbes: %w", err)
+	}
+	if err := validatePromQL(probes); err != nil {
+		return fmt.Errorf("startupProbes: %w", err)
+	}
+	return nil
+}
+
+func newStartupProbes(config Config, sources Sources) *StartupProbes {
+	if len(config.StartupProbes) == 0 {
+		return nil
+	}
+	probes := &StartupProbes{
+		gathers:  newMetricGathers(sources, probeMetrics(config.StartupProbes)),
+		timeout:  time.Duration(config.StartupTimeout),
+		interval: time.Duration(config.Timeout),
+	}
+	if probes.timeout == 0 {
+		probes.timeout = defaultStartupTimeout
+	}
+	if probes.interval <= 0 {
+		probes.interval = defaultProbeInterval
+	}
+	for _, probe := range config.StartupProbes {
+		condition, _ := parseReady(probe.ready())
+		probes.conditions = append(probes.conditions, condition)
+	}
+	return probes
+}
+
+func (probes *StartupProbes) check(ctx context.Context) []StartupPending {
+	ctx = withQueryCache(ctx, nil)
+	pending := make([]StartupPending, 0)
+	for n, gather := range probes.gathers {
+		value := gather.gather(ctx)
+		if condition := probes.conditions[n]; !hasValue(value) || !condition.check(float64(value)) {
+			pending = append(pending, StartupPending{Probe: gather.name(), Value: value, Ready: condition.operator + " " + strconv.FormatFloat(condition.limit, 'f', -1, 64)})
+		}
+	}
+	return pending
+}
+
+func (probes *StartupProbes) wait(ctx context.Context) bool {
+	started := time.Now()
+	deadline, cancel := context.WithTimeout(ctx, probes.timeout)
+	defer cancel()
+	probes.startup = &StartupResult{Limit: probes.timeout.Seconds()}
+	for {
+		pending := probes.check(deadline)
+		probes.startup.Seconds = time.Since(started).Seconds()
+		if len(pending) == 0 {
+			probes.startup.Ready = true
+			log.Println("stand is ready after", time.Since(started).Round(time.Second))
+			return true
+		}
+		probes.startup.Pending = pending
+		select {
+		case <-deadline.Done():
+			if ctx.Err() == nil {
+				log.Println("stand never became ready within", probes.timeout)
+				for _, probe := range pending {
+					log.Println(" probe("+probe.Probe+"):", probe.Value, "want", probe.Ready)
+				}
+			}
+			return false
+		case <-time.After(probes.interval):
+		}
+	}
+}
+
+func (probes *StartupProbes) result() *StartupResult {
+	if probes == nil {
+		return nil
+	}
+	return probes.startup
+}
+
+func (scheduler *Scheduler) waitReady() bool {
+	if scheduler.probes == nil {
+		return true
+	}
+	log.Println("=[ startup ]===========================")
+	ctx, span := startSpan(scheduler.context(), "startup")
+	defer span.End()
+	if scheduler.probes.wait(ctx) {
+		return true
+	}
+	span.SetStatus(codes.Error, "not ready")
+	if scheduler.context().Err() == nil {
+		scheduler.notReady = true
+		scheduler.sendDown()
+	}
+	return false
+}
+
+func renderStartup(result *StartupResult) string {
+	b := strings.Builder{}
+	b.WriteString("## Startup\n\n")
+	if result.Ready {
+		fmt.Fprintf(&b, "ready after %.1fs of %.1fs\n\n", result.Seconds, result.Limit)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "stand never became ready within %.1fs\n\n| probe | value | ready |\n|---|---|---|\n", result.Limit)
+	for _, pending := range result.Pending {
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", pending.Probe, pending.Value, pending.Ready)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
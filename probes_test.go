@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReady(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		text      string
+		assertion Assertion
+		err       string
+	}{
+		{text: ">= 1", assertion: Assertion{operator: ">=", limit: 1}},
+		{text: "== 0.5", assertion: Assertion{operator: "==", limit: 0.5}},
+		{text: "< 10", assertion: Assertion{operator: "<", limit: 10}},
+		{text: "1", err: `ready "1": expected "<operator> <value>"`},
+		{text: "=> 1", err: "unknown operator =>"},
+		{text: "== up", err: `ready "== up"`},
+	}
+	for n, variant := range variants {
+		assertion, err := parseReady(variant.text)
+		if variant.err != "" {
+			requires.ErrorContains(err, variant.err, n)
+			continue
+		}
+		requires.NoError(err, n)
+		requires.Equal(variant.assertion, assertion, n)
+	}
+	requires.Equal(">= 1", StartupProbe{}.ready())
+}
+
+func TestValidateStartupProbes(t *testing.T) {
+	requires := require.New(t)
+	probe := StartupProbe{Metric: Metric{Name: "api_up", Query: `up{job="api"}`}}
+	requires.NoError(validateStartupProbes(Config{}))
+	requires.NoError(validateStartupProbes(Config{StartupProbes: []StartupProbe{probe}, StartupTimeout: Duration(time.Minute)}))
+	requires.ErrorContains(validateStartupProbes(Config{StartupTimeout: -1}), "startupTimeout must not be negative")
+	requires.ErrorContains(validateStartupProbes(Config{StartupProbes: []StartupProbe{{}}}), "startupProbes: name is not set")
+	requires.ErrorContains(validateStartupProbes(Config{StartupProbes: []StartupProbe{{Metric: probe.Metric, Ready: "up"}}}), "startupProbes: probe api_up: ready")
+	requires.ErrorContains(validateStartupProbes(Config{StartupProbes: []StartupProbe{{Metric: Metric{Name: "x", Type: "bogus"}}}}), "startupProbes: metric x: unknown type bogus")
+	requires.ErrorContains(validateStartupProbes(Config{StartupProbes: []StartupProbe{{Metric: Metric{Name: "x", Query: "up{"}}}}), "startupProbes: metric x: query")
+
+	config, err := App{}.parseConfig([]byte("startupTimeout: 2m\nstartupProbes:\n  - name: api_up\n    query: up{job=\"api\"}\n    ready: \"== 1\"\n"))
+	requires.NoError(err)
+	requires.Equal([]StartupProbe{{Metric: Metric{Name: "api_up", Query: `up{job="api"}`}, Ready: "== 1"}}, config.StartupProbes)
+	requires.Equal(Duration(2*time.Minute), config.StartupTimeout)
+	_, err = App{}.parseConfig([]byte("startupProbes:\n  - name: api_up\n    quer: up\n"))
+	requires.ErrorContains(err, "line 3: unknown key quer, did you mean query?")
+
+	plugin := StartupProbe{Metric: Metric{Name: "db_ready", Type: "plugin", Plugin: []string{"./ready.sh"}}}
+	requires.ErrorContains(checkRemoteConfig(Config{StartupProbes: []StartupProbe{plugin}}), "startupProbes: probe db_ready: plugins are not allowed in posted configs")
+	requires.NoError(checkRemoteConfig(Config{StartupProbes: []StartupProbe{probe}}))
+}
+
+func TestStartupProbesWait(t *testing.T) {
+	requires := require.New(t)
+	probes := &StartupProbes{
+		gathers:    []MetricGather{&SequenceMetricGather{metricName: "up", values: []int{-1, 0, 1}}, ValueMetricGather{metricName: "ready", value: 3}},
+		conditions: []Assertion{{operator: ">=", limit: 1}, {operator: "==", limit: 3}},
+		timeout:    time.Second,
+		interval:   time.Millisecond,
+	}
+	requires.True(probes.wait(context.Background()))
+	requires.True(probes.result().Ready)
+	requires.Less(probes.result().Seconds, 1.0)
+
+	scheduler := &Scheduler{
+		eventer:  &FakeEventer{},
+		progress: NewProgress(time.Now(), time.Minute),
+		probes: &StartupProbes{
+			gathers:    []MetricGather{ValueMetricGather{metricName: "up", value: 0}, ValueMetricGather{metricName: "ready", value: 1}},
+			conditions: []Assertion{{operator: ">=", limit: 1}, {operator: "==", limit: 1}},
+			timeout:    30 * time.Millisecond,
+			interval:   5 * time.Millisecond,
+		},
+		startDelay:   time.Hour,
+		testDuration: time.Hour,
+	}
+	scheduler.run()
+	requires.True(scheduler.notReady)
+	requires.True(scheduler.failed())
+	result := scheduler.probes.result()
+	requires.False(result.Ready)
+	requires.Equal([]StartupPending{{Probe: "up", Value: 0, Ready: ">= 1"}}, result.Pending)
+	requires.Equal(newRunReport(scheduler, nil).Startup, result)
+
+	b, err := renderReport("md", RunReport{Startup: result})
+	requires.NoError(err)
+	requires.Contains(string(b), "## Startup\n\nstand never became ready within 0.0s\n\n| probe | value | ready |\n|---|---|---|\n| up | 0 | >= 1 |\n")
+	requires.Equal("## Startup\n\nready after 1.5s of 60.0s\n\n", renderStartup(&StartupResult{Ready: true, Seconds: 1.5, Limit: 60}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scheduler = &Scheduler{ctx: ctx, probes: &StartupProbes{
+		gathers:    []MetricGather{ValueMetricGather{metricName: "up", value: 0}},
+		conditions: []Assertion{{operator: ">=", limit: 1}},
+		timeout:    time.Minute,
+		interval:   time.Millisecond,
+	}}
+	requires.False(scheduler.waitReady())
+	requires.False(scheduler.notReady)
+	requires.Nil((*StartupProbes)(nil).result())
+}
+
+func TestNewStartupProbes(t *testing.T) {
+	requires := require.New(t)
+	requires.Nil(newStartupProbes(Config{}, Sources{}))
+	probes := newStartupProbes(Config{StartupProbes: []StartupProbe{{Metric: Metric{Name: "up", Query: "up"}, Ready: "== 1"}}}, Sources{})
+	requires.Equal(defaultStartupTimeout, probes.timeout)
+	requires.Equal(defaultProbeInterval, probes.interval)
+	requires.Equal([]Assertion{{operator: "==", limit: 1}}, probes.conditions)
+	requires.Len(probes.gathers, 1)
+	probes = newStartupProbes(Config{StartupTimeout: Duration(time.Minute), Timeout: Duration(5 * time.Second), StartupProbes: []StartupProbe{{Metric: Metric{Name: "up"}}}}, Sources{})
+	requires.Equal(time.Minute, probes.timeout)
+	requires.Equal(5*time.Second, probes.interval)
+}
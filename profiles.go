@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func applyProfile(b []byte, profile string) ([]byte, error) {
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	raw, ok := doc["profiles"]
+	if !ok && profile == "" {
+		return b, nil
+	}
+	delete(doc, "profiles")
+	profiles, ok := raw.(map[string]any)
+	if raw != nil && !ok {
+		return nil, fmt.Errorf("profiles must be a mapping")
+	}
+	if profile == "" {
+		return yaml.Marshal(doc)
+	}
+	chain, err := profileChain(profiles, profile)
+	if err != nil {
+		return nil, err
+	}
+	var merged any = doc
+	for _, overrides := range chain {
+		merged = mergeValues(merged, overrides)
+	}
+	return yaml.Marshal(merged)
+}
+
+func profileChain(profiles map[string]any, profile string) ([]map[string]any, error) {
+	chain := make([]map[string]any, 0)
+	seen := map[string]bool{}
+	for name := profile; name != ""; {
+		if seen[name] {
+			return nil, fmt.Errorf("profile %s: extends cycle", name)
+		}
+		seen[name] = true
+		raw, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %s (available: %s)", name, strings.Join(slices.Sorted(maps.Keys(profiles)), ", "))
+		}
+		overrides, ok := raw.(map[string]any)
+		if raw != nil && !ok {
+			return nil, fmt.Errorf("profile %s must be a mapping", name)
+		}
+		overrides = maps.Clone(overrides)
+		extends, _ := overrides["extends"].(string)
+		delete(overrides, "extends")
+		chain = append([]map[string]any{overrides}, chain...)
+		name = extends
+	}
+	return chain, nil
+}
+
+func namedItems(values []any) bool {
+	for _, value := range values {
+		item, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		if _, ok := item["name"].(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeValues(base any, override any) any {
+	switch override := override.(type) {
+	case map[string]any:
+		baseMap, ok := base.(map[string]any)
+		if !ok {
+			return override
+		}
+		merged := maps.Clone(baseMap)
+		for key, value := range override {
+			merged[key] = mergeValues(baseMap[key], value)
+		}
+		return merged
+	case []any:
+		baseList, ok := base.([]any)
+		if !ok || !namedItems(baseList) || !namedItems(override) {
+			return override
+		}
+		merged := slices.Clone(baseList)
+		for _, value := range override {
+			item := value.(map[string]any)
+			n := slices.IndexFunc(merged, func(existing any) bool {
+				return existing.(map[string]any)["name"] == item["name"]
+			})
+			if n < 0 {
+				merged = append(merged, item)
+			} else {
+				merged[n] = mergeValues(merged[n], item)
+			}
+		}
+		return merged
+	}
+	return override
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const profilesConfig = `
+host: http://localhost:9090
+testDuration: 60
+metrics:
+  - name: errors
+    query: sum(errors)
+    maxValue: 1
+  - name: cpu
+    query: sum(cpu)
+    maxValue: 80
+profiles:
+  staging:
+    host: http://staging:9090
+  perf:
+    extends: staging
+    testDuration: 30m
+    metrics:
+      - name: cpu
+        maxValue: 95
+      - name: latency
+        query: p99
+        maxValue: 500
+  loop:
+    extends: loop
+`
+
+func TestApplyProfile(t *testing.T) {
+	variants := []struct {
+		profile  string
+		host     string
+		duration time.Duration
+		metrics  []Metric
+		err      string
+	}{
+		{"", "http://localhost:9090", time.Minute, []Metric{
+			{Name: "errors", Query: "sum(errors)", MaxValue: 1},
+			{Name: "cpu", Query: "sum(cpu)", MaxValue: 80},
+		}, ""},
+		{"staging", "http://staging:9090", time.Minute, []Metric{
+			{Name: "errors", Query: "sum(errors)", MaxValue: 1},
+			{Name: "cpu", Query: "sum(cpu)", MaxValue: 80},
+		}, ""},
+		{"perf", "http://staging:9090", 30 * time.Minute, []Metric{
+			{Name: "errors", Query: "sum(errors)", MaxValue: 1},
+			{Name: "cpu", Query: "sum(cpu)", MaxValue: 95},
+			{Name: "latency", Query: "p99", MaxValue: 500},
+		}, ""},
+		{"loop", "", 0, nil, "profile loop: extends cycle"},
+		{"prod", "", 0, nil, "unknown profile prod (available: loop, perf, staging)"},
+	}
+	for _, variant := range variants {
+		t.Run(variant.profile, func(t *testing.T) {
+			requires := require.New(t)
+			config, err := App{profile: variant.profile}.parseConfig([]byte(profilesConfig))
+			if variant.err != "" {
+				requires.EqualError(err, variant.err)
+				return
+			}
+			requires.NoError(err)
+			requires.Equal(variant.host, config.Host)
+			requires.Equal(variant.duration, time.Duration(config.TestDuration))
+			requires.Equal(variant.metrics, config.Metrics)
+		})
+	}
+}
+
+func TestApplyProfileWithoutProfiles(t *testing.T) {
+	requires := require.New(t)
+	b := []byte("host: http://localhost:9090\n")
+	out, err := applyProfile(b, "")
+	requires.NoError(err)
+	requires.Equal(b, out)
+	_, err = applyProfile(b, "perf")
+	requires.EqualError(err, "unknown profile perf (available: )")
+}
+
+func TestMergeValues(t *testing.T) {
+	requires := require.New(t)
+	base := map[string]any{
+		"load":      []any{"k6", "run"},
+		"grafana":   map[string]any{"url": "http://grafana", "token": "secret"},
+		"scenarios": []any{map[string]any{"name": "a", "duration": 10}},
+	}
+	merged := mergeValues(base, map[string]any{
+		"load":      []any{"locust"},
+		"grafana":   map[string]any{"url": "http://grafana.perf"},
+		"scenarios": []any{map[string]any{"name": "a", "duration": 20}, map[string]any{"name": "b"}},
+	})
+	requires.Equal(map[string]any{
+		"load":      []any{"locust"},
+		"grafana":   map[string]any{"url": "http://grafana.perf", "token": "secret"},
+		"scenarios": []any{map[string]any{"name": "a", "duration": 20}, map[string]any{"name": "b"}},
+	}, merged)
+	requires.Equal(map[string]any{"url": "http://grafana", "token": "secret"}, base["grafana"])
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+type Progress struct {
+	mutex    sync.Mutex
+	started  time.Time
+	planned  time.Duration
+	ticks    int
+	scenario string
+	failing  bool
+	output   func(line string)
+}
+
+func NewProgress(started time.Time, planned time.Duration) *Progress {
+	return &Progress{started: started, planned: planned, output: func(line string) { log.Println(line) }}
+}
+
+func (progress *Progress) tick() {
+	if progress == nil {
+		return
+	}
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	progress.ticks++
+}
+
+func (progress *Progress) fail() {
+	if progress == nil {
+		return
+	}
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	progress.failing = true
+}
+
+func (progress *Progress) setScenario(name string) {
+	if progress == nil {
+		return
+	}
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	progress.scenario = name
+}
+
+func (progress *Progress) line(now time.Time) string {
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	elapsed := now.Sub(progress.started).Round(time.Second)
+	remaining := max(progress.planned-elapsed, 0).Round(time.Second)
+	state := "passing"
+	if progress.failing {
+		state = "failing"
+	}
+	line := fmt.Sprintf(" progress: elapsed %s remaining %s ticks %d %s", elapsed, remaining, progress.ticks, state)
+	if progress.scenario != "" {
+		line += " scenario " + progress.scenario
+	}
+	return line
+}
+
+func (progress *Progress) start(clock ClockInt, interval time.Duration) func() {
+	clock = clockOf(clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	leave := clock.join()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer leave()
+		for clock.sleep(ctx, interval) {
+			progress.output(progress.line(clock.now()))
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (scheduler *Scheduler) plannedDuration() time.Duration {
+	planned := scheduler.startDelay
+	if scheduler.drain != nil {
+		planned += scheduler.drain.duration
+	}
+	if len(scheduler.scenarios) == 0 {
+		return planned + expectedDuration(Duration(scheduler.testDuration), scheduler.iterations, Duration(scheduler.timeout))
+	}
+	for _, scenario := range scheduler.scenarios {
+		planned += expectedDuration(Duration(scenario.testDuration), scenario.iterations, Duration(scheduler.timeout))
+	}
+	return planned
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressLine(t *testing.T) {
+	requires := require.New(t)
+	started := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	progress := NewProgress(started, 30*time.Minute)
+	progress.tick()
+	progress.tick()
+	requires.Equal(" progress: elapsed 5m0s remaining 25m0s ticks 2 passing", progress.line(started.Add(5*time.Minute+200*time.Millisecond)))
+	progress.setScenario("soak")
+	progress.fail()
+	requires.Equal(" progress: elapsed 40m0s remaining 0s ticks 2 failing scenario soak", progress.line(started.Add(40*time.Minute)))
+	var none *Progress
+	none.tick()
+	none.fail()
+	none.setScenario("a")
+}
+
+func TestSchedulerPlannedDuration(t *testing.T) {
+	requires := require.New(t)
+	requires.Equal(70*time.Second, (&Scheduler{startDelay: 10 * time.Second, testDuration: time.Minute}).plannedDuration())
+	scheduler := Scheduler{startDelay: 10 * time.Second, testDuration: time.Hour,
+		scenarios: []ScenarioRun{{testDuration: time.Minute}, {testDuration: 2 * time.Minute}}}
+	requires.Equal(190*time.Second, scheduler.plannedDuration())
+}
+
+func TestSchedulerHeartbeat(t *testing.T) {
+	requires := require.New(t)
+	var mutex sync.Mutex
+	lines := make([]string, 0)
+	fakeEventer := FakeEventer{}
+	scheduler := Scheduler{eventer: &fakeEventer, testDuration: 350 * time.Millisecond, timeout: 100 * time.Millisecond, heartbeat: 100 * time.Millisecond}
+	progress := NewProgress(time.Now(), time.Second)
+	progress.output = func(line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		lines = append(lines, line)
+	}
+	stop := progress.start(nil, scheduler.heartbeat)
+	scheduler.progress = progress
+	scheduler.loop()
+	stop()
+	requires.GreaterOrEqual(len(lines), 2)
+	requires.Regexp(`^ progress: elapsed \S+ remaining \S+ ticks \d passing$`, lines[len(lines)-1])
+	requires.Equal(fakeEventer.fired, progress.ticks)
+	scheduler.markViolated()
+	requires.Contains(progress.line(time.Now()), "failing")
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+func validatePromQL(config Config) error {
+	metrics := append([]Metric{}, config.Metrics...)
+	for _, scenario := range config.Scenarios {
+		metrics = append(metrics, scenario.Metrics...)
+	}
+	for _, metric := range metrics {
+		if metric.Type != "" && metric.Type != "prometheus" && metric.Type != "histogram" {
+			continue
+		}
+		if _, err := parser.ParseExpr(metric.Query); err != nil {
+			return fmt.Errorf("metric %s: query %q: %w", metric.Name, metric.Query, err)
+		}
+	}
+	return nil
+}
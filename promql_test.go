@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePromQL(t *testing.T) {
+	variants := []struct {
+		config Config
+		err    string
+	}{
+		{config: Config{Metrics: []Metric{{Name: "rps", Query: `sum(rate(http_requests_total{job="api"}[1m]))`}}}},
+		{config: Config{Metrics: []Metric{{Name: "logs", Type: "loki", Query: `count_over_time({app="api"}[1m])`}}}},
+		{config: Config{Metrics: []Metric{{Name: "rps", Query: `sum(rate(http_requests_total[1m])`}}}, err: "metric rps: query"},
+		{config: Config{Metrics: []Metric{{Name: "empty", Type: "prometheus"}}}, err: "metric empty"},
+		{config: Config{Scenarios: []Scenario{{Name: "s", Metrics: []Metric{{Name: "bad", Query: "rate(x[1q])"}}}}}, err: "1:8"},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		err := validatePromQL(variant.config)
+		if variant.err == "" {
+			requires.NoError(err, n)
+			continue
+		}
+		requires.ErrorContains(err, variant.err, n)
+	}
+}
+
+func TestAppPrepareRendersBeforeValidation(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		TestDuration: Duration(1),
+		Vars:         map[string]string{"job": "api"},
+		Metrics:      []Metric{{Name: "up", Query: `up{job="{{ .job }}"}`}},
+	}
+	_, _, err := App{}.prepare(config)
+	requires.NoError(err)
+	config.Metrics[0].Query = `up{job="{{ .job }}"`
+	_, _, err = App{}.prepare(config)
+	requires.ErrorContains(err, "metric up")
+}
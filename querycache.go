@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+type queryCacheKey struct{}
+
+type QueryEntry struct {
+	once     sync.Once
+	value    model.Value
+	warnings v1.Warnings
+	err      error
+}
+
+// QueryCache takes a slot of the QueryLimiter only for a query that is not
+// cached yet.
+type QueryCache struct {
+	mutex   sync.Mutex
+	limiter *QueryLimiter
+	entries map[string]*QueryEntry
+}
+
+// CachedInt is a metric querying through the QueryCache.
+type CachedInt interface {
+	cached()
+}
+
+func withQueryCache(ctx context.Context, limiter *QueryLimiter) context.Context {
+	return context.WithValue(ctx, queryCacheKey{}, &QueryCache{limiter: limiter, entries: map[string]*QueryEntry{}})
+}
+
+func queryCacheFrom(ctx context.Context) *QueryCache {
+	cache, _ := ctx.Value(queryCacheKey{}).(*QueryCache)
+	return cache
+}
+
+func queryKey(host string, query string, offset time.Duration, align time.Duration) string {
+	return strings.Join([]string{host, query, offset.String(), align.String()}, "\x00")
+}
+
+func (cache *QueryCache) query(ctx context.Context, key string, run func() (model.Value, v1.Warnings, error)) (model.Value, v1.Warnings, error) {
+	if cache == nil {
+		return run()
+	}
+	cache.mutex.Lock()
+	entry, ok := cache.entries[key]
+	if !ok {
+		entry = &QueryEntry{}
+		cache.entries[key] = entry
+	}
+	cache.mutex.Unlock()
+	entry.once.Do(func() {
+		release, err := cache.limiter.acquire(ctx)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		defer release()
+		entry.value, entry.warnings, entry.err = run()
+	})
+	return entry.value, entry.warnings, entry.err
+}
+
+func cachedQuery(metric MetricGather) bool {
+	if labeled, ok := metric.(LabeledMetric); ok {
+		metric = labeled.MetricGather
+	}
+	_, ok := metric.(CachedInt)
+	return ok
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCache(t *testing.T) {
+	requires := require.New(t)
+	var calls atomic.Int32
+	run := func() (model.Value, v1.Warnings, error) {
+		calls.Add(1)
+		return &model.Scalar{Value: 7}, v1.Warnings{"slow"}, nil
+	}
+	value, warnings, err := queryCacheFrom(context.Background()).query(context.Background(), "a", run)
+	requires.NoError(err)
+	requires.Equal(&model.Scalar{Value: 7}, value)
+	requires.Equal(v1.Warnings{"slow"}, warnings)
+	_, _, _ = queryCacheFrom(context.Background()).query(context.Background(), "a", run)
+	requires.Equal(int32(2), calls.Load())
+
+	calls.Store(0)
+	cache := queryCacheFrom(withQueryCache(context.Background(), nil))
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _, err := cache.query(context.Background(), "a", run)
+			requires.NoError(err)
+			requires.Equal(&model.Scalar{Value: 7}, value)
+		}()
+	}
+	wg.Wait()
+	requires.Equal(int32(1), calls.Load())
+	_, warnings, _ = cache.query(context.Background(), "a", run)
+	requires.Equal(v1.Warnings{"slow"}, warnings)
+	_, _, err = cache.query(context.Background(), "b", func() (model.Value, v1.Warnings, error) { return nil, nil, errors.New("bad_data") })
+	requires.ErrorContains(err, "bad_data")
+	_, _, err = cache.query(context.Background(), "b", run)
+	requires.ErrorContains(err, "bad_data")
+	requires.Equal(int32(1), calls.Load())
+
+	requires.NotEqual(queryKey("h", "up", 0, 0), queryKey("h", "up", time.Minute, 0))
+	requires.NotEqual(queryKey("h", "up", 0, 0), queryKey("h", "up", 0, time.Minute))
+	requires.NotEqual(queryKey("h", "up", 0, 0), queryKey("other", "up", 0, 0))
+}
+
+func TestGatherSharesQueries(t *testing.T) {
+	requires := require.New(t)
+	mutex := sync.Mutex{}
+	queries := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		queries[r.FormValue("query")]++
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("query") == "sum(rate(errors[1m]))" {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"42"]}]}}`))
+	}))
+	defer server.Close()
+	gathers := newMetricGathers(Sources{host: server.URL}, []Metric{
+		{Name: "rps", Query: "sum(rate(requests[1m]))", MaxValue: 100},
+		{Name: "rps_strict", Query: "sum(rate(requests[1m]))", MaxValue: 10},
+		{Name: "rps_lagged", Query: "sum(rate(requests[1m]))", Offset: Duration(time.Minute), MaxValue: 100},
+		{Name: "errors", Query: "sum(rate(errors[1m]))", MaxValue: 1},
+		{Name: "errors_zero", Query: "sum(rate(errors[1m]))", OnMissing: "treatAsZero", MaxValue: 1},
+	})
+	gatherer := Gatherer{metrics: gathers}
+	values, ok := gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.False(ok)
+	requires.Equal([]int{42, 42, 42, -1, 0}, []int{values.values[0].value, values.values[1].value, values.values[2].value, values.values[3].value, values.values[4].value})
+	requires.Equal(map[string]int{"sum(rate(requests[1m]))": 2, "sum(rate(errors[1m]))": 1}, queries)
+
+	_, _ = gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.Equal(map[string]int{"sum(rate(requests[1m]))": 4, "sum(rate(errors[1m]))": 2}, queries)
+}
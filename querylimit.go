@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/time/rate"
+)
+
+// QueryLimitsConfig are top-level settings of the load of the gatherer on the
+// sources of the stand, so a large catalog doesn't skew the metrics under test.
+type QueryLimitsConfig struct {
+	MaxConcurrentQueries int     `yaml:"maxConcurrentQueries"`
+	QueryRateLimit       float64 `yaml:"queryRateLimit"`
+}
+
+// QueryLimiter is shared by the gatherers of all scenarios: at most
+// maxConcurrentQueries queries at once and queryRateLimit queries per second.
+type QueryLimiter struct {
+	slots   chan struct{}
+	limiter *rate.Limiter
+}
+
+func validateQueryLimits(config QueryLimitsConfig) error {
+	if config.MaxConcurrentQueries < 0 {
+		return errors.New("maxConcurrentQueries must not be negative")
+	}
+	if config.QueryRateLimit < 0 {
+		return errors.New("queryRateLimit must not be negative")
+	}
+	return nil
+}
+
+func newQueryLimiter(config QueryLimitsConfig) *QueryLimiter {
+	if config.MaxConcurrentQueries == 0 && config.QueryRateLimit == 0 {
+		return nil
+	}
+	limiter := &QueryLimiter{}
+	if config.MaxConcurrentQueries > 1 {
+		limiter.slots = make(chan struct{}, config.MaxConcurrentQueries)
+	}
+	if config.QueryRateLimit > 0 {
+		limiter.limiter = rate.NewLimiter(rate.Limit(config.QueryRateLimit), 1)
+	}
+	return limiter
+}
+
+// concurrent tells whether the queries of a tick run at once; without
+// maxConcurrentQueries they run one after another.
+func (limiter *QueryLimiter) concurrent() bool {
+	return limiter != nil && limiter.slots != nil
+}
+
+// acquire waits for a free slot and the rate limit before a query.
+func (limiter *QueryLimiter) acquire(ctx context.Context) (func(), error) {
+	if limiter == nil {
+		return func() {}, nil
+	}
+	release := func() {}
+	if limiter.slots != nil {
+		select {
+		case limiter.slots <- struct{}{}:
+			release = func() { <-limiter.slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if limiter.limiter != nil {
+		if err := limiter.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
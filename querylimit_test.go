@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type SlowMetricGather struct {
+	metric  string
+	running *atomic.Int32
+	peak    *atomic.Int32
+}
+
+func (m SlowMetricGather) name() string  { return m.metric }
+func (m SlowMetricGather) maxValue() int { return 10 }
+
+func (m SlowMetricGather) gather(ctx context.Context) int {
+	running := m.running.Add(1)
+	defer m.running.Add(-1)
+	for {
+		peak := m.peak.Load()
+		if running <= peak || m.peak.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return 1
+}
+
+func slowGatherer(queries *QueryLimiter, count int) (Gatherer, *atomic.Int32) {
+	running, peak := &atomic.Int32{}, &atomic.Int32{}
+	gatherer := Gatherer{queries: queries}
+	for n := range count {
+		gatherer.metrics = append(gatherer.metrics, SlowMetricGather{metric: string(rune('a' + n)), running: running, peak: peak})
+	}
+	return gatherer, peak
+}
+
+func TestQueryLimiter(t *testing.T) {
+	requires := require.New(t)
+	requires.Nil(newQueryLimiter(QueryLimitsConfig{}))
+
+	gatherer, peak := slowGatherer(nil, 4)
+	values, check := gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.True(check)
+	requires.Equal(int32(1), peak.Load())
+	requires.Equal([]string{"a", "b", "c", "d"}, []string{values.values[0].name, values.values[1].name, values.values[2].name, values.values[3].name})
+
+	shared := newQueryLimiter(QueryLimitsConfig{MaxConcurrentQueries: 2})
+	first, peak := slowGatherer(shared, 6)
+	second := Gatherer{queries: shared, metrics: first.metrics}
+	done := make(chan MetricValues)
+	go func() {
+		values, _ := second.gatherAndCheck(context.Background(), time.Now())
+		done <- values
+	}()
+	values, _ = first.gatherAndCheck(context.Background(), time.Now())
+	requires.Len((<-done).values, 6)
+	requires.Equal(int32(2), peak.Load())
+	requires.Equal("f", values.values[5].name)
+	requires.Equal(1, values.values[5].value)
+
+	limited, _ := slowGatherer(newQueryLimiter(QueryLimitsConfig{QueryRateLimit: 20}), 4)
+	start := time.Now()
+	_, _ = limited.gatherAndCheck(context.Background(), start)
+	requires.GreaterOrEqual(time.Since(start), 140*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	values, _ = limited.gatherAndCheck(ctx, time.Now())
+	requires.Equal(-1, values.values[0].value)
+}
+
+func TestQueryLimiterSources(t *testing.T) {
+	requires := require.New(t)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/query_range" {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1,"3"]}}`))
+	}))
+	defer server.Close()
+	limited := func() Sources {
+		return Sources{host: server.URL, queries: newQueryLimiter(QueryLimitsConfig{QueryRateLimit: 5})}
+	}
+
+	sources := limited()
+	gatherer := Gatherer{queries: sources.queries, metrics: []MetricGather{
+		PrometheusMetric{Host: server.URL, Name: "a", Query: "up"}, PrometheusMetric{Host: server.URL, Name: "b", Query: "up"},
+		PrometheusMetric{Host: server.URL, Name: "c", Query: "up"}, PrometheusMetric{Host: server.URL, Name: "d", Query: "rps"},
+	}}
+	start := time.Now()
+	values, _ := gatherer.gatherAndCheck(context.Background(), start)
+	requires.Equal(int32(2), requests.Load())
+	requires.Equal(3, values.values[2].value)
+	requires.Less(time.Since(start), 500*time.Millisecond)
+
+	requests.Store(0)
+	config := Config{Ranges: RangesConfig{Enabled: true}, Metrics: []Metric{
+		{Name: "a", Query: "a", MaxValueQuery: "limit_a"}, {Name: "b", Query: "b", MaxValueQuery: "limit_b"}, {Name: "c", Query: "c", MaxValueQuery: "limit_c"},
+	}}
+	start = time.Now()
+	requires.True(newMaxValueQueries(config, limited()).resolve(context.Background()))
+	requires.GreaterOrEqual(time.Since(start), 400*time.Millisecond)
+
+	start = time.Now()
+	newRangeQueries(config, limited()).query(context.Background(), []RangeWindow{{start: start.Add(-time.Minute), end: start}})
+	requires.GreaterOrEqual(time.Since(start), 400*time.Millisecond)
+	requires.Equal(int32(6), requests.Load())
+}
+
+func TestQueryLimitsConfig(t *testing.T) {
+	requires := require.New(t)
+	config := ConfigYAML{}
+	requires.NoError(decodeStrict([]byte("maxConcurrentQueries: 4\nqueryRateLimit: 2.5\n"), &config))
+	requires.Equal(QueryLimitsConfig{MaxConcurrentQueries: 4, QueryRateLimit: 2.5}, config.QueryLimits)
+	requires.NoError(validateQueryLimits(config.QueryLimits))
+	requires.ErrorContains(validateQueryLimits(QueryLimitsConfig{MaxConcurrentQueries: -1}), "maxConcurrentQueries must not be negative")
+	requires.ErrorContains(validateQueryLimits(QueryLimitsConfig{QueryRateLimit: -1}), "queryRateLimit must not be negative")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var rabbitMQStats = []string{"", "messages", "messages_ready", "messages_unacknowledged", "consumers", "publish_rate", "deliver_rate"}
+
+type RabbitMQConfig struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	VHost    string `yaml:"vhost"`
+}
+
+type RabbitMQClient struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+	vhost    string
+}
+
+func NewRabbitMQClient(config RabbitMQConfig) *RabbitMQClient {
+	vhost := config.VHost
+	if vhost == "" {
+		vhost = "/"
+	}
+	username, password := config.Username, config.Password
+	if username == "" {
+		username, password = "guest", "guest"
+	}
+	return &RabbitMQClient{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		url:      strings.TrimSuffix(config.URL, "/"),
+		username: username,
+		password: password,
+		vhost:    vhost,
+	}
+}
+
+type RabbitMQRate struct {
+	Rate float64 `json:"rate"`
+}
+
+type RabbitMQQueue struct {
+	Messages               float64 `json:"messages"`
+	MessagesReady          float64 `json:"messages_ready"`
+	MessagesUnacknowledged float64 `json:"messages_unacknowledged"`
+	Consumers              float64 `json:"consumers"`
+	MessageStats           struct {
+		PublishDetails    RabbitMQRate `json:"publish_details"`
+		DeliverGetDetails RabbitMQRate `json:"deliver_get_details"`
+	} `json:"message_stats"`
+}
+
+func (queue RabbitMQQueue) value(stat string) (float64, error) {
+	switch stat {
+	case "", "messages":
+		return queue.Messages, nil
+	case "messages_ready":
+		return queue.MessagesReady, nil
+	case "messages_unacknowledged":
+		return queue.MessagesUnacknowledged, nil
+	case "consumers":
+		return queue.Consumers, nil
+	case "publish_rate":
+		return queue.MessageStats.PublishDetails.Rate, nil
+	case "deliver_rate":
+		return queue.MessageStats.DeliverGetDetails.Rate, nil
+	}
+	return 0, fmt.Errorf("unknown rabbitmq stat %s", stat)
+}
+
+var errNoRabbitMQQueue = errors.New("no such queue")
+
+func (client *RabbitMQClient) queue(ctx context.Context, name string) (RabbitMQQueue, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	path := "/api/queues/" + url.PathEscape(client.vhost) + "/" + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.url+path, nil)
+	if err != nil {
+		return RabbitMQQueue{}, err
+	}
+	req.SetBasicAuth(client.username, client.password)
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return RabbitMQQueue{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return RabbitMQQueue{}, errNoRabbitMQQueue
+	}
+	if resp.StatusCode != http.StatusOK {
+		return RabbitMQQueue{}, fmt.Errorf("status %s", resp.Status)
+	}
+	queue := RabbitMQQueue{}
+	if err := json.NewDecoder(resp.Body).Decode(&queue); err != nil {
+		return RabbitMQQueue{}, err
+	}
+	return queue, nil
+}
+
+type RabbitMQMetric struct {
+	client    *RabbitMQClient
+	Name      string
+	Queue     string
+	Stat      string
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric RabbitMQMetric) name() string {
+	return metric.Name
+}
+
+func (metric RabbitMQMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric RabbitMQMetric) gather(ctx context.Context) int {
+	queue, err := metric.client.queue(ctx, metric.Queue)
+	if errors.Is(err, errNoRabbitMQQueue) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("Error querying RabbitMQ: %v\n", err)
+		return -1
+	}
+	value, err := queue.value(metric.Stat)
+	if err != nil {
+		log.Println("WARNING:", err)
+		return -1
+	}
+	return int(value)
+}
+
+func validateRabbitMQMetric(config Config, metric Metric) error {
+	if config.RabbitMQ.URL == "" {
+		return fmt.Errorf("rabbitmq.url is not set")
+	}
+	if metric.Queue == "" {
+		return fmt.Errorf("queue is not set")
+	}
+	if !slices.Contains(rabbitMQStats, metric.Query) {
+		return fmt.Errorf("unknown rabbitmq stat %s", metric.Query)
+	}
+	return nil
+}
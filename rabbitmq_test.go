@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const rabbitMQQueueJSON = `{
+	"name": "orders", "vhost": "/", "messages": 42, "messages_ready": 40, "messages_unacknowledged": 2, "consumers": 3,
+	"message_stats": {"publish_details": {"rate": 12.5}, "deliver_get_details": {"rate": 11.2}}
+}`
+
+func rabbitMQServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if username != "perf" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.EscapedPath() != "/api/queues/%2F/orders" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(rabbitMQQueueJSON))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRabbitMQMetric(t *testing.T) {
+	server := rabbitMQServer(t)
+	variants := []struct {
+		queue     string
+		stat      string
+		password  string
+		onMissing string
+		value     int
+	}{
+		{queue: "orders", value: 42},
+		{queue: "orders", stat: "messages_ready", value: 40},
+		{queue: "orders", stat: "messages_unacknowledged", value: 2},
+		{queue: "orders", stat: "consumers", value: 3},
+		{queue: "orders", stat: "publish_rate", value: 12},
+		{queue: "orders", stat: "deliver_rate", value: 11},
+		{queue: "orders", stat: "unknown", value: -1},
+		{queue: "payments", value: -1},
+		{queue: "payments", onMissing: "treatAsZero", value: 0},
+		{queue: "orders", password: "wrong", value: -1},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		password := variant.password
+		if password == "" {
+			password = "secret"
+		}
+		sources := Sources{rabbit: NewRabbitMQClient(RabbitMQConfig{URL: server.URL + "/", Username: "perf", Password: password})}
+		gathers := newMetricGathers(sources, []Metric{{Name: "depth", Type: "rabbitmq", Queue: variant.queue, Query: variant.stat, OnMissing: variant.onMissing}})
+		requires.Equal(variant.value, gathers[0].gather(context.Background()), n)
+	}
+}
+
+func TestValidateRabbitMQMetric(t *testing.T) {
+	requires := require.New(t)
+	config := Config{RabbitMQ: RabbitMQConfig{URL: "http://localhost:15672"}}
+	requires.NoError(validateRabbitMQMetric(config, Metric{Queue: "orders", Query: "consumers"}))
+	requires.ErrorContains(validateRabbitMQMetric(Config{}, Metric{Queue: "orders"}), "rabbitmq.url is not set")
+	requires.ErrorContains(validateRabbitMQMetric(config, Metric{}), "queue is not set")
+	requires.ErrorContains(validateRabbitMQMetric(config, Metric{Queue: "orders", Query: "bytes"}), "unknown rabbitmq stat bytes")
+
+	config.Metrics = []Metric{{Name: "depth", Type: "rabbitmq"}}
+	requires.ErrorContains(validateMetricTypes(config), "metric depth: queue is not set")
+
+	redactedConfig := redactConfig(Config{RabbitMQ: RabbitMQConfig{Password: "secret"}})
+	requires.Equal(redacted, redactedConfig.RabbitMQ.Password)
+}
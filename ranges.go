@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	defaultRangeStep = 5 * time.Second
+	// maxRangePoints stays below the 11000 points per series Prometheus allows.
+	maxRangePoints = 10000
+)
+
+type RangesConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Step    Duration `yaml:"step"`
+}
+
+type RangeWindow struct {
+	scenario string
+	start    time.Time
+	end      time.Time
+}
+
+type RangeSeries struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Points [][2]float64      `json:"points"`
+}
+
+// RangeResult is the series of a metric's query over a scenario, points are
+// [unix seconds, value].
+type RangeResult struct {
+	Scenario string        `json:"scenario,omitempty"`
+	Metric   string        `json:"metric"`
+	Query    string        `json:"query"`
+	Step     string        `json:"step"`
+	Series   []RangeSeries `json:"series"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type RangeQuery struct {
+	metric string
+	query  string
+	offset time.Duration
+}
+
+// RangeQueries runs the query of every Prometheus metric over the window of
+// each scenario once gathering is done, before the stand is torn down.
+type RangeQueries struct {
+	host    string
+	headers http.Header
+	step    time.Duration
+	queries map[string][]RangeQuery
+	limiter *QueryLimiter
+	results []RangeResult
+}
+
+func isPrometheusMetric(metric Metric) bool {
+	return metric.Type == "" || metric.Type == "prometheus"
+}
+
+func validateRanges(config Config) error {
+	if config.Ranges.Step < 0 {
+		return errors.New("ranges.step must not be negative")
+	}
+	metrics := append([]Metric{}, config.Metrics...)
+	for _, scenario := range config.Scenarios {
+		metrics = append(metrics, scenario.Metrics...)
+	}
+	for _, metric := range metrics {
+		if len(metric.RangeAssertions) > 0 && !isPrometheusMetric(metric) {
+			return fmt.Errorf("metric %s: rangeAssertions need a prometheus metric", metric.Name)
+		}
+	}
+	return nil
+}
+
+func newRangeQueries(config Config, sources Sources) *RangeQueries {
+	step := time.Duration(config.Ranges.Step)
+	if step <= 0 {
+		step = defaultRangeStep
+	}
+	ranges := &RangeQueries{host: sources.host, headers: sources.headers, step: step, queries: map[string][]RangeQuery{}, limiter: sources.queries}
+	add := func(scenario string, metrics []Metric) {
+		for _, metric := range metrics {
+			if isPrometheusMetric(metric) && (config.Ranges.Enabled || len(metric.RangeAssertions) > 0) {
+				ranges.queries[scenario] = append(ranges.queries[scenario],
+					RangeQuery{metric: metric.Name, query: metric.Query, offset: time.Duration(metric.Offset)})
+			}
+		}
+	}
+	if len(config.Scenarios) == 0 {
+		add("", config.Metrics)
+	}
+	for _, scenario := range config.Scenarios {
+		add(scenario.Name, mergeMetrics(config.Metrics, scenario.Metrics))
+	}
+	if len(ranges.queries) == 0 {
+		return nil
+	}
+	return ranges
+}
+
+func (ranges *RangeQueries) stepFor(window RangeWindow) time.Duration {
+	step := ranges.step
+	if minimum := window.end.Sub(window.start) / maxRangePoints; step < minimum {
+		step = minimum.Truncate(time.Second) + time.Second
+	}
+	return step
+}
+
+func (ranges *RangeQueries) query(ctx context.Context, windows []RangeWindow) {
+	if ranges == nil {
+		return
+	}
+	client, err := api.NewClient(api.Config{
+		Address:      ranges.host,
+		RoundTripper: HeaderRoundTripper{headers: ranges.headers, next: api.DefaultRoundTripper},
+	})
+	if err != nil {
+		log.Println("range query error:", err)
+		return
+	}
+	v1api := v1.NewAPI(client)
+	for _, window := range windows {
+		step := ranges.stepFor(window)
+		for _, query := range ranges.queries[window.scenario] {
+			result := RangeResult{Scenario: window.scenario, Metric: query.metric, Query: query.query, Step: step.String(), Series: []RangeSeries{}}
+			queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			value, warnings, err := ranges.queryRange(queryCtx, v1api, query, window, step)
+			cancel()
+			addQueryWarnings(ctx, query.metric, warnings)
+			result.Warnings = warnings
+			if err == nil {
+				result.Series, err = rangeSeries(value)
+			}
+			if err != nil {
+				log.Printf("WARNING: range query of metric %s: %v\n", query.metric, err)
+				result.Error = err.Error()
+			}
+			ranges.results = append(ranges.results, result)
+		}
+	}
+}
+
+func (ranges *RangeQueries) queryRange(ctx context.Context, v1api v1.API, query RangeQuery, window RangeWindow, step time.Duration) (model.Value, v1.Warnings, error) {
+	release, err := ranges.limiter.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return v1api.QueryRange(ctx, query.query, v1.Range{
+		Start: window.start.Add(-query.offset), End: window.end.Add(-query.offset), Step: step,
+	})
+}
+
+func rangeSeries(value model.Value) ([]RangeSeries, error) {
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type %s", value.Type())
+	}
+	series := make([]RangeSeries, 0, len(matrix))
+	for _, stream := range matrix {
+		points := make([][2]float64, 0, len(stream.Values))
+		for _, pair := range stream.Values {
+			if v := float64(pair.Value); !math.IsNaN(v) && !math.IsInf(v, 0) {
+				points = append(points, [2]float64{float64(pair.Timestamp.Unix()), v})
+			}
+		}
+		series = append(series, RangeSeries{Labels: seriesLabels(stream.Metric), Points: points})
+	}
+	return series, nil
+}
+
+// samples are the points of every series of the metric in the scenario, the
+// samples of range assertions.
+func (ranges *RangeQueries) samples(scenario string, metric string) []float64 {
+	samples := make([]float64, 0)
+	if ranges == nil {
+		return samples
+	}
+	for _, result := range ranges.results {
+		if result.Scenario != scenario || result.Metric != metric {
+			continue
+		}
+		for _, series := range result.Series {
+			for _, point := range series.Points {
+				samples = append(samples, point[1])
+			}
+		}
+	}
+	return samples
+}
+
+func (ranges *RangeQueries) result() []RangeResult {
+	if ranges == nil {
+		return nil
+	}
+	return ranges.results
+}
+
+func renderRanges(results []RangeResult) string {
+	b := strings.Builder{}
+	b.WriteString("## Ranges\n\n| scenario | metric | step | series | points | max |\n|---|---|---|---|---|---|\n")
+	for _, result := range results {
+		if result.Error != "" {
+			fmt.Fprintf(&b, "| %s | %s | %s | error: %s | | |\n", result.Scenario, result.Metric, result.Step, result.Error)
+			continue
+		}
+		points, peak := 0, math.Inf(-1)
+		for _, series := range result.Series {
+			for _, point := range series.Points {
+				points++
+				peak = math.Max(peak, point[1])
+			}
+		}
+		if points == 0 {
+			fmt.Fprintf(&b, "| %s | %s | %s | %d | 0 | |\n", result.Scenario, result.Metric, result.Step, len(result.Series))
+			continue
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %d | %d | %g |\n", result.Scenario, result.Metric, result.Step, len(result.Series), points, peak)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func splitRanged(assertions []RunAssertion) ([]RunAssertion, []RunAssertion) {
+	sampled, ranged := make([]RunAssertion, 0, len(assertions)), make([]RunAssertion, 0)
+	for _, assertion := range assertions {
+		if assertion.ranged {
+			ranged = append(ranged, assertion)
+		} else {
+			sampled = append(sampled, assertion)
+		}
+	}
+	return sampled, ranged
+}
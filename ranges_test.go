@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func rangeServer(t *testing.T, steps *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/query_range", r.URL.Path)
+		*steps = append(*steps, r.FormValue("step"))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.FormValue("query") {
+		case "latency":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` +
+				`{"metric":{"pod":"a"},"values":[[100,"120"],[105,"950"],[110,"130"]]},` +
+				`{"metric":{"pod":"b"},"values":[[100,"110"],[105,"NaN"]]}]}}`))
+		case "broken":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}
+	}))
+}
+
+func TestRangeQueries(t *testing.T) {
+	requires := require.New(t)
+	steps := []string{}
+	server := rangeServer(t, &steps)
+	defer server.Close()
+
+	config := Config{
+		Metrics: []Metric{
+			{Name: "latency", Query: "latency", RangeAssertions: []string{"max <= 500", "p50 <= 200"}},
+			{Name: "errors", Query: "errors"},
+			{Name: "broken", Query: "broken"},
+			{Name: "depth", Type: "rabbitmq", Queue: "orders"},
+		},
+	}
+	requires.Nil(newRangeQueries(Config{Metrics: config.Metrics[1:]}, Sources{host: server.URL}))
+	config.Ranges = RangesConfig{Enabled: true, Step: Duration(10 * time.Second)}
+	ranges := newRangeQueries(config, Sources{host: server.URL})
+	requires.Len(ranges.queries[""], 3)
+
+	start := time.Unix(100, 0)
+	ranges.query(context.Background(), []RangeWindow{{start: start, end: start.Add(10 * time.Second)}})
+	requires.Equal([]string{"10", "10", "10"}, steps)
+	results := ranges.result()
+	requires.Equal([]RangeSeries{
+		{Labels: map[string]string{"pod": "a"}, Points: [][2]float64{{100, 120}, {105, 950}, {110, 130}}},
+		{Labels: map[string]string{"pod": "b"}, Points: [][2]float64{{100, 110}}},
+	}, results[0].Series)
+	requires.Equal("10s", results[0].Step)
+	requires.Empty(results[1].Series)
+	requires.Contains(results[2].Error, "parse error")
+	requires.Equal([]float64{120, 950, 130, 110}, ranges.samples("", "latency"))
+
+	assertions, err := collectAssertions(config)
+	requires.NoError(err)
+	scheduler := &Scheduler{assertions: assertions, ranges: ranges}
+	scheduler.checkAssertions(&Reporter{})
+	requires.True(scheduler.assertsFailed)
+	requires.Equal([]string{" FAIL latency range max <= 500 actual 950\n", " ok   latency range p50 <= 200 actual 120\n"},
+		assertionLines(scheduler.results))
+	report := newRunReport(scheduler, nil)
+	requires.True(report.Assertions[0].Range)
+	requires.Len(report.Ranges, 3)
+	requires.Contains(string(renderMarkdown(report)), "## Ranges\n\n| scenario | metric | step | series | points | max |\n|---|---|---|---|---|---|\n"+
+		"|  | latency | 10s | 2 | 4 | 950 |\n|  | errors | 10s | 0 | 0 | |\n")
+
+	long := RangeWindow{start: start, end: start.Add(48 * time.Hour)}
+	requires.Equal(18*time.Second, ranges.stepFor(long))
+}
+
+func TestValidateRanges(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateRanges(Config{Metrics: []Metric{{Name: "latency", RangeAssertions: []string{"p99 <= 800"}}}}))
+	requires.ErrorContains(validateRanges(Config{Scenarios: []Scenario{{Name: "peak", Metrics: []Metric{{Name: "depth", Type: "rabbitmq", RangeAssertions: []string{"max <= 1"}}}}}}),
+		"metric depth: rangeAssertions need a prometheus metric")
+	requires.ErrorContains(validateRanges(Config{Ranges: RangesConfig{Step: -1}}), "ranges.step must not be negative")
+	_, err := collectAssertions(Config{Metrics: []Metric{{Name: "latency", RangeAssertions: []string{"p99 800"}}}})
+	requires.ErrorContains(err, "metric latency: rangeAssertions: assertion")
+
+	sampled, ranged := splitRanged([]RunAssertion{{metric: "a"}, {metric: "b", ranged: true}})
+	requires.Equal([]RunAssertion{{metric: "a"}}, sampled)
+	requires.Equal([]RunAssertion{{metric: "b", ranged: true}}, ranged)
+}
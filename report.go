@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+type AssertionJSON struct {
+	Scenario  string  `json:"scenario,omitempty"`
+	Metric    string  `json:"metric"`
+	Assertion string  `json:"assertion"`
+	Value     float64 `json:"value"`
+	Ok        bool    `json:"ok"`
+	NoData    bool    `json:"noData,omitempty"`
+	Budget    bool    `json:"budget,omitempty"`
+	Range     bool    `json:"range,omitempty"`
+}
+
+type RunReport struct {
+	RunID      string             `json:"runId"`
+	Finished   time.Time          `json:"finished"`
+	Passed     bool               `json:"passed"`
+	Values     []MetricValuesJSON `json:"values"`
+	Summary    []MetricSummary    `json:"summary,omitempty"`
+	Assertions []AssertionJSON    `json:"assertions,omitempty"`
+	Artifacts  string             `json:"artifacts,omitempty"`
+	Stalls     []Stall            `json:"stalls,omitempty"`
+	Snapshot   *StandSnapshot     `json:"snapshot,omitempty"`
+	Episodes   []Episode          `json:"episodes,omitempty"`
+	Baselines  []Baseline         `json:"baselines,omitempty"`
+	StandStart *StandStart        `json:"standStart,omitempty"`
+	Startup    *StartupResult     `json:"startup,omitempty"`
+	Drain      *DrainResult       `json:"drain,omitempty"`
+	Trends     []TrendResult      `json:"trends,omitempty"`
+	Limits     []QueryLimit       `json:"limits,omitempty"`
+	Ranges     []RangeResult      `json:"ranges,omitempty"`
+	Exclusions []ExclusionWindow  `json:"exclusions,omitempty"`
+	Spool      string             `json:"spool,omitempty"`
+}
+
+func newRunReport(scheduler *Scheduler, values []MetricValues) RunReport {
+	report := RunReport{
+		RunID:    scheduler.runID,
+		Finished: time.Now(),
+		Passed:   !scheduler.failed(),
+		Values:   make([]MetricValuesJSON, 0),
+	}
+	for _, value := range values {
+		report.Values = append(report.Values, metricValuesJSON(value))
+	}
+	report.Summary = summarize(values)
+	report.Stalls = scheduler.stalls
+	report.Episodes = violationEpisodes(values, scheduler.recoverTicks)
+	report.Baselines = scheduler.anomalies.baselines()
+	report.StandStart = scheduler.start
+	report.Startup = scheduler.probes.result()
+	report.Drain = scheduler.drain.result()
+	report.Trends = scheduler.trends
+	report.Limits = scheduler.limits.result()
+	report.Ranges = scheduler.ranges.result()
+	report.Exclusions = scheduler.exclusions.result()
+	for _, result := range scheduler.results {
+		report.Assertions = append(report.Assertions, AssertionJSON{
+			Scenario:  result.scenario,
+			Metric:    result.metric,
+			Assertion: result.assertion.String(),
+			Value:     result.value,
+			Ok:        result.ok,
+			NoData:    result.noData,
+			Budget:    result.budget,
+			Range:     result.ranged,
+		})
+	}
+	return report
+}
+
+func saveRunReport(fileName string, report RunReport) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, b, 0o644)
+}
+
+func loadRunReport(fileName string) (RunReport, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return RunReport{}, err
+	}
+	report := RunReport{}
+	if err := json.Unmarshal(b, &report); err != nil {
+		return RunReport{}, err
+	}
+	return report, nil
+}
+
+var reportFormats = []string{"json", "csv", "md", "html"}
+
+func renderReport(format string, report RunReport) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(report, "", "  ")
+	case "csv":
+		return renderCSV(report)
+	case "md":
+		return renderMarkdown(report), nil
+	case "html":
+		return renderHTML(report)
+	}
+	return nil, fmt.Errorf("unknown report format %s", format)
+}
+
+func isViolation(values MetricValuesJSON, name string) bool {
+	for _, violation := range values.Violations {
+		if violation.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var csvHeader = []string{"timestamp", "scenario", "metric", "labels", "value", "violation", "series"}
+
+func renderCSV(report RunReport) ([]byte, error) {
+	var b bytes.Buffer
+	writer := csv.NewWriter(&b)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, values := range report.Values {
+		for _, record := range csvRecords(values) {
+			if err := writer.Write(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+	writer.Flush()
+	return b.Bytes(), writer.Error()
+}
+
+func reportResult(report RunReport) string {
+	if report.Passed {
+		return "passed"
+	}
+	return "failed"
+}
+
+func renderMarkdown(report RunReport) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Run %s\n\nResult: **%s**\n\n", report.RunID, reportResult(report))
+	if len(report.Assertions) > 0 {
+		b.WriteString("## Assertions\n\n| scenario | metric | assertion | value | result |\n|---|---|---|---|---|\n")
+		for _, assertion := range report.Assertions {
+			result := "ok"
+			if !assertion.Ok {
+				result = "FAIL"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %g | %s |\n", assertion.Scenario, assertion.Metric, assertion.Assertion, assertion.Value, result)
+		}
+		b.WriteString("\n")
+	}
+	if len(report.Episodes) > 0 {
+		b.WriteString("## Violation episodes\n\n| metric | start | end | violated ticks | peak | series |\n|---|---|---|---|---|---|\n")
+		for _, episode := range report.Episodes {
+			end := "not recovered"
+			if episode.Recovered {
+				end = episode.End.Format(time.RFC3339)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %d | %d | %s |\n", teamCityKey(episode.Scenario, episode.Metric),
+				episode.Start.Format(time.RFC3339), end, episode.Ticks, episode.Peak, formatLabels(episode.Series))
+		}
+		b.WriteString("\n")
+	}
+	if report.StandStart != nil {
+		b.WriteString(renderStandStart(report.StandStart))
+	}
+	if report.Startup != nil {
+		b.WriteString(renderStartup(report.Startup))
+	}
+	if report.Drain != nil {
+		b.WriteString(renderDrain(report.Drain))
+	}
+	if len(report.Trends) > 0 {
+		b.WriteString(renderTrends(report.Trends))
+	}
+	if len(report.Exclusions) > 0 {
+		b.WriteString(renderExclusions(report.Exclusions))
+	}
+	if len(report.Ranges) > 0 {
+		b.WriteString(renderRanges(report.Ranges))
+	}
+	if len(report.Baselines) > 0 {
+		b.WriteString("## Anomaly baselines\n\n| metric | samples | mean | stddev |\n|---|---|---|---|\n")
+		for _, baseline := range report.Baselines {
+			fmt.Fprintf(&b, "| %s | %d | %.2f | %.2f |\n", baseline.Metric, baseline.Samples, baseline.Mean, baseline.Stddev)
+		}
+		b.WriteString("\n")
+	}
+	if len(report.Stalls) > 0 {
+		b.WriteString("## Stalls\n\n| scenario | started | seconds | aborted |\n|---|---|---|---|\n")
+		for _, stall := range report.Stalls {
+			fmt.Fprintf(&b, "| %s | %s | %.1f | %t |\n", stall.Scenario, stall.Started.Format(time.RFC3339), stall.Seconds, stall.Aborted)
+		}
+		b.WriteString("\n")
+	}
+	if report.Snapshot != nil {
+		b.WriteString("## Stand at teardown\n\n| service | container | image | status | exit code | cpu | memory |\n|---|---|---|---|---|---|---|\n")
+		for _, service := range report.Snapshot.Services {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %d | %s | %s |\n", teamCityKey(service.Environment, service.Service), service.Container,
+				service.Image, service.Status, service.ExitCode, service.CPU, service.Memory)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("## Summary\n\n")
+	writeSummaryTable(&b, reportSummaries(report))
+	b.WriteString("\n## Values\n\n| timestamp | scenario | metric | labels | value | series |\n|---|---|---|---|---|---|\n")
+	for _, values := range report.Values {
+		for _, value := range values.Values {
+			mark := ""
+			if isViolation(values, value.Name) {
+				mark = " **!**"
+			}
+			if value.Excluded {
+				mark = " (excluded)"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %d%s | %s |\n", values.Timestamp.Format(time.RFC3339), values.Scenario, value.Name,
+				formatLabels(value.Labels), value.Value, mark, formatLabels(value.Series))
+		}
+	}
+	return b.Bytes()
+}
+
+type ValueRow struct {
+	Timestamp time.Time
+	Scenario  string
+	Name      string
+	Value     int
+	Series    string
+	Violation bool
+}
+
+type ValueGroup struct {
+	Labels string
+	Rows   []ValueRow
+}
+
+func labelGroups(report RunReport) []ValueGroup {
+	groups := make([]ValueGroup, 0)
+	index := map[string]int{}
+	for _, values := range report.Values {
+		for _, value := range values.Values {
+			labels := formatLabels(value.Labels)
+			n, ok := index[labels]
+			if !ok {
+				n = len(groups)
+				index[labels] = n
+				groups = append(groups, ValueGroup{Labels: labels})
+			}
+			groups[n].Rows = append(groups[n].Rows, ValueRow{
+				Timestamp: values.Timestamp,
+				Scenario:  values.Scenario,
+				Name:      value.Name,
+				Value:     value.Value,
+				Series:    formatLabels(value.Series),
+				Violation: isViolation(values, value.Name),
+			})
+		}
+	}
+	return groups
+}
+
+var reportFuncs = map[string]any{
+	"result": reportResult,
+	"time":   func(t time.Time) string { return t.Format(time.RFC3339) },
+}
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Run {{ .RunID }}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; }
+.fail { background: #f8d0d0; }
+</style>
+</head>
+<body>
+<h1>Run {{ .RunID }}</h1>
+<p>Result: <b>{{ result .RunReport }}</b></p>
+{{- if .Assertions }}
+<h2>Assertions</h2>
+<table>
+<tr><th>scenario</th><th>metric</th><th>assertion</th><th>value</th></tr>
+{{- range .Assertions }}
+<tr{{ if not .Ok }} class="fail"{{ end }}><td>{{ .Scenario }}</td><td>{{ .Metric }}</td><td>{{ .Assertion }}</td><td>{{ .Value }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- range .Groups }}
+<h2>Values{{ if .Labels }} {{ .Labels }}{{ end }}</h2>
+<table>
+<tr><th>timestamp</th><th>scenario</th><th>metric</th><th>value</th><th>series</th></tr>
+{{- range .Rows }}
+<tr{{ if .Violation }} class="fail"{{ end }}><td>{{ time .Timestamp }}</td><td>{{ .Scenario }}</td><td>{{ .Name }}</td><td>{{ .Value }}</td><td>{{ .Series }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
+
+type ReportData struct {
+	RunReport
+	Groups []ValueGroup
+}
+
+func reportData(report RunReport) ReportData {
+	return ReportData{RunReport: report, Groups: labelGroups(report)}
+}
+
+func renderHTML(report RunReport) ([]byte, error) {
+	var b bytes.Buffer
+	if err := htmlReport.Execute(&b, reportData(report)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+type TemplateInt interface {
+	Execute(w io.Writer, data any) error
+}
+
+// parseTemplate parses a user template file for the data of the html report;
+// html output is escaped by html/template, md and csv are not.
+func parseTemplate(format string, fileName string) (TemplateInt, error) {
+	switch format {
+	case "html":
+		return htmltemplate.New(filepath.Base(fileName)).Funcs(reportFuncs).ParseFiles(fileName)
+	case "md", "csv":
+		return template.New(filepath.Base(fileName)).Funcs(reportFuncs).ParseFiles(fileName)
+	}
+	return nil, fmt.Errorf("format %s does not support templates", format)
+}
+
+func renderTemplate(format string, fileName string, report RunReport) ([]byte, error) {
+	tmpl, err := parseTemplate(format, fileName)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, reportData(report)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
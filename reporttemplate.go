@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+type ReportConfig struct {
+	Template string `yaml:"template"`
+	Output   string `yaml:"output"`
+}
+
+func (config ReportConfig) path(workDir string) string {
+	if filepath.IsAbs(config.Template) {
+		return config.Template
+	}
+	return filepath.Join(workDir, config.Template)
+}
+
+// output names the rendered file in the run directory: report.output or the
+// template name without .tmpl, e.g. team.html.tmpl gives team.html.
+func (config ReportConfig) output() string {
+	if config.Output != "" {
+		return config.Output
+	}
+	return strings.TrimSuffix(filepath.Base(config.Template), ".tmpl")
+}
+
+func (config ReportConfig) format() string {
+	switch strings.ToLower(filepath.Ext(config.output())) {
+	case ".html", ".htm":
+		return "html"
+	}
+	return "md"
+}
+
+func validateReport(config Config) error {
+	report := config.Report
+	if report.Template == "" {
+		if report.Output != "" {
+			return errors.New("report.output needs report.template")
+		}
+		return nil
+	}
+	if output := report.output(); output != filepath.Base(output) || output == "." || output == "" {
+		return fmt.Errorf("report.output %q must be a file name in the run directory", output)
+	}
+	if _, err := parseTemplate(report.format(), report.path(config.WorkDir)); err != nil {
+		return fmt.Errorf("report.template: %w", err)
+	}
+	return nil
+}
+
+func (output *RunOutput) writeTemplateReport(config ReportConfig, workDir string, report RunReport) {
+	if config.Template == "" {
+		return
+	}
+	b, err := renderTemplate(config.format(), config.path(workDir), report)
+	if err != nil {
+		log.Println("output error: report.template:", err)
+		return
+	}
+	output.writeFile(config.output(), b)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportTemplate(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "team.md.tmpl"), []byte("# {{ .RunID }} {{ result .RunReport }}\n{{ range .Summary }}{{ .Metric }} p95 {{ .P95 }}\n{{ end }}"), 0o644))
+	requires.NoError(os.WriteFile(filepath.Join(dir, "team.html.tmpl"), []byte("<p>{{ .RunID }}</p>"), 0o644))
+	requires.NoError(os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte("{{ .RunID "), 0o644))
+	report := RunReport{RunID: "<r1>", Passed: true, Summary: []MetricSummary{{Metric: "latency", P95: 120}}}
+
+	output := &RunOutput{dir: t.TempDir()}
+	output.writeTemplateReport(ReportConfig{Template: "team.md.tmpl"}, dir, report)
+	output.writeTemplateReport(ReportConfig{Template: filepath.Join(dir, "team.html.tmpl"), Output: "team.htm"}, "", report)
+	output.writeTemplateReport(ReportConfig{}, dir, report)
+	md, err := os.ReadFile(filepath.Join(output.dir, "team.md"))
+	requires.NoError(err)
+	requires.Equal("# <r1> passed\nlatency p95 120\n", string(md))
+	html, err := os.ReadFile(filepath.Join(output.dir, "team.htm"))
+	requires.NoError(err)
+	requires.Equal("<p>&lt;r1&gt;</p>", string(html))
+
+	variants := []struct {
+		report ReportConfig
+		err    string
+	}{
+		{report: ReportConfig{}},
+		{report: ReportConfig{Template: "team.md.tmpl"}},
+		{report: ReportConfig{Template: "team.html.tmpl", Output: "index.html"}},
+		{report: ReportConfig{Output: "index.html"}, err: "report.output needs report.template"},
+		{report: ReportConfig{Template: "team.md.tmpl", Output: "../index.md"}, err: `report.output "../index.md" must be a file name in the run directory`},
+		{report: ReportConfig{Template: "broken.tmpl"}, err: "report.template: template: broken.tmpl:1"},
+		{report: ReportConfig{Template: "missing.tmpl"}, err: "report.template: open"},
+	}
+	for n, variant := range variants {
+		err := validateReport(Config{WorkDir: dir, Report: variant.report})
+		if variant.err == "" {
+			requires.NoError(err, n)
+			continue
+		}
+		requires.ErrorContains(err, variant.err, n)
+	}
+	requires.ErrorContains(checkRemoteConfig(Config{Report: ReportConfig{Template: "team.md.tmpl"}}), "report.template is not allowed in posted configs")
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ResultsConfig struct {
+	Format string   `yaml:"format"`
+	File   string   `yaml:"file"`
+	Window Duration `yaml:"window"`
+}
+
+var resultsFormats = []string{"k6", "jtl", "gatling", "locust"}
+
+type ResultSample struct {
+	time       time.Time
+	latency    float64
+	hasLatency bool
+	failed     bool
+	hasStatus  bool
+}
+
+type LocustRow struct {
+	time        time.Time
+	requests    float64
+	failures    float64
+	avg         float64
+	percentiles map[string]float64
+}
+
+type ResultsTail struct {
+	format  string
+	file    string
+	window  time.Duration
+	now     func() time.Time
+	mutex   sync.Mutex
+	current string
+	offset  int64
+	header  []string
+	samples []ResultSample
+	rows    []LocustRow
+}
+
+func NewResultsTail(config ResultsConfig, workDir string) *ResultsTail {
+	file := config.File
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(workDir, file)
+	}
+	window := time.Duration(config.Window)
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	return &ResultsTail{format: config.Format, file: file, window: window, now: time.Now}
+}
+
+// newest resolves a file pattern to its newest match: gatling writes every run
+// into a new directory.
+func newest(pattern string) (string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern, nil
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	found, modified := "", time.Time{}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.ModTime().Before(modified) {
+			found, modified = match, info.ModTime()
+		}
+	}
+	return found, nil
+}
+
+func (tail *ResultsTail) read() error {
+	fileName, err := newest(tail.file)
+	if err != nil || fileName == "" {
+		return err
+	}
+	file, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	// the next scenario starts the load tool again, which starts a new file
+	if fileName != tail.current || info.Size() < tail.offset {
+		tail.current, tail.offset, tail.header, tail.rows = fileName, 0, nil, nil
+	}
+	if _, err := file.Seek(tail.offset, io.SeekStart); err != nil {
+		return err
+	}
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	end := bytes.LastIndexByte(b, '\n')
+	if end < 0 {
+		return nil
+	}
+	tail.offset += int64(end + 1)
+	for _, line := range bytes.Split(b[:end], []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if tail.format == "locust" {
+			row, ok, err := tail.parseLocust(line)
+			if err != nil {
+				log.Println("WARNING: results line skipped:", err)
+			} else if ok {
+				tail.rows = append(tail.rows, row)
+			}
+			continue
+		}
+		sample, ok, err := tail.parse(line)
+		if err != nil {
+			log.Println("WARNING: results line skipped:", err)
+			continue
+		}
+		if ok {
+			tail.samples = append(tail.samples, sample)
+		}
+	}
+	return nil
+}
+
+func (tail *ResultsTail) parse(line []byte) (ResultSample, bool, error) {
+	switch tail.format {
+	case "jtl":
+		return tail.parseJTL(line)
+	case "gatling":
+		return parseGatling(line)
+	}
+	return parseK6(line)
+}
+
+type K6Point struct {
+	Type   string `json:"type"`
+	Metric string `json:"metric"`
+	Data   struct {
+		Time  time.Time `json:"time"`
+		Value float64   `json:"value"`
+	} `json:"data"`
+}
+
+func parseK6(line []byte) (ResultSample, bool, error) {
+	point := K6Point{}
+	if err := json.Unmarshal(line, &point); err != nil {
+		return ResultSample{}, false, err
+	}
+	if point.Type != "Point" {
+		return ResultSample{}, false, nil
+	}
+	switch point.Metric {
+	case "http_req_duration":
+		return ResultSample{time: point.Data.Time, latency: point.Data.Value, hasLatency: true}, true, nil
+	case "http_req_failed":
+		return ResultSample{time: point.Data.Time, failed: point.Data.Value != 0, hasStatus: true}, true, nil
+	}
+	return ResultSample{}, false, nil
+}
+
+func (tail *ResultsTail) parseJTL(line []byte) (ResultSample, bool, error) {
+	record, err := csv.NewReader(bytes.NewReader(line)).Read()
+	if err != nil {
+		return ResultSample{}, false, err
+	}
+	if tail.header == nil {
+		tail.header = record
+		return ResultSample{}, false, nil
+	}
+	field := func(name string) (string, error) {
+		n := slices.Index(tail.header, name)
+		if n < 0 || n >= len(record) {
+			return "", fmt.Errorf("jtl column %s not found", name)
+		}
+		return record[n], nil
+	}
+	timeStamp, err := field("timeStamp")
+	if err != nil {
+		return ResultSample{}, false, err
+	}
+	elapsed, err := field("elapsed")
+	if err != nil {
+		return ResultSample{}, false, err
+	}
+	success, err := field("success")
+	if err != nil {
+		return ResultSample{}, false, err
+	}
+	millis, err := strconv.ParseInt(timeStamp, 10, 64)
+	if err != nil {
+		return ResultSample{}, false, err
+	}
+	latency, err := strconv.ParseFloat(elapsed, 64)
+	if err != nil {
+		return ResultSample{}, false, err
+	}
+	return ResultSample{
+		time:       time.UnixMilli(millis),
+		latency:    latency,
+		hasLatency: true,
+		failed:     success != "true",
+		hasStatus:  true,
+	}, true, nil
+}
+
+// parseGatling reads the REQUEST records of a text simulation.log (Gatling
+// before 3.9); the columns before the timestamps differ between versions.
+func parseGatling(line []byte) (ResultSample, bool, error) {
+	fields := strings.Split(strings.TrimRight(string(line), "\r"), "\t")
+	if fields[0] != "REQUEST" {
+		return ResultSample{}, false, nil
+	}
+	for n := len(fields) - 1; n >= 3; n-- {
+		if fields[n] != "OK" && fields[n] != "KO" {
+			continue
+		}
+		start, startErr := strconv.ParseInt(fields[n-2], 10, 64)
+		end, endErr := strconv.ParseInt(fields[n-1], 10, 64)
+		if startErr != nil || endErr != nil {
+			continue
+		}
+		return ResultSample{
+			time:       time.UnixMilli(end),
+			latency:    float64(end - start),
+			hasLatency: true,
+			failed:     fields[n] == "KO",
+			hasStatus:  true,
+		}, true, nil
+	}
+	return ResultSample{}, false, fmt.Errorf("gatling record without timestamps and status: %s", line)
+}
+
+var locustPercentiles = []string{"50", "66", "75", "80", "90", "95", "98", "99", "99.9", "99.99", "100"}
+
+// parseLocust reads the Aggregated rows of locust_stats_history.csv: running
+// totals and the percentiles of the last seconds, not single requests.
+func (tail *ResultsTail) parseLocust(line []byte) (LocustRow, bool, error) {
+	record, err := csv.NewReader(bytes.NewReader(line)).Read()
+	if err != nil {
+		return LocustRow{}, false, err
+	}
+	if tail.header == nil {
+		tail.header = record
+		return LocustRow{}, false, nil
+	}
+	field := func(name string) (float64, error) {
+		n := slices.Index(tail.header, name)
+		if n < 0 || n >= len(record) {
+			return 0, fmt.Errorf("locust column %s not found", name)
+		}
+		if record[n] == "N/A" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(record[n], 64)
+	}
+	if n := slices.Index(tail.header, "Name"); n < 0 || n >= len(record) || record[n] != "Aggregated" {
+		return LocustRow{}, false, nil
+	}
+	timestamp, err := field("Timestamp")
+	if err != nil {
+		return LocustRow{}, false, err
+	}
+	row := LocustRow{time: time.Unix(int64(timestamp), 0), percentiles: map[string]float64{}}
+	for name, value := range map[string]*float64{"Total Request Count": &row.requests, "Total Failure Count": &row.failures, "Total Average Response Time": &row.avg} {
+		if *value, err = field(name); err != nil {
+			return LocustRow{}, false, err
+		}
+	}
+	for _, percentile := range locustPercentiles {
+		if value, err := field(percentile + "%"); err == nil {
+			row.percentiles[percentile] = value
+		}
+	}
+	return row, true, nil
+}
+
+func locustPercentile(stat string) (string, bool) {
+	switch stat {
+	case "latency_median":
+		return "50", true
+	case "latency_max":
+		return "100", true
+	}
+	percentile, ok := strings.CutPrefix(stat, "latency_p")
+	return percentile, ok && slices.Contains(locustPercentiles, percentile)
+}
+
+func (tail *ResultsTail) locustValue(stat string, since time.Time) (float64, error) {
+	n := 0
+	for n+1 < len(tail.rows) && !tail.rows[n+1].time.After(since) {
+		n++
+	}
+	tail.rows = tail.rows[n:]
+	base, rows := LocustRow{}, tail.rows
+	if len(rows) > 0 && !rows[0].time.After(since) {
+		base, rows = rows[0], rows[1:]
+	}
+	last := base
+	if len(rows) > 0 {
+		last = rows[len(rows)-1]
+	}
+	if last.requests < base.requests {
+		base = LocustRow{}
+	}
+	requests, failures := last.requests-base.requests, last.failures-base.failures
+	switch stat {
+	case "requests":
+		return requests, nil
+	case "rps":
+		return requests / tail.window.Seconds(), nil
+	case "errors":
+		return failures, nil
+	case "error_rate", "latency_avg":
+		if requests == 0 {
+			return 0, nil
+		}
+		if stat == "latency_avg" {
+			return (last.avg*last.requests - base.avg*base.requests) / requests, nil
+		}
+		return failures * 100 / requests, nil
+	}
+	if percentile, ok := locustPercentile(stat); ok {
+		if len(rows) == 0 {
+			return 0, nil
+		}
+		return last.percentiles[percentile], nil
+	}
+	return 0, fmt.Errorf("unknown locust results stat %s", stat)
+}
+
+func (tail *ResultsTail) value(stat string) (float64, error) {
+	tail.mutex.Lock()
+	defer tail.mutex.Unlock()
+	if err := tail.read(); err != nil {
+		return 0, err
+	}
+	since := tail.now().Add(-tail.window)
+	if tail.format == "locust" {
+		return tail.locustValue(stat, since)
+	}
+	n := 0
+	for n < len(tail.samples) && tail.samples[n].time.Before(since) {
+		n++
+	}
+	tail.samples = tail.samples[n:]
+
+	latencies := make([]float64, 0)
+	requests, failures := 0, 0
+	for _, sample := range tail.samples {
+		if sample.hasLatency {
+			latencies = append(latencies, sample.latency)
+		}
+		if sample.hasStatus {
+			requests++
+			if sample.failed {
+				failures++
+			}
+		}
+	}
+	switch stat {
+	case "requests":
+		return float64(len(latencies)), nil
+	case "rps":
+		return float64(len(latencies)) / tail.window.Seconds(), nil
+	case "errors":
+		return float64(failures), nil
+	case "error_rate":
+		if requests == 0 {
+			return 0, nil
+		}
+		return float64(failures) * 100 / float64(requests), nil
+	}
+	if aggregate, ok := strings.CutPrefix(stat, "latency_"); ok {
+		if len(latencies) == 0 {
+			return 0, nil
+		}
+		return aggregateValues(aggregate, latencies)
+	}
+	return 0, fmt.Errorf("unknown results stat %s", stat)
+}
+
+func validResultsStat(format string, stat string) bool {
+	switch stat {
+	case "requests", "rps", "errors", "error_rate":
+		return true
+	}
+	if format == "locust" {
+		_, ok := locustPercentile(stat)
+		return ok || stat == "latency_avg"
+	}
+	aggregate, ok := strings.CutPrefix(stat, "latency_")
+	if !ok || aggregate == "count" {
+		return false
+	}
+	_, err := aggregateValues(aggregate, []float64{0})
+	return err == nil
+}
+
+type ResultsMetric struct {
+	tail     *ResultsTail
+	Name     string
+	Stat     string
+	MaxValue int
+}
+
+func (metric ResultsMetric) name() string {
+	return metric.Name
+}
+
+func (metric ResultsMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric ResultsMetric) gather(ctx context.Context) int {
+	value, err := metric.tail.value(metric.Stat)
+	if err != nil {
+		log.Printf("Error reading results: %v\n", err)
+		return -1
+	}
+	return int(value)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendFile(t *testing.T, fileName string, text string) {
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(text)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+}
+
+func TestResultsTailK6(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tail := NewResultsTail(ResultsConfig{Format: "k6", File: "k6.json"}, dir)
+	tail.now = func() time.Time { return now }
+
+	value, err := tail.value("rps")
+	requires.NoError(err)
+	requires.Equal(0.0, value)
+
+	fileName := filepath.Join(dir, "k6.json")
+	appendFile(t, fileName, `{"type":"Metric","metric":"http_req_duration","data":{}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2024-01-02T03:03:00Z","value":900}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2024-01-02T03:04:01Z","value":100}}
+{"type":"Point","metric":"http_req_failed","data":{"time":"2024-01-02T03:04:01Z","value":0}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2024-01-02T03:04:02Z","value":300}}
+{"type":"Point","metric":"http_req_failed","data":{"time":"2024-01-02T03:04:02Z","value":1}}
+{"type":"Point","metric":"http_req_duration","data":{"time":"2024-01-02T03:04:03Z","val`)
+	variants := map[string]float64{
+		"requests":       2,
+		"rps":            0.2,
+		"errors":         1,
+		"error_rate":     50,
+		"latency_avg":    200,
+		"latency_max":    300,
+		"latency_p50":    100,
+		"latency_median": 100,
+	}
+	for stat, expected := range variants {
+		value, err := tail.value(stat)
+		requires.NoError(err, stat)
+		requires.InDelta(expected, value, 0.001, stat)
+	}
+
+	appendFile(t, fileName, `ue":500}}
+`)
+	value, err = tail.value("latency_max")
+	requires.NoError(err)
+	requires.Equal(500.0, value)
+
+	now = now.Add(time.Minute)
+	value, err = tail.value("requests")
+	requires.NoError(err)
+	requires.Equal(0.0, value)
+
+	_, err = tail.value("bytes")
+	requires.Error(err)
+}
+
+func TestResultsTailJTL(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "results.jtl")
+	appendFile(t, fileName, "timeStamp,elapsed,label,responseCode,success\n"+
+		"1704164641000,120,home,200,true\n"+
+		"1704164642000,80,home,500,false\n"+
+		"broken,1,home,200,true\n")
+	tail := NewResultsTail(ResultsConfig{Format: "jtl", File: fileName, Window: Duration(5 * time.Second)}, "/elsewhere")
+	tail.now = func() time.Time { return time.UnixMilli(1704164643000) }
+	metric := ResultsMetric{tail: tail, Name: "errors", Stat: "error_rate", MaxValue: 10}
+	requires.Equal(50, metric.gather(context.Background()))
+	requires.Equal(100, ResultsMetric{tail: tail, Stat: "latency_avg"}.gather(context.Background()))
+	requires.Equal(-1, ResultsMetric{tail: tail, Stat: "bytes"}.gather(context.Background()))
+}
+
+func TestResultsTailGatling(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	tail := NewResultsTail(ResultsConfig{Format: "gatling", File: "gatling-results/*/simulation.log"}, dir)
+	tail.now = func() time.Time { return time.UnixMilli(1704164643000) }
+	value, err := tail.value("requests")
+	requires.NoError(err)
+	requires.Equal(0.0, value)
+
+	old := filepath.Join(dir, "gatling-results", "shop-1")
+	requires.NoError(os.MkdirAll(old, 0o755))
+	appendFile(t, filepath.Join(old, "simulation.log"), "REQUEST\t\thome\t1704164641000\t1704164641900\tOK\t \n")
+	requires.NoError(os.Chtimes(filepath.Join(old, "simulation.log"), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+	current := filepath.Join(dir, "gatling-results", "shop-2")
+	requires.NoError(os.MkdirAll(current, 0o755))
+	appendFile(t, filepath.Join(current, "simulation.log"), "RUN\tShopSimulation\tshop\t1704164640000\t \t3.8.4\n"+
+		"USER\tshop\tSTART\t1704164640500\n"+
+		"REQUEST\t\thome\t1704164641000\t1704164641120\tOK\t \n"+
+		"REQUEST\tcheckout\tpay\t1704164641500\t1704164641580\tKO\tstatus.find.is(200), but actually found 500\n"+
+		"REQUEST\tscenario\t7\t\tlegacy\t1704164642000\t1704164642100\tOK\t\n"+
+		"REQUEST\tbroken\n")
+	variants := map[string]float64{"requests": 3, "errors": 1, "latency_max": 120, "latency_min": 80, "latency_avg": 100}
+	for stat, expected := range variants {
+		value, err := tail.value(stat)
+		requires.NoError(err, stat)
+		requires.InDelta(expected, value, 0.001, stat)
+	}
+}
+
+func TestResultsTailLocust(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "locust_stats_history.csv")
+	header := "Timestamp,User Count,Type,Name,Requests/s,Failures/s,50%,66%,75%,80%,90%,95%,98%,99%,99.9%,99.99%,100%," +
+		"Total Request Count,Total Failure Count,Total Median Response Time,Total Average Response Time,Total Min Response Time,Total Max Response Time,Total Average Content Size\n"
+	appendFile(t, fileName, header+
+		"1704164630,10,,Aggregated,0,0,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,0,0,0,0,0,0,0\n"+
+		"1704164635,10,,Aggregated,20,1,100,110,120,130,140,150,160,170,180,190,200,100,2,100,100,50,200,512\n"+
+		"1704164640,10,GET,/home,20,1,90,100,110,120,130,140,150,160,170,180,190,150,3,95,100,50,200,512\n"+
+		"1704164640,10,,Aggregated,20,1,90,100,110,120,130,140,150,160,170,180,190,150,3,95,90,50,200,512\n"+
+		"1704164643,10,,Aggregated,20,1,80,90,100,110,120,130,140,150,160,170,185,200,7,90,85,50,200,512\n")
+	tail := NewResultsTail(ResultsConfig{Format: "locust", File: fileName, Window: Duration(5 * time.Second)}, dir)
+	tail.now = func() time.Time { return time.Unix(1704164641, 0) }
+	variants := map[string]float64{
+		"requests":       100,
+		"rps":            20,
+		"errors":         5,
+		"error_rate":     5,
+		"latency_avg":    70,
+		"latency_median": 80,
+		"latency_p95":    130,
+		"latency_p99.9":  160,
+		"latency_max":    185,
+	}
+	for stat, expected := range variants {
+		value, err := tail.value(stat)
+		requires.NoError(err, stat)
+		requires.InDelta(expected, value, 0.001, stat)
+	}
+	_, err := tail.value("latency_p97")
+	requires.Error(err)
+
+	requires.NoError(os.WriteFile(fileName, []byte(header+"1704164650,10,,Aggregated,5,0,60,60,60,60,60,60,60,60,60,60,60,10,0,60,60,60,60,512\n"), 0o644))
+	tail.now = func() time.Time { return time.Unix(1704164651, 0) }
+	value, err := tail.value("requests")
+	requires.NoError(err)
+	requires.Equal(10.0, value)
+	requires.True(validResultsStat("locust", "latency_p99.9"))
+	requires.False(validResultsStat("locust", "latency_p97"))
+	requires.True(validResultsStat("k6", "latency_p97"))
+}
+
+func TestValidateResultsMetric(t *testing.T) {
+	requires := require.New(t)
+	config := Config{Results: ResultsConfig{Format: "k6", File: "k6.json"}}
+	for _, stat := range []string{"rps", "requests", "errors", "error_rate", "latency_p95", "latency_avg"} {
+		config.Metrics = []Metric{{Name: "a", Type: "results", Query: stat}}
+		requires.NoError(validateMetricTypes(config), stat)
+	}
+	for _, stat := range []string{"", "latency_count", "latency_p0", "bytes"} {
+		config.Metrics = []Metric{{Name: "a", Type: "results", Query: stat}}
+		requires.Error(validateMetricTypes(config), stat)
+	}
+	config.Metrics = []Metric{{Name: "a", Type: "results", Query: "rps"}}
+	requires.Error(validateMetricTypes(Config{Metrics: config.Metrics}))
+	requires.Error(validateMetricTypes(Config{Results: ResultsConfig{Format: "wrk", File: "x"}, Metrics: config.Metrics}))
+}
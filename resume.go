@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	runStateFile = "state.json"
+	ticksFile    = "ticks.ndjson"
+)
+
+// RunState is saved to the run directory at every tick for --resume.
+type RunState struct {
+	RunID     string          `json:"runId"`
+	Started   time.Time       `json:"started"`
+	Gathering time.Time       `json:"gathering,omitzero"`
+	Limits    []QueryLimit    `json:"limits,omitempty"`
+	Scenarios []ScenarioState `json:"scenarios,omitempty"`
+}
+
+// ScenarioState of a run without scenarios has an empty name.
+type ScenarioState struct {
+	Name    string    `json:"name"`
+	Started time.Time `json:"started"`
+	Ended   time.Time `json:"ended,omitzero"`
+	Ticks   int       `json:"ticks"`
+}
+
+type RunProgress struct {
+	path    string
+	state   RunState
+	resumed int
+	resume  bool
+}
+
+type CheckerInt interface {
+	aligned(values MetricValues) bool
+	check(values MetricValues) (MetricValues, bool)
+}
+
+func newRunProgress(dir string, state RunState, resume bool) *RunProgress {
+	progress := &RunProgress{path: filepath.Join(dir, runStateFile), state: state, resumed: len(state.Scenarios), resume: resume}
+	progress.save()
+	return progress
+}
+
+func (progress *RunProgress) save() {
+	if progress == nil {
+		return
+	}
+	b, err := json.MarshalIndent(progress.state, "", "  ")
+	if err != nil {
+		log.Println("state error:", err)
+		return
+	}
+	tmp := progress.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		log.Println("state error:", err)
+		return
+	}
+	if err := os.Rename(tmp, progress.path); err != nil {
+		log.Println("state error:", err)
+	}
+}
+
+func (progress *RunProgress) isResumed() bool {
+	return progress != nil && progress.resume
+}
+
+func (progress *RunProgress) gathering(now time.Time, delay time.Duration) time.Time {
+	if progress == nil {
+		return now.Add(delay)
+	}
+	if progress.state.Gathering.IsZero() {
+		progress.state.Gathering = now.Add(delay)
+		progress.save()
+	}
+	return progress.state.Gathering
+}
+
+func (progress *RunProgress) limits() []QueryLimit {
+	if progress == nil {
+		return nil
+	}
+	return progress.state.Limits
+}
+
+func (progress *RunProgress) resolved(limits []QueryLimit) {
+	if progress == nil {
+		return
+	}
+	progress.state.Limits = limits
+	progress.save()
+}
+
+// scenario is true for a scenario of the resumed run.
+func (progress *RunProgress) scenario(n int, name string, now time.Time) (ScenarioState, bool) {
+	if progress == nil {
+		return ScenarioState{Name: name, Started: now}, false
+	}
+	if n < progress.resumed {
+		return progress.state.Scenarios[n], true
+	}
+	progress.state.Scenarios = append(progress.state.Scenarios, ScenarioState{Name: name, Started: now})
+	progress.save()
+	return progress.state.Scenarios[n], false
+}
+
+// tick does not count the drain after the last scenario.
+func (progress *RunProgress) tick() {
+	if progress == nil || len(progress.state.Scenarios) == 0 {
+		return
+	}
+	scenario := &progress.state.Scenarios[len(progress.state.Scenarios)-1]
+	if !scenario.Ended.IsZero() {
+		return
+	}
+	scenario.Ticks++
+	progress.save()
+}
+
+func (progress *RunProgress) ended(now time.Time) {
+	if progress == nil || len(progress.state.Scenarios) == 0 {
+		return
+	}
+	progress.state.Scenarios[len(progress.state.Scenarios)-1].Ended = now
+	progress.save()
+}
+
+// remaining counts the duration from the start of the scenario, the time the
+// gatherer was down is not made up.
+func (state ScenarioState) remaining(now time.Time, duration time.Duration, iterations int) (time.Duration, int, bool) {
+	if iterations > 0 {
+		return 0, iterations - state.Ticks, state.Ticks < iterations
+	}
+	left := state.Started.Add(duration).Sub(now)
+	return left, 0, left > 0
+}
+
+func (progress *RunProgress) ticks() int {
+	if progress == nil {
+		return 0
+	}
+	ticks := 0
+	for _, scenario := range progress.state.Scenarios {
+		ticks += scenario.Ticks
+	}
+	return ticks
+}
+
+// findRunState refuses a finished run, one with metadata.json.
+func findRunState(baseDir string, runID string) (RunState, string, error) {
+	states, err := filepath.Glob(filepath.Join(baseDir, "*", runStateFile))
+	if err != nil {
+		return RunState{}, "", err
+	}
+	for _, path := range states {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return RunState{}, "", err
+		}
+		state := RunState{}
+		if err := json.Unmarshal(b, &state); err != nil {
+			return RunState{}, "", fmt.Errorf("%s: %w", path, err)
+		}
+		if state.RunID != runID {
+			continue
+		}
+		dir := filepath.Dir(path)
+		if _, err := os.Stat(filepath.Join(dir, "metadata.json")); err == nil {
+			return RunState{}, "", fmt.Errorf("run %s is finished", runID)
+		}
+		return state, dir, nil
+	}
+	return RunState{}, "", fmt.Errorf("run %s not found in %s", runID, baseDir)
+}
+
+func (app App) resumeState(config Config) (*RunState, string, error) {
+	if app.resume == "" {
+		return nil, "", nil
+	}
+	if app.fakeTime {
+		return nil, "", errors.New("--resume does not work with --faketime")
+	}
+	state, dir, err := findRunState(app.outputBaseDir(config), app.resume)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateResume(config, state); err != nil {
+		return nil, "", err
+	}
+	return &state, dir, nil
+}
+
+func validateResume(config Config, state RunState) error {
+	for n, scenario := range state.Scenarios {
+		switch {
+		case len(config.Scenarios) == 0 && scenario.Name != "":
+			return fmt.Errorf("run %s: the config has no scenarios, the run has %s", state.RunID, scenario.Name)
+		case len(config.Scenarios) > 0 && (n >= len(config.Scenarios) || config.Scenarios[n].Name != scenario.Name):
+			return fmt.Errorf("run %s: scenario %s is not in the config at position %d", state.RunID, scenario.Name, n+1)
+		}
+	}
+	return nil
+}
+
+// replay checks the ticks of the resumed run again, so its violations,
+// budgets, anomaly baselines and episodes carry over.
+func (scheduler *Scheduler) replay(ticks []MetricValues) {
+	scheduler.limits.restore(scheduler.runState.limits())
+	eventers := map[string]EventerInt{"": scheduler.eventer}
+	for _, scenario := range scheduler.scenarios {
+		eventers[scenario.name] = scenario.eventer
+	}
+	if scheduler.drain != nil {
+		eventers[drainScenario] = scheduler.drain.eventer
+	}
+	for _, values := range ticks {
+		eventer, ok := eventers[values.scenario].(*Eventer)
+		if !ok {
+			log.Println("WARNING: journal: scenario", values.scenario, "is not in the config")
+			continue
+		}
+		eventer.replay(values)
+	}
+}
+
+func (eventer *Eventer) replay(values MetricValues) {
+	result, ok := values, true
+	if checker, isChecker := eventer.gatherer.(CheckerInt); isChecker && checker.aligned(values) {
+		result, ok = checker.check(values)
+	} else {
+		log.Println("WARNING: journal: the metrics of scenario", values.scenario, "changed, a tick is not checked again")
+	}
+	eventer.reporter.sendResult(result)
+	if eventer.observed != nil {
+		eventer.observed(result)
+	}
+	if result.failing() && eventer.violated != nil {
+		eventer.violated()
+	}
+	if !ok {
+		eventer.stoper()
+	}
+}
+
+func (gatherer Gatherer) aligned(values MetricValues) bool {
+	if len(values.values) != len(gatherer.metrics) {
+		return false
+	}
+	for n, metric := range gatherer.metrics {
+		if values.values[n].name != metric.name() {
+			return false
+		}
+	}
+	return true
+}
+
+type TickJournal struct {
+	file *os.File
+}
+
+func openTickJournal(dir string) (*TickJournal, error) {
+	file, err := os.OpenFile(filepath.Join(dir, ticksFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &TickJournal{file: file}, nil
+}
+
+func (journal *TickJournal) observe(values MetricValues) {
+	b, err := json.Marshal(metricValuesJSON(values))
+	if err != nil {
+		log.Println("journal error:", err)
+		return
+	}
+	if _, err := journal.file.Write(append(b, '\n')); err != nil {
+		log.Println("journal error:", err)
+	}
+}
+
+func (journal *TickJournal) close() {
+	if err := journal.file.Close(); err != nil {
+		log.Println("journal error:", err)
+	}
+}
+
+// readTicks truncates a line cut by the crash, so the resumed run appends
+// after the last complete tick.
+func readTicks(dir string) ([]MetricValues, error) {
+	ticks := make([]MetricValues, 0)
+	file, err := os.OpenFile(filepath.Join(dir, ticksFile), os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return ticks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	reader := bufio.NewReader(file)
+	offset := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return ticks, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		values := MetricValuesJSON{}
+		if err != nil || json.Unmarshal(line, &values) != nil {
+			log.Println("WARNING: journal: dropped a cut tick")
+			return ticks, file.Truncate(offset)
+		}
+		ticks = append(ticks, metricValuesFromJSON(values))
+		offset += int64(len(line))
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resumeConfig(host string) Config {
+	return Config{Host: host, Timeout: 1, Scenarios: []Scenario{
+		{Name: "warmup", Iterations: 2, Metrics: []Metric{{Name: "rps", Query: "rps", MaxValue: 10}}},
+		{Name: "peak", Iterations: 3, Metrics: []Metric{{Name: "rps", Query: "rps", MaxValue: 10}}},
+	}}
+}
+
+func scenarioTicks(values []MetricValues) map[string]int {
+	ticks := map[string]int{}
+	for _, value := range values {
+		ticks[value.scenario]++
+	}
+	return ticks
+}
+
+func TestRunState(t *testing.T) {
+	requires := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1,"3"]}}`))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	reporter := Reporter{}
+	scheduler, err := App{envManager: &FakeEnvManager{}, outputDir: dir}.execute(context.Background(), resumeConfig(server.URL), &reporter)
+	requires.NoError(err)
+	runDir, err := filepath.Glob(filepath.Join(dir, scheduler.runID+"-*"))
+	requires.NoError(err)
+	requires.Len(runDir, 1)
+	b, err := os.ReadFile(filepath.Join(runDir[0], runStateFile))
+	requires.NoError(err)
+	state := RunState{}
+	requires.NoError(json.Unmarshal(b, &state))
+	requires.Equal(scheduler.runID, state.RunID)
+	requires.Len(state.Scenarios, 2)
+	requires.Equal([]int{2, 3}, []int{state.Scenarios[0].Ticks, state.Scenarios[1].Ticks})
+	requires.False(state.Scenarios[1].Ended.IsZero())
+	b, err = os.ReadFile(filepath.Join(runDir[0], ticksFile))
+	requires.NoError(err)
+	requires.Equal(5, strings.Count(string(b), "\n"))
+
+	_, _, err = findRunState(dir, scheduler.runID)
+	requires.ErrorContains(err, "is finished")
+	_, _, err = findRunState(dir, "01HNONE")
+	requires.ErrorContains(err, "run 01HNONE not found in "+dir)
+}
+
+func valueServer(value string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1,"` + value + `"]}}`))
+	}))
+}
+
+// crashedRun leaves the run directory of a gatherer that crashed after two
+// ticks of peak with the values.
+func crashedRun(t *testing.T, dir string, peak ...int) (RunState, string) {
+	requires := require.New(t)
+	started := time.Now().Add(-time.Minute).Truncate(time.Second)
+	runDir := filepath.Join(dir, "01HCRASH-"+started.Format("20060102-150405"))
+	requires.NoError(os.MkdirAll(runDir, 0o755))
+	state := RunState{RunID: "01HCRASH", Started: started, Gathering: started.Add(time.Second), Scenarios: []ScenarioState{
+		{Name: "warmup", Started: started.Add(time.Second), Ended: started.Add(2 * time.Second), Ticks: 2},
+		{Name: "peak", Started: started.Add(2 * time.Second), Ticks: len(peak)},
+	}}
+	b, err := json.Marshal(state)
+	requires.NoError(err)
+	requires.NoError(os.WriteFile(filepath.Join(runDir, runStateFile), b, 0o644))
+	journal := strings.Builder{}
+	ticks := []MetricValues{{scenario: "warmup", values: []MetricValue{{name: "rps", value: 3}}}, {scenario: "warmup", values: []MetricValue{{name: "rps", value: 3}}}}
+	for _, value := range peak {
+		ticks = append(ticks, MetricValues{scenario: "peak", values: []MetricValue{{name: "rps", value: value}}})
+	}
+	for _, tick := range ticks {
+		tick.timestamp = started
+		b, err := json.Marshal(metricValuesJSON(tick))
+		requires.NoError(err)
+		journal.Write(append(b, '\n'))
+	}
+	journal.WriteString(`{"scenario":"peak","timest`)
+	requires.NoError(os.WriteFile(filepath.Join(runDir, ticksFile), []byte(journal.String()), 0o644))
+	requires.NoError(os.WriteFile(filepath.Join(runDir, "run.log"), []byte("before the crash\n"), 0o644))
+	return state, runDir
+}
+
+func TestResumeRun(t *testing.T) {
+	requires := require.New(t)
+	server := valueServer("3")
+	defer server.Close()
+	dir := t.TempDir()
+	state, runDir := crashedRun(t, dir, 3, 3)
+
+	env := &FakeEnvManager{}
+	reporter := Reporter{}
+	scheduler, err := App{envManager: env, outputDir: dir, resume: "01HCRASH"}.execute(context.Background(), resumeConfig(server.URL), &reporter)
+	requires.NoError(err)
+	requires.False(env.started)
+	requires.True(env.stopped)
+	requires.Equal("01HCRASH", scheduler.runID)
+	requires.Equal(map[string]int{"warmup": 2, "peak": 3}, scenarioTicks(reporter.snapshot()))
+	requires.Len(scheduler.windows, 2)
+	requires.True(state.Scenarios[0].Started.Equal(scheduler.windows[0].start))
+
+	b, err := os.ReadFile(filepath.Join(runDir, ticksFile))
+	requires.NoError(err)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	requires.Len(lines, 5)
+	for _, line := range lines {
+		requires.True(json.Valid([]byte(line)))
+	}
+	b, err = os.ReadFile(filepath.Join(runDir, "run.log"))
+	requires.NoError(err)
+	requires.True(strings.HasPrefix(string(b), "before the crash\n"))
+	requires.Contains(string(b), "resume run 01HCRASH with 4 gathered ticks")
+	report, err := loadRunReport(filepath.Join(runDir, "report.json"))
+	requires.NoError(err)
+	requires.True(report.Passed)
+
+	_, err = App{envManager: env, outputDir: dir, resume: "01HCRASH"}.execute(context.Background(), resumeConfig(server.URL), &Reporter{})
+	requires.ErrorContains(err, "run 01HCRASH is finished")
+	_, err = App{envManager: env, outputDir: dir, resume: "01HCRASH", fakeTime: true}.execute(context.Background(), resumeConfig(server.URL), &Reporter{})
+	requires.ErrorContains(err, "--resume does not work with --faketime")
+}
+
+func TestResumeViolations(t *testing.T) {
+	requires := require.New(t)
+	passing := valueServer("3")
+	defer passing.Close()
+	failing := valueServer("20")
+	defer failing.Close()
+	variants := []struct {
+		server  *httptest.Server
+		metric  Metric
+		peak    []int
+		passed  bool
+		stopped bool
+	}{
+		{server: passing, metric: Metric{OnViolation: "continue"}, peak: []int{20, 20}},
+		{server: passing, metric: Metric{OnViolation: "continue"}, peak: []int{3, 3}, passed: true},
+		{server: failing, metric: Metric{AllowedViolations: 2}, peak: []int{20, 20}, stopped: true},
+		{server: passing, metric: Metric{AllowedViolations: 2}, peak: []int{20, 3}, passed: true},
+	}
+	for _, variant := range variants {
+		dir := t.TempDir()
+		_, runDir := crashedRun(t, dir, variant.peak...)
+		config := resumeConfig(variant.server.URL)
+		metric := variant.metric
+		metric.Name, metric.Query, metric.MaxValue = "rps", "rps", 10
+		config.Scenarios[0].Metrics = []Metric{metric}
+		config.Scenarios[1].Metrics = []Metric{metric}
+		reporter := Reporter{}
+		scheduler, err := App{envManager: &FakeEnvManager{}, outputDir: dir, resume: "01HCRASH"}.execute(context.Background(), config, &reporter)
+		requires.NoError(err)
+		requires.Equal(!variant.passed, scheduler.failed(), variant)
+		requires.Equal(variant.stopped, scheduler.status == 1, variant)
+		report, err := loadRunReport(filepath.Join(runDir, "report.json"))
+		requires.NoError(err)
+		requires.Equal(variant.passed, report.Passed, variant)
+	}
+
+	b, err := json.Marshal(ScenarioState{Name: "peak", Started: time.Now()})
+	requires.NoError(err)
+	requires.NotContains(string(b), "ended")
+}
+
+func TestValidateResume(t *testing.T) {
+	requires := require.New(t)
+	config := resumeConfig("")
+	requires.NoError(validateResume(config, RunState{RunID: "01HRUN", Scenarios: []ScenarioState{{Name: "warmup"}}}))
+	requires.ErrorContains(validateResume(config, RunState{RunID: "01HRUN", Scenarios: []ScenarioState{{Name: "peak"}}}),
+		"run 01HRUN: scenario peak is not in the config at position 1")
+	requires.ErrorContains(validateResume(Config{}, RunState{RunID: "01HRUN", Scenarios: []ScenarioState{{Name: "warmup"}}}),
+		"run 01HRUN: the config has no scenarios, the run has warmup")
+
+	interrupted := ScenarioState{Started: time.Now().Add(-time.Minute), Ticks: 4}
+	_, iterations, left := interrupted.remaining(time.Now(), 0, 10)
+	requires.True(left)
+	requires.Equal(6, iterations)
+	_, _, left = interrupted.remaining(time.Now(), 0, 4)
+	requires.False(left)
+	duration, _, left := interrupted.remaining(time.Now(), 2*time.Minute, 0)
+	requires.True(left)
+	requires.InDelta(float64(time.Minute), float64(duration), float64(time.Second))
+	_, _, left = interrupted.remaining(time.Now(), 30*time.Second, 0)
+	requires.False(left)
+}
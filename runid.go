@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const defaultRunIDHeader = "X-Run-Id"
+
+func newRunID() string {
+	return ulid.Make().String()
+}
+
+func (config Config) runIDHeader() string {
+	if config.RunIDHeader == "" {
+		return defaultRunIDHeader
+	}
+	return config.RunIDHeader
+}
+
+type HeaderRoundTripper struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (roundTripper HeaderRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(roundTripper.headers) == 0 {
+		return roundTripper.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for name, values := range roundTripper.headers {
+		req.Header[name] = values
+	}
+	return roundTripper.next.RoundTrip(req)
+}
+
+func logRunID(runID string) func() {
+	prefix, flags := log.Prefix(), log.Flags()
+	log.SetPrefix("[" + runID + "] ")
+	log.SetFlags(flags | log.Lmsgprefix)
+	return func() {
+		log.SetPrefix(prefix)
+		log.SetFlags(flags)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRunID(t *testing.T) {
+	requires := require.New(t)
+	runID := newRunID()
+	requires.Len(runID, 26)
+	id, err := ulid.Parse(runID)
+	requires.NoError(err)
+	requires.WithinDuration(time.Now(), ulid.Time(id.Time()), time.Second)
+	requires.NotEqual(newRunID(), newRunID())
+}
+
+func TestPrometheusRunIDHeader(t *testing.T) {
+	requires := require.New(t)
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Perf-Run")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1,"3"]}}`))
+	}))
+	defer server.Close()
+
+	config := Config{RunIDHeader: "x-perf-run"}
+	sources := Sources{host: server.URL, headers: http.Header{http.CanonicalHeaderKey(config.runIDHeader()): {"01HRUN"}}}
+	gathers := newMetricGathers(sources, []Metric{{Name: "a", Query: "up"}})
+	requires.Equal(3, gathers[0].gather(context.Background()))
+	requires.Equal("01HRUN", <-received)
+
+	gathers = newMetricGathers(Sources{host: server.URL}, []Metric{{Name: "a", Query: "up"}})
+	requires.Equal(3, gathers[0].gather(context.Background()))
+	requires.Empty(<-received)
+	requires.Equal(defaultRunIDHeader, Config{}.runIDHeader())
+}
+
+func TestLogRunID(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&b)
+	defer log.SetOutput(writer)
+
+	restore := logRunID("01HRUN")
+	log.Println("during run")
+	restore()
+	log.Println("after run")
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	requires.Len(lines, 2)
+	requires.Contains(lines[0], "[01HRUN] during run")
+	requires.NotContains(lines[1], "01HRUN")
+}
+
+func TestSelfMetricsRunInfo(t *testing.T) {
+	requires := require.New(t)
+	selfMetrics := NewSelfMetrics()
+	selfMetrics.run("01HRUN")
+	requires.Contains(scrapeSelfMetrics(t, selfMetrics), `metricsgatherer_run_info{run_id="01HRUN"} 1`)
+	selfMetrics.run("01HNEXT")
+	out := scrapeSelfMetrics(t, selfMetrics)
+	requires.NotContains(out, "01HRUN")
+	requires.Contains(out, `metricsgatherer_run_info{run_id="01HNEXT"} 1`)
+}
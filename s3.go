@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+const defaultS3Prefix = "{{ .RunID }}-{{ .Date }}"
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+	Insecure  bool   `yaml:"insecure"`
+	Prefix    string `yaml:"prefix"`
+	PublicURL string `yaml:"publicURL"`
+}
+
+type ArtifactKey struct {
+	RunID  string
+	Date   string
+	Result string
+	Vars   map[string]string
+}
+
+func s3PrefixTemplate(config S3Config) (*template.Template, error) {
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = defaultS3Prefix
+	}
+	return template.New("prefix").Option("missingkey=zero").Parse(prefix)
+}
+
+func validateS3(config S3Config) error {
+	if config.Bucket == "" {
+		return nil
+	}
+	if _, err := s3PrefixTemplate(config); err != nil {
+		return fmt.Errorf("s3.prefix: %w", err)
+	}
+	if config.PublicURL != "" {
+		if _, err := url.Parse(config.PublicURL); err != nil {
+			return fmt.Errorf("s3.publicURL: %w", err)
+		}
+	}
+	return nil
+}
+
+func artifactPrefix(config S3Config, key ArtifactKey) (string, error) {
+	tmpl, err := s3PrefixTemplate(config)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, key); err != nil {
+		return "", err
+	}
+	return strings.Trim(path.Clean("/"+b.String()), "/"), nil
+}
+
+func artifactsURL(config S3Config, endpoint *url.URL, prefix string) string {
+	base := strings.TrimSuffix(config.PublicURL, "/")
+	if base == "" {
+		base = endpoint.String() + "/" + config.Bucket
+	}
+	return base + "/" + prefix + "/"
+}
+
+func uploadArtifacts(config S3Config, dir string, key ArtifactKey) (string, error) {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return "", err
+	}
+	prefix, err := artifactPrefix(config, key)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	err = filepath.WalkDir(dir, func(fileName string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, fileName)
+		if err != nil {
+			return err
+		}
+		object := prefix + "/" + filepath.ToSlash(rel)
+		options := minio.PutObjectOptions{ContentType: mime.TypeByExtension(filepath.Ext(fileName))}
+		if _, err := client.FPutObject(ctx, config.Bucket, object, fileName, options); err != nil {
+			return fmt.Errorf("upload %s: %w", object, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return artifactsURL(config, client.EndpointURL(), prefix), nil
+}
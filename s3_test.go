@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadArtifacts(t *testing.T) {
+	requires := require.New(t)
+	var mutex sync.Mutex
+	objects := map[string]string{}
+	contentTypes := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		objects[r.URL.Path] = string(b)
+		contentTypes[r.URL.Path] = r.Header.Get("Content-Type")
+		mutex.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "report.json"), []byte(`{"passed":true}`), 0o644))
+	requires.NoError(os.WriteFile(filepath.Join(dir, "run.log"), []byte("log"), 0o644))
+	endpoint, _ := url.Parse(server.URL)
+	config := S3Config{
+		Endpoint: endpoint.Host, Region: "us-east-1", Bucket: "perf", Insecure: true,
+		AccessKey: "key", SecretKey: "secret", Prefix: "{{ .Vars.team }}/{{ .Result }}/{{ .RunID }}",
+	}
+	url, err := uploadArtifacts(config, dir, ArtifactKey{RunID: "r1", Result: "passed", Vars: map[string]string{"team": "core"}})
+	requires.NoError(err)
+	requires.Equal(server.URL+"/perf/core/passed/r1/", url)
+	requires.Len(objects, 2)
+	requires.Contains(objects["/perf/core/passed/r1/report.json"], `{"passed":true}`)
+	requires.Contains(objects["/perf/core/passed/r1/run.log"], "log")
+	requires.Equal("application/json", contentTypes["/perf/core/passed/r1/report.json"])
+
+	config.PublicURL = "https://minio.example.com/perf/"
+	url, err = uploadArtifacts(config, dir, ArtifactKey{RunID: "r2", Result: "failed"})
+	requires.NoError(err)
+	requires.Equal("https://minio.example.com/perf/failed/r2/", url)
+}
+
+func TestArtifactPrefix(t *testing.T) {
+	requires := require.New(t)
+	prefix, err := artifactPrefix(S3Config{}, ArtifactKey{RunID: "r1", Date: "20240102-030405"})
+	requires.NoError(err)
+	requires.Equal("r1-20240102-030405", prefix)
+	prefix, err = artifactPrefix(S3Config{Prefix: "/runs/../{{ .Vars.env }}/{{ .RunID }}/"}, ArtifactKey{RunID: "r1"})
+	requires.NoError(err)
+	requires.Equal("r1", prefix)
+	requires.NoError(validateS3(S3Config{}))
+	requires.Error(validateS3(S3Config{Bucket: "perf", Prefix: "{{ .RunID"}))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+)
+
+type MetricSelector struct {
+	key     string
+	pattern string
+}
+
+func (selector MetricSelector) String() string {
+	return selector.key + "=" + selector.pattern
+}
+
+func (selector MetricSelector) matches(metric Metric, tags []string) bool {
+	if selector.key == "name" {
+		matched, _ := path.Match(selector.pattern, metric.Name)
+		return matched
+	}
+	return slices.ContainsFunc(tags, func(tag string) bool {
+		matched, _ := path.Match(selector.pattern, tag)
+		return matched
+	})
+}
+
+type SelectorsFlag []MetricSelector
+
+func (selectors *SelectorsFlag) String() string {
+	if selectors == nil {
+		return ""
+	}
+	texts := make([]string, 0, len(*selectors))
+	for _, selector := range *selectors {
+		texts = append(texts, selector.String())
+	}
+	return strings.Join(texts, ",")
+}
+
+func (selectors *SelectorsFlag) Set(value string) error {
+	key, pattern, ok := strings.Cut(value, "=")
+	if !ok || (key != "tag" && key != "name") || pattern == "" {
+		return fmt.Errorf("%q: expected tag=<tag> or name=<name>", value)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("%q: %w", value, err)
+	}
+	*selectors = append(*selectors, MetricSelector{key: key, pattern: pattern})
+	return nil
+}
+
+type Selection struct {
+	only SelectorsFlag
+	skip SelectorsFlag
+}
+
+func (selection Selection) selected(metric Metric, tags []string, used map[string]bool) bool {
+	if len(selection.only) > 0 {
+		n := slices.IndexFunc(selection.only, func(selector MetricSelector) bool { return selector.matches(metric, tags) })
+		if n < 0 {
+			return false
+		}
+		used["--only "+selection.only[n].String()] = true
+	} else if metric.Disabled {
+		return false
+	}
+	for _, selector := range selection.skip {
+		if selector.matches(metric, tags) {
+			used["--skip "+selector.String()] = true
+			return false
+		}
+	}
+	return true
+}
+
+// apply drops disabled metrics and applies --only and --skip; a scenario
+// metric without tags has the tags of the top-level metric it overrides.
+func (selection Selection) apply(config Config) (Config, error) {
+	tags := map[string][]string{}
+	for _, metric := range config.Metrics {
+		tags[metric.Name] = metric.Tags
+	}
+	used := map[string]bool{}
+	kept, skipped := 0, make([]string, 0)
+	filter := func(metrics []Metric) []Metric {
+		selected := make([]Metric, 0, len(metrics))
+		for _, metric := range metrics {
+			metricTags := metric.Tags
+			if len(metricTags) == 0 {
+				metricTags = tags[metric.Name]
+			}
+			if selection.selected(metric, metricTags, used) {
+				selected = append(selected, metric)
+				kept++
+			} else if !slices.Contains(skipped, metric.Name) {
+				skipped = append(skipped, metric.Name)
+			}
+		}
+		return selected
+	}
+	config.Metrics = filter(config.Metrics)
+	scenarios := make([]Scenario, 0, len(config.Scenarios))
+	for _, scenario := range config.Scenarios {
+		scenario.Metrics = filter(scenario.Metrics)
+		scenarios = append(scenarios, scenario)
+	}
+	config.Scenarios = scenarios
+	if len(selection.only) == 0 && len(selection.skip) == 0 {
+		return config, nil
+	}
+	for _, flag := range []struct {
+		name      string
+		selectors SelectorsFlag
+	}{{"--only", selection.only}, {"--skip", selection.skip}} {
+		for _, selector := range flag.selectors {
+			if !used[flag.name+" "+selector.String()] {
+				log.Println("WARNING:", flag.name, selector, "matches no metric")
+			}
+		}
+	}
+	if kept == 0 {
+		return config, errors.New("--only and --skip leave no metrics")
+	}
+	if len(skipped) > 0 {
+		log.Println("metrics skipped:", strings.Join(skipped, ", "))
+	}
+	return config, nil
+}
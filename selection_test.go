@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func selectionConfig() Config {
+	return Config{
+		Metrics: []Metric{
+			{Name: "db_connections", Tags: []string{"db"}},
+			{Name: "db_locks", Tags: []string{"db", "slow"}},
+			{Name: "gc_pause", Tags: []string{"jvm"}},
+			{Name: "heap_dump_size", Disabled: true},
+		},
+		Scenarios: []Scenario{{Name: "peak", Metrics: []Metric{{Name: "db_connections", MaxValue: 200}, {Name: "gc_young", Tags: []string{"jvm"}}}}},
+	}
+}
+
+func metricNames(metrics []Metric) []string {
+	names := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		names = append(names, metric.Name)
+	}
+	return names
+}
+
+func TestSelection(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		only     []string
+		skip     []string
+		metrics  []string
+		scenario []string
+		err      string
+	}{
+		{metrics: []string{"db_connections", "db_locks", "gc_pause"}, scenario: []string{"db_connections", "gc_young"}},
+		{only: []string{"tag=db"}, metrics: []string{"db_connections", "db_locks"}, scenario: []string{"db_connections"}},
+		{only: []string{"tag=db"}, skip: []string{"tag=slow"}, metrics: []string{"db_connections"}, scenario: []string{"db_connections"}},
+		{skip: []string{"name=gc_*"}, metrics: []string{"db_connections", "db_locks"}, scenario: []string{"db_connections"}},
+		{only: []string{"name=heap_dump_size", "tag=jvm"}, metrics: []string{"gc_pause", "heap_dump_size"}, scenario: []string{"gc_young"}},
+		{only: []string{"tag=kafka"}, err: "--only and --skip leave no metrics"},
+	}
+	for _, variant := range variants {
+		selection := Selection{}
+		for _, text := range variant.only {
+			requires.NoError(selection.only.Set(text))
+		}
+		for _, text := range variant.skip {
+			requires.NoError(selection.skip.Set(text))
+		}
+		config, err := selection.apply(selectionConfig())
+		if variant.err != "" {
+			requires.EqualError(err, variant.err)
+			continue
+		}
+		requires.NoError(err)
+		requires.Equal(variant.metrics, metricNames(config.Metrics), selection.only.String()+" "+selection.skip.String())
+		requires.Equal(variant.scenario, metricNames(config.Scenarios[0].Metrics), selection.only.String()+" "+selection.skip.String())
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	selection := Selection{}
+	requires.NoError(selection.skip.Set("name=gc_paws"))
+	_, err := selection.apply(selectionConfig())
+	requires.NoError(err)
+	requires.Contains(logs.String(), "WARNING: --skip name=gc_paws matches no metric")
+
+	for _, text := range []string{"db", "label=db", "name=", "name=[a"} {
+		requires.Error(selection.only.Set(text), text)
+	}
+}
+
+func TestSelectionFlags(t *testing.T) {
+	requires := require.New(t)
+	app := App{}
+	_, err := app.parseArgs([]string{"run", "--only", "tag=db", "--only", "name=gc_pause", "--skip", "tag=slow"})
+	requires.NoError(err)
+	requires.Equal("tag=db,name=gc_pause", app.selection.only.String())
+	requires.Equal(SelectorsFlag{{key: "tag", pattern: "slow"}}, app.selection.skip)
+	requires.Equal([]string{"run", "--config", "./config.yaml", "--stand", "v1", "--report", "v1.json", "--only", "tag=db", "--only", "name=gc_pause", "--skip", "tag=slow"},
+		app.standArgs("v1", "v1.json"))
+	_, err = app.parseArgs([]string{"validate", "--skip", "gc_pause"})
+	requires.ErrorContains(err, `"gc_pause": expected tag=<tag> or name=<name>`)
+
+	config, _, err := App{selection: app.selection}.prepare(Config{TestDuration: Duration(1), Metrics: []Metric{{Name: "gc_pause", Query: "gc"}, {Name: "db", Query: "db", Tags: []string{"db"}}}})
+	requires.NoError(err)
+	requires.Equal([]string{"gc_pause", "db"}, metricNames(config.Metrics))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type SelfMetricsConfig struct {
+	Listen string `yaml:"listen"`
+}
+
+type SelfMetrics struct {
+	registry      *prometheus.Registry
+	ticks         prometheus.Counter
+	drift         prometheus.Gauge
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+	violations    *prometheus.CounterVec
+	info          *prometheus.GaugeVec
+	server        *http.Server
+	addr          net.Addr
+}
+
+func NewSelfMetrics() *SelfMetrics {
+	selfMetrics := &SelfMetrics{
+		registry: prometheus.NewRegistry(),
+		ticks: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "metricsgatherer_ticks_total",
+			Help: "Gather ticks executed.",
+		}),
+		drift: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "metricsgatherer_tick_drift_seconds",
+			Help: "Delay of the last tick against its schedule.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "metricsgatherer_query_duration_seconds",
+			Help:    "Query latency per metric.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"metric"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metricsgatherer_query_errors_total",
+			Help: "Queries that returned no value per metric.",
+		}, []string{"metric"}),
+		violations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metricsgatherer_violations_total",
+			Help: "Threshold violations per metric.",
+		}, []string{"metric"}),
+		info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metricsgatherer_run_info",
+			Help: "The run being gathered, always 1.",
+		}, []string{"run_id"}),
+	}
+	selfMetrics.registry.MustRegister(selfMetrics.ticks, selfMetrics.drift, selfMetrics.queryDuration, selfMetrics.queryErrors, selfMetrics.violations, selfMetrics.info)
+	return selfMetrics
+}
+
+func (selfMetrics *SelfMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(selfMetrics.registry, promhttp.HandlerOpts{})
+}
+
+func (selfMetrics *SelfMetrics) start(listen string) error {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	selfMetrics.addr = listener.Addr()
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", selfMetrics.handler())
+	selfMetrics.server = &http.Server{Handler: mux}
+	log.Println("self metrics:", listener.Addr())
+	go func() {
+		if err := selfMetrics.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Println("self metrics error:", err)
+		}
+	}()
+	return nil
+}
+
+func (selfMetrics *SelfMetrics) stop() {
+	if selfMetrics.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := selfMetrics.server.Shutdown(ctx); err != nil {
+		log.Println("self metrics error:", err)
+	}
+}
+
+func (selfMetrics *SelfMetrics) tick(drift time.Duration) {
+	if selfMetrics == nil {
+		return
+	}
+	selfMetrics.ticks.Inc()
+	selfMetrics.drift.Set(drift.Seconds())
+}
+
+func (selfMetrics *SelfMetrics) query(name string, duration time.Duration, value int) {
+	if selfMetrics == nil {
+		return
+	}
+	selfMetrics.queryDuration.WithLabelValues(name).Observe(duration.Seconds())
+	if !hasValue(value) {
+		selfMetrics.queryErrors.WithLabelValues(name).Inc()
+	}
+}
+
+func (selfMetrics *SelfMetrics) run(runID string) {
+	if selfMetrics == nil {
+		return
+	}
+	selfMetrics.info.Reset()
+	selfMetrics.info.WithLabelValues(runID).Set(1)
+}
+
+func (selfMetrics *SelfMetrics) violation(name string) {
+	if selfMetrics == nil {
+		return
+	}
+	selfMetrics.violations.WithLabelValues(name).Inc()
+}
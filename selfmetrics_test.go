@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ErrorMetricGather struct {
+}
+
+func (m ErrorMetricGather) name() string                   { return "b" }
+func (m ErrorMetricGather) gather(ctx context.Context) int { return -1 }
+func (m ErrorMetricGather) maxValue() int                  { return 1 }
+
+func scrapeSelfMetrics(t *testing.T, selfMetrics *SelfMetrics) string {
+	recorder := httptest.NewRecorder()
+	selfMetrics.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+func TestSelfMetricsGatherer(t *testing.T) {
+	requires := require.New(t)
+	selfMetrics := NewSelfMetrics()
+	gatherer := Gatherer{metrics: []MetricGather{FakeMetricGather{}, ErrorMetricGather{}}, self: selfMetrics}
+	gatherer.gatherAndCheck(context.Background(), time.Now())
+	gatherer.gatherAndCheck(context.Background(), time.Now())
+	out := scrapeSelfMetrics(t, selfMetrics)
+	requires.Contains(out, `metricsgatherer_query_duration_seconds_count{metric="a"} 2`)
+	requires.Contains(out, `metricsgatherer_query_duration_seconds_count{metric="b"} 2`)
+	requires.Contains(out, `metricsgatherer_query_errors_total{metric="b"} 2`)
+	requires.NotContains(out, `metricsgatherer_query_errors_total{metric="a"}`)
+	requires.Contains(out, `metricsgatherer_violations_total{metric="a"} 2`)
+}
+
+func TestSelfMetricsScheduler(t *testing.T) {
+	requires := require.New(t)
+	selfMetrics := NewSelfMetrics()
+	scheduler := Scheduler{eventer: &FakeEventer{}, testDuration: 250 * time.Millisecond, timeout: 100 * time.Millisecond, self: selfMetrics}
+	scheduler.loop()
+	out := scrapeSelfMetrics(t, selfMetrics)
+	requires.Regexp(`metricsgatherer_ticks_total [23]\n`, out)
+	requires.Contains(out, "metricsgatherer_tick_drift_seconds ")
+}
+
+func TestSelfMetricsNil(t *testing.T) {
+	var selfMetrics *SelfMetrics
+	selfMetrics.tick(time.Second)
+	selfMetrics.query("a", time.Second, 1)
+	selfMetrics.violation("a")
+}
+
+func TestSelfMetricsServer(t *testing.T) {
+	requires := require.New(t)
+	selfMetrics := NewSelfMetrics()
+	requires.NoError(selfMetrics.start("127.0.0.1:0"))
+	defer selfMetrics.stop()
+	selfMetrics.tick(0)
+	response, err := http.Get("http://" + selfMetrics.addr.String() + "/metrics")
+	requires.NoError(err)
+	defer response.Body.Close()
+	b, err := io.ReadAll(response.Body)
+	requires.NoError(err)
+	requires.Contains(string(b), "metricsgatherer_ticks_total 1")
+}
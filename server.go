@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const maxConfigSize = 1 << 20
+
+type MetricValuesJSON struct {
+	Scenario   string      `json:"scenario,omitempty"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Values     []ValueJSON `json:"values"`
+	Violations []ValueJSON `json:"violations,omitempty"`
+}
+
+func metricValuesJSON(values MetricValues) MetricValuesJSON {
+	return MetricValuesJSON{
+		Scenario:   values.scenario,
+		Timestamp:  values.timestamp,
+		Values:     valuesJSON(values.values),
+		Violations: valuesJSON(values.violations),
+	}
+}
+
+type ServerRun struct {
+	reporter *Reporter
+	latest   *MetricValues
+	cancel   context.CancelFunc
+	state    string
+	started  time.Time
+	finished time.Time
+}
+
+type Server struct {
+	app     App
+	mutex   sync.Mutex
+	current *ServerRun
+}
+
+type StatusJSON struct {
+	State    string            `json:"state"`
+	Started  time.Time         `json:"started,omitzero"`
+	Finished time.Time         `json:"finished,omitzero"`
+	Latest   *MetricValuesJSON `json:"latest,omitempty"`
+}
+
+func NewServer(app App) *Server {
+	return &Server{app: app}
+}
+
+func (server *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", server.startRun)
+	mux.HandleFunc("GET /status", server.status)
+	mux.HandleFunc("POST /abort", server.abort)
+	mux.HandleFunc("GET /report", server.report)
+	mux.HandleFunc("GET /stream", server.stream)
+	mux.HandleFunc("GET /events", server.events)
+	return server.authorize(mux)
+}
+
+func (server *Server) authorize(next http.Handler) http.Handler {
+	if server.app.token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + server.app.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func checkRemoteConfig(config Config) error {
+	if len(config.OnAbort.Command) > 0 {
+		return errors.New("onAbort.command is not allowed in posted configs")
+	}
+	for _, env := range config.Environments {
+		if len(env.Command) > 0 || len(env.Stop) > 0 || len(env.Ready.Command) > 0 {
+			return fmt.Errorf("environment %s: commands are not allowed in posted configs", env.Name)
+		}
+	}
+	if len(config.ComposeCommand) > 0 {
+		return errors.New("composeCommand is not allowed in posted configs")
+	}
+	if config.EnvManager == "ssh" {
+		return errors.New("envManager ssh is not allowed in posted configs")
+	}
+	if len(config.Actions) > 0 {
+		return errors.New("actions are not allowed in posted configs")
+	}
+	if config.Email.PassTemplate != "" || config.Email.FailTemplate != "" {
+		return errors.New("email templates are not allowed in posted configs")
+	}
+	if config.Report.Template != "" {
+		return errors.New("report.template is not allowed in posted configs")
+	}
+	if config.Budgets != "" {
+		return errors.New("budgets is not allowed in posted configs")
+	}
+	if config.CloudMonitoring.CredentialsFile != "" {
+		return errors.New("cloudMonitoring.credentialsFile is not allowed in posted configs")
+	}
+	if config.History.enabled() {
+		return errors.New("history retention is not allowed in posted configs")
+	}
+	if len(config.Matrix) > 0 {
+		return errors.New("matrix is not supported in posted configs")
+	}
+	if len(config.Stands) > 0 {
+		return errors.New("stands are not supported in posted configs")
+	}
+	if config.OutputDir != "" {
+		return errors.New("outputDir is not allowed in posted configs")
+	}
+	for _, scenario := range config.Scenarios {
+		if len(scenario.Load) > 0 || scenario.Runner.Type != "" {
+			return fmt.Errorf("scenario %s: load is not allowed in posted configs", scenario.Name)
+		}
+		for _, metric := range scenario.Metrics {
+			if len(metric.Plugin) > 0 {
+				return fmt.Errorf("metric %s: plugins are not allowed in posted configs", metric.Name)
+			}
+		}
+	}
+	for _, metric := range config.Metrics {
+		if len(metric.Plugin) > 0 {
+			return fmt.Errorf("metric %s: plugins are not allowed in posted configs", metric.Name)
+		}
+	}
+	for _, probe := range config.StartupProbes {
+		if probe.Type == "plugin" || len(probe.Plugin) > 0 {
+			return fmt.Errorf("startupProbes: probe %s: plugins are not allowed in posted configs", probe.Name)
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Println("write response error:", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err string) {
+	writeJSON(w, status, map[string]string{"error": err})
+}
+
+func (server *Server) startRun(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	config, err := server.app.parseConfig(b)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkRemoteConfig(config); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.current != nil && server.current.state == "running" {
+		writeError(w, http.StatusConflict, "run in progress")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &ServerRun{reporter: &Reporter{}, cancel: cancel, state: "running", started: time.Now()}
+	run.reporter.subscribe(func(values MetricValues) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		run.latest = &values
+	})
+	server.current = run
+	go server.execute(ctx, run, config)
+	writeJSON(w, http.StatusAccepted, StatusJSON{State: run.state, Started: run.started})
+}
+
+func (server *Server) execute(ctx context.Context, run *ServerRun, config Config) {
+	scheduler, err := server.safeExecute(ctx, run, config)
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	run.finished = time.Now()
+	switch {
+	case err != nil:
+		log.Println("run error:", err)
+		run.state = "error"
+	case scheduler.startFailed():
+		run.state = "startFailed"
+	case ctx.Err() == context.Canceled && scheduler.status == 0:
+		run.state = "aborted"
+	case scheduler.failed():
+		run.state = "failed"
+	default:
+		run.state = "passed"
+	}
+	run.cancel()
+}
+
+func (server *Server) safeExecute(ctx context.Context, run *ServerRun, config Config) (scheduler *Scheduler, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("run panic: %v", r)
+		}
+	}()
+	return server.app.execute(ctx, config, run.reporter)
+}
+
+func (server *Server) status(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.current == nil {
+		writeJSON(w, http.StatusOK, StatusJSON{State: "idle"})
+		return
+	}
+	status := StatusJSON{
+		State:    server.current.state,
+		Started:  server.current.started,
+		Finished: server.current.finished,
+	}
+	if server.current.latest != nil {
+		latest := metricValuesJSON(*server.current.latest)
+		status.Latest = &latest
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (server *Server) abort(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.current == nil || server.current.state != "running" {
+		writeError(w, http.StatusConflict, "no run in progress")
+		return
+	}
+	server.current.cancel()
+	writeJSON(w, http.StatusAccepted, StatusJSON{State: "aborting", Started: server.current.started})
+}
+
+func (server *Server) report(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.current == nil {
+		writeError(w, http.StatusNotFound, "no run")
+		return
+	}
+	report := make([]MetricValuesJSON, 0)
+	for _, values := range server.current.reporter.snapshot() {
+		report = append(report, metricValuesJSON(values))
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (server *Server) currentReporter(w http.ResponseWriter) *Reporter {
+	server.mutex.Lock()
+	current := server.current
+	server.mutex.Unlock()
+	if current == nil {
+		writeError(w, http.StatusNotFound, "no run")
+		return nil
+	}
+	return current.reporter
+}
+
+func (server *Server) stream(w http.ResponseWriter, r *http.Request) {
+	if reporter := server.currentReporter(w); reporter != nil {
+		writeNDJSON(w, r, reporter)
+	}
+}
+
+func (server *Server) events(w http.ResponseWriter, r *http.Request) {
+	if reporter := server.currentReporter(w); reporter != nil {
+		writeEvents(w, r, reporter)
+	}
+}
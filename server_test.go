@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serverRequest(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func serverStatus(t *testing.T, handler http.Handler) StatusJSON {
+	status := StatusJSON{}
+	w := serverRequest(handler, http.MethodGet, "/status", "")
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	return status
+}
+
+func waitServerState(t *testing.T, handler http.Handler) StatusJSON {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := serverStatus(t, handler); status.State != "running" {
+			return status
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("run did not finish")
+	return StatusJSON{}
+}
+
+func TestServerIdle(t *testing.T) {
+	variants := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{method: http.MethodGet, path: "/status", status: http.StatusOK},
+		{method: http.MethodGet, path: "/report", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/stream", status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/abort", status: http.StatusConflict},
+		{method: http.MethodPost, path: "/runs", body: "metrics: [", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "onAbort: {command: [rm]}", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "scenarios: [{name: a, load: [sh]}]", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "outputDir: /tmp", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "matrix: {concurrency: [10, 50]}", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "environments: [{name: seed, command: [sh]}]", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "actions: [{name: a, compose: [kill]}]", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "email: {failTemplate: /etc/passwd}", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "metrics: [{name: a, type: plugin, plugin: [sh]}]", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: "scenarios: [{name: s, metrics: [{name: a, type: plugin, plugin: [sh]}]}]", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/runs", body: strings.Repeat("#", maxConfigSize+1), status: http.StatusRequestEntityTooLarge},
+	}
+	requires := require.New(t)
+	handler := NewServer(App{}).handler()
+	for n, variant := range variants {
+		w := serverRequest(handler, variant.method, variant.path, variant.body)
+		requires.Equal(variant.status, w.Code, n)
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	requires := require.New(t)
+	handler := NewServer(App{token: "secret"}).handler()
+	requires.Equal(http.StatusUnauthorized, serverRequest(handler, http.MethodGet, "/status", "").Code)
+	r := httptest.NewRequest(http.MethodGet, "/status", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	requires.Equal(http.StatusOK, w.Code)
+}
+
+func TestIsLoopback(t *testing.T) {
+	requires := require.New(t)
+	requires.True(isLoopback("127.0.0.1:8080"))
+	requires.True(isLoopback("localhost:8080"))
+	requires.False(isLoopback(":8080"))
+	requires.False(isLoopback("0.0.0.0:8080"))
+}
+
+func TestServerRunStates(t *testing.T) {
+	variants := []struct {
+		config string
+		state  string
+	}{
+		{
+			config: "host: http://127.0.0.1:1\ntestDuration: 1\ntimeout: 1\nmetrics: [{name: a, query: up, maxValue: 0}]",
+			state:  "passed",
+		},
+		{
+			config: "host: http://127.0.0.1:1\ntestDuration: 5\ntimeout: 1\nmetrics: [{name: a, query: up, maxValue: -2}]",
+			state:  "failed",
+		},
+	}
+	requires := require.New(t)
+	for n, variant := range variants {
+		env := &FakeEnvManager{}
+		handler := NewServer(App{envManager: env, outputDir: t.TempDir()}).handler()
+		requires.Equal(http.StatusAccepted, serverRequest(handler, http.MethodPost, "/runs", variant.config).Code, n)
+		status := waitServerState(t, handler)
+		requires.Equal(variant.state, status.State, n)
+		requires.NotNil(status.Latest, n)
+		requires.True(env.stopped, n)
+
+		report := []MetricValuesJSON{}
+		w := serverRequest(handler, http.MethodGet, "/report", "")
+		requires.NoError(json.NewDecoder(w.Body).Decode(&report))
+		requires.NotEmpty(report, n)
+		requires.Equal("a", report[0].Values[0].Name, n)
+		requires.Equal(-1, report[0].Values[0].Value, n)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	requires := require.New(t)
+	reporter := &Reporter{}
+	server := NewServer(App{})
+	server.current = &ServerRun{reporter: reporter, state: "running", cancel: func() {}}
+	handler := server.handler()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serverRequest(handler, http.MethodGet, "/stream", "")
+	}()
+	for {
+		reporter.mutex.Lock()
+		subscribed := len(reporter.streams) > 0
+		reporter.mutex.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	reporter.sendResult(MetricValues{scenario: "a", values: []MetricValue{{name: "a", value: 1}}})
+	reporter.close()
+	w := <-done
+	values := MetricValuesJSON{}
+	requires.NoError(json.NewDecoder(w.Body).Decode(&values))
+	requires.Equal("a", values.Scenario)
+}
+
+func TestServerRunAbort(t *testing.T) {
+	requires := require.New(t)
+	handler := NewServer(App{envManager: &FakeEnvManager{}, outputDir: t.TempDir()}).handler()
+	config := "startDelay: 60\ntestDuration: 60"
+	requires.Equal(http.StatusAccepted, serverRequest(handler, http.MethodPost, "/runs", config).Code)
+	requires.Equal("running", serverStatus(t, handler).State)
+	requires.Equal(http.StatusConflict, serverRequest(handler, http.MethodPost, "/runs", config).Code)
+	requires.Equal(http.StatusAccepted, serverRequest(handler, http.MethodPost, "/abort", "").Code)
+	requires.Equal("aborted", waitServerState(t, handler).State)
+	requires.Equal(http.StatusConflict, serverRequest(handler, http.MethodPost, "/abort", "").Code)
+}
+
+func TestServerRunError(t *testing.T) {
+	requires := require.New(t)
+	handler := NewServer(App{envManager: &FakeEnvManager{startErr: errors.New("compose up failed")}, outputDir: t.TempDir()}).handler()
+	requires.Equal(http.StatusAccepted, serverRequest(handler, http.MethodPost, "/runs", "testDuration: 1").Code)
+	requires.Equal("startFailed", waitServerState(t, handler).State)
+}
+
+func TestServerStatusLatest(t *testing.T) {
+	requires := require.New(t)
+	reporter := &Reporter{}
+	reporter.sendResult(MetricValues{
+		scenario:  "soak",
+		timestamp: time.Now(),
+		values:    []MetricValue{{name: "a", value: 1}, {name: "b", value: 5}},
+	})
+	server := NewServer(App{})
+	server.current = &ServerRun{reporter: reporter, state: "running", cancel: func() {}}
+	latest := reporter.snapshot()[0]
+	server.current.latest = &latest
+	status := serverStatus(t, server.handler())
+	requires.Equal("running", status.State)
+	requires.Equal("soak", status.Latest.Scenario)
+	requires.Equal([]ValueJSON{{Name: "a", Value: 1}, {Name: "b", Value: 5}}, status.Latest.Values)
+}
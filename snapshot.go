@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const snapshotTimeout = 30 * time.Second
+
+type ServiceSnapshot struct {
+	Environment string `json:"environment,omitempty"`
+	Service     string `json:"service"`
+	Container   string `json:"container"`
+	Image       string `json:"image"`
+	ImageID     string `json:"imageId,omitempty"`
+	State       string `json:"state"`
+	Status      string `json:"status"`
+	ExitCode    int    `json:"exitCode"`
+	CPU         string `json:"cpu,omitempty"`
+	Memory      string `json:"memory,omitempty"`
+	NetIO       string `json:"netIO,omitempty"`
+	BlockIO     string `json:"blockIO,omitempty"`
+}
+
+type StandSnapshot struct {
+	Taken    time.Time         `json:"taken"`
+	Services []ServiceSnapshot `json:"services"`
+}
+
+type Snapshotter interface {
+	snapshot() ([]ServiceSnapshot, error)
+}
+
+type composePsEntry struct {
+	Name     string `json:"Name"`
+	Service  string `json:"Service"`
+	Image    string `json:"Image"`
+	State    string `json:"State"`
+	Status   string `json:"Status"`
+	ExitCode int    `json:"ExitCode"`
+}
+
+type dockerStatsEntry struct {
+	Name     string `json:"Name"`
+	CPUPerc  string `json:"CPUPerc"`
+	MemUsage string `json:"MemUsage"`
+	NetIO    string `json:"NetIO"`
+	BlockIO  string `json:"BlockIO"`
+}
+
+func commandOutput(workDir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = workDir
+	b, err := cmd.Output()
+	if err != nil {
+		return b, fmt.Errorf("%s: %w", strings.Join(args, " "), err)
+	}
+	return b, nil
+}
+
+func decodeJSONLines[T any](b []byte) ([]T, error) {
+	trimmed := bytes.TrimSpace(b)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		entries := []T{}
+		err := json.Unmarshal(trimmed, &entries)
+		return entries, err
+	}
+	entries := make([]T, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry T
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func (envManager DockerCompose) docker(args ...string) []string {
+	command := envManager.dockerCommand
+	if len(command) == 0 {
+		command = []string{"docker"}
+		if compose := envManager.compose(); strings.HasPrefix(compose[0], "podman") {
+			command = []string{"podman"}
+		}
+	}
+	return append(append([]string{}, command...), args...)
+}
+
+func (envManager DockerCompose) snapshot() ([]ServiceSnapshot, error) {
+	b, err := commandOutput(envManager.workDir, envManager.compose("ps", "--all", "--format", "json")...)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := decodeJSONLines[composePsEntry](b)
+	if err != nil {
+		return nil, fmt.Errorf("compose ps: %w", err)
+	}
+	services := make([]ServiceSnapshot, 0, len(entries))
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		services = append(services, ServiceSnapshot{
+			Service:   entry.Service,
+			Container: entry.Name,
+			Image:     entry.Image,
+			State:     entry.State,
+			Status:    entry.Status,
+			ExitCode:  entry.ExitCode,
+		})
+		names = append(names, entry.Name)
+	}
+	if len(names) == 0 {
+		return services, nil
+	}
+	var errs []error
+	if b, err := commandOutput(envManager.workDir, envManager.docker(append([]string{"stats", "--no-stream", "--format", "{{json .}}"}, names...)...)...); err != nil {
+		errs = append(errs, err)
+	} else if stats, err := decodeJSONLines[dockerStatsEntry](b); err != nil {
+		errs = append(errs, fmt.Errorf("docker stats: %w", err))
+	} else {
+		for _, stat := range stats {
+			for n := range services {
+				if services[n].Container == stat.Name {
+					services[n].CPU, services[n].Memory = stat.CPUPerc, stat.MemUsage
+					services[n].NetIO, services[n].BlockIO = stat.NetIO, stat.BlockIO
+				}
+			}
+		}
+	}
+	if b, err := commandOutput(envManager.workDir, envManager.docker(append([]string{"inspect", "--format", "{{.Name}} {{.Image}}"}, names...)...)...); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			name, image, ok := strings.Cut(line, " ")
+			if !ok {
+				continue
+			}
+			for n := range services {
+				if services[n].Container == strings.TrimPrefix(name, "/") {
+					services[n].ImageID = image
+				}
+			}
+		}
+	}
+	return services, errors.Join(errs...)
+}
+
+func (envs *Environments) snapshot() ([]ServiceSnapshot, error) {
+	services := make([]ServiceSnapshot, 0)
+	var errs []error
+	for _, env := range envs.environments[:envs.started] {
+		snapshotter, ok := env.manager.(Snapshotter)
+		if !ok {
+			continue
+		}
+		envServices, err := snapshotter.snapshot()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment %s: %w", env.name, err))
+		}
+		for _, service := range envServices {
+			service.Environment = env.name
+			services = append(services, service)
+		}
+	}
+	return services, errors.Join(errs...)
+}
+
+func takeSnapshot(envManager EnvManagerInt) *StandSnapshot {
+	snapshotter, ok := envManager.(Snapshotter)
+	if !ok {
+		return nil
+	}
+	services, err := snapshotter.snapshot()
+	if err != nil {
+		log.Println("snapshot error:", err)
+	}
+	if services == nil {
+		return nil
+	}
+	for _, service := range services {
+		if service.State != "running" {
+			log.Printf("WARNING: service %s is %s (exit code %d)\n", service.Service, service.State, service.ExitCode)
+		}
+	}
+	return &StandSnapshot{Taken: time.Now(), Services: services}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const composePsOutput = `{"Name":"stand-api-1","Service":"api","Image":"api:1.2","State":"running","Status":"Up 5 minutes","ExitCode":0}
+{"Name":"stand-db-1","Service":"db","Image":"postgres:16","State":"exited","Status":"Exited (137) 1 minute ago","ExitCode":137}
+`
+
+const dockerStatsOutput = `{"Name":"stand-api-1","CPUPerc":"12.50%","MemUsage":"120MiB / 2GiB","NetIO":"1MB / 2MB","BlockIO":"0B / 0B"}
+`
+
+func TestDecodeJSONLines(t *testing.T) {
+	requires := require.New(t)
+	entries, err := decodeJSONLines[composePsEntry]([]byte(composePsOutput))
+	requires.NoError(err)
+	requires.Len(entries, 2)
+	entries, err = decodeJSONLines[composePsEntry]([]byte(`[{"Name":"a","Service":"api"}]`))
+	requires.NoError(err)
+	requires.Equal("api", entries[0].Service)
+	entries, err = decodeJSONLines[composePsEntry]([]byte("\n"))
+	requires.NoError(err)
+	requires.Empty(entries)
+	_, err = decodeJSONLines[composePsEntry]([]byte("NAME IMAGE"))
+	requires.Error(err)
+}
+
+func TestDockerComposeSnapshot(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	requires.NoError(os.WriteFile(filepath.Join(dir, "ps.json"), []byte(composePsOutput), 0o644))
+	requires.NoError(os.WriteFile(filepath.Join(dir, "stats.json"), []byte(dockerStatsOutput), 0o644))
+	envManager := DockerCompose{
+		workDir:        dir,
+		composeCommand: []string{"sh", "-c", `echo "$@" >> calls; cat ps.json`, "sh"},
+		dockerCommand: []string{"sh", "-c", `echo "$@" >> calls; case "$1" in
+stats) cat stats.json;;
+inspect) echo "/stand-api-1 sha256:aaa"; echo "/stand-db-1 sha256:bbb";;
+esac`, "sh"},
+	}
+	services, err := envManager.snapshot()
+	requires.NoError(err)
+	requires.Equal([]ServiceSnapshot{
+		{Service: "api", Container: "stand-api-1", Image: "api:1.2", ImageID: "sha256:aaa", State: "running", Status: "Up 5 minutes",
+			CPU: "12.50%", Memory: "120MiB / 2GiB", NetIO: "1MB / 2MB", BlockIO: "0B / 0B"},
+		{Service: "db", Container: "stand-db-1", Image: "postgres:16", ImageID: "sha256:bbb", State: "exited", Status: "Exited (137) 1 minute ago", ExitCode: 137},
+	}, services)
+	calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+	requires.NoError(err)
+	requires.Equal("ps --all --format json\nstats --no-stream --format {{json .}} stand-api-1 stand-db-1\ninspect --format {{.Name}} {{.Image}} stand-api-1 stand-db-1\n", string(calls))
+
+	envManager.dockerCommand = []string{"false"}
+	services, err = envManager.snapshot()
+	requires.Error(err)
+	requires.Len(services, 2)
+
+	snapshot := takeSnapshot(&Environments{environments: []Environment{{name: "app", manager: envManager}}, started: 1})
+	requires.NotNil(snapshot)
+	requires.Equal("app", snapshot.Services[0].Environment)
+	requires.Nil(takeSnapshot(&FakeEnvManager{}))
+
+	report := RunReport{Snapshot: snapshot}
+	requires.Contains(string(renderMarkdown(report)), "| app/db | stand-db-1 | postgres:16 | Exited (137) 1 minute ago | 137 |  |  |\n")
+
+	requires.Equal([]string{"podman", "ps"}, DockerCompose{composeCommand: []string{"podman-compose"}}.docker("ps"))
+	requires.Equal([]string{"docker", "ps"}, DockerCompose{composeCommand: []string{"docker", "compose"}}.docker("ps"))
+}
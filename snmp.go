@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+type SNMPv3Config struct {
+	Username     string `yaml:"username"`
+	AuthProtocol string `yaml:"authProtocol"`
+	AuthPassword string `yaml:"authPassword"`
+	PrivProtocol string `yaml:"privProtocol"`
+	PrivPassword string `yaml:"privPassword"`
+}
+
+type SNMPConfig struct {
+	Target    string       `yaml:"target"`
+	Version   string       `yaml:"version"`
+	Community string       `yaml:"community"`
+	Timeout   Duration     `yaml:"timeout"`
+	V3        SNMPv3Config `yaml:"v3"`
+}
+
+var snmpVersions = map[string]gosnmp.SnmpVersion{
+	"":   gosnmp.Version2c,
+	"1":  gosnmp.Version1,
+	"2c": gosnmp.Version2c,
+	"3":  gosnmp.Version3,
+}
+
+var snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"":       gosnmp.NoAuth,
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
+}
+
+var snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"":        gosnmp.NoPriv,
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES192":  gosnmp.AES192,
+	"AES256":  gosnmp.AES256,
+	"AES192C": gosnmp.AES192C,
+	"AES256C": gosnmp.AES256C,
+}
+
+func validateSNMP(config SNMPConfig) error {
+	if _, ok := snmpVersions[config.Version]; !ok {
+		return fmt.Errorf("unknown snmp.version %s", config.Version)
+	}
+	if _, ok := snmpAuthProtocols[config.V3.AuthProtocol]; !ok {
+		return fmt.Errorf("unknown snmp.v3.authProtocol %s", config.V3.AuthProtocol)
+	}
+	if _, ok := snmpPrivProtocols[config.V3.PrivProtocol]; !ok {
+		return fmt.Errorf("unknown snmp.v3.privProtocol %s", config.V3.PrivProtocol)
+	}
+	if config.Version == "3" && config.V3.Username == "" {
+		return fmt.Errorf("snmp.v3.username is not set")
+	}
+	if config.V3.PrivProtocol != "" && config.V3.AuthProtocol == "" {
+		return fmt.Errorf("snmp.v3.privProtocol requires authProtocol")
+	}
+	return nil
+}
+
+type SNMPClient struct {
+	config SNMPConfig
+}
+
+func NewSNMPClient(config SNMPConfig) *SNMPClient {
+	return &SNMPClient{config: config}
+}
+
+func (client *SNMPClient) session(ctx context.Context, target string) (*gosnmp.GoSNMP, error) {
+	host, port := target, "161"
+	if h, p, err := net.SplitHostPort(target); err == nil {
+		host, port = h, p
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("snmp target %s: %w", target, err)
+	}
+	timeout := time.Duration(client.config.Timeout)
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	session := &gosnmp.GoSNMP{
+		Context:   ctx,
+		Target:    host,
+		Port:      uint16(portNumber),
+		Transport: "udp",
+		Community: client.config.Community,
+		Version:   snmpVersions[client.config.Version],
+		Timeout:   timeout,
+		Retries:   1,
+		MaxOids:   gosnmp.MaxOids,
+	}
+	if session.Version == gosnmp.Version3 {
+		v3 := client.config.V3
+		session.SecurityModel = gosnmp.UserSecurityModel
+		session.MsgFlags = gosnmp.NoAuthNoPriv
+		if v3.AuthProtocol != "" {
+			session.MsgFlags = gosnmp.AuthNoPriv
+		}
+		if v3.PrivProtocol != "" {
+			session.MsgFlags = gosnmp.AuthPriv
+		}
+		session.SecurityParameters = &gosnmp.UsmSecurityParameters{
+			UserName:                 v3.Username,
+			AuthenticationProtocol:   snmpAuthProtocols[v3.AuthProtocol],
+			AuthenticationPassphrase: v3.AuthPassword,
+			PrivacyProtocol:          snmpPrivProtocols[v3.PrivProtocol],
+			PrivacyPassphrase:        v3.PrivPassword,
+		}
+	}
+	return session, nil
+}
+
+func (client *SNMPClient) get(ctx context.Context, target string, oid string) (gosnmp.SnmpPDU, error) {
+	session, err := client.session(ctx, target)
+	if err != nil {
+		return gosnmp.SnmpPDU{}, err
+	}
+	if err := session.Connect(); err != nil {
+		return gosnmp.SnmpPDU{}, err
+	}
+	defer session.Conn.Close()
+	packet, err := session.Get([]string{oid})
+	if err != nil {
+		return gosnmp.SnmpPDU{}, err
+	}
+	if packet.Error != gosnmp.NoError {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("snmp error %s", packet.Error)
+	}
+	if len(packet.Variables) != 1 {
+		return gosnmp.SnmpPDU{}, fmt.Errorf("snmp: %d variables in response", len(packet.Variables))
+	}
+	return packet.Variables[0], nil
+}
+
+type SNMPSample struct {
+	mutex    sync.Mutex
+	previous uint64
+	kind     gosnmp.Asn1BER
+	seen     bool
+}
+
+type SNMPMetric struct {
+	client    *SNMPClient
+	sample    *SNMPSample
+	Name      string
+	Target    string
+	OID       string
+	Delta     bool
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric SNMPMetric) name() string {
+	return metric.Name
+}
+
+func (metric SNMPMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric SNMPMetric) gather(ctx context.Context) int {
+	pdu, err := metric.client.get(ctx, metric.Target, metric.OID)
+	if err != nil {
+		log.Printf("Error querying SNMP: %v\n", err)
+		return -1
+	}
+	switch pdu.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+	default:
+		log.Printf("WARNING: unsupported SNMP type %s of %s\n", pdu.Type, metric.OID)
+		return -1
+	}
+	value := gosnmp.ToBigInt(pdu.Value)
+	if !metric.Delta {
+		return int(value.Int64())
+	}
+	return metric.sample.delta(pdu.Type, value.Uint64())
+}
+
+func (sample *SNMPSample) delta(kind gosnmp.Asn1BER, value uint64) int {
+	sample.mutex.Lock()
+	defer sample.mutex.Unlock()
+	previous, seen := sample.previous, sample.seen && sample.kind == kind
+	sample.previous, sample.kind, sample.seen = value, kind, true
+	switch {
+	case !seen:
+		return -1
+	case value >= previous:
+		return int(value - previous)
+	case kind == gosnmp.Counter32:
+		return int(value + (1 << 32) - previous)
+	}
+	return int(value)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/require"
+)
+
+type FakeSNMPAgent struct {
+	mutex  sync.Mutex
+	values map[string]gosnmp.SnmpPDU
+}
+
+func (agent *FakeSNMPAgent) set(pdu gosnmp.SnmpPDU) {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	agent.values[pdu.Name] = pdu
+}
+
+func startSNMPAgent(t *testing.T, values ...gosnmp.SnmpPDU) (*FakeSNMPAgent, string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	agent := &FakeSNMPAgent{values: map[string]gosnmp.SnmpPDU{}}
+	for _, pdu := range values {
+		agent.set(pdu)
+	}
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := (&gosnmp.GoSNMP{Version: gosnmp.Version2c}).SnmpDecodePacket(buf[:n])
+			if err != nil || request.Community != "public" {
+				continue
+			}
+			response := &gosnmp.SnmpPacket{
+				Version:   request.Version,
+				Community: request.Community,
+				PDUType:   gosnmp.GetResponse,
+				RequestID: request.RequestID,
+			}
+			agent.mutex.Lock()
+			for _, variable := range request.Variables {
+				name := strings.TrimPrefix(variable.Name, ".")
+				pdu, ok := agent.values[name]
+				if !ok {
+					pdu = gosnmp.SnmpPDU{Name: name, Type: gosnmp.NoSuchObject}
+				}
+				response.Variables = append(response.Variables, pdu)
+			}
+			agent.mutex.Unlock()
+			b, err := response.MarshalMsg()
+			if err == nil {
+				_, _ = conn.WriteTo(b, addr)
+			}
+		}
+	}()
+	return agent, conn.LocalAddr().String()
+}
+
+func TestSNMPMetric(t *testing.T) {
+	_, addr := startSNMPAgent(t,
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.2.2.1.14.2", Type: gosnmp.Counter32, Value: uint32(17)},
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.2.2.1.5.2", Type: gosnmp.Gauge32, Value: uint32(1000)},
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("switch")},
+	)
+	variants := []struct {
+		oid       string
+		onMissing string
+		value     int
+	}{
+		{"1.3.6.1.2.1.2.2.1.14.2", "", 17},
+		{"1.3.6.1.2.1.2.2.1.5.2", "", 1000},
+		{"1.3.6.1.2.1.1.5.0", "", -1},
+		{"1.3.6.1.2.1.2.2.1.14.9", "", -1},
+		{"1.3.6.1.2.1.2.2.1.14.9", "treatAsZero", 0},
+	}
+	client := NewSNMPClient(SNMPConfig{Target: addr, Community: "public"})
+	for _, variant := range variants {
+		t.Run(variant.oid+variant.onMissing, func(t *testing.T) {
+			metric := SNMPMetric{client: client, sample: &SNMPSample{}, Name: "a", Target: addr, OID: variant.oid, OnMissing: variant.onMissing}
+			require.Equal(t, variant.value, metric.gather(context.Background()))
+		})
+	}
+}
+
+func TestSNMPMetricDelta(t *testing.T) {
+	requires := require.New(t)
+	oid := "1.3.6.1.2.1.2.2.1.13.2"
+	agent, addr := startSNMPAgent(t, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Counter32, Value: uint32(4294967290)})
+	gathers := newMetricGathers(Sources{snmp: NewSNMPClient(SNMPConfig{Target: addr, Community: "public"})},
+		[]Metric{{Name: "drops", Type: "snmp", Query: oid, Aggregate: "delta"}})
+	metric := gathers[0]
+	requires.Equal(-1, metric.gather(context.Background()))
+	agent.set(gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Counter32, Value: uint32(4294967295)})
+	requires.Equal(5, metric.gather(context.Background()))
+	agent.set(gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Counter32, Value: uint32(3)})
+	requires.Equal(4, metric.gather(context.Background()))
+	agent.set(gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Counter32, Value: uint32(3)})
+	requires.Equal(0, metric.gather(context.Background()))
+}
+
+func TestSNMPSession(t *testing.T) {
+	requires := require.New(t)
+	client := NewSNMPClient(SNMPConfig{Version: "3", V3: SNMPv3Config{Username: "lab", AuthProtocol: "SHA", AuthPassword: "auth", PrivProtocol: "AES", PrivPassword: "priv"}})
+	session, err := client.session(context.Background(), "10.0.0.2")
+	requires.NoError(err)
+	requires.Equal("10.0.0.2", session.Target)
+	requires.Equal(uint16(161), session.Port)
+	requires.Equal(gosnmp.Version3, session.Version)
+	requires.Equal(gosnmp.AuthPriv, session.MsgFlags)
+	requires.Equal(&gosnmp.UsmSecurityParameters{
+		UserName: "lab", AuthenticationProtocol: gosnmp.SHA, AuthenticationPassphrase: "auth",
+		PrivacyProtocol: gosnmp.AES, PrivacyPassphrase: "priv",
+	}, session.SecurityParameters)
+	session, err = NewSNMPClient(SNMPConfig{Community: "public"}).session(context.Background(), "10.0.0.2:1161")
+	requires.NoError(err)
+	requires.Equal(uint16(1161), session.Port)
+	requires.Equal(gosnmp.Version2c, session.Version)
+	_, err = client.session(context.Background(), "10.0.0.2:snmp")
+	requires.Error(err)
+}
+
+func TestValidateSNMPMetrics(t *testing.T) {
+	variants := []struct {
+		name   string
+		snmp   SNMPConfig
+		metric Metric
+		err    string
+	}{
+		{"ok", SNMPConfig{Target: "sw1"}, Metric{Query: "1.3.6"}, ""},
+		{"metric target", SNMPConfig{}, Metric{Target: "sw2", Query: "1.3.6", Aggregate: "delta"}, ""},
+		{"target", SNMPConfig{}, Metric{Query: "1.3.6"}, "metric a: snmp.target is not set"},
+		{"oid", SNMPConfig{Target: "sw1"}, Metric{}, "metric a: query (oid) is not set"},
+		{"aggregate", SNMPConfig{Target: "sw1"}, Metric{Query: "1.3.6", Aggregate: "rate"}, "metric a: unknown snmp aggregate rate"},
+		{"version", SNMPConfig{Target: "sw1", Version: "2"}, Metric{Query: "1.3.6"}, "metric a: unknown snmp.version 2"},
+		{"username", SNMPConfig{Target: "sw1", Version: "3"}, Metric{Query: "1.3.6"}, "metric a: snmp.v3.username is not set"},
+		{"auth", SNMPConfig{Target: "sw1", Version: "3", V3: SNMPv3Config{Username: "u", AuthProtocol: "SHA1"}}, Metric{Query: "1.3.6"}, "metric a: unknown snmp.v3.authProtocol SHA1"},
+		{"priv", SNMPConfig{Target: "sw1", Version: "3", V3: SNMPv3Config{Username: "u", PrivProtocol: "AES"}}, Metric{Query: "1.3.6"}, "metric a: snmp.v3.privProtocol requires authProtocol"},
+	}
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			metric := variant.metric
+			metric.Name, metric.Type = "a", "snmp"
+			err := validateMetricTypes(Config{SNMP: variant.snmp, Metrics: []Metric{metric}})
+			if variant.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, variant.err)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+)
+
+func valuesFromJSON(values []ValueJSON) []MetricValue {
+	result := make([]MetricValue, 0)
+	for _, value := range values {
+		result = append(result, MetricValue{name: value.Name, value: value.Value, labels: value.Labels, series: value.Series, excluded: value.Excluded, warnings: value.Warnings})
+	}
+	return result
+}
+
+func metricValuesFromJSON(values MetricValuesJSON) MetricValues {
+	return MetricValues{
+		scenario:   values.Scenario,
+		timestamp:  values.Timestamp,
+		values:     valuesFromJSON(values.Values),
+		violations: valuesFromJSON(values.Violations),
+	}
+}
+
+func (reporter *Reporter) spillTo(fileName string, limit int) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.spill = file
+	reporter.limit = limit
+	return nil
+}
+
+func (reporter *Reporter) flush() {
+	writer := bufio.NewWriter(reporter.spill)
+	encoder := json.NewEncoder(writer)
+	for _, values := range reporter.values {
+		if err := encoder.Encode(metricValuesJSON(values)); err != nil {
+			log.Println("spill error:", err)
+			return
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		log.Println("spill error:", err)
+		return
+	}
+	reporter.spilled += len(reporter.values)
+	reporter.values = nil
+}
+
+func (reporter *Reporter) spilledValues() []MetricValues {
+	result := make([]MetricValues, 0, reporter.spilled)
+	if reporter.spilled == 0 {
+		return result
+	}
+	file, err := os.Open(reporter.spill.Name())
+	if err != nil {
+		log.Println("spill error:", err)
+		return result
+	}
+	defer func() { _ = file.Close() }()
+	decoder := json.NewDecoder(file)
+	for range reporter.spilled {
+		values := MetricValuesJSON{}
+		if err := decoder.Decode(&values); err != nil {
+			log.Println("spill error:", err)
+			break
+		}
+		result = append(result, metricValuesFromJSON(values))
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporterConcurrent(t *testing.T) {
+	requires := require.New(t)
+	reporter := &Reporter{}
+	received := 0
+	var mutex sync.Mutex
+	reporter.subscribe(func(MetricValues) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received++
+	})
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				reporter.sendResult(MetricValues{values: []MetricValue{{name: "a", value: 1}}})
+				_ = reporter.snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+	requires.Len(reporter.snapshot(), 800)
+	requires.Equal(800, received)
+}
+
+func TestReporterSpill(t *testing.T) {
+	requires := require.New(t)
+	reporter := &Reporter{}
+	requires.NoError(reporter.spillTo(filepath.Join(t.TempDir(), "values.ndjson"), 3))
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for n := range 10 {
+		reporter.sendResult(MetricValues{
+			scenario:   "s",
+			timestamp:  timestamp.Add(time.Duration(n) * time.Second),
+			values:     []MetricValue{{name: "a", value: n, labels: map[string]string{"team": "core"}}},
+			violations: []MetricValue{{name: "a", value: n}},
+		})
+		requires.LessOrEqual(len(reporter.values), 3)
+	}
+	values := reporter.snapshot()
+	requires.Len(values, 10)
+	for n, value := range values {
+		requires.Equal(n, value.values[0].value)
+		requires.True(timestamp.Add(time.Duration(n) * time.Second).Equal(value.timestamp))
+	}
+	requires.Equal(map[string]string{"team": "core"}, values[9].values[0].labels)
+	reporter.close()
+	requires.Empty(reporter.values)
+	requires.Len(reporter.snapshot(), 10)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SampleCounts struct {
+	scenario       string
+	metric         string
+	counts         map[int]int
+	violations     int
+	warnings       int
+	firstViolation time.Time
+}
+
+// SpoolAggregates replaces the gathered values in spool mode: a metric costs as
+// much memory as it has distinct values, and its percentiles stay exact.
+type SpoolAggregates struct {
+	mutex    sync.Mutex
+	index    map[string]int
+	metrics  []*SampleCounts
+	episodes *EpisodeTracker
+}
+
+func NewSpoolAggregates(recoverTicks map[string]int) *SpoolAggregates {
+	return &SpoolAggregates{index: map[string]int{}, episodes: NewEpisodeTracker(recoverTicks)}
+}
+
+func (aggregates *SpoolAggregates) counts(scenario string, metric string) *SampleCounts {
+	key := teamCityKey(scenario, metric)
+	n, ok := aggregates.index[key]
+	if !ok {
+		n = len(aggregates.metrics)
+		aggregates.index[key] = n
+		aggregates.metrics = append(aggregates.metrics, &SampleCounts{scenario: scenario, metric: metric, counts: map[int]int{}})
+	}
+	return aggregates.metrics[n]
+}
+
+func (aggregates *SpoolAggregates) observe(values MetricValues) {
+	aggregates.mutex.Lock()
+	for _, value := range values.values {
+		counts := aggregates.counts(values.scenario, value.name)
+		if hasValue(value.value) && !value.excluded {
+			counts.counts[value.value]++
+		}
+		if len(value.warnings) > 0 {
+			counts.warnings++
+		}
+	}
+	for _, violation := range values.violations {
+		counts := aggregates.counts(values.scenario, violation.name)
+		if counts.violations == 0 {
+			counts.firstViolation = values.timestamp
+		}
+		counts.violations++
+	}
+	aggregates.mutex.Unlock()
+	aggregates.episodes.observe(values)
+}
+
+func (counts *SampleCounts) sorted() []float64 {
+	keys := make([]int, 0, len(counts.counts))
+	total := 0
+	for value, count := range counts.counts {
+		keys = append(keys, value)
+		total += count
+	}
+	slices.Sort(keys)
+	sorted := make([]float64, 0, total)
+	for _, value := range keys {
+		for range counts.counts[value] {
+			sorted = append(sorted, float64(value))
+		}
+	}
+	return sorted
+}
+
+func (aggregates *SpoolAggregates) samples(scenario string, metric string) []float64 {
+	aggregates.mutex.Lock()
+	defer aggregates.mutex.Unlock()
+	n, ok := aggregates.index[teamCityKey(scenario, metric)]
+	if !ok {
+		return nil
+	}
+	return aggregates.metrics[n].sorted()
+}
+
+func (aggregates *SpoolAggregates) summaries() []MetricSummary {
+	aggregates.mutex.Lock()
+	defer aggregates.mutex.Unlock()
+	summaries := make([]MetricSummary, 0, len(aggregates.metrics))
+	for _, counts := range aggregates.metrics {
+		summary := MetricSummary{Scenario: counts.scenario, Metric: counts.metric, Violations: counts.violations, Warnings: counts.warnings, FirstViolation: counts.firstViolation}
+		summarizeSamples(&summary, counts.sorted())
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func validateSpool(config Config) error {
+	if config.Spool && config.ReportBuffer > 0 {
+		return errors.New("spool and reportBuffer are exclusive")
+	}
+	if config.ReportBuffer < 0 {
+		return errors.New("reportBuffer must not be negative")
+	}
+	return nil
+}
+
+func (reporter *Reporter) spoolTo(fileName string, recoverTicks map[string]int) error {
+	if err := reporter.spillTo(fileName, 0); err != nil {
+		return err
+	}
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.aggregates = NewSpoolAggregates(recoverTicks)
+	return nil
+}
+
+func (reporter *Reporter) summaries() []MetricSummary {
+	if reporter.aggregates != nil {
+		return reporter.aggregates.summaries()
+	}
+	return summarize(reporter.snapshot())
+}
+
+func (reporter *Reporter) checkAssertions(assertions []RunAssertion) ([]AssertionResult, bool) {
+	if reporter.aggregates != nil {
+		return checkSampleAssertions(reporter.aggregates.samples, assertions)
+	}
+	return checkAssertions(reporter.snapshot(), assertions)
+}
+
+// runReport builds the report without the spooled values: report.json names
+// the spool instead, report.csv is streamed from it by writeReports.
+func (reporter *Reporter) runReport(scheduler *Scheduler) RunReport {
+	if reporter.aggregates == nil {
+		return newRunReport(scheduler, reporter.snapshot())
+	}
+	report := newRunReport(scheduler, nil)
+	report.Summary = reporter.aggregates.summaries()
+	report.Episodes = reporter.aggregates.episodes.list()
+	report.Spool = filepath.Base(reporter.spill.Name())
+	return report
+}
+
+func readSpool(fileName string, each func(MetricValuesJSON) error) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		values := MetricValuesJSON{}
+		if err := decoder.Decode(&values); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := each(values); err != nil {
+			return err
+		}
+	}
+}
+
+// loadSpooledValues fills the values of a spooled report from the spool next to it.
+func loadSpooledValues(fileName string, report *RunReport) error {
+	if report.Spool == "" || len(report.Values) > 0 {
+		return nil
+	}
+	return readSpool(filepath.Join(filepath.Dir(fileName), report.Spool), func(values MetricValuesJSON) error {
+		report.Values = append(report.Values, values)
+		return nil
+	})
+}
+
+func writeSpoolCSV(spool string, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	err = writer.Write(csvHeader)
+	if err == nil {
+		err = readSpool(spool, func(values MetricValuesJSON) error {
+			for _, record := range csvRecords(values) {
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		writer.Flush()
+		err = writer.Error()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func csvRecords(values MetricValuesJSON) [][]string {
+	records := make([][]string, 0, len(values.Values))
+	for _, value := range values.Values {
+		records = append(records, []string{
+			values.Timestamp.Format(time.RFC3339),
+			values.Scenario,
+			value.Name,
+			formatLabels(value.Labels),
+			strconv.Itoa(value.Value),
+			strconv.FormatBool(isViolation(values, value.Name)),
+			formatLabels(value.Series),
+		})
+	}
+	return records
+}
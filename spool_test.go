@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func spoolTicks() []MetricValues {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	ticks := make([]MetricValues, 0)
+	for n := range 50 {
+		scenario := "ramp"
+		if n >= 25 {
+			scenario = "peak"
+		}
+		tick := MetricValues{
+			scenario:  scenario,
+			timestamp: timestamp.Add(time.Duration(n) * time.Second),
+			values:    []MetricValue{{name: "latency", value: 100 + n%7*10, labels: map[string]string{"team": "core"}}, {name: "errors", value: n % 3}},
+		}
+		if n == 40 {
+			tick.values[1].value = -1
+		}
+		if n%3 == 2 {
+			tick.violations = []MetricValue{{name: "errors", value: 2}}
+		}
+		ticks = append(ticks, tick)
+	}
+	return ticks
+}
+
+func TestReporterSpool(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	spooled := &Reporter{}
+	requires.NoError(spooled.spoolTo(filepath.Join(dir, "values.ndjson"), map[string]int{"errors": 2}))
+	memory := &Reporter{}
+	for _, tick := range spoolTicks() {
+		spooled.sendResult(tick)
+		memory.sendResult(tick)
+		requires.Empty(spooled.values)
+	}
+	requires.Equal(summarize(memory.snapshot()), spooled.summaries())
+
+	p95, _ := parseAssertion("p95 < 150")
+	avg, _ := parseAssertion("avg < 1")
+	assertions := []RunAssertion{{scenario: "peak", metric: "latency", assertion: p95}, {scenario: "ramp", metric: "errors", assertion: avg}, {metric: "latency", assertion: avg}}
+	results, ok := spooled.checkAssertions(assertions)
+	expected, expectedOk := memory.checkAssertions(assertions)
+	requires.Equal(expected, results)
+	requires.Equal(expectedOk, ok)
+	requires.True(results[2].noData)
+
+	spooled.close()
+	scheduler := &Scheduler{runID: "r1", recoverTicks: map[string]int{"errors": 2}}
+	report := spooled.runReport(scheduler)
+	full := memory.runReport(scheduler)
+	requires.Empty(report.Values)
+	requires.Equal("values.ndjson", report.Spool)
+	requires.Equal(full.Summary, report.Summary)
+	requires.Equal(full.Episodes, report.Episodes)
+	requires.Equal(reportAverages(full), reportAverages(report))
+
+	output := &RunOutput{dir: dir}
+	output.writeReports(report)
+	csv, err := os.ReadFile(filepath.Join(dir, "report.csv"))
+	requires.NoError(err)
+	expectedCSV, err := renderCSV(full)
+	requires.NoError(err)
+	requires.Equal(string(expectedCSV), string(csv))
+
+	loaded, err := loadRunReport(filepath.Join(dir, "report.json"))
+	requires.NoError(err)
+	requires.NoError(loadSpooledValues(filepath.Join(dir, "report.json"), &loaded))
+	requires.Equal(len(full.Values), len(loaded.Values))
+	requires.Equal(full.Values[49].Values, loaded.Values[49].Values)
+	requires.Error(loadSpooledValues(filepath.Join(t.TempDir(), "report.json"), &RunReport{Spool: "values.ndjson"}))
+
+	requires.NoError(validateSpool(Config{Spool: true}))
+	requires.ErrorContains(validateSpool(Config{Spool: true, ReportBuffer: 100}), "spool and reportBuffer are exclusive")
+	requires.ErrorContains(validateSpool(Config{ReportBuffer: -1}), "reportBuffer must not be negative")
+}
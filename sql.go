@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+type SQLConfig struct {
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+var sqlDrivers = map[string]string{
+	"postgres": "pgx",
+	"mysql":    "mysql",
+}
+
+func openSQL(config SQLConfig) (*sql.DB, error) {
+	driver, ok := sqlDrivers[config.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown sql driver %s", config.Driver)
+	}
+	return sql.Open(driver, config.DSN)
+}
+
+type SQLMetric struct {
+	db        *sql.DB
+	Name      string
+	Query     string
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric SQLMetric) name() string {
+	return metric.Name
+}
+
+func (metric SQLMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric SQLMetric) gather(ctx context.Context) int {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var value sql.NullFloat64
+	err := metric.db.QueryRowContext(ctx, metric.Query).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !value.Valid) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("Error querying SQL: %v\n", err)
+		return -1
+	}
+	return int(value.Float64)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var fakeSQLResults = map[string][]driver.Value{
+	"failed":   {int64(3)},
+	"ratio":    {2.7},
+	"null":     {nil},
+	"noRows":   {},
+	"textRows": {"abc"},
+}
+
+type FakeSQLDriver struct{}
+
+func (FakeSQLDriver) Open(string) (driver.Conn, error) { return FakeSQLConn{}, nil }
+
+type FakeSQLConn struct{}
+
+func (FakeSQLConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (FakeSQLConn) Close() error                        { return nil }
+func (FakeSQLConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (FakeSQLConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, ok := fakeSQLResults[query]
+	if !ok {
+		return nil, errors.New("syntax error")
+	}
+	return &FakeSQLRows{values: values}, nil
+}
+
+type FakeSQLRows struct {
+	values []driver.Value
+	read   int
+}
+
+func (rows *FakeSQLRows) Columns() []string { return []string{"value"} }
+func (rows *FakeSQLRows) Close() error      { return nil }
+
+func (rows *FakeSQLRows) Next(dest []driver.Value) error {
+	if rows.read >= len(rows.values) {
+		return io.EOF
+	}
+	dest[0] = rows.values[rows.read]
+	rows.read++
+	return nil
+}
+
+func init() {
+	sql.Register("fake", FakeSQLDriver{})
+}
+
+func TestSQLMetricGather(t *testing.T) {
+	variants := []struct {
+		query     string
+		onMissing string
+		value     int
+	}{
+		{query: "failed", value: 3},
+		{query: "ratio", value: 2},
+		{query: "null", value: -1},
+		{query: "null", onMissing: "treatAsZero", value: 0},
+		{query: "noRows", onMissing: "fail", value: missingValue},
+		{query: "textRows", value: -1},
+		{query: "broken", value: -1},
+	}
+	requires := require.New(t)
+	db, err := sql.Open("fake", "")
+	requires.NoError(err)
+	defer func() { _ = db.Close() }()
+	for _, variant := range variants {
+		metric := SQLMetric{db: db, Name: "a", Query: variant.query, MaxValue: 5, OnMissing: variant.onMissing}
+		requires.Equal(variant.value, metric.gather(context.Background()), variant.query)
+	}
+}
+
+func TestOpenSQL(t *testing.T) {
+	requires := require.New(t)
+	db, err := openSQL(SQLConfig{Driver: "postgres", DSN: "postgres://user@localhost/db"})
+	requires.NoError(err)
+	requires.NoError(db.Close())
+	_, err = openSQL(SQLConfig{Driver: "oracle"})
+	requires.Error(err)
+	requires.Error(validateMetricTypes(Config{Metrics: []Metric{{Name: "a", Type: "sql"}}}))
+	requires.Error(validateMetricTypes(Config{SQL: SQLConfig{Driver: "oracle", DSN: "x"}, Metrics: []Metric{{Name: "a", Type: "sql"}}}))
+	requires.NoError(validateMetricTypes(Config{SQL: SQLConfig{Driver: "mysql", DSN: "x"}, Metrics: []Metric{{Name: "a", Type: "sql"}}}))
+}
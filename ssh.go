@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type SSHConfig struct {
+	Host       string   `yaml:"host"`
+	User       string   `yaml:"user"`
+	KeyFile    string   `yaml:"keyFile"`
+	KnownHosts string   `yaml:"knownHosts"`
+	WorkDir    string   `yaml:"workDir"`
+	Start      []string `yaml:"start"`
+	Stop       []string `yaml:"stop"`
+	Timeout    Duration `yaml:"timeout"`
+}
+
+type SSHEnv struct {
+	addr            string
+	user            string
+	keyFile         string
+	knownHosts      string
+	workDir         string
+	startCommands   [][]string
+	stopCommands    [][]string
+	timeout         time.Duration
+	teardownTimeout time.Duration
+}
+
+func validateSSH(config SSHConfig) error {
+	if config.Host == "" {
+		return errors.New("envManager ssh: ssh.host is not set")
+	}
+	if config.User == "" {
+		return errors.New("envManager ssh: ssh.user is not set")
+	}
+	if config.KeyFile == "" {
+		return errors.New("envManager ssh: ssh.keyFile is not set")
+	}
+	return nil
+}
+
+func sshAddr(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, "22")
+}
+
+func newSSHEnv(config Config, teardownTimeout time.Duration) *SSHEnv {
+	remote := config.SSH
+	command := config.ComposeCommand
+	if len(command) == 0 {
+		command = composeCommands[0]
+	}
+	compose := DockerCompose{composeCommand: command, pull: config.Env.Pull == "always", build: config.Env.Build, down: config.Env.Down}
+	env := &SSHEnv{
+		addr:            sshAddr(remote.Host),
+		user:            remote.User,
+		keyFile:         remote.KeyFile,
+		knownHosts:      remote.KnownHosts,
+		workDir:         remote.WorkDir,
+		startCommands:   compose.startCommands(),
+		stopCommands:    [][]string{compose.compose(compose.down.args()...)},
+		timeout:         time.Duration(remote.Timeout),
+		teardownTimeout: teardownTimeout,
+	}
+	if len(remote.Start) > 0 {
+		env.startCommands = [][]string{remote.Start}
+	}
+	if len(remote.Stop) > 0 {
+		env.stopCommands = [][]string{remote.Stop}
+	}
+	if env.timeout <= 0 {
+		env.timeout = 30 * time.Second
+	}
+	return env
+}
+
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func (env *SSHEnv) remoteCommand(command []string) string {
+	quoted := make([]string, 0, len(command))
+	for _, arg := range command {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	line := strings.Join(quoted, " ")
+	if env.workDir != "" {
+		line = "cd " + shellQuote(env.workDir) + " && " + line
+	}
+	return line
+}
+
+func (env *SSHEnv) clientConfig() (*ssh.ClientConfig, error) {
+	key, err := os.ReadFile(env.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("ssh key %s: %w", env.keyFile, err)
+	}
+	knownHostsFile := env.knownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            env.user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         env.timeout,
+	}, nil
+}
+
+func (env *SSHEnv) run(logMsg string, commands [][]string, timeout time.Duration) error {
+	config, err := env.clientConfig()
+	if err != nil {
+		return fmt.Errorf("%s: %w", logMsg, err)
+	}
+	client, err := ssh.Dial("tcp", env.addr, config)
+	if err != nil {
+		return fmt.Errorf("%s: %w", logMsg, err)
+	}
+	defer client.Close()
+	for _, command := range commands {
+		line := env.remoteCommand(command)
+		log.Println(logMsg+":", env.user+"@"+env.addr, line)
+		if err := env.exec(client, line, timeout); err != nil {
+			return fmt.Errorf("%s: %w", logMsg, err)
+		}
+	}
+	return nil
+}
+
+func (env *SSHEnv) exec(client *ssh.Client, line string, timeout time.Duration) (err error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			_ = client.Close()
+		})
+		defer func() {
+			if !timer.Stop() {
+				err = fmt.Errorf("timed out after %s", timeout)
+			}
+		}()
+	}
+	out, err := session.CombinedOutput(line)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (env *SSHEnv) start() error {
+	return env.run("start stand", env.startCommands, 0)
+}
+
+func (env *SSHEnv) stop() error {
+	return env.run("stop stand", env.stopCommands, env.teardownTimeout)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type FakeSSHServer struct {
+	mutex    sync.Mutex
+	commands []string
+	exit     uint32
+	output   string
+	delay    time.Duration
+}
+
+func (server *FakeSSHServer) serve(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	require.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "perf" && bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(hostSigner)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn, config)
+		}
+	}()
+	return listener.Addr().String(), hostSigner.PublicKey()
+}
+
+func (server *FakeSSHServer) handle(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for request := range channelRequests {
+				if request.Type != "exec" {
+					_ = request.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				_ = ssh.Unmarshal(request.Payload, &payload)
+				server.mutex.Lock()
+				server.commands = append(server.commands, payload.Command)
+				exit, output, delay := server.exit, server.output, server.delay
+				server.mutex.Unlock()
+				_ = request.Reply(true, nil)
+				time.Sleep(delay)
+				_, _ = channel.Write([]byte(output))
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{exit}))
+				return
+			}
+		}()
+	}
+}
+
+func sshTestEnv(t *testing.T, server *FakeSSHServer) *SSHEnv {
+	requires := require.New(t)
+	clientPublic, clientPrivate, err := ed25519.GenerateKey(rand.Reader)
+	requires.NoError(err)
+	block, err := ssh.MarshalPrivateKey(clientPrivate, "")
+	requires.NoError(err)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	requires.NoError(os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+	clientKey, err := ssh.NewPublicKey(clientPublic)
+	requires.NoError(err)
+	addr, hostKey := server.serve(t, clientKey)
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	requires.NoError(os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)+"\n"), 0o600))
+	config := Config{
+		ComposeCommand: []string{"docker", "compose"},
+		SSH:            SSHConfig{Host: addr, User: "perf", KeyFile: keyFile, KnownHosts: knownHostsFile, WorkDir: "/srv/stand"},
+	}
+	return newSSHEnv(config, time.Second)
+}
+
+func TestSSHEnv(t *testing.T) {
+	requires := require.New(t)
+	server := &FakeSSHServer{}
+	env := sshTestEnv(t, server)
+	requires.NoError(env.start())
+	requires.NoError(env.stop())
+	requires.Equal([]string{
+		"cd '/srv/stand' && 'docker' 'compose' 'up' '-d' '--remove-orphans'",
+		"cd '/srv/stand' && 'docker' 'compose' 'down'",
+	}, server.commands)
+
+	server.exit, server.output = 1, "no such service\n"
+	requires.ErrorContains(env.start(), "start stand: Process exited with status 1: no such service")
+
+	server.exit, server.output, server.delay = 0, "", 2*time.Second
+	requires.ErrorContains(env.stop(), "stop stand: timed out after 1s")
+
+	env.user = "root"
+	requires.Error(env.start())
+	env.user = "perf"
+	env.knownHosts = filepath.Join(t.TempDir(), "known_hosts")
+	requires.NoError(os.WriteFile(env.knownHosts, nil, 0o600))
+	requires.ErrorContains(env.start(), "knownhosts: key is unknown")
+}
+
+func TestNewSSHEnv(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		Env: StandConfig{Pull: "always"},
+		SSH: SSHConfig{Host: "perf1", User: "ci", KeyFile: "id", WorkDir: "/srv/it's"},
+	}
+	env := newSSHEnv(config, time.Minute)
+	requires.Equal("perf1:22", env.addr)
+	requires.Equal(30*time.Second, env.timeout)
+	requires.Len(env.startCommands, 2)
+	requires.Equal("cd '/srv/it'\\''s' && 'docker' 'compose' 'pull'", env.remoteCommand(env.startCommands[0]))
+
+	config.SSH.Start = []string{"systemctl", "start", "app"}
+	config.SSH.Stop = []string{"systemctl", "stop", "app"}
+	config.SSH.Host = "perf1:2222"
+	env = newSSHEnv(config, time.Minute)
+	requires.Equal("perf1:2222", env.addr)
+	requires.Equal([][]string{{"systemctl", "start", "app"}}, env.startCommands)
+	requires.Equal([][]string{{"systemctl", "stop", "app"}}, env.stopCommands)
+
+	requires.NoError(validateEnvManager(Config{EnvManager: "ssh", SSH: config.SSH}))
+	requires.Error(validateEnvManager(Config{EnvManager: "ssh", SSH: SSHConfig{Host: "perf1", User: "ci"}}))
+	requires.Error(validateEnvManager(Config{EnvManager: "ssh", SSH: config.SSH, Environments: []EnvironmentConfig{{Name: "a"}}}))
+	requires.Error(checkRemoteConfig(Config{EnvManager: "ssh"}))
+}
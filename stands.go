@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type StandYAML struct {
+	Config `yaml:",inline"`
+	Name   string `yaml:"name"`
+}
+
+type StandReport struct {
+	Name     string    `json:"name"`
+	ExitCode int       `json:"exitCode"`
+	Report   RunReport `json:"report"`
+}
+
+type StandsReport struct {
+	RunID  string        `json:"runId"`
+	Passed bool          `json:"passed"`
+	Stands []StandReport `json:"stands"`
+}
+
+var standNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// applyStand merges the overlay of one stand over the top-level keys like a
+// profile. Without a stand it drops the overlays and returns the stand names.
+func applyStand(b []byte, stand string) ([]byte, []string, error) {
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, err
+	}
+	raw, ok := doc["stands"]
+	if !ok {
+		if stand != "" {
+			return nil, nil, fmt.Errorf("unknown stand %s: the config has no stands", stand)
+		}
+		return b, nil, nil
+	}
+	delete(doc, "stands")
+	items, ok := raw.([]any)
+	if raw != nil && !ok {
+		return nil, nil, errors.New("stands must be a list")
+	}
+	names := make([]string, 0, len(items))
+	var overlay map[string]any
+	for n, item := range items {
+		overrides, _ := item.(map[string]any)
+		name, _ := overrides["name"].(string)
+		switch {
+		case name == "":
+			return nil, nil, fmt.Errorf("stand %d: name is not set", n+1)
+		case !standNamePattern.MatchString(name):
+			return nil, nil, fmt.Errorf("stand %s: name may only contain letters, digits, '.', '_' and '-'", name)
+		case slices.Contains(names, name):
+			return nil, nil, fmt.Errorf("stand %s: duplicate name", name)
+		}
+		names = append(names, name)
+		if name == stand {
+			overlay = maps.Clone(overrides)
+			delete(overlay, "name")
+		}
+	}
+	if stand == "" {
+		b, err := yaml.Marshal(doc)
+		return b, names, err
+	}
+	if overlay == nil {
+		return nil, nil, fmt.Errorf("unknown stand %s (available: %s)", stand, strings.Join(names, ", "))
+	}
+	b, err := yaml.Marshal(mergeValues(doc, overlay))
+	return b, nil, err
+}
+
+func (app App) standConfig(name string) (App, Config, error) {
+	stand := app
+	stand.stand = name
+	config, err := stand.loadConfig(app.configFile)
+	if err != nil {
+		return stand, config, err
+	}
+	if len(config.Matrix) > 0 {
+		return stand, config, errors.New("matrix is not supported in stands")
+	}
+	return stand, config, nil
+}
+
+func validateStands(config Config) error {
+	if len(config.Stands) > 0 && len(config.Matrix) > 0 {
+		return errors.New("stands and matrix are exclusive")
+	}
+	return nil
+}
+
+// standArgs are the flags of the child process running one stand: the config
+// and the run flags of this process, without the ones that would clash.
+func (app App) standArgs(name string, reportFile string) []string {
+	args := []string{"run", "--config", app.configFile, "--stand", name, "--report", reportFile}
+	for _, key := range slices.Sorted(maps.Keys(app.vars)) {
+		args = append(args, "--var", key+"="+app.vars[key])
+	}
+	if app.profile != "" {
+		args = append(args, "--profile", app.profile)
+	}
+	if app.outputDir != "" {
+		args = append(args, "--output-dir", app.outputDir)
+	}
+	overrides := []struct {
+		name  string
+		value *DurationFlag
+	}{
+		{name: "--duration", value: &app.overrides.duration},
+		{name: "--start-delay", value: &app.overrides.startDelay},
+		{name: "--interval", value: &app.overrides.interval},
+	}
+	for _, override := range overrides {
+		if override.value.set {
+			args = append(args, override.name, override.value.String())
+		}
+	}
+	for _, selector := range app.selection.only {
+		args = append(args, "--only", selector.String())
+	}
+	for _, selector := range app.selection.skip {
+		args = append(args, "--skip", selector.String())
+	}
+	if app.budgets != "" {
+		args = append(args, "--budgets", app.budgets)
+	}
+	if app.keepOnFailure {
+		args = append(args, "--keep-on-failure")
+	}
+	if app.noColor {
+		args = append(args, "--no-color")
+	}
+	if app.fakeTime {
+		args = append(args, "--faketime")
+	}
+	return args
+}
+
+// StandWriter prefixes every line of a stand's output with its name, the
+// stands share one logger so their lines do not interleave.
+type StandWriter struct {
+	prefix string
+	logger *log.Logger
+	line   []byte
+}
+
+func (writer *StandWriter) Write(p []byte) (int, error) {
+	writer.line = append(writer.line, p...)
+	for {
+		n := bytes.IndexByte(writer.line, '\n')
+		if n < 0 {
+			return len(p), nil
+		}
+		writer.logger.Print(writer.prefix + string(writer.line[:n+1]))
+		writer.line = writer.line[n+1:]
+	}
+}
+
+func (writer *StandWriter) flush() {
+	if len(writer.line) > 0 {
+		writer.logger.Print(writer.prefix + string(writer.line))
+		writer.line = nil
+	}
+}
+
+func standEnv(environ []string) []string {
+	return slices.DeleteFunc(slices.Clone(environ), func(variable string) bool {
+		return strings.HasPrefix(variable, "TEAMCITY_VERSION=")
+	})
+}
+
+func (app App) runStand(ctx context.Context, command []string, name string, dir string, logger *log.Logger) StandReport {
+	stand := StandReport{Name: name, ExitCode: -1}
+	reportFile := filepath.Join(dir, name+".json")
+	cmd := exec.CommandContext(ctx, command[0], append(slices.Clone(command[1:]), app.standArgs(name, reportFile)...)...)
+	// the stand gets one SIGTERM and tears down; its own process group keeps
+	// a Ctrl-C from reaching it twice, which would stop it without teardown
+	detachStand(cmd)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.Env = standEnv(os.Environ())
+	writer := &StandWriter{prefix: "[" + name + "] ", logger: logger}
+	cmd.Stdout, cmd.Stderr = writer, writer
+	err := cmd.Run()
+	writer.flush()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		stand.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		log.Printf("stand %s: %v\n", name, err)
+		return stand
+	} else {
+		stand.ExitCode = 0
+	}
+	report, err := loadRunReport(reportFile)
+	if err != nil {
+		log.Printf("stand %s: no report: %v\n", name, err)
+		return stand
+	}
+	stand.Report = report
+	return stand
+}
+
+func (app App) executeStands(ctx context.Context, config Config, command []string) (StandsReport, error) {
+	if err := validateStands(config); err != nil {
+		return StandsReport{}, err
+	}
+	for _, name := range config.Stands {
+		if _, _, err := app.standConfig(name); err != nil {
+			return StandsReport{}, fmt.Errorf("stand %s: %w", name, err)
+		}
+	}
+	report := StandsReport{RunID: newRunID(), Passed: true, Stands: make([]StandReport, len(config.Stands))}
+	output, err := newRunOutput(app.outputBaseDir(config), "stands-"+report.RunID, time.Now())
+	if err != nil {
+		return report, err
+	}
+	defer output.close()
+	log.Printf("=[ stands: %s ]====================\n", strings.Join(config.Stands, ", "))
+	logger := log.New(log.Writer(), "", 0)
+	var wg sync.WaitGroup
+	for n, name := range config.Stands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Stands[n] = app.runStand(ctx, command, name, output.dir, logger)
+		}()
+	}
+	wg.Wait()
+	for _, stand := range report.Stands {
+		report.Passed = report.Passed && stand.ExitCode == 0 && stand.Report.Passed
+	}
+	grid := renderStandsGrid(report)
+	log.Println("=[ stands ]============================")
+	log.Print("\n" + string(grid))
+	output.writeFile("stands.md", grid)
+	if b, err := json.MarshalIndent(report, "", "  "); err != nil {
+		log.Println("output error:", err)
+	} else {
+		output.writeFile("stands.json", b)
+	}
+	return report, nil
+}
+
+func standResult(stand StandReport) string {
+	if stand.Report.RunID == "" {
+		return fmt.Sprintf("error (exit %d)", stand.ExitCode)
+	}
+	return reportResult(stand.Report)
+}
+
+// renderStandsGrid puts the stands side by side: a column per stand with the
+// avg, p95 and max of every metric, the avg also relative to the first stand.
+func renderStandsGrid(report StandsReport) []byte {
+	var b bytes.Buffer
+	header := []string{"metric"}
+	result := []string{"result"}
+	summaries := make([]map[string]MetricSummary, 0, len(report.Stands))
+	keys := make([]string, 0)
+	for _, stand := range report.Stands {
+		header = append(header, stand.Name)
+		result = append(result, standResult(stand))
+		byKey := map[string]MetricSummary{}
+		for _, summary := range reportSummaries(stand.Report) {
+			key := teamCityKey(summary.Scenario, summary.Metric)
+			byKey[key] = summary
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+		summaries = append(summaries, byKey)
+	}
+	fmt.Fprintf(&b, "| %s |\n|%s\n", strings.Join(header, " | "), strings.Repeat("---|", len(header)))
+	fmt.Fprintf(&b, "| %s |\n", strings.Join(result, " | "))
+	for _, key := range keys {
+		row := []string{key}
+		base, hasBase := summaries[0][key]
+		for n, byKey := range summaries {
+			summary, ok := byKey[key]
+			if !ok || summary.Samples == 0 {
+				row = append(row, "")
+				continue
+			}
+			value := fmt.Sprintf("avg %g, p95 %g, max %g", summary.Avg, summary.P95, summary.Max)
+			if n > 0 && hasBase && base.Samples > 0 {
+				value += " (" + formatPercent(percentChange(base.Avg, summary.Avg)) + ")"
+			}
+			if summary.Violations > 0 {
+				value += " **!**"
+			}
+			row = append(row, value)
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+	}
+	return b.Bytes()
+}
+
+func (app App) runStands(ctx context.Context, config Config) {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	report, err := app.executeStands(ctx, config, []string{executable})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if app.reportFile != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(app.reportFile, b, 0o644)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if ctx.Err() != nil {
+		log.Println("=[ interrupted ]=======================")
+		os.Exit(130)
+	}
+	if !report.Passed {
+		log.Println("=[ failed ]============================")
+		os.Exit(1)
+	}
+	log.Println("=[ passed ]============================")
+}
+
+func (app App) planStands(ctx context.Context, config Config, w io.Writer) error {
+	if err := validateStands(config); err != nil {
+		return err
+	}
+	for n, name := range config.Stands {
+		fmt.Fprintf(w, "=[ stand %d/%d: %s ]====================\n", n+1, len(config.Stands), name)
+		stand, standConfig, err := app.standConfig(name)
+		if err == nil {
+			err = stand.plan(ctx, standConfig, w)
+		}
+		if err != nil {
+			return fmt.Errorf("stand %s: %w", name, err)
+		}
+	}
+	return nil
+}
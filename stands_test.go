@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const standsConfig = `host: http://prometheus:9090
+testDuration: 1m
+workDir: stand
+metrics:
+  - {name: latency, query: latency, maxValue: 100}
+  - {name: errors, query: errors, maxValue: 1}
+stands:
+  - name: v1
+    vars: {version: "1.0"}
+  - name: v2
+    workDir: stand-v2
+    host: http://prometheus-v2:9090
+    metrics:
+      - {name: latency, maxValue: 150}
+`
+
+func TestApplyStand(t *testing.T) {
+	requires := require.New(t)
+	config, err := App{}.parseConfig([]byte(standsConfig))
+	requires.NoError(err)
+	requires.Equal([]string{"v1", "v2"}, config.Stands)
+	requires.Equal("stand", config.WorkDir)
+
+	config, err = App{stand: "v1"}.parseConfig([]byte(standsConfig))
+	requires.NoError(err)
+	requires.Empty(config.Stands)
+	requires.Equal(map[string]string{"version": "1.0"}, config.Vars)
+	requires.Equal("stand", config.WorkDir)
+
+	config, err = App{stand: "v2"}.parseConfig([]byte(standsConfig))
+	requires.NoError(err)
+	requires.Equal("stand-v2", config.WorkDir)
+	requires.Equal("http://prometheus-v2:9090", config.Host)
+	requires.Equal([]Metric{{Name: "latency", Query: "latency", MaxValue: 150}, {Name: "errors", Query: "errors", MaxValue: 1}}, config.Metrics)
+
+	variants := []struct {
+		config string
+		stand  string
+		err    string
+	}{
+		{config: standsConfig, stand: "v3", err: "unknown stand v3 (available: v1, v2)"},
+		{config: "host: http://prometheus:9090\n", stand: "v1", err: "unknown stand v1: the config has no stands"},
+		{config: "stands: [{workDir: a}]\n", err: "stand 1: name is not set"},
+		{config: "stands: [{name: a}, {name: a}]\n", err: "stand a: duplicate name"},
+		{config: "stands: [{name: ../a}]\n", err: "stand ../a: name may only contain"},
+		{config: "stands: [{name: a, workdir: b}]\n", err: "unknown key workdir, did you mean workDir?"},
+		{config: "stands: {a: {}}\n", err: "cannot unmarshal"},
+	}
+	for _, variant := range variants {
+		_, err := App{stand: variant.stand}.parseConfig([]byte(variant.config))
+		requires.ErrorContains(err, variant.err, variant.config)
+	}
+	requires.ErrorContains(validateStands(Config{Stands: []string{"a"}, Matrix: map[string][]string{"n": {"1"}}}), "stands and matrix are exclusive")
+	requires.ErrorContains(checkRemoteConfig(Config{Stands: []string{"a"}}), "stands are not supported in posted configs")
+}
+
+func TestStandArgs(t *testing.T) {
+	requires := require.New(t)
+	app := App{configFile: "perf.yaml", vars: VarsFlag{"b": "2", "a": "1"}, profile: "ci", keepOnFailure: true, fakeTime: true}
+	requires.NoError(app.overrides.duration.Set("90s"))
+	requires.Equal([]string{"run", "--config", "perf.yaml", "--stand", "v1", "--report", "out/v1.json",
+		"--var", "a=1", "--var", "b=2", "--profile", "ci", "--duration", "1m30s", "--keep-on-failure", "--faketime"},
+		app.standArgs("v1", "out/v1.json"))
+
+	parsed := App{}
+	_, err := parsed.parseArgs(app.standArgs("v1", "out/v1.json"))
+	requires.NoError(err)
+	requires.Equal("v1", parsed.stand)
+	requires.Equal(app.vars, parsed.vars)
+	requires.Equal(app.overrides, parsed.overrides)
+	requires.Equal([]string{"PATH=/bin"}, standEnv([]string{"TEAMCITY_VERSION=2024.1", "PATH=/bin"}))
+}
+
+func TestStandWriter(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	writer := &StandWriter{prefix: "[v1] ", logger: log.New(&b, "", 0)}
+	_, _ = writer.Write([]byte("first\nsec"))
+	_, _ = writer.Write([]byte("ond\nthird"))
+	requires.Equal("[v1] first\n[v1] second\n", b.String())
+	writer.flush()
+	requires.Equal("[v1] first\n[v1] second\n[v1] third\n", b.String())
+}
+
+// fakeStand stands in for the child process: v1 passes, v2 fails with a
+// violation, broken exits without a report.
+const fakeStand = `while [ $# -gt 0 ]; do
+  case $1 in
+    --stand) stand=$2; shift;;
+    --report) report=$2; shift;;
+  esac
+  shift
+done
+echo "gathering $stand"
+case $stand in
+  v1) echo '{"runId":"r1","passed":true,"summary":[{"metric":"latency","samples":3,"avg":100,"p95":120,"max":130}]}' > "$report";;
+  v2) echo '{"runId":"r2","passed":false,"summary":[{"metric":"latency","samples":3,"avg":110,"p95":160,"max":170,"violations":1}]}' > "$report"; exit 1;;
+  *) echo "no stand" >&2; exit 3;;
+esac
+`
+
+func TestExecuteStands(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	requires.NoError(os.WriteFile(configFile, []byte(standsConfig+"  - name: broken\n"), 0o644))
+	script := filepath.Join(dir, "stand.sh")
+	requires.NoError(os.WriteFile(script, []byte(fakeStand), 0o644))
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	app := App{configFile: configFile, outputDir: dir}
+	config, err := app.loadConfig(configFile)
+	requires.NoError(err)
+	report, err := app.executeStands(context.Background(), config, []string{"sh", script})
+	requires.NoError(err)
+	requires.False(report.Passed)
+	requires.Len(report.Stands, 3)
+	requires.Equal(StandReport{Name: "v1", ExitCode: 0, Report: RunReport{RunID: "r1", Passed: true, Summary: []MetricSummary{{Metric: "latency", Samples: 3, Avg: 100, P95: 120, Max: 130}}}}, report.Stands[0])
+	requires.Equal(1, report.Stands[1].ExitCode)
+	requires.Equal("r2", report.Stands[1].Report.RunID)
+	requires.Equal(3, report.Stands[2].ExitCode)
+	requires.Contains(logs.String(), "[v1] gathering v1\n")
+	requires.Contains(logs.String(), "[broken] no stand\n")
+
+	grid := string(renderStandsGrid(report))
+	requires.Equal("| metric | v1 | v2 | broken |\n|---|---|---|---|\n| result | passed | failed | error (exit 3) |\n"+
+		"| latency | avg 100, p95 120, max 130 | avg 110, p95 160, max 170 (+10.0%) **!** |  |\n", grid)
+	matches, err := filepath.Glob(filepath.Join(dir, "stands-*", "stands.md"))
+	requires.NoError(err)
+	requires.Len(matches, 1)
+	b, err := os.ReadFile(matches[0])
+	requires.NoError(err)
+	requires.Equal(grid, string(b))
+	b, err = os.ReadFile(strings.TrimSuffix(matches[0], ".md") + ".json")
+	requires.NoError(err)
+	saved := StandsReport{}
+	requires.NoError(json.Unmarshal(b, &saved))
+	requires.Equal(report, saved)
+}
+
+func TestPlanStands(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	requires.NoError(os.WriteFile(configFile, []byte(standsConfig), 0o644))
+	app := App{configFile: configFile, envManager: &FakeEnvManager{}}
+	config, err := app.loadConfig(configFile)
+	requires.NoError(err)
+	var b bytes.Buffer
+	requires.NoError(app.planStands(context.Background(), config, &b))
+	requires.Contains(b.String(), "=[ stand 1/2: v1 ]")
+	requires.Contains(b.String(), "=[ stand 2/2: v2 ]")
+	requires.Contains(b.String(), "stand: stand-v2\n")
+	requires.Contains(b.String(), "latency (prometheus) latency maxValue 150\n")
+}
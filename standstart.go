@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStartRetryDelay = 10 * time.Second
+	startFailedExitCode    = 5
+)
+
+// StandStart is the start of the stand when it needed retries or failed.
+type StandStart struct {
+	Attempts int    `json:"attempts"`
+	Failed   bool   `json:"failed,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func validateStartRetries(config Config) error {
+	if config.StartRetries < 0 {
+		return errors.New("startRetries must not be negative")
+	}
+	if config.StartRetryDelay < 0 {
+		return errors.New("startRetryDelay must not be negative")
+	}
+	return nil
+}
+
+// startStand starts the environment, a failed attempt is cleaned up with
+// stop before the next one so a half started stand does not hold ports.
+func (scheduler *Scheduler) startStand() error {
+	delay := scheduler.startRetryDelay
+	if delay <= 0 {
+		delay = defaultStartRetryDelay
+	}
+	attempts := scheduler.startRetries + 1
+	for attempt := 1; ; attempt++ {
+		err := scheduler.envManager.start()
+		if err == nil {
+			if attempt > 1 {
+				scheduler.start = &StandStart{Attempts: attempt}
+			}
+			return nil
+		}
+		if attempt >= attempts || scheduler.context().Err() != nil {
+			scheduler.start = &StandStart{Attempts: attempt, Failed: true, Error: err.Error()}
+			return err
+		}
+		log.Printf("WARNING: start attempt %d of %d failed: %v\n", attempt, attempts, err)
+		if err := scheduler.envManager.stop(); err != nil {
+			log.Println(err)
+		}
+		log.Println("retry start in", delay)
+		if !(RealClock{}).sleep(scheduler.context(), delay) {
+			scheduler.start = &StandStart{Attempts: attempt, Failed: true, Error: err.Error()}
+			return err
+		}
+	}
+}
+
+func (scheduler *Scheduler) startFailed() bool {
+	return scheduler.start != nil && scheduler.start.Failed
+}
+
+func renderStandStart(start *StandStart) string {
+	b := strings.Builder{}
+	b.WriteString("## Stand start\n\n")
+	if !start.Failed {
+		fmt.Fprintf(&b, "started on attempt %d\n\n", start.Attempts)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "failed to start (attempts: %d): %s\n\n", start.Attempts, start.Error)
+	return b.String()
+}
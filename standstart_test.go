@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type FlakyEnvManager struct {
+	failures int
+	starts   int
+	stops    int
+}
+
+func (env *FlakyEnvManager) start() error {
+	env.starts++
+	if env.starts <= env.failures {
+		return errors.New("port is already allocated")
+	}
+	return nil
+}
+
+func (env *FlakyEnvManager) stop() error {
+	env.stops++
+	return nil
+}
+
+func TestStartStand(t *testing.T) {
+	requires := require.New(t)
+	variants := []struct {
+		failures int
+		retries  int
+		start    *StandStart
+		stops    int
+	}{
+		{failures: 0, retries: 2},
+		{failures: 2, retries: 2, start: &StandStart{Attempts: 3}, stops: 2},
+		{failures: 3, retries: 2, start: &StandStart{Attempts: 3, Failed: true, Error: "port is already allocated"}, stops: 2},
+		{failures: 1, start: &StandStart{Attempts: 1, Failed: true, Error: "port is already allocated"}},
+	}
+	for _, variant := range variants {
+		env := &FlakyEnvManager{failures: variant.failures}
+		scheduler := Scheduler{envManager: env, startRetries: variant.retries, startRetryDelay: time.Millisecond}
+		err := scheduler.init()
+		requires.Equal(variant.start != nil && variant.start.Failed, err != nil)
+		requires.Equal(variant.start, scheduler.start)
+		requires.Equal(variant.stops, env.stops)
+		requires.Equal(variant.start != nil && variant.start.Failed, scheduler.failed())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	env := &FlakyEnvManager{failures: 3}
+	scheduler := Scheduler{ctx: ctx, envManager: env, startRetries: 5}
+	requires.Error(scheduler.init())
+	requires.Equal(1, env.starts)
+
+	requires.ErrorContains(validateStartRetries(Config{StartRetries: -1}), "startRetries must not be negative")
+	requires.ErrorContains(validateStartRetries(Config{StartRetryDelay: -1}), "startRetryDelay must not be negative")
+}
+
+func TestExecuteStartFailure(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	env := &FlakyEnvManager{failures: 5}
+	config := Config{TestDuration: 1, Timeout: 1, StartRetries: 1, StartRetryDelay: Duration(time.Millisecond)}
+	reporter := Reporter{}
+	scheduler, err := App{envManager: env, outputDir: dir}.execute(context.Background(), config, &reporter)
+	requires.NoError(err)
+	requires.True(scheduler.startFailed())
+	requires.True(scheduler.failed())
+	requires.Equal(2, env.starts)
+	requires.Equal(2, env.stops)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "report.json"))
+	requires.NoError(err)
+	requires.Len(matches, 1)
+	report, err := loadRunReport(matches[0])
+	requires.NoError(err)
+	requires.False(report.Passed)
+	requires.Equal(&StandStart{Attempts: 2, Failed: true, Error: "port is already allocated"}, report.StandStart)
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(matches[0]), "report.md"))
+	requires.NoError(err)
+	requires.Contains(string(b), "## Stand start\n\nfailed to start (attempts: 2): port is already allocated\n")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ProfileYAML struct {
+	Config  `yaml:",inline"`
+	Extends string `yaml:"extends"`
+}
+
+type ConfigYAML struct {
+	Config   `yaml:",inline"`
+	Profiles map[string]ProfileYAML `yaml:"profiles"`
+	Include  []string               `yaml:"include"`
+	Stands   []StandYAML            `yaml:"stands"`
+}
+
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (.+) not found in type (\S+)$`)
+
+func decodeStrict(b []byte, value any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(value); err != nil && !errors.Is(err, io.EOF) {
+		return strictError(err)
+	}
+	return nil
+}
+
+func strictError(err error) error {
+	var typeError *yaml.TypeError
+	if !errors.As(err, &typeError) {
+		return err
+	}
+	keys := map[string][]string{}
+	yamlKeys(reflect.TypeOf(ConfigYAML{}), keys)
+	lines := make([]string, 0, len(typeError.Errors))
+	for _, line := range typeError.Errors {
+		match := unknownFieldPattern.FindStringSubmatch(line)
+		if match == nil {
+			lines = append(lines, line)
+			continue
+		}
+		line = fmt.Sprintf("line %s: unknown key %s", match[1], match[2])
+		if suggestion := closestKey(match[2], keys[match[3]]); suggestion != "" {
+			line += ", did you mean " + suggestion + "?"
+		}
+		lines = append(lines, line)
+	}
+	return errors.New("config errors:\n  " + strings.Join(lines, "\n  "))
+}
+
+// unknownKeys checks the mapping of an UnmarshalYAML with the mappings
+// nested in it, node.Decode does not know KnownFields.
+func unknownKeys(node *yaml.Node, value any) error {
+	var errs []string
+	nestedUnknownKeys(node, reflect.TypeOf(value).Elem(), &errs)
+	if len(errs) > 0 {
+		return &yaml.TypeError{Errors: errs}
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+func nestedUnknownKeys(node *yaml.Node, t reflect.Type, errs *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch {
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			nestedKnownKeys(item, t.Elem(), errs)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for n := 1; n < len(node.Content); n += 2 {
+			nestedKnownKeys(node.Content[n], t.Elem(), errs)
+		}
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for n := 0; n+1 < len(node.Content); n += 2 {
+			key := node.Content[n]
+			field, ok := yamlField(t, key.Value)
+			if !ok {
+				*errs = append(*errs, fmt.Sprintf("line %d: field %s not found in type %s", key.Line, key.Value, t))
+				continue
+			}
+			nestedKnownKeys(node.Content[n+1], field, errs)
+		}
+	}
+}
+
+// nestedKnownKeys leaves a type with its own UnmarshalYAML to it.
+func nestedKnownKeys(node *yaml.Node, t reflect.Type, errs *[]string) {
+	if t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+	nestedUnknownKeys(node, t, errs)
+}
+
+func yamlField(t reflect.Type, key string) (reflect.Type, bool) {
+	for n := range t.NumField() {
+		field := t.Field(n)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if options == "inline" && field.Type.Kind() == reflect.Struct {
+			if inlined, ok := yamlField(field.Type, key); ok {
+				return inlined, true
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if name == key {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+func yamlFields(t reflect.Type) []string {
+	fields := make([]string, 0, t.NumField())
+	for n := range t.NumField() {
+		field := t.Field(n)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if options == "inline" {
+			fields = append(fields, yamlFields(field.Type)...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+func yamlKeys(t reflect.Type, keys map[string][]string) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	if _, ok := keys[t.String()]; ok {
+		return
+	}
+	keys[t.String()] = yamlFields(t)
+	for n := range t.NumField() {
+		if t.Field(n).IsExported() {
+			yamlKeys(t.Field(n).Type, keys)
+		}
+	}
+}
+
+func closestKey(key string, known []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range known {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for n := range previous {
+		previous[n] = n
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigStrict(t *testing.T) {
+	variants := []struct {
+		config  string
+		profile string
+		err     string
+	}{
+		{config: "metrics: [{name: a, query: up, maxVaule: 5}]", err: "line 1: unknown key maxVaule, did you mean maxValue?"},
+		{config: "testDuraton: 10", err: "line 1: unknown key testDuraton, did you mean testDuration?"},
+		{config: "onAbort: {webhok: http://x}", err: "line 1: unknown key webhok, did you mean webhook?"},
+		{config: "scenarios:\n  - name: s\n    metrics:\n      - name: a\n        onMisssing: fail", err: "line 5: unknown key onMisssing, did you mean onMissing?"},
+		{config: "zzz: 1", err: "line 1: unknown key zzz"},
+		{config: "metrics:\n  - name: a\n    type: http\n    probe: {timeoutt: 1s}", err: "line 4: unknown key timeoutt, did you mean timeout?"},
+		{config: "metrics:\n  - name: a\n    anomaly: {sigmaa: 3}", err: "line 3: unknown key sigmaa, did you mean sigma?"},
+		{config: "scenarios:\n  - name: s\n    metrics:\n      - name: a\n        anomaly:\n          baselin: 1m", err: "line 6: unknown key baselin, did you mean baseline?"},
+		{config: "startupProbes:\n  - name: a\n    type: http\n    probe: {methd: HEAD}", err: "line 4: unknown key methd, did you mean method?"},
+		{config: "metrics:\n  - name: a\n    type: http\n    probe: {method: HEAD, timeout: 1s}\n    anomaly: {sigma: 3}"},
+		{config: "host: x\nprofiles: {ci: {extends: base, timout: 1}, base: {}}", err: "line 2: unknown key timout, did you mean timeout?"},
+		{config: "metrics: [{name: a, maxValue: [1]}]", err: "cannot unmarshal"},
+		{config: "host: x\nprofiles: {ci: {extends: base, timeout: 1}, base: {}}", profile: "ci"},
+		{config: "metrics: [{name: a, maxValue: 0.01 * b}, {name: b}]"},
+		{config: ""},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		_, err := App{profile: variant.profile}.parseConfig([]byte(variant.config))
+		if variant.err == "" {
+			requires.NoError(err, variant.config)
+			continue
+		}
+		requires.ErrorContains(err, variant.err, variant.config)
+		if !strings.Contains(variant.err, "did you mean") {
+			requires.NotContains(err.Error(), "did you mean", variant.config)
+		}
+	}
+}
+
+func TestClosestKey(t *testing.T) {
+	requires := require.New(t)
+	requires.Equal("maxValue", closestKey("maxvalue", []string{"name", "maxValue"}))
+	requires.Equal("", closestKey("threshold", []string{"name", "maxValue"}))
+	requires.Equal(2, editDistance("maxVaule", "maxValue"))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+type MetricSummary struct {
+	Scenario       string    `json:"scenario,omitempty"`
+	Metric         string    `json:"metric"`
+	Samples        int       `json:"samples"`
+	Min            float64   `json:"min"`
+	Max            float64   `json:"max"`
+	Avg            float64   `json:"avg"`
+	Median         float64   `json:"median"`
+	P95            float64   `json:"p95"`
+	Stddev         float64   `json:"stddev"`
+	Violations     int       `json:"violations"`
+	Warnings       int       `json:"warnings,omitempty"`
+	FirstViolation time.Time `json:"firstViolation,omitzero"`
+}
+
+func summarize(values []MetricValues) []MetricSummary {
+	summaries := make([]MetricSummary, 0)
+	samples := map[string][]float64{}
+	index := map[string]int{}
+	for _, tick := range values {
+		for _, value := range tick.values {
+			key := teamCityKey(tick.scenario, value.name)
+			if _, ok := index[key]; !ok {
+				index[key] = len(summaries)
+				summaries = append(summaries, MetricSummary{Scenario: tick.scenario, Metric: value.name})
+			}
+			if hasValue(value.value) && !value.excluded {
+				samples[key] = append(samples[key], float64(value.value))
+			}
+			if len(value.warnings) > 0 {
+				summaries[index[key]].Warnings++
+			}
+		}
+		for _, violation := range tick.violations {
+			summary := &summaries[index[teamCityKey(tick.scenario, violation.name)]]
+			if summary.Violations == 0 {
+				summary.FirstViolation = tick.timestamp
+			}
+			summary.Violations++
+		}
+	}
+	for key, n := range index {
+		sorted := samples[key]
+		sort.Float64s(sorted)
+		summarizeSamples(&summaries[n], sorted)
+	}
+	return summaries
+}
+
+func summarizeSamples(summary *MetricSummary, sorted []float64) {
+	if len(sorted) == 0 {
+		return
+	}
+	summary.Samples = len(sorted)
+	summary.Min = sorted[0]
+	summary.Max = sorted[len(sorted)-1]
+	summary.Avg, _ = aggregateValues("avg", sorted)
+	summary.Median = percentile(sorted, 50)
+	summary.P95 = percentile(sorted, 95)
+	summary.Stddev = stddev(sorted)
+}
+
+func reportSummaries(report RunReport) []MetricSummary {
+	if report.Summary != nil {
+		return report.Summary
+	}
+	values := make([]MetricValues, 0, len(report.Values))
+	for _, tick := range report.Values {
+		values = append(values, metricValuesFromJSON(tick))
+	}
+	return summarize(values)
+}
+
+func writeSummaryTable(b *bytes.Buffer, summaries []MetricSummary) {
+	b.WriteString("| metric | samples | min | max | avg | p95 | violations | warnings |\n|---|---|---|---|---|---|---|---|\n")
+	for _, summary := range summaries {
+		fmt.Fprintf(b, "| %s | %d | %g | %g | %.2f | %g | %d | %d |\n", teamCityKey(summary.Scenario, summary.Metric),
+			summary.Samples, summary.Min, summary.Max, summary.Avg, summary.P95, summary.Violations, summary.Warnings)
+	}
+}
+
+func logSummaries(summaries []MetricSummary, colors bool) {
+	if len(summaries) == 0 {
+		return
+	}
+	log.Println("=[ summary ]=================")
+	logTable(summaryTable(summaries), colors)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	requires := require.New(t)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	values := make([]MetricValues, 0)
+	for n, sample := range []int{2, 4, 4, 4, 5, 5, 7, 9, -1} {
+		tick := MetricValues{timestamp: start.Add(time.Duration(n) * time.Second), values: []MetricValue{{name: "a", value: sample}, {name: "b", value: -1}}}
+		if sample > 6 {
+			tick.violations = []MetricValue{{name: "a", value: sample}}
+		}
+		values = append(values, tick)
+	}
+	values = append(values, MetricValues{scenario: "soak", values: []MetricValue{{name: "a", value: 1}}})
+
+	summaries := summarize(values)
+	requires.Len(summaries, 3)
+	a := summaries[0]
+	requires.Equal("a", a.Metric)
+	requires.Equal(8, a.Samples)
+	requires.Equal(2.0, a.Min)
+	requires.Equal(9.0, a.Max)
+	requires.Equal(5.0, a.Avg)
+	requires.Equal(4.0, a.Median)
+	requires.Equal(9.0, a.P95)
+	requires.Equal(2.0, a.Stddev)
+	requires.Equal(2, a.Violations)
+	requires.Equal(start.Add(6*time.Second), a.FirstViolation)
+	requires.Equal(MetricSummary{Metric: "b"}, summaries[1])
+	requires.Equal("soak", summaries[2].Scenario)
+	requires.Equal(1, summaries[2].Samples)
+
+	report := RunReport{Values: []MetricValuesJSON{metricValuesJSON(values[0])}}
+	requires.Len(reportSummaries(report), 2)
+	report.Summary = summaries
+	requires.Len(reportSummaries(report), 3)
+
+	var b bytes.Buffer
+	writeSummaryTable(&b, summaries[:1])
+	requires.Contains(b.String(), "| a | 8 | 2 | 9 | 5.00 | 9 | 2 | 0 |\n")
+}
+
+func TestAggregateStddev(t *testing.T) {
+	requires := require.New(t)
+	value, err := aggregateValues("stddev", []float64{1, 3})
+	requires.NoError(err)
+	requires.Equal(1.0, value)
+	requires.Equal(0.0, stddev([]float64{5}))
+	requires.False(math.IsNaN(stddev([]float64{5, 5})))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v4/common"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+var systemStats = []string{
+	"cpu", "load1", "load5", "load15",
+	"memory", "memory_used", "memory_available", "swap",
+	"disk", "disk_free", "disk_inodes", "fds",
+}
+
+type SystemConfig struct {
+	HostProc string `yaml:"hostProc"`
+}
+
+type SystemSample struct {
+	mutex    sync.Mutex
+	previous cpu.TimesStat
+}
+
+type SystemMetric struct {
+	hostProc string
+	sample   *SystemSample
+	Name     string
+	Stat     string
+	Path     string
+	MaxValue int
+}
+
+func (metric SystemMetric) name() string {
+	return metric.Name
+}
+
+func (metric SystemMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric SystemMetric) context(ctx context.Context) context.Context {
+	if metric.hostProc == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, common.EnvKey, common.EnvMap{common.HostProcEnvKey: metric.hostProc})
+}
+
+func (metric SystemMetric) query(ctx context.Context) (float64, error) {
+	ctx = metric.context(ctx)
+	switch metric.Stat {
+	case "cpu":
+		times, err := cpu.TimesWithContext(ctx, false)
+		if err != nil {
+			return 0, err
+		}
+		if len(times) == 0 {
+			return 0, fmt.Errorf("no cpu times")
+		}
+		return metric.sample.busy(times[0]), nil
+	case "load1", "load5", "load15":
+		avg, err := load.AvgWithContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+		value := map[string]float64{"load1": avg.Load1, "load5": avg.Load5, "load15": avg.Load15}[metric.Stat]
+		return value * 100, nil
+	case "memory", "memory_used", "memory_available":
+		memory, err := mem.VirtualMemoryWithContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+		switch metric.Stat {
+		case "memory_used":
+			return float64(memory.Used), nil
+		case "memory_available":
+			return float64(memory.Available), nil
+		}
+		return memory.UsedPercent, nil
+	case "swap":
+		swap, err := mem.SwapMemoryWithContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return swap.UsedPercent, nil
+	case "disk", "disk_free", "disk_inodes":
+		path := metric.Path
+		if path == "" {
+			path = "/"
+		}
+		usage, err := disk.UsageWithContext(ctx, path)
+		if err != nil {
+			return 0, err
+		}
+		switch metric.Stat {
+		case "disk_free":
+			return float64(usage.Free), nil
+		case "disk_inodes":
+			return usage.InodesUsedPercent, nil
+		}
+		return usage.UsedPercent, nil
+	case "fds":
+		return openFileDescriptors(metric.hostProc)
+	}
+	return 0, fmt.Errorf("unknown system stat %s", metric.Stat)
+}
+
+func (metric SystemMetric) gather(ctx context.Context) int {
+	value, err := metric.query(ctx)
+	if err != nil {
+		log.Printf("Error querying system: %v\n", err)
+		return -1
+	}
+	return int(value)
+}
+
+func (sample *SystemSample) busy(times cpu.TimesStat) float64 {
+	sample.mutex.Lock()
+	defer sample.mutex.Unlock()
+	previous := sample.previous
+	sample.previous = times
+	idle := (times.Idle + times.Iowait) - (previous.Idle + previous.Iowait)
+	total := times.Total() - previous.Total()
+	if total <= 0 {
+		return 0
+	}
+	return max(total-idle, 0) / total * 100
+}
+
+func openFileDescriptors(hostProc string) (float64, error) {
+	if hostProc == "" {
+		hostProc = os.Getenv("HOST_PROC")
+	}
+	if hostProc == "" {
+		hostProc = "/proc"
+	}
+	b, err := os.ReadFile(filepath.Join(hostProc, "sys", "fs", "file-nr"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty file-nr")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func validateSystemMetric(metric Metric) error {
+	if !slices.Contains(systemStats, metric.Query) {
+		return fmt.Errorf("unknown system stat %s", metric.Query)
+	}
+	if metric.Path != "" && !strings.HasPrefix(metric.Query, "disk") {
+		return fmt.Errorf("path is only used by disk stats")
+	}
+	return nil
+}
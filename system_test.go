@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeProc(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"loadavg":        "1.50 0.75 0.25 1/100 12345\n",
+		"meminfo":        "MemTotal:       1000 kB\nMemFree:         200 kB\nMemAvailable:    400 kB\nBuffers:           0 kB\nCached:          100 kB\n",
+		"stat":           "cpu  100 0 100 700 100 0 0 0 0 0\n",
+		"sys/fs/file-nr": "2048\t0\t9223372036854775807\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestSystemMetric(t *testing.T) {
+	hostProc := fakeProc(t)
+	variants := []struct {
+		stat  string
+		value int
+	}{
+		{stat: "cpu", value: 20},
+		{stat: "load1", value: 150},
+		{stat: "load5", value: 75},
+		{stat: "load15", value: 25},
+		{stat: "memory", value: 70},
+		{stat: "memory_used", value: 700 * 1024},
+		{stat: "memory_available", value: 400 * 1024},
+		{stat: "fds", value: 2048},
+		{stat: "unknown", value: -1},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		metric := SystemMetric{hostProc: hostProc, sample: &SystemSample{}, Name: variant.stat, Stat: variant.stat}
+		requires.Equal(variant.value, metric.gather(context.Background()), variant.stat)
+	}
+
+	for _, stat := range []string{"swap", "disk", "disk_inodes"} {
+		value := SystemMetric{Stat: stat, Path: t.TempDir()}.gather(context.Background())
+		requires.True(value >= 0 && value <= 100, stat, value)
+	}
+	requires.Positive(SystemMetric{Stat: "disk_free"}.gather(context.Background()))
+	requires.Equal(-1, SystemMetric{Stat: "disk", Path: filepath.Join(t.TempDir(), "missing")}.gather(context.Background()))
+}
+
+func TestSystemSampleBusy(t *testing.T) {
+	requires := require.New(t)
+	sample := &SystemSample{}
+	requires.Equal(20.0, sample.busy(cpu.TimesStat{User: 100, System: 100, Idle: 700, Iowait: 100}))
+	requires.Equal(50.0, sample.busy(cpu.TimesStat{User: 150, System: 150, Idle: 800, Iowait: 100}))
+	requires.Equal(0.0, sample.busy(cpu.TimesStat{User: 150, System: 150, Idle: 800, Iowait: 100}))
+}
+
+func TestValidateSystemMetric(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{{Name: "load", Type: "system", Query: "load1"}}}))
+	requires.NoError(validateMetricTypes(Config{Metrics: []Metric{{Name: "disk", Type: "system", Query: "disk", Path: "/data"}}}))
+	requires.ErrorContains(validateMetricTypes(Config{Metrics: []Metric{{Name: "x", Type: "system", Query: "temperature"}}}), "metric x: unknown system stat temperature")
+	requires.ErrorContains(validateMetricTypes(Config{Metrics: []Metric{{Name: "x", Type: "system", Query: "cpu", Path: "/"}}}), "path is only used by disk stats")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+type TableCell struct {
+	text  string
+	color string
+}
+
+type Table struct {
+	header []string
+	rows   [][]TableCell
+}
+
+func (table *Table) add(cells ...TableCell) {
+	table.rows = append(table.rows, cells)
+}
+
+func (table Table) lines(colors bool) []string {
+	widths := make([]int, len(table.header))
+	for n, title := range table.header {
+		widths[n] = utf8.RuneCountInString(title)
+	}
+	for _, row := range table.rows {
+		for n, cell := range row {
+			widths[n] = max(widths[n], utf8.RuneCountInString(cell.text))
+		}
+	}
+	format := func(n int, text string, color string) string {
+		padding := strings.Repeat(" ", widths[n]-utf8.RuneCountInString(text))
+		if n > 0 {
+			text = padding + text
+		} else {
+			text += padding
+		}
+		if colors && color != "" {
+			return color + text + colorReset
+		}
+		return text
+	}
+	lines := make([]string, 0, len(table.rows)+2)
+	header := make([]string, len(table.header))
+	separator := make([]string, len(table.header))
+	for n, title := range table.header {
+		header[n] = format(n, title, colorBold)
+		separator[n] = strings.Repeat("-", widths[n])
+	}
+	lines = append(lines, strings.Join(header, "  "), strings.Join(separator, "  "))
+	for _, row := range table.rows {
+		cells := make([]string, len(row))
+		for n, cell := range row {
+			cells[n] = format(n, cell.text, cell.color)
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+	return lines
+}
+
+func valuesTable(values []MetricValues) Table {
+	names := make([]string, 0)
+	for _, tick := range values {
+		for _, value := range tick.values {
+			if !slices.Contains(names, value.name) {
+				names = append(names, value.name)
+			}
+		}
+	}
+	table := Table{header: append([]string{"time"}, names...)}
+	for _, tick := range values {
+		row := []TableCell{{text: tick.timestamp.Format(time.TimeOnly)}}
+		for _, name := range names {
+			row = append(row, valueCell(tick, name))
+		}
+		table.add(row...)
+	}
+	return table
+}
+
+func valueCell(tick MetricValues, name string) TableCell {
+	n := slices.IndexFunc(tick.values, func(value MetricValue) bool { return value.name == name })
+	if n < 0 {
+		return TableCell{}
+	}
+	value := tick.values[n].value
+	cell := TableCell{text: strconv.Itoa(value)}
+	if !hasValue(value) {
+		cell = TableCell{text: "-", color: colorYellow}
+	}
+	if violation, ok := violatedValue(tick, name); ok {
+		cell.color = colorRed
+		if violation.tolerated {
+			cell.color = colorYellow
+		}
+	}
+	return cell
+}
+
+func summaryTable(summaries []MetricSummary) Table {
+	table := Table{header: []string{"metric", "samples", "min", "max", "avg", "median", "p95", "stddev", "violations", "first violation", "warnings"}}
+	for _, summary := range summaries {
+		status, first := colorGreen, ""
+		if summary.Violations > 0 {
+			status, first = colorRed, summary.FirstViolation.Format(time.RFC3339)
+		}
+		table.add(
+			TableCell{text: teamCityKey(summary.Scenario, summary.Metric), color: status},
+			TableCell{text: strconv.Itoa(summary.Samples)},
+			TableCell{text: fmt.Sprintf("%g", summary.Min)},
+			TableCell{text: fmt.Sprintf("%g", summary.Max)},
+			TableCell{text: fmt.Sprintf("%.2f", summary.Avg)},
+			TableCell{text: fmt.Sprintf("%g", summary.Median)},
+			TableCell{text: fmt.Sprintf("%g", summary.P95)},
+			TableCell{text: fmt.Sprintf("%.2f", summary.Stddev)},
+			TableCell{text: strconv.Itoa(summary.Violations), color: status},
+			TableCell{text: first},
+			TableCell{text: strconv.Itoa(summary.Warnings)},
+		)
+	}
+	return table
+}
+
+func logTable(table Table, colors bool) {
+	for _, line := range table.lines(colors) {
+		log.Println(" " + line)
+	}
+}
+
+func (app App) colors() bool {
+	if app.noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	stat, err := os.Stderr.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+type PlainWriter struct {
+	writer io.Writer
+}
+
+func (writer PlainWriter) Write(p []byte) (int, error) {
+	if _, err := writer.writer.Write(ansiPattern.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
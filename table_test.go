@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableLines(t *testing.T) {
+	requires := require.New(t)
+	table := Table{header: []string{"metric", "value"}}
+	table.add(TableCell{text: "errors"}, TableCell{text: "12", color: colorRed})
+	table.add(TableCell{text: "cpu_usage"}, TableCell{text: "7"})
+	requires.Equal([]string{
+		"metric     value",
+		"---------  -----",
+		"errors        12",
+		"cpu_usage      7",
+	}, table.lines(false))
+	requires.Equal([]string{
+		colorBold + "metric   " + colorReset + "  " + colorBold + "value" + colorReset,
+		"---------  -----",
+		"errors     " + colorRed + "   12" + colorReset,
+		"cpu_usage      7",
+	}, table.lines(true))
+}
+
+func TestValuesTable(t *testing.T) {
+	requires := require.New(t)
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	values := []MetricValues{
+		{timestamp: at, values: []MetricValue{{name: "errors", value: 0}, {name: "latency", value: 120}}},
+		{
+			timestamp:  at.Add(5 * time.Second),
+			values:     []MetricValue{{name: "errors", value: 3}, {name: "latency", value: -1}},
+			violations: []MetricValue{{name: "errors", value: 3}},
+		},
+		{
+			timestamp:  at.Add(10 * time.Second),
+			values:     []MetricValue{{name: "errors", value: 2}, {name: "queue", value: 7}},
+			violations: []MetricValue{{name: "errors", value: 2, tolerated: true}},
+		},
+	}
+	table := valuesTable(values)
+	requires.Equal([]string{"time", "errors", "latency", "queue"}, table.header)
+	requires.Equal([]TableCell{{text: "03:04:15"}, {text: "2", color: colorYellow}, {}, {text: "7"}}, table.rows[2])
+	requires.Equal([]TableCell{{text: "03:04:10"}, {text: "3", color: colorRed}, {text: "-", color: colorYellow}, {}}, table.rows[1])
+	requires.Equal([]string{
+		"time      errors  latency  queue",
+		"--------  ------  -------  -----",
+		"03:04:05       0      120",
+		"03:04:10       3        -",
+		"03:04:15       2               7",
+	}, table.lines(false))
+
+	summaries := summaryTable([]MetricSummary{
+		{Metric: "errors", Samples: 3, Max: 3, Avg: 5.0 / 3, Violations: 2, FirstViolation: at.UTC()},
+		{Scenario: "ramp", Metric: "latency", Samples: 1, Min: 120, Max: 120, Avg: 120},
+	})
+	requires.Equal(TableCell{text: "errors", color: colorRed}, summaries.rows[0][0])
+	requires.Equal(TableCell{text: "1.67"}, summaries.rows[0][4])
+	requires.Equal(TableCell{text: at.UTC().Format(time.RFC3339)}, summaries.rows[0][9])
+	requires.Equal(TableCell{text: "ramp/latency", color: colorGreen}, summaries.rows[1][0])
+	requires.Equal(TableCell{}, summaries.rows[1][9])
+}
+
+func TestReporterReport(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	writer, flags := log.Writer(), log.Flags()
+	log.SetOutput(&b)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	}()
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	reporter := &Reporter{}
+	reporter.sendResult(MetricValues{timestamp: at, values: []MetricValue{{name: "a", value: 1}}})
+	reporter.sendResult(MetricValues{scenario: "ramp", timestamp: at.Add(time.Second), values: []MetricValue{{name: "a", value: 2}}})
+	reporter.report(false)
+	requires.Equal(`=[ report ]==================
+ time      a
+ --------  -
+ 03:04:05  1
+ scenario: ramp
+ time      a
+ --------  -
+ 03:04:06  2
+=[ summary ]=================
+ metric  samples  min  max   avg  median  p95  stddev  violations  first violation  warnings
+ ------  -------  ---  ---  ----  ------  ---  ------  ----------  ---------------  --------
+ a             1    1    1  1.00       1    1    0.00           0                          0
+ ramp/a        1    2    2  2.00       2    2    0.00           0                          0
+=[ end ]=====================
+`, b.String())
+}
+
+func TestPlainWriter(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	n, err := PlainWriter{writer: &b}.Write([]byte(colorRed + "12" + colorReset + " ok\n"))
+	requires.NoError(err)
+	requires.Equal(len(colorRed+"12"+colorReset+" ok\n"), n)
+	requires.Equal("12 ok\n", b.String())
+
+	requires.False(App{noColor: true}.colors())
+	t.Setenv("NO_COLOR", "1")
+	requires.False(App{}.colors())
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var teamCityEscaper = strings.NewReplacer(
+	"|", "||",
+	"'", "|'",
+	"\n", "|n",
+	"\r", "|r",
+	"[", "|[",
+	"]", "|]",
+)
+
+type TeamCityReporter struct {
+	writer io.Writer
+}
+
+func (app App) teamCity() *TeamCityReporter {
+	if !app.teamCityOutput && os.Getenv("TEAMCITY_VERSION") == "" {
+		return nil
+	}
+	return &TeamCityReporter{writer: os.Stdout}
+}
+
+func (teamCity TeamCityReporter) message(name string, attributes ...string) {
+	var b strings.Builder
+	b.WriteString("##teamcity[" + name)
+	for i := 0; i+1 < len(attributes); i += 2 {
+		b.WriteString(" " + attributes[i] + "='" + teamCityEscaper.Replace(attributes[i+1]) + "'")
+	}
+	b.WriteString("]\n")
+	_, _ = io.WriteString(teamCity.writer, b.String())
+}
+
+func (teamCity TeamCityReporter) test(name string, failure string) {
+	teamCity.message("testStarted", "name", name)
+	if failure != "" {
+		teamCity.message("testFailed", "name", name, "message", failure)
+	}
+	teamCity.message("testFinished", "name", name)
+}
+
+func teamCityKey(scenario string, metric string) string {
+	if scenario == "" {
+		return metric
+	}
+	return scenario + "/" + metric
+}
+
+func (teamCity TeamCityReporter) attach(reporter *Reporter) {
+	reporter.subscribe(func(values MetricValues) {
+		for _, value := range values.values {
+			if hasValue(value.value) {
+				teamCity.message("buildStatisticValue", "key", teamCityKey(values.scenario, value.name), "value", strconv.Itoa(value.value))
+			}
+		}
+		for _, violation := range values.violations {
+			key := teamCityKey(values.scenario, violation.name)
+			if violation.tolerated {
+				teamCity.message("message", "text", fmt.Sprintf("%s is %d, above maxValue within allowedViolations", key, violation.value), "status", "WARNING")
+				continue
+			}
+			teamCity.test("maxValue "+key+" "+values.timestamp.Format("15:04:05"), fmt.Sprintf("%s is %d, above maxValue", key, violation.value))
+		}
+	})
+}
+
+func (teamCity TeamCityReporter) assertions(results []AssertionResult) {
+	for _, result := range results {
+		name := teamCityKey(result.scenario, result.metric) + " " + result.assertion.String()
+		switch {
+		case result.noData:
+			teamCity.test(name, "no data")
+		case result.ok:
+			teamCity.test(name, "")
+		default:
+			teamCity.test(name, "actual "+strconv.FormatFloat(result.value, 'f', -1, 64))
+		}
+	}
+}
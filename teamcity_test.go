@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamCityAttach(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	reporter := &Reporter{}
+	TeamCityReporter{writer: &b}.attach(reporter)
+	reporter.sendResult(MetricValues{
+		scenario:   "soak",
+		timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		values:     []MetricValue{{name: "errors", value: 3}, {name: "rps", value: -1}},
+		violations: []MetricValue{{name: "errors", value: 3}},
+	})
+	requires.Equal("##teamcity[buildStatisticValue key='soak/errors' value='3']\n"+
+		"##teamcity[testStarted name='maxValue soak/errors 03:04:05']\n"+
+		"##teamcity[testFailed name='maxValue soak/errors 03:04:05' message='soak/errors is 3, above maxValue']\n"+
+		"##teamcity[testFinished name='maxValue soak/errors 03:04:05']\n", b.String())
+	b.Reset()
+	reporter.sendResult(MetricValues{
+		scenario:   "soak",
+		timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		violations: []MetricValue{{name: "errors", value: 3, tolerated: true}},
+	})
+	requires.Equal("##teamcity[message text='soak/errors is 3, above maxValue within allowedViolations' status='WARNING']\n", b.String())
+}
+
+func TestTeamCityAssertions(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	avg, _ := parseAssertion("avg < 5")
+	TeamCityReporter{writer: &b}.assertions([]AssertionResult{
+		{RunAssertion: RunAssertion{metric: "a", assertion: avg}, value: 1, ok: true},
+		{RunAssertion: RunAssertion{metric: "b", assertion: avg}, value: 7.5},
+		{RunAssertion: RunAssertion{metric: "c", assertion: avg}, noData: true},
+	})
+	requires.Equal("##teamcity[testStarted name='a avg < 5']\n"+
+		"##teamcity[testFinished name='a avg < 5']\n"+
+		"##teamcity[testStarted name='b avg < 5']\n"+
+		"##teamcity[testFailed name='b avg < 5' message='actual 7.5']\n"+
+		"##teamcity[testFinished name='b avg < 5']\n"+
+		"##teamcity[testStarted name='c avg < 5']\n"+
+		"##teamcity[testFailed name='c avg < 5' message='no data']\n"+
+		"##teamcity[testFinished name='c avg < 5']\n", b.String())
+}
+
+func TestTeamCityEscape(t *testing.T) {
+	requires := require.New(t)
+	var b bytes.Buffer
+	TeamCityReporter{writer: &b}.message("message", "text", "it's [a]|b\n")
+	requires.Equal("##teamcity[message text='it|'s |[a|]||b|n']\n", b.String())
+}
+
+func TestAppTeamCity(t *testing.T) {
+	requires := require.New(t)
+	t.Setenv("TEAMCITY_VERSION", "")
+	requires.Nil(App{}.teamCity())
+	requires.NotNil(App{teamCityOutput: true}.teamCity())
+	t.Setenv("TEAMCITY_VERSION", "2024.1")
+	requires.NotNil(App{}.teamCity())
+}
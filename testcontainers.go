@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type TestcontainersConfig struct {
+	ComposeFile string            `yaml:"composeFile"`
+	Timeout     Duration          `yaml:"timeout"`
+	Wait        map[string]string `yaml:"wait"`
+}
+
+type WaitSpec struct {
+	kind string
+	arg  string
+}
+
+func parseWaitSpec(spec string) (WaitSpec, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "healthy", "running":
+		if arg != "" {
+			return WaitSpec{}, fmt.Errorf("wait %q: %s takes no argument", spec, kind)
+		}
+	case "log", "port":
+		if arg == "" {
+			return WaitSpec{}, fmt.Errorf("wait %q: %s requires an argument", spec, kind)
+		}
+	default:
+		return WaitSpec{}, fmt.Errorf("wait %q: expected healthy, running, log:<text> or port:<port>", spec)
+	}
+	return WaitSpec{kind: kind, arg: arg}, nil
+}
+
+func validateEnvManager(config Config) error {
+	switch config.EnvManager {
+	case "", "compose":
+		return nil
+	case "ssh":
+		if len(config.Environments) > 0 {
+			return errors.New("envManager ssh does not support environments")
+		}
+		return validateSSH(config.SSH)
+	case "nomad":
+		if len(config.Environments) > 0 {
+			return errors.New("envManager nomad does not support environments")
+		}
+		return validateNomad(config.Nomad)
+	case "testcontainers":
+	default:
+		return fmt.Errorf("unknown envManager %s", config.EnvManager)
+	}
+	if !testcontainersSupported {
+		return errors.New("envManager testcontainers requires a build with -tags testcontainers")
+	}
+	if len(config.Environments) > 0 {
+		return errors.New("envManager testcontainers does not support environments")
+	}
+	for service, spec := range config.Testcontainers.Wait {
+		if _, err := parseWaitSpec(spec); err != nil {
+			return fmt.Errorf("service %s: %w", service, err)
+		}
+	}
+	return nil
+}
+
+func testcontainersComposeFile(config Config) string {
+	file := config.Testcontainers.ComposeFile
+	if file == "" {
+		file = "docker-compose.yaml"
+	}
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(config.WorkDir, file)
+}
+
+func testcontainersWaits(config Config) map[string]WaitSpec {
+	waits := map[string]WaitSpec{}
+	for service, spec := range config.Testcontainers.Wait {
+		if wait, err := parseWaitSpec(spec); err == nil {
+			waits[service] = wait
+		}
+	}
+	return waits
+}
+
+func newTestcontainersEnv(config Config, teardownTimeout time.Duration) *TestcontainersEnv {
+	return &TestcontainersEnv{
+		composeFile:     testcontainersComposeFile(config),
+		identifier:      "metricsgatherer-" + strings.ToLower(newRunID()),
+		waits:           testcontainersWaits(config),
+		timeout:         time.Duration(config.Testcontainers.Timeout),
+		teardownTimeout: teardownTimeout,
+	}
+}
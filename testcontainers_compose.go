@@ -0,0 +1,105 @@
+//go:build testcontainers
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	tccompose "github.com/testcontainers/testcontainers-go/modules/compose"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const testcontainersSupported = true
+
+const defaultTestcontainersTimeout = 5 * time.Minute
+
+type TestcontainersEnv struct {
+	composeFile     string
+	identifier      string
+	waits           map[string]WaitSpec
+	timeout         time.Duration
+	teardownTimeout time.Duration
+	stack           tccompose.ComposeStack
+}
+
+func waitStrategy(spec WaitSpec, timeout time.Duration) wait.Strategy {
+	switch spec.kind {
+	case "healthy":
+		return wait.ForHealthCheck().WithStartupTimeout(timeout)
+	case "log":
+		return wait.ForLog(spec.arg).WithStartupTimeout(timeout)
+	case "port":
+		return wait.ForListeningPort(nat.Port(spec.arg)).WithStartupTimeout(timeout)
+	}
+	return nil
+}
+
+func (env *TestcontainersEnv) start() error {
+	timeout := env.timeout
+	if timeout <= 0 {
+		timeout = defaultTestcontainersTimeout
+	}
+	stack, err := tccompose.NewDockerComposeWith(
+		tccompose.WithStackFiles(env.composeFile),
+		tccompose.StackIdentifier(env.identifier),
+	)
+	if err != nil {
+		return err
+	}
+	env.stack = stack
+	for service, spec := range env.waits {
+		if strategy := waitStrategy(spec, timeout); strategy != nil {
+			env.stack = env.stack.WaitForService(service, strategy)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	log.Println("testcontainers up:", env.composeFile)
+	return env.stack.Up(ctx, tccompose.Wait(true))
+}
+
+func (env *TestcontainersEnv) stop() error {
+	if env.stack == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), env.teardownTimeout)
+	defer cancel()
+	log.Println("testcontainers down:", env.composeFile)
+	return env.stack.Down(ctx, tccompose.RemoveOrphans(true), tccompose.RemoveVolumes(true))
+}
+
+func (env *TestcontainersEnv) logs() ([]byte, error) {
+	if env.stack == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	out := make([]byte, 0)
+	services := env.stack.Services()
+	slices.Sort(services)
+	for _, service := range services {
+		container, err := env.stack.ServiceContainer(ctx, service)
+		if err != nil {
+			return out, err
+		}
+		reader, err := container.Logs(ctx)
+		if err != nil {
+			return out, err
+		}
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			out = fmt.Appendf(out, "%s | %s\n", service, scanner.Text())
+		}
+		_ = reader.Close()
+		if err := scanner.Err(); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,26 @@
+//go:build !testcontainers
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const testcontainersSupported = false
+
+type TestcontainersEnv struct {
+	composeFile     string
+	identifier      string
+	waits           map[string]WaitSpec
+	timeout         time.Duration
+	teardownTimeout time.Duration
+}
+
+func (env *TestcontainersEnv) start() error {
+	return errors.New("envManager testcontainers requires a build with -tags testcontainers")
+}
+
+func (env *TestcontainersEnv) stop() error {
+	return nil
+}
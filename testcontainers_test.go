@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWaitSpec(t *testing.T) {
+	variants := []struct {
+		spec string
+		wait WaitSpec
+		err  string
+	}{
+		{"healthy", WaitSpec{kind: "healthy"}, ""},
+		{"running", WaitSpec{kind: "running"}, ""},
+		{"log:ready to accept connections", WaitSpec{kind: "log", arg: "ready to accept connections"}, ""},
+		{"port:8080/tcp", WaitSpec{kind: "port", arg: "8080/tcp"}, ""},
+		{"healthy:1", WaitSpec{}, `wait "healthy:1": healthy takes no argument`},
+		{"log", WaitSpec{}, `wait "log": log requires an argument`},
+		{"http:/", WaitSpec{}, `wait "http:/": expected healthy, running, log:<text> or port:<port>`},
+	}
+	for _, variant := range variants {
+		t.Run(variant.spec, func(t *testing.T) {
+			requires := require.New(t)
+			wait, err := parseWaitSpec(variant.spec)
+			if variant.err != "" {
+				requires.EqualError(err, variant.err)
+				return
+			}
+			requires.NoError(err)
+			requires.Equal(variant.wait, wait)
+		})
+	}
+}
+
+func TestValidateEnvManager(t *testing.T) {
+	variants := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"default", Config{}, ""},
+		{"compose", Config{EnvManager: "compose"}, ""},
+		{"unknown", Config{EnvManager: "podman"}, "unknown envManager podman"},
+		{"testcontainers", Config{EnvManager: "testcontainers", Testcontainers: TestcontainersConfig{Wait: map[string]string{"api": "healthy"}}}, ""},
+		{"environments", Config{EnvManager: "testcontainers", Environments: []EnvironmentConfig{{Name: "db"}}}, "envManager testcontainers does not support environments"},
+		{"wait", Config{EnvManager: "testcontainers", Testcontainers: TestcontainersConfig{Wait: map[string]string{"api": "ready"}}}, `service api: wait "ready": expected healthy, running, log:<text> or port:<port>`},
+	}
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			err := validateEnvManager(variant.config)
+			if variant.config.EnvManager == "testcontainers" && !testcontainersSupported {
+				require.EqualError(t, err, "envManager testcontainers requires a build with -tags testcontainers")
+				return
+			}
+			if variant.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, variant.err)
+		})
+	}
+}
+
+func TestAppTuneTestcontainers(t *testing.T) {
+	requires := require.New(t)
+	config := Config{
+		WorkDir:    "/stand",
+		EnvManager: "testcontainers",
+		Testcontainers: TestcontainersConfig{
+			ComposeFile: "compose.perf.yaml",
+			Timeout:     Duration(time.Minute),
+			Wait:        map[string]string{"api": "healthy", "db": "log:ready"},
+		},
+	}
+	scheduler := App{}.tune(&Reporter{}, config, Sources{})
+	env, ok := scheduler.envManager.(*TestcontainersEnv)
+	requires.True(ok)
+	requires.Equal("/stand/compose.perf.yaml", env.composeFile)
+	requires.Regexp("^metricsgatherer-[0-9a-z]{26}$", env.identifier)
+	requires.Equal(map[string]WaitSpec{"api": {kind: "healthy"}, "db": {kind: "log", arg: "ready"}}, env.waits)
+	requires.Equal(time.Minute, env.timeout)
+	requires.Equal(defaultTeardownTimeout, env.teardownTimeout)
+	requires.Equal("/abs/compose.yaml", testcontainersComposeFile(Config{WorkDir: "/stand", Testcontainers: TestcontainersConfig{ComposeFile: "/abs/compose.yaml"}}))
+	requires.Equal("/stand/docker-compose.yaml", testcontainersComposeFile(Config{WorkDir: "/stand"}))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type RelativeThreshold struct {
+	Factor float64
+	Ref    string
+}
+
+func (threshold RelativeThreshold) String() string {
+	if threshold.Factor == 1 {
+		return threshold.Ref
+	}
+	return strconv.FormatFloat(threshold.Factor, 'g', -1, 64) + " * " + threshold.Ref
+}
+
+func parseFactor(text string) (float64, bool) {
+	if percent, ok := strings.CutSuffix(text, "%"); ok {
+		factor, err := strconv.ParseFloat(percent, 64)
+		return factor / 100, err == nil
+	}
+	factor, err := strconv.ParseFloat(text, 64)
+	return factor, err == nil
+}
+
+func isMetricName(text string) bool {
+	if text == "" {
+		return false
+	}
+	for n, r := range text {
+		if !(r == '_' || r == '-' || r == '.' || r == '/' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || n > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRelativeThreshold(text string) (RelativeThreshold, error) {
+	left, right, found := strings.Cut(text, "*")
+	left, right = strings.TrimSpace(left), strings.TrimSpace(right)
+	switch {
+	case !found && isMetricName(left):
+		return RelativeThreshold{Factor: 1, Ref: left}, nil
+	case found && isMetricName(right):
+		if factor, ok := parseFactor(left); ok {
+			return RelativeThreshold{Factor: factor, Ref: right}, nil
+		}
+	case found && isMetricName(left):
+		if factor, ok := parseFactor(right); ok {
+			return RelativeThreshold{Factor: factor, Ref: left}, nil
+		}
+	}
+	return RelativeThreshold{}, fmt.Errorf("maxValue %q: expected a number, <metric> or <factor> * <metric>", text)
+}
+
+func (metric *Metric) UnmarshalYAML(node *yaml.Node) error {
+	type plain Metric
+	var relative *RelativeThreshold
+	if node.Kind == yaml.MappingNode {
+		if err := unknownKeys(node, metric); err != nil {
+			return err
+		}
+		content := make([]*yaml.Node, 0, len(node.Content))
+		for n := 0; n+1 < len(node.Content); n += 2 {
+			key, value := node.Content[n], node.Content[n+1]
+			if key.Value == "maxValue" && value.Kind == yaml.ScalarNode && value.ShortTag() == "!!str" {
+				threshold, err := parseRelativeThreshold(value.Value)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", value.Line, err)
+				}
+				relative = &threshold
+				continue
+			}
+			content = append(content, key, value)
+		}
+		copied := *node
+		copied.Content = content
+		node = &copied
+	}
+	if err := node.Decode((*plain)(metric)); err != nil {
+		return err
+	}
+	metric.RelativeMax = relative
+	return nil
+}
+
+func (metric Metric) MarshalYAML() (any, error) {
+	type plain Metric
+	if metric.RelativeMax == nil {
+		return plain(metric), nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(plain(metric)); err != nil {
+		return nil, err
+	}
+	for n := 0; n+1 < len(node.Content); n += 2 {
+		if node.Content[n].Value == "maxValue" {
+			node.Content[n+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: metric.RelativeMax.String()}
+		}
+	}
+	return node, nil
+}
+
+func relativeThresholds(metrics []Metric) map[string]RelativeThreshold {
+	thresholds := map[string]RelativeThreshold{}
+	for _, metric := range metrics {
+		if metric.RelativeMax != nil {
+			thresholds[metric.Name] = *metric.RelativeMax
+		}
+	}
+	return thresholds
+}
+
+func validateRelativeThresholds(metrics []Metric) error {
+	names := map[string]bool{}
+	for _, metric := range metrics {
+		names[metric.Name] = true
+	}
+	for _, metric := range metrics {
+		if metric.RelativeMax == nil {
+			continue
+		}
+		if metric.RelativeMax.Ref == metric.Name {
+			return fmt.Errorf("metric %s: maxValue references itself", metric.Name)
+		}
+		if !names[metric.RelativeMax.Ref] {
+			return fmt.Errorf("metric %s: maxValue references unknown metric %s", metric.Name, metric.RelativeMax.Ref)
+		}
+		if metric.OnMissing == "treatAsMax" {
+			return fmt.Errorf("metric %s: onMissing treatAsMax needs a fixed maxValue", metric.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseRelativeThreshold(t *testing.T) {
+	variants := []struct {
+		text      string
+		threshold RelativeThreshold
+		err       bool
+	}{
+		{"0.01 * requests_total", RelativeThreshold{Factor: 0.01, Ref: "requests_total"}, false},
+		{"requests_total*0.5", RelativeThreshold{Factor: 0.5, Ref: "requests_total"}, false},
+		{"1% * requests", RelativeThreshold{Factor: 0.01, Ref: "requests"}, false},
+		{"capacity", RelativeThreshold{Factor: 1, Ref: "capacity"}, false},
+		{"0.01 * ", RelativeThreshold{}, true},
+		{"a * b", RelativeThreshold{}, true},
+		{"requests + 1", RelativeThreshold{}, true},
+	}
+	for _, variant := range variants {
+		t.Run(variant.text, func(t *testing.T) {
+			requires := require.New(t)
+			threshold, err := parseRelativeThreshold(variant.text)
+			if variant.err {
+				requires.Error(err)
+				return
+			}
+			requires.NoError(err)
+			requires.Equal(variant.threshold, threshold)
+		})
+	}
+}
+
+func TestMetricYAMLRelativeMaxValue(t *testing.T) {
+	requires := require.New(t)
+	config, err := App{}.parseConfig([]byte(`
+metrics:
+  - name: requests
+    query: sum(requests)
+    maxValue: 100000
+  - name: errors
+    query: sum(errors)
+    maxValue: "0.01 * requests"
+`))
+	requires.NoError(err)
+	requires.Equal(100000, config.Metrics[0].MaxValue)
+	requires.Nil(config.Metrics[0].RelativeMax)
+	requires.Equal("sum(errors)", config.Metrics[1].Query)
+	requires.Equal(&RelativeThreshold{Factor: 0.01, Ref: "requests"}, config.Metrics[1].RelativeMax)
+	b, err := yaml.Marshal(config.Metrics)
+	requires.NoError(err)
+	requires.Contains(string(b), "maxValue: 0.01 * requests\n")
+	requires.Contains(string(b), "maxValue: 100000\n")
+	_, err = App{}.parseConfig([]byte("metrics: [{name: a, maxValue: \"x + 1\"}]"))
+	requires.EqualError(err, `line 1: maxValue "x + 1": expected a number, <metric> or <factor> * <metric>`)
+}
+
+type ValueMetricGather struct {
+	metricName string
+	value      int
+	max        int
+}
+
+func (m ValueMetricGather) name() string                   { return m.metricName }
+func (m ValueMetricGather) gather(ctx context.Context) int { return m.value }
+func (m ValueMetricGather) maxValue() int                  { return m.max }
+
+func TestGathererRelativeThreshold(t *testing.T) {
+	variants := []struct {
+		name       string
+		errors     int
+		requests   int
+		violations int
+	}{
+		{"below", 9, 1000, 0},
+		{"equal", 10, 1000, 0},
+		{"above", 11, 1000, 1},
+		{"no requests", 11, -1, 0},
+	}
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			requires := require.New(t)
+			gatherer := Gatherer{
+				metrics: []MetricGather{
+					ValueMetricGather{metricName: "errors", value: variant.errors},
+					ValueMetricGather{metricName: "requests", value: variant.requests, max: 1 << 30},
+				},
+				relative: map[string]RelativeThreshold{"errors": {Factor: 0.01, Ref: "requests"}},
+			}
+			values, ok := gatherer.gatherAndCheck(context.Background(), time.Now())
+			requires.Len(values.violations, variant.violations)
+			requires.Equal(variant.violations == 0, ok)
+		})
+	}
+}
+
+func TestValidateRelativeThresholds(t *testing.T) {
+	variants := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"ok", Config{Metrics: []Metric{{Name: "r"}, {Name: "e", RelativeMax: &RelativeThreshold{Factor: 0.01, Ref: "r"}}}}, ""},
+		{"scenario ref", Config{
+			Metrics:   []Metric{{Name: "e", RelativeMax: &RelativeThreshold{Factor: 0.01, Ref: "r"}}},
+			Scenarios: []Scenario{{Name: "s", Metrics: []Metric{{Name: "r"}}}},
+		}, ""},
+		{"self", Config{Metrics: []Metric{{Name: "e", RelativeMax: &RelativeThreshold{Factor: 1, Ref: "e"}}}}, "metric e: maxValue references itself"},
+		{"treatAsMax", Config{Metrics: []Metric{{Name: "r"}, {Name: "e", OnMissing: "treatAsMax", RelativeMax: &RelativeThreshold{Factor: 1, Ref: "r"}}}},
+			"metric e: onMissing treatAsMax needs a fixed maxValue"},
+		{"scenario", Config{Scenarios: []Scenario{{Name: "s", Metrics: []Metric{{Name: "e", RelativeMax: &RelativeThreshold{Factor: 1, Ref: "x"}}}}}},
+			"scenario s: metric e: maxValue references unknown metric x"},
+	}
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			err := validateMetricTypes(variant.config)
+			if variant.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, variant.err)
+		})
+	}
+}
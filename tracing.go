@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultTracingService  = "metricsgatherer"
+	defaultTracesPath      = "/v1/traces"
+	tracerName             = "github.com/abatalev/metricsgatherer"
+	tracingShutdownTimeout = 10 * time.Second
+)
+
+type TracingConfig struct {
+	Endpoint    string            `yaml:"endpoint"`
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"serviceName"`
+}
+
+func (config TracingConfig) service() string {
+	if config.ServiceName == "" {
+		return defaultTracingService
+	}
+	return config.ServiceName
+}
+
+func (config TracingConfig) endpoint() string {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return config.Endpoint
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultTracesPath
+	}
+	return u.String()
+}
+
+func validateTracing(config TracingConfig) error {
+	if config.Endpoint == "" {
+		if len(config.Headers) > 0 || config.ServiceName != "" {
+			return errors.New("tracing: endpoint is not set")
+		}
+		return nil
+	}
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return fmt.Errorf("tracing: endpoint: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("tracing: endpoint %s is not an http(s) url", config.Endpoint)
+	}
+	return nil
+}
+
+func startTracing(ctx context.Context, config TracingConfig, runID string) (context.Context, func()) {
+	if config.Endpoint == "" {
+		return ctx, func() {}
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(config.endpoint())}
+	if len(config.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(config.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		log.Println("tracing error:", err)
+		return ctx, func() {}
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.service()))),
+	)
+	ctx, span := provider.Tracer(tracerName).Start(ctx, "run", trace.WithAttributes(attribute.String("run.id", runID)))
+	return ctx, func() {
+		span.End()
+		shutdown, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(shutdown); err != nil {
+			log.Println("tracing error:", err)
+		}
+	}
+}
+
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
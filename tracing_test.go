@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidateTracing(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateTracing(TracingConfig{}))
+	requires.NoError(validateTracing(TracingConfig{Endpoint: "http://collector:4318"}))
+	requires.NoError(validateTracing(TracingConfig{Endpoint: "https://collector/v1/traces", Headers: map[string]string{"Authorization": "Bearer x"}}))
+	requires.ErrorContains(validateTracing(TracingConfig{ServiceName: "perf"}), "tracing: endpoint is not set")
+	requires.ErrorContains(validateTracing(TracingConfig{Endpoint: "collector:4318"}), "is not an http(s) url")
+	requires.ErrorContains(validateTracing(TracingConfig{Endpoint: "http://%zz"}), "tracing: endpoint:")
+
+	requires.Equal("http://collector:4318/v1/traces", TracingConfig{Endpoint: "http://collector:4318"}.endpoint())
+	requires.Equal("http://collector:4318/v1/traces", TracingConfig{Endpoint: "http://collector:4318/"}.endpoint())
+	requires.Equal("https://collector/custom", TracingConfig{Endpoint: "https://collector/custom"}.endpoint())
+	requires.Equal(defaultTracingService, TracingConfig{}.service())
+	requires.Equal("perf", TracingConfig{ServiceName: "perf"}.service())
+	requires.Equal(map[string]string{"Authorization": redacted}, redactConfig(Config{Tracing: TracingConfig{Headers: map[string]string{"Authorization": "Bearer x"}}}).Tracing.Headers)
+}
+
+func TestStartTracing(t *testing.T) {
+	requires := require.New(t)
+	mutex := sync.Mutex{}
+	spans := map[string]string{}
+	var service, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requires.Equal("/v1/traces", r.URL.Path)
+		b, err := io.ReadAll(r.Body)
+		requires.NoError(err)
+		request := &coltracepb.ExportTraceServiceRequest{}
+		requires.NoError(proto.Unmarshal(b, request))
+		mutex.Lock()
+		defer mutex.Unlock()
+		authorization = r.Header.Get("Authorization")
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, attribute := range resourceSpans.Resource.Attributes {
+				if attribute.Key == "service.name" {
+					service = attribute.Value.GetStringValue()
+				}
+			}
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					runID := ""
+					for _, attribute := range span.Attributes {
+						if attribute.Key == "run.id" {
+							runID = attribute.Value.GetStringValue()
+						}
+					}
+					spans[span.Name] = runID
+				}
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, finish := startTracing(context.Background(), TracingConfig{Endpoint: server.URL, ServiceName: "perf", Headers: map[string]string{"Authorization": "Bearer x"}}, "run-1")
+	_, span := startSpan(ctx, "tick")
+	span.End()
+	finish()
+	mutex.Lock()
+	defer mutex.Unlock()
+	requires.Equal(map[string]string{"run": "run-1", "tick": ""}, spans)
+	requires.Equal("perf", service)
+	requires.Equal("Bearer x", authorization)
+
+	ctx, finish = startTracing(context.Background(), TracingConfig{}, "run-2")
+	_, span = startSpan(ctx, "tick")
+	requires.False(span.IsRecording())
+	span.End()
+	finish()
+}
+
+func TestSchedulerSpans(t *testing.T) {
+	requires := require.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, root := provider.Tracer(tracerName).Start(context.Background(), "run")
+	scheduler := &Scheduler{ctx: ctx, envManager: &FakeEnvManager{}, testDuration: 50 * time.Millisecond, timeout: 20 * time.Millisecond}
+	scheduler.eventer = &Eventer{
+		reporter: &Reporter{},
+		gatherer: Gatherer{metrics: []MetricGather{ValueMetricGather{metricName: "rps", value: 5, max: 10}, ValueMetricGather{metricName: "lag", value: -1}}},
+		stoper:   func() { scheduler.sendDown() },
+	}
+	requires.NoError(scheduler.init())
+	scheduler.run()
+	requires.NoError(scheduler.down())
+	root.End()
+
+	names := map[string]int{}
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		names[span.Name()]++
+		byName[span.Name()+span.Status().Description] = span
+	}
+	requires.Equal(1, names["env.start"])
+	requires.Equal(1, names["warmup"])
+	requires.Equal(1, names["gather"])
+	requires.Equal(1, names["env.stop"])
+	requires.GreaterOrEqual(names["tick"], 1)
+	requires.Equal(2*names["tick"], names["query"])
+	requires.Equal(root.SpanContext().SpanID(), byName["gather"].Parent().SpanID())
+	requires.Equal(byName["gather"].SpanContext().SpanID(), byName["tick"].Parent().SpanID())
+	failed := byName["queryno value"]
+	requires.NotNil(failed)
+	requires.Equal(codes.Error, failed.Status().Code)
+	requires.Equal(byName["tick"].SpanContext().TraceID(), failed.SpanContext().TraceID())
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultTrendRuns    = 10
+	defaultTrendMinRuns = 3
+)
+
+var trendStats = []string{"", "avg", "median", "p95", "min", "max"}
+
+type TrendsConfig struct {
+	Branch  string  `yaml:"branch"`
+	Runs    int     `yaml:"runs"`
+	MinRuns int     `yaml:"minRuns"`
+	Stat    string  `yaml:"stat"`
+	Warn    float64 `yaml:"warn"`
+	Fail    float64 `yaml:"fail"`
+}
+
+type TrendResult struct {
+	Scenario string  `json:"scenario,omitempty"`
+	Metric   string  `json:"metric"`
+	Stat     string  `json:"stat"`
+	Value    float64 `json:"value"`
+	Median   float64 `json:"median"`
+	Runs     int     `json:"runs"`
+	Percent  float64 `json:"percent"`
+	Status   string  `json:"status"`
+}
+
+func (config TrendsConfig) enabled() bool {
+	return config.Warn > 0 || config.Fail > 0
+}
+
+func (config TrendsConfig) stat() string {
+	if config.Stat == "" {
+		return "avg"
+	}
+	return config.Stat
+}
+
+func validateTrends(config TrendsConfig) error {
+	if !slices.Contains(trendStats, config.Stat) {
+		return fmt.Errorf("trends: unknown stat %s, expected one of %s", config.Stat, strings.Join(trendStats[1:], ", "))
+	}
+	if config.Runs < 0 || config.MinRuns < 0 {
+		return errors.New("trends: runs and minRuns must not be negative")
+	}
+	if config.Warn < 0 || config.Fail < 0 {
+		return errors.New("trends: warn and fail must not be negative")
+	}
+	if config.Warn > 0 && config.Fail > 0 && config.Fail < config.Warn {
+		return errors.New("trends: fail must not be below warn")
+	}
+	return nil
+}
+
+func summaryStat(summary MetricSummary, stat string) float64 {
+	switch stat {
+	case "median":
+		return summary.Median
+	case "p95":
+		return summary.P95
+	case "min":
+		return summary.Min
+	case "max":
+		return summary.Max
+	}
+	return summary.Avg
+}
+
+func loadTrendHistory(baseDir string, config TrendsConfig) ([]RunReport, error) {
+	runs, err := loadHistory(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	limit := config.Runs
+	if limit == 0 {
+		limit = defaultTrendRuns
+	}
+	reports := make([]RunReport, 0, limit)
+	for n := len(runs) - 1; n >= 0 && len(reports) < limit; n-- {
+		run := runs[n]
+		if !run.Passed || run.Branch != config.Branch {
+			continue
+		}
+		report, err := loadRunReport(filepath.Join(run.dir, "report.json"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("run %s: %w", run.RunID, err)
+		}
+		reports = append(reports, report)
+	}
+	slices.Reverse(reports)
+	return reports, nil
+}
+
+func checkTrends(config TrendsConfig, history []RunReport, summaries []MetricSummary) ([]TrendResult, bool) {
+	minRuns := config.MinRuns
+	if minRuns == 0 {
+		minRuns = defaultTrendMinRuns
+	}
+	stat := config.stat()
+	previous := map[string][]float64{}
+	for _, report := range history {
+		for _, summary := range reportSummaries(report) {
+			if summary.Samples > 0 {
+				key := teamCityKey(summary.Scenario, summary.Metric)
+				previous[key] = append(previous[key], summaryStat(summary, stat))
+			}
+		}
+	}
+	results := make([]TrendResult, 0)
+	ok := true
+	for _, summary := range summaries {
+		key := teamCityKey(summary.Scenario, summary.Metric)
+		values := previous[key]
+		if summary.Samples == 0 || len(values) < minRuns {
+			continue
+		}
+		median, _ := aggregateValues("median", values)
+		if median == 0 {
+			continue
+		}
+		result := TrendResult{
+			Scenario: summary.Scenario,
+			Metric:   summary.Metric,
+			Stat:     stat,
+			Value:    summaryStat(summary, stat),
+			Median:   median,
+			Runs:     len(values),
+			Status:   "ok",
+		}
+		result.Percent = (result.Value - median) / math.Abs(median) * 100
+		switch {
+		case config.Fail > 0 && result.Percent > config.Fail:
+			result.Status = "fail"
+			ok = false
+		case config.Warn > 0 && result.Percent > config.Warn:
+			result.Status = "warn"
+		}
+		if result.Status != "ok" {
+			log.Printf("WARNING: trend %s: %s %g is %.1f%% above the median %g of the last %d runs (%s)\n",
+				key, stat, result.Value, result.Percent, median, result.Runs, result.Status)
+		}
+		results = append(results, result)
+	}
+	return results, ok
+}
+
+func (scheduler *Scheduler) checkTrends(config TrendsConfig, history []RunReport, summaries []MetricSummary) {
+	results, ok := checkTrends(config, history, summaries)
+	scheduler.trends = results
+	scheduler.trendsFailed = !ok
+}
+
+func renderTrends(trends []TrendResult) string {
+	b := strings.Builder{}
+	b.WriteString("## Trends\n\n| metric | stat | value | median | runs | change | status |\n|---|---|---|---|---|---|---|\n")
+	for _, trend := range trends {
+		fmt.Fprintf(&b, "| %s | %s | %g | %g | %d | %+.1f%% | %s |\n", teamCityKey(trend.Scenario, trend.Metric),
+			trend.Stat, trend.Value, trend.Median, trend.Runs, trend.Percent, trend.Status)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func trendReport(avg float64) RunReport {
+	return RunReport{Summary: []MetricSummary{
+		{Metric: "latency", Samples: 10, Avg: avg, P95: avg * 2},
+		{Scenario: "soak", Metric: "latency", Samples: 10, Avg: avg / 2, P95: avg},
+		{Metric: "errors", Samples: 10},
+	}}
+}
+
+func TestCheckTrends(t *testing.T) {
+	requires := require.New(t)
+	history := []RunReport{trendReport(100), trendReport(90), trendReport(110), trendReport(400), trendReport(105)}
+	summaries := []MetricSummary{
+		{Metric: "latency", Samples: 10, Avg: 140, P95: 220},
+		{Scenario: "soak", Metric: "latency", Samples: 10, Avg: 56, P95: 115},
+		{Metric: "errors", Samples: 10, Avg: 3},
+		{Metric: "queue", Samples: 10, Avg: 1},
+		{Metric: "idle", Avg: 1},
+	}
+	results, ok := checkTrends(TrendsConfig{Warn: 10, Fail: 25}, history, summaries)
+	requires.False(ok)
+	requires.Len(results, 2)
+	requires.InDelta(33.33, results[0].Percent, 0.01)
+	requires.InDelta(6.67, results[1].Percent, 0.01)
+	results[0].Percent, results[1].Percent = 0, 0
+	requires.Equal([]TrendResult{
+		{Metric: "latency", Stat: "avg", Value: 140, Median: 105, Runs: 5, Status: "fail"},
+		{Scenario: "soak", Metric: "latency", Stat: "avg", Value: 56, Median: 52.5, Runs: 5, Status: "ok"},
+	}, results)
+
+	results, ok = checkTrends(TrendsConfig{Warn: 5, Stat: "p95"}, history, summaries)
+	requires.True(ok)
+	requires.Equal("ok", results[0].Status)
+	requires.Equal(210.0, results[0].Median)
+	requires.Equal("warn", results[1].Status)
+
+	results, ok = checkTrends(TrendsConfig{Fail: 10, MinRuns: 6}, history, summaries)
+	requires.True(ok)
+	requires.Empty(results)
+}
+
+func TestLoadTrendHistory(t *testing.T) {
+	requires := require.New(t)
+	dir := t.TempDir()
+	started := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	runs := []struct {
+		branch string
+		passed bool
+		avg    float64
+		report bool
+	}{
+		{branch: "main", passed: true, avg: 1, report: true},
+		{branch: "main", passed: true, avg: 2, report: true},
+		{branch: "feature", passed: true, avg: 3, report: true},
+		{branch: "main", passed: false, avg: 4, report: true},
+		{branch: "main", passed: true, avg: 5},
+		{branch: "main", passed: true, avg: 6, report: true},
+	}
+	for n, run := range runs {
+		runDir := filepath.Join(dir, "run"+string(rune('a'+n)))
+		requires.NoError(os.MkdirAll(runDir, 0o755))
+		metadata, err := json.Marshal(RunMetadata{RunID: "run" + string(rune('a'+n)), Started: started.Add(time.Duration(n) * time.Minute), Passed: run.passed, Branch: run.branch})
+		requires.NoError(err)
+		requires.NoError(os.WriteFile(filepath.Join(runDir, "metadata.json"), metadata, 0o644))
+		if run.report {
+			report, err := json.Marshal(trendReport(run.avg))
+			requires.NoError(err)
+			requires.NoError(os.WriteFile(filepath.Join(runDir, "report.json"), report, 0o644))
+		}
+	}
+	reports, err := loadTrendHistory(dir, TrendsConfig{Branch: "main"})
+	requires.NoError(err)
+	requires.Len(reports, 3)
+	requires.Equal([]float64{1, 2, 6}, []float64{reports[0].Summary[0].Avg, reports[1].Summary[0].Avg, reports[2].Summary[0].Avg})
+
+	reports, err = loadTrendHistory(dir, TrendsConfig{Branch: "main", Runs: 2})
+	requires.NoError(err)
+	requires.Len(reports, 2)
+	requires.Equal(2.0, reports[0].Summary[0].Avg)
+
+	reports, err = loadTrendHistory(dir, TrendsConfig{})
+	requires.NoError(err)
+	requires.Empty(reports)
+
+	requires.NoError(os.WriteFile(filepath.Join(dir, "runf", "report.json"), []byte("{"), 0o644))
+	_, err = loadTrendHistory(dir, TrendsConfig{Branch: "main"})
+	requires.ErrorContains(err, "run runf:")
+}
+
+func TestTrendsReport(t *testing.T) {
+	requires := require.New(t)
+	scheduler := &Scheduler{}
+	values := []MetricValues{{values: []MetricValue{{name: "latency", value: 200}}}}
+	scheduler.checkTrends(TrendsConfig{Fail: 50}, []RunReport{trendReport(100), trendReport(100), trendReport(120)}, summarize(values))
+	requires.True(scheduler.failed())
+	requires.Len(scheduler.trends, 1)
+
+	report := RunReport{Trends: scheduler.trends}
+	b, err := renderReport("md", report)
+	requires.NoError(err)
+	requires.Contains(string(b), "## Trends\n\n| metric | stat | value | median | runs | change | status |\n|---|---|---|---|---|---|---|\n| latency | avg | 200 | 100 | 3 | +100.0% | fail |\n")
+}
+
+func TestValidateTrends(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateTrends(TrendsConfig{}))
+	requires.NoError(validateTrends(TrendsConfig{Stat: "p95", Warn: 10, Fail: 20, Runs: 5}))
+	requires.ErrorContains(validateTrends(TrendsConfig{Stat: "p99"}), "trends: unknown stat p99")
+	requires.ErrorContains(validateTrends(TrendsConfig{Runs: -1}), "must not be negative")
+	requires.ErrorContains(validateTrends(TrendsConfig{Warn: -1}), "must not be negative")
+	requires.ErrorContains(validateTrends(TrendsConfig{Warn: 20, Fail: 10}), "fail must not be below warn")
+	requires.False(TrendsConfig{}.enabled())
+	requires.True(TrendsConfig{Warn: 1}.enabled())
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+type queryWarningsKey struct{}
+
+// QueryWarnings collects the warnings of the Prometheus queries of a metric
+// in a tick, e.g. of a query over partial data of a failed store.
+type QueryWarnings struct {
+	mutex    sync.Mutex
+	warnings []string
+}
+
+func withQueryWarnings(ctx context.Context) (context.Context, *QueryWarnings) {
+	collector := &QueryWarnings{}
+	return context.WithValue(ctx, queryWarningsKey{}, collector), collector
+}
+
+func addQueryWarnings(ctx context.Context, metric string, warnings v1.Warnings) {
+	if len(warnings) == 0 {
+		return
+	}
+	log.Printf("WARNING: metric %s: query warnings: %s\n", metric, strings.Join(warnings, "; "))
+	collector, ok := ctx.Value(queryWarningsKey{}).(*QueryWarnings)
+	if !ok {
+		return
+	}
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	for _, warning := range warnings {
+		if !slices.Contains(collector.warnings, warning) {
+			collector.warnings = append(collector.warnings, warning)
+		}
+	}
+}
+
+func (collector *QueryWarnings) list() []string {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	return slices.Clone(collector.warnings)
+}
+
+func failOnWarnings(metrics []Metric) map[string]bool {
+	fail := map[string]bool{}
+	for _, metric := range metrics {
+		if metric.FailOnWarnings {
+			fail[metric.Name] = true
+		}
+	}
+	return fail
+}
+
+func validateWarnings(config Config) error {
+	metrics := append([]Metric{}, config.Metrics...)
+	for _, scenario := range config.Scenarios {
+		metrics = append(metrics, scenario.Metrics...)
+	}
+	for _, metric := range metrics {
+		if metric.FailOnWarnings && !isPrometheusMetric(metric) && metric.Type != "histogram" {
+			return fmt.Errorf("metric %s: failOnWarnings needs a prometheus or histogram metric", metric.Name)
+		}
+	}
+	return nil
+}
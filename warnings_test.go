@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func warningServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("query") == "partial" {
+			_, _ = w.Write([]byte(`{"status":"success","warnings":["store eu-1 unavailable, partial response"],` +
+				`"data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"4"]}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"4"]}]}}`))
+	}))
+}
+
+func TestQueryWarnings(t *testing.T) {
+	requires := require.New(t)
+	server := warningServer()
+	defer server.Close()
+
+	gatherer := Gatherer{
+		metrics: []MetricGather{
+			PrometheusMetric{Host: server.URL, Name: "errors", Query: "partial", MaxValue: 10},
+			PrometheusMetric{Host: server.URL, Name: "errors_strict", Query: "partial", MaxValue: 10},
+			PrometheusMetric{Host: server.URL, Name: "latency", Query: "full", MaxValue: 10},
+		},
+		failOn: failOnWarnings([]Metric{{Name: "errors"}, {Name: "errors_strict", FailOnWarnings: true}, {Name: "latency", FailOnWarnings: true}}),
+	}
+	values, check := gatherer.gatherAndCheck(context.Background(), time.Now())
+	requires.False(check)
+	warnings := []string{"store eu-1 unavailable, partial response"}
+	requires.Equal(warnings, values.values[0].warnings)
+	requires.Equal(warnings, values.values[1].warnings)
+	requires.Nil(values.values[2].warnings)
+	requires.Equal([]MetricValue{{name: "errors_strict", value: 4}}, values.violations)
+	requires.Equal(warnings, valuesJSON(values.values)[0].Warnings)
+	requires.Equal(warnings, valuesFromJSON(valuesJSON(values.values))[1].warnings)
+
+	summaries := summarize([]MetricValues{values, values})
+	requires.Equal(2, summaries[0].Warnings)
+	requires.Equal(0, summaries[2].Warnings)
+	aggregates := NewSpoolAggregates(nil)
+	aggregates.observe(values)
+	requires.Equal(1, aggregates.summaries()[1].Warnings)
+}
+
+func TestValidateWarnings(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateWarnings(Config{Metrics: []Metric{{Name: "a", FailOnWarnings: true}, {Name: "b", Type: "histogram", FailOnWarnings: true}}}))
+	requires.ErrorContains(validateWarnings(Config{Scenarios: []Scenario{{Name: "peak", Metrics: []Metric{{Name: "depth", Type: "rabbitmq", FailOnWarnings: true}}}}}),
+		"metric depth: failOnWarnings needs a prometheus or histogram metric")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const stalledExitCode = 3
+
+type WatchdogConfig struct {
+	Factor float64 `yaml:"factor"`
+	Abort  bool    `yaml:"abort"`
+}
+
+type Stall struct {
+	Scenario string    `json:"scenario,omitempty"`
+	Started  time.Time `json:"started"`
+	Seconds  float64   `json:"seconds"`
+	Aborted  bool      `json:"aborted,omitempty"`
+}
+
+type Watchdog struct {
+	limit time.Duration
+	abort bool
+}
+
+func validateWatchdog(config Config) error {
+	if config.Watchdog.Factor == 0 {
+		return nil
+	}
+	if config.Watchdog.Factor < 1 {
+		return errors.New("watchdog.factor must be at least 1")
+	}
+	if config.Timeout <= 0 {
+		return errors.New("watchdog: timeout (tick interval) is not set")
+	}
+	return nil
+}
+
+func newWatchdog(config WatchdogConfig, interval time.Duration) *Watchdog {
+	if config.Factor == 0 || interval <= 0 {
+		return nil
+	}
+	return &Watchdog{limit: time.Duration(config.Factor * float64(interval)), abort: config.Abort}
+}
+
+func (watchdog *Watchdog) watch(ctx context.Context, clock ClockInt, scenario string, tick func(ctx context.Context)) (Stall, bool) {
+	if watchdog == nil {
+		tick(ctx)
+		return Stall{}, false
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	clock = clockOf(clock)
+	var fired atomic.Bool
+	started := clock.now()
+	limit, abort := watchdog.limit, watchdog.abort
+	timer, stop := context.WithCancel(ctx)
+	leave := clock.join()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer leave()
+		if !clock.sleep(timer, limit) {
+			return
+		}
+		fired.Store(true)
+		log.Println("WARNING: tick runs longer than", limit)
+		if abort {
+			cancel()
+		}
+	}()
+	tick(ctx)
+	stop()
+	<-done
+	if !fired.Load() {
+		return Stall{}, false
+	}
+	elapsed := clock.now().Sub(started)
+	log.Println("WARNING: tick stalled for", elapsed.Round(time.Millisecond))
+	stall := Stall{Scenario: scenario, Started: started, Seconds: elapsed.Seconds(), Aborted: abort}
+	return stall, true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type HangingEventer struct {
+	fired int
+}
+
+func (eventer *HangingEventer) Fire(ctx context.Context) {
+	eventer.fired++
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+	}
+}
+
+func TestNewWatchdog(t *testing.T) {
+	requires := require.New(t)
+	requires.Nil(newWatchdog(WatchdogConfig{}, time.Second))
+	requires.Nil(newWatchdog(WatchdogConfig{Factor: 3}, 0))
+	requires.Equal(&Watchdog{limit: 1500 * time.Millisecond, abort: true}, newWatchdog(WatchdogConfig{Factor: 3, Abort: true}, 500*time.Millisecond))
+
+	requires.NoError(validateWatchdog(Config{}))
+	requires.NoError(validateWatchdog(Config{Timeout: Duration(time.Second), Watchdog: WatchdogConfig{Factor: 2}}))
+	requires.Error(validateWatchdog(Config{Timeout: Duration(time.Second), Watchdog: WatchdogConfig{Factor: 0.5}}))
+	requires.Error(validateWatchdog(Config{Watchdog: WatchdogConfig{Factor: 2}}))
+}
+
+func TestWatchdogWatch(t *testing.T) {
+	requires := require.New(t)
+	var watchdog *Watchdog
+	_, stalled := watchdog.watch(context.Background(), nil, "", func(ctx context.Context) {})
+	requires.False(stalled)
+
+	watchdog = &Watchdog{limit: 50 * time.Millisecond}
+	_, stalled = watchdog.watch(context.Background(), nil, "", func(ctx context.Context) {})
+	requires.False(stalled)
+
+	started := time.Now()
+	stall, stalled := watchdog.watch(context.Background(), nil, "s", func(ctx context.Context) { time.Sleep(100 * time.Millisecond) })
+	requires.True(stalled)
+	requires.Equal("s", stall.Scenario)
+	requires.False(stall.Aborted)
+	requires.GreaterOrEqual(stall.Seconds, 0.1)
+	requires.WithinDuration(started, stall.Started, 10*time.Millisecond)
+
+	watchdog = &Watchdog{limit: 50 * time.Millisecond, abort: true}
+	started = time.Now()
+	stall, stalled = watchdog.watch(context.Background(), nil, "", (&HangingEventer{}).Fire)
+	requires.True(stalled)
+	requires.True(stall.Aborted)
+	requires.Less(time.Since(started), 500*time.Millisecond)
+}
+
+func TestSchedulerWatchdog(t *testing.T) {
+	variants := []struct {
+		abort   bool
+		fired   int
+		stalled bool
+		status  int
+	}{
+		{abort: false, fired: 2},
+		{abort: true, fired: 1, stalled: true, status: 1},
+	}
+	requires := require.New(t)
+	for _, variant := range variants {
+		eventer := &HangingEventer{}
+		scheduler := Scheduler{eventer: eventer, watchdog: &Watchdog{limit: 50 * time.Millisecond, abort: variant.abort}, scenario: "s"}
+		scheduler.tick(context.Background())
+		scheduler.tick(context.Background())
+		requires.Equal(variant.fired, eventer.fired)
+		requires.Equal(variant.stalled, scheduler.stalled)
+		requires.Equal(variant.status, scheduler.status)
+		requires.Len(scheduler.stalls, variant.fired)
+
+		report := newRunReport(&scheduler, nil)
+		requires.Len(report.Stalls, variant.fired)
+		requires.Contains(string(renderMarkdown(report)), "## Stalls\n\n| scenario | started | seconds | aborted |")
+	}
+}
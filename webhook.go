@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"text/template"
+	"time"
+)
+
+var webhookEvents = []string{"started", "violated", "finished"}
+
+type WebhookConfig struct {
+	Name     string            `yaml:"name"`
+	URL      string            `yaml:"url"`
+	Events   []string          `yaml:"events"`
+	Headers  map[string]string `yaml:"headers"`
+	Template string            `yaml:"template"`
+}
+
+type WebhookEvent struct {
+	Event      string            `json:"event"`
+	RunID      string            `json:"runId"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Result     string            `json:"result,omitempty"`
+	Passed     bool              `json:"passed"`
+	Scenario   string            `json:"scenario,omitempty"`
+	Violations []ValueJSON       `json:"violations,omitempty"`
+	Summary    []MetricSummary   `json:"summary,omitempty"`
+	Vars       map[string]string `json:"vars,omitempty"`
+}
+
+type WebhookNotifier struct {
+	name     string
+	url      string
+	events   []string
+	headers  map[string]string
+	template *template.Template
+	reporter *Reporter
+	vars     map[string]string
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		b, err := json.Marshal(value)
+		return string(b), err
+	},
+	"time": func(t time.Time) string { return t.Format(time.RFC3339) },
+}
+
+func parseWebhookTemplate(webhook WebhookConfig) (*template.Template, error) {
+	if webhook.Template == "" {
+		return nil, nil
+	}
+	return template.New(webhook.Name).Funcs(webhookFuncs).Option("missingkey=error").Parse(webhook.Template)
+}
+
+func validateWebhooks(config Config) error {
+	for n, webhook := range config.Webhooks {
+		name := webhook.Name
+		if name == "" {
+			name = fmt.Sprint(n + 1)
+		}
+		if webhook.URL == "" {
+			return fmt.Errorf("webhook %s: url is not set", name)
+		}
+		for _, event := range webhook.Events {
+			if !slices.Contains(webhookEvents, event) {
+				return fmt.Errorf("webhook %s: unknown event %s", name, event)
+			}
+		}
+		if _, err := parseWebhookTemplate(webhook); err != nil {
+			return fmt.Errorf("webhook %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func newWebhookNotifiers(config Config, reporter *Reporter) []NotifierInt {
+	notifiers := make([]NotifierInt, 0, len(config.Webhooks))
+	for _, webhook := range config.Webhooks {
+		tmpl, err := parseWebhookTemplate(webhook)
+		if err != nil {
+			log.Println("webhook error:", err)
+			continue
+		}
+		events := webhook.Events
+		if len(events) == 0 {
+			events = webhookEvents
+		}
+		notifiers = append(notifiers, WebhookNotifier{
+			name:     webhook.Name,
+			url:      webhook.URL,
+			events:   events,
+			headers:  webhook.Headers,
+			template: tmpl,
+			reporter: reporter,
+			vars:     config.Vars,
+		})
+	}
+	return notifiers
+}
+
+func (notifier WebhookNotifier) payload(event WebhookEvent) ([]byte, error) {
+	if notifier.template == nil {
+		return json.Marshal(event)
+	}
+	var b bytes.Buffer
+	if err := notifier.template.Execute(&b, event); err != nil {
+		return nil, err
+	}
+	if !json.Valid(b.Bytes()) {
+		return nil, errors.New("template output is not valid JSON")
+	}
+	return b.Bytes(), nil
+}
+
+func (notifier WebhookNotifier) send(event WebhookEvent) {
+	if !slices.Contains(notifier.events, event.Event) {
+		return
+	}
+	event.Vars = notifier.vars
+	b, err := notifier.payload(event)
+	if err != nil {
+		log.Println("webhook", notifier.name, "error:", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, notifier.url, bytes.NewReader(b))
+	if err != nil {
+		log.Println("webhook", notifier.name, "error:", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range notifier.headers {
+		req.Header.Set(name, value)
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Println("webhook", notifier.name, "error:", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Println("webhook", notifier.name, "error: status", resp.Status)
+	}
+}
+
+func (notifier WebhookNotifier) started(runID string, timestamp time.Time) {
+	notifier.send(WebhookEvent{Event: "started", RunID: runID, Timestamp: timestamp, Passed: true})
+}
+
+func (notifier WebhookNotifier) violated(runID string, values MetricValues) {
+	notifier.send(WebhookEvent{
+		Event:      "violated",
+		RunID:      runID,
+		Timestamp:  values.timestamp,
+		Passed:     !values.failing(),
+		Scenario:   values.scenario,
+		Violations: valuesJSON(values.violations),
+	})
+}
+
+func (notifier WebhookNotifier) finished(runID string, timestamp time.Time, ok bool) {
+	event := WebhookEvent{Event: "finished", RunID: runID, Timestamp: timestamp, Passed: ok, Result: "passed"}
+	if !ok {
+		event.Result = "failed"
+	}
+	if notifier.reporter != nil {
+		event.Summary = notifier.reporter.summaries()
+	}
+	notifier.send(event)
+}
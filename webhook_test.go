@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type WebhookRequest struct {
+	header http.Header
+	body   string
+}
+
+func webhookServer(t *testing.T) (*httptest.Server, func() []WebhookRequest) {
+	var mutex sync.Mutex
+	requests := make([]WebhookRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, WebhookRequest{header: r.Header, body: string(b)})
+		mutex.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []WebhookRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]WebhookRequest{}, requests...)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	requires := require.New(t)
+	server, requests := webhookServer(t)
+	reporter := &Reporter{}
+	reporter.sendResult(MetricValues{values: []MetricValue{{name: "errors", value: 3}}, violations: []MetricValue{{name: "errors", value: 3}}})
+	config := Config{
+		Vars: map[string]string{"team": "perf"},
+		Webhooks: []WebhookConfig{
+			{Name: "raw", URL: server.URL},
+			{
+				Name:    "pagerduty",
+				URL:     server.URL,
+				Events:  []string{"violated", "finished"},
+				Headers: map[string]string{"Authorization": "Token t1"},
+				Template: `{"summary": {{ json (printf "run %s %s" .RunID .Event) }}, "team": {{ json .Vars.team }},` +
+					`"violations": [{{ range $n, $v := .Violations }}{{ if $n }},{{ end }}{{ json $v.Name }}{{ end }}],` +
+					`"metrics": {{ len .Summary }}}`,
+			},
+		},
+	}
+	notifiers := App{}.notifiers(config, reporter)
+	requires.Len(notifiers, 2)
+	notifyStarted(notifiers, "r1")
+	for _, notifier := range notifiers {
+		notifier.violated("r1", reporter.snapshot()[0])
+	}
+	notifyFinished(notifiers, "r1", false)
+
+	got := requests()
+	requires.Len(got, 5)
+	event := WebhookEvent{}
+	requires.NoError(json.Unmarshal([]byte(got[0].body), &event))
+	requires.Equal("started", event.Event)
+	requires.Equal("r1", event.RunID)
+	requires.Equal("application/json", got[0].header.Get("Content-Type"))
+
+	requires.JSONEq(`{"summary": "run r1 violated", "team": "perf", "violations": ["errors"], "metrics": 0}`, got[2].body)
+	requires.Equal("Token t1", got[2].header.Get("Authorization"))
+	requires.JSONEq(`{"summary": "run r1 finished", "team": "perf", "violations": [], "metrics": 1}`, got[4].body)
+	requires.NoError(json.Unmarshal([]byte(got[3].body), &event))
+	requires.Equal("failed", event.Result)
+	requires.Equal("errors", event.Summary[0].Metric)
+}
+
+func TestWebhookPayloadInvalid(t *testing.T) {
+	requires := require.New(t)
+	server, requests := webhookServer(t)
+	notifiers := newWebhookNotifiers(Config{Webhooks: []WebhookConfig{{URL: server.URL, Template: `{"run": {{ .RunID }}}`}}}, nil)
+	notifiers[0].started("r1", time.Now())
+	requires.Empty(requests())
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	requires := require.New(t)
+	requires.NoError(validateWebhooks(Config{Webhooks: []WebhookConfig{{URL: "http://x", Events: []string{"finished"}}}}))
+	requires.ErrorContains(validateWebhooks(Config{Webhooks: []WebhookConfig{{}}}), "webhook 1: url is not set")
+	requires.ErrorContains(validateWebhooks(Config{Webhooks: []WebhookConfig{{Name: "pd", URL: "http://x", Events: []string{"aborted"}}}}), "webhook pd: unknown event aborted")
+	requires.Error(validateWebhooks(Config{Webhooks: []WebhookConfig{{URL: "http://x", Template: "{{ .RunID"}}}))
+
+	redactedConfig := redactConfig(Config{Webhooks: []WebhookConfig{{URL: "http://x", Headers: map[string]string{"Authorization": "Token t1"}}}})
+	requires.Equal(redacted, redactedConfig.Webhooks[0].Headers["Authorization"])
+}
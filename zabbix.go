@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ZabbixConfig struct {
+	URL      string `yaml:"url"`
+	Token    string `yaml:"token"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// ZabbixClient calls the JSON-RPC API with an API token or, without one,
+// with a session of user.login that is renewed once it expires.
+type ZabbixClient struct {
+	client   *http.Client
+	url      string
+	token    string
+	username string
+	password string
+	mu       sync.Mutex
+	session  string
+}
+
+func NewZabbixClient(config ZabbixConfig) *ZabbixClient {
+	url := strings.TrimSuffix(config.URL, "/")
+	if !strings.HasSuffix(url, ".php") {
+		url += "/api_jsonrpc.php"
+	}
+	return &ZabbixClient{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		url:      url,
+		token:    config.Token,
+		username: config.Username,
+		password: config.Password,
+	}
+}
+
+type zabbixError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+func (err *zabbixError) Error() string {
+	return fmt.Sprintf("zabbix %d: %s %s", err.Code, err.Message, err.Data)
+}
+
+func (err *zabbixError) sessionExpired() bool {
+	return strings.Contains(err.Data, "re-login") || strings.Contains(err.Data, "Session terminated")
+}
+
+func (client *ZabbixClient) call(ctx context.Context, method string, params any, auth string, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method, "params": params, "id": 1})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json-rpc")
+	if auth != "" {
+		req.Header.Set("Authorization", "Bearer "+auth)
+	}
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	response := struct {
+		Result json.RawMessage `json:"result"`
+		Error  *zabbixError    `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+func (client *ZabbixClient) auth(ctx context.Context) (string, error) {
+	if client.token != "" {
+		return client.token, nil
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.session != "" {
+		return client.session, nil
+	}
+	session := ""
+	params := map[string]string{"username": client.username, "password": client.password}
+	if err := client.call(ctx, "user.login", params, "", &session); err != nil {
+		return "", fmt.Errorf("login: %w", err)
+	}
+	client.session = session
+	return session, nil
+}
+
+func (client *ZabbixClient) logout(session string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.session == session {
+		client.session = ""
+	}
+}
+
+type ZabbixItem struct {
+	LastValue string `json:"lastvalue"`
+	LastClock string `json:"lastclock"`
+}
+
+var errNoZabbixItem = errors.New("no such item")
+
+// item returns the item with the key on the host; an item that never got a
+// value has lastclock 0 and counts as missing too.
+func (client *ZabbixClient) item(ctx context.Context, host string, key string) (ZabbixItem, error) {
+	params := map[string]any{
+		"output": []string{"lastvalue", "lastclock"},
+		"host":   host,
+		"filter": map[string]string{"key_": key},
+	}
+	items := []ZabbixItem{}
+	for attempt := 0; ; attempt++ {
+		auth, err := client.auth(ctx)
+		if err != nil {
+			return ZabbixItem{}, err
+		}
+		err = client.call(ctx, "item.get", params, auth, &items)
+		var rpcErr *zabbixError
+		if errors.As(err, &rpcErr) && rpcErr.sessionExpired() && client.token == "" && attempt == 0 {
+			client.logout(auth)
+			continue
+		}
+		if err != nil {
+			return ZabbixItem{}, err
+		}
+		break
+	}
+	if len(items) == 0 || items[0].LastClock == "" || items[0].LastClock == "0" {
+		return ZabbixItem{}, errNoZabbixItem
+	}
+	if len(items) > 1 {
+		return ZabbixItem{}, fmt.Errorf("%d items with key %s on host %s", len(items), key, host)
+	}
+	return items[0], nil
+}
+
+type ZabbixMetric struct {
+	client    *ZabbixClient
+	Name      string
+	Host      string
+	Key       string
+	MaxValue  int
+	OnMissing string
+}
+
+func (metric ZabbixMetric) name() string {
+	return metric.Name
+}
+
+func (metric ZabbixMetric) maxValue() int {
+	return metric.MaxValue
+}
+
+func (metric ZabbixMetric) gather(ctx context.Context) int {
+	item, err := metric.client.item(ctx, metric.Host, metric.Key)
+	if errors.Is(err, errNoZabbixItem) {
+		return missing(metric.Name, metric.OnMissing, metric.MaxValue)
+	}
+	if err != nil {
+		log.Printf("Error querying Zabbix: %v\n", err)
+		return -1
+	}
+	value, err := strconv.ParseFloat(item.LastValue, 64)
+	if err != nil {
+		log.Printf("WARNING: zabbix item %s on %s is not a number: %q\n", metric.Key, metric.Host, item.LastValue)
+		return -1
+	}
+	return int(value)
+}
+
+func validateZabbixMetric(config Config, metric Metric) error {
+	if config.Zabbix.URL == "" {
+		return fmt.Errorf("zabbix.url is not set")
+	}
+	if config.Zabbix.Token == "" && config.Zabbix.Username == "" {
+		return fmt.Errorf("zabbix.token or zabbix.username is not set")
+	}
+	if metric.Target == "" {
+		return fmt.Errorf("target is not set")
+	}
+	if metric.Query == "" {
+		return fmt.Errorf("query is not set")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type zabbixRequest struct {
+	Method string         `json:"method"`
+	Params map[string]any `json:"params"`
+}
+
+func zabbixServer(t *testing.T, logins *int, expired *bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := zabbixRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		reply := func(result any) {
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "result": result, "id": 1})
+		}
+		if request.Method == "user.login" {
+			*logins++
+			if request.Params["password"] != "secret" {
+				_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1,
+					"error": map[string]any{"code": -32602, "message": "Invalid params.", "data": "Incorrect user name or password."}})
+				return
+			}
+			reply("session")
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if auth != "Bearer token" && auth != "Bearer session" || *expired {
+			*expired = false
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1,
+				"error": map[string]any{"code": -32602, "message": "Invalid params.", "data": "Session terminated, re-login, please."}})
+			return
+		}
+		filter := request.Params["filter"].(map[string]any)
+		switch request.Params["host"].(string) + "/" + filter["key_"].(string) {
+		case "db01/system.cpu.util":
+			reply([]ZabbixItem{{LastValue: "42.7", LastClock: "1700000000"}})
+		case "db01/agent.version":
+			reply([]ZabbixItem{{LastValue: "6.0.1", LastClock: "1700000000"}})
+		case "db01/new.item":
+			reply([]ZabbixItem{{LastValue: "0", LastClock: "0"}})
+		default:
+			reply([]ZabbixItem{})
+		}
+	}))
+}
+
+func TestZabbixMetric(t *testing.T) {
+	requires := require.New(t)
+	logins, expired := 0, false
+	server := zabbixServer(t, &logins, &expired)
+	defer server.Close()
+
+	variants := []struct {
+		host      string
+		key       string
+		onMissing string
+		value     int
+	}{
+		{host: "db01", key: "system.cpu.util", value: 42},
+		{host: "db01", key: "agent.version", value: -1},
+		{host: "db01", key: "new.item", value: -1},
+		{host: "db01", key: "new.item", onMissing: "treatAsZero", value: 0},
+		{host: "db02", key: "system.cpu.util", onMissing: "treatAsZero", value: 0},
+	}
+	sources := Sources{zabbix: NewZabbixClient(ZabbixConfig{URL: server.URL + "/api_jsonrpc.php", Token: "token"})}
+	for n, variant := range variants {
+		gathers := newMetricGathers(sources, []Metric{{Name: "cpu", Type: "zabbix", Target: variant.host, Query: variant.key, OnMissing: variant.onMissing}})
+		requires.Equal(variant.value, gathers[0].gather(context.Background()), n)
+	}
+	requires.Equal(0, logins)
+
+	client := NewZabbixClient(ZabbixConfig{URL: server.URL + "/", Username: "perf", Password: "secret"})
+	requires.Equal(server.URL+"/api_jsonrpc.php", client.url)
+	metric := ZabbixMetric{client: client, Name: "cpu", Host: "db01", Key: "system.cpu.util"}
+	requires.Equal(42, metric.gather(context.Background()))
+	requires.Equal(42, metric.gather(context.Background()))
+	requires.Equal(1, logins)
+	expired = true
+	requires.Equal(42, metric.gather(context.Background()))
+	requires.Equal(2, logins)
+
+	metric.client = NewZabbixClient(ZabbixConfig{URL: server.URL, Username: "perf", Password: "wrong"})
+	requires.Equal(-1, metric.gather(context.Background()))
+}
+
+func TestValidateZabbixMetric(t *testing.T) {
+	requires := require.New(t)
+	config := Config{Zabbix: ZabbixConfig{URL: "http://zabbix", Token: "token"}}
+	requires.NoError(validateZabbixMetric(config, Metric{Target: "db01", Query: "system.cpu.util"}))
+	requires.ErrorContains(validateZabbixMetric(Config{}, Metric{Target: "db01"}), "zabbix.url is not set")
+	requires.ErrorContains(validateZabbixMetric(Config{Zabbix: ZabbixConfig{URL: "http://zabbix"}}, Metric{Target: "db01"}), "zabbix.token or zabbix.username is not set")
+	requires.ErrorContains(validateZabbixMetric(config, Metric{Query: "system.cpu.util"}), "target is not set")
+	requires.ErrorContains(validateZabbixMetric(config, Metric{Target: "db01"}), "query is not set")
+
+	config.Metrics = []Metric{{Name: "cpu", Type: "zabbix"}}
+	requires.ErrorContains(validateMetricTypes(config), "metric cpu: target is not set")
+
+	redactedConfig := redactConfig(Config{Zabbix: ZabbixConfig{Token: "token", Password: "secret"}})
+	requires.Equal(redacted, redactedConfig.Zabbix.Token)
+	requires.Equal(redacted, redactedConfig.Zabbix.Password)
+}